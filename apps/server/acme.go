@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// acmeDomains splits the comma-separated acme_domains setting
+func acmeDomains(domains string) []string {
+	var result []string
+	for _, domain := range strings.Split(domains, ",") {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			result = append(result, domain)
+		}
+	}
+	return result
+}
+
+// validateACMEConfig checks the ACME settings. Only names reachable by the CA on port 443
+// (TLS-ALPN-01) or 80 (HTTP-01) can be validated: no wildcards and no IP addresses.
+func validateACMEConfig(c *Config) error {
+	if c.TLSCertFile != "" || c.TLSSelfSigned {
+		return fmt.Errorf("acme_domains cannot be combined with tls_cert_file or tls_self_signed")
+	}
+	for _, domain := range acmeDomains(c.ACMEDomains) {
+		if strings.Contains(domain, "*") {
+			return fmt.Errorf("invalid acme_domains entry '%s': wildcard certificates are not supported", domain)
+		}
+		if net.ParseIP(domain) != nil || !strings.Contains(domain, ".") || strings.ContainsAny(domain, ":/ ") {
+			return fmt.Errorf("invalid acme_domains entry '%s': expected a fully qualified host name", domain)
+		}
+	}
+	u, err := url.Parse(c.ACMEDirectoryURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("invalid acme_directory_url '%s': expected https://host/directory", c.ACMEDirectoryURL)
+	}
+	return nil
+}
+
+// newACMEManager creates the ACME (RFC 8555) client that obtains and renews certificates for
+// acme_domains. The account key and certificates are stored in a directory next to the
+// database. TLS-ALPN-01 is answered on the HTTPS listener; HTTP-01 needs the plain HTTP
+// listener on tls_redirect_address (see startHTTPSRedirect).
+func (s *Server) newACMEManager(dbPath string) (*autocert.Manager, error) {
+	domains := acmeDomains(s.Config.ACMEDomains)
+	known := make(map[string]bool, len(domains))
+	for _, domain := range domains {
+		known[domain] = true
+	}
+	httpClient, err := acmeHTTPClient(s.Config.ACMECAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(domains...),
+		Cache:      acmeCache{DirCache: autocert.DirCache(filepath.Join(filepath.Dir(dbPath), ACMECacheDir)), domains: known},
+		Email:      s.Config.ACMEEmail,
+		Client: &acme.Client{
+			DirectoryURL: s.Config.ACMEDirectoryURL,
+			UserAgent:    LogAppName,
+			HTTPClient:   httpClient,
+		},
+	}, nil
+}
+
+// acmeHTTPClient returns the client used to talk to the ACME directory. With acme_ca_file
+// only the CAs in that file are trusted (e.g. a private CA or a Pebble test server);
+// otherwise the system roots are used (nil = http.DefaultClient).
+func acmeHTTPClient(caFile string) (*http.Client, error) {
+	if caFile == "" {
+		return nil, nil
+	}
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read acme_ca_file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in acme_ca_file %s", caFile)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	return &http.Client{Transport: transport}, nil
+}
+
+// obtainACMECertificates loads or requests the certificates at startup, so the first visitor
+// doesn't wait for issuance. Loading a certificate also schedules its background renewal.
+func (s *Server) obtainACMECertificates() {
+	for _, domain := range acmeDomains(s.Config.ACMEDomains) {
+		if s.isStopping() {
+			return
+		}
+		// Ask for the ECDSA certificate every current client accepts
+		cert, err := s.ACME.GetCertificate(&tls.ClientHelloInfo{
+			ServerName:       domain,
+			SignatureSchemes: []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256},
+			SupportedCurves:  []tls.CurveID{tls.CurveP256},
+			CipherSuites:     []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+		})
+		if err != nil {
+			Warning("Failed to obtain ACME certificate for %s (retried on the next HTTPS request): %v", domain, err)
+			continue
+		}
+		if cert.Leaf != nil {
+			Info("ACME certificate for %s valid until %s", domain, cert.Leaf.NotAfter.Format("2006-01-02"))
+		}
+	}
+}
+
+// acmeCache is the certificate directory; it logs newly issued and renewed certificates
+type acmeCache struct {
+	autocert.DirCache
+	domains map[string]bool
+}
+
+// Put stores an entry. Certificates are stored under the domain name ("+rsa" for the
+// RSA fallback); account keys and challenge tokens under other keys.
+func (c acmeCache) Put(ctx context.Context, key string, data []byte) error {
+	if err := c.DirCache.Put(ctx, key, data); err != nil {
+		return err
+	}
+	if domain := strings.TrimSuffix(key, "+rsa"); c.domains[domain] {
+		if leaf := acmeCachedLeaf(data); leaf != nil {
+			Info("Obtained ACME certificate for %s from %s (valid until %s)", domain, leaf.Issuer.CommonName, leaf.NotAfter.Format("2006-01-02"))
+		} else {
+			Info("Obtained ACME certificate for %s", domain)
+		}
+	}
+	return nil
+}
+
+// acmeCachedLeaf parses the leaf certificate of a cached key and chain
+func acmeCachedLeaf(data []byte) *x509.Certificate {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil
+		}
+		if block.Type == "CERTIFICATE" {
+			leaf, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil
+			}
+			return leaf
+		}
+	}
+}
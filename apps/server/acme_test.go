@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestACMEDirectory serves a minimal ACME directory over HTTPS with a test certificate
+// and returns the server and a PEM file with its certificate
+func newTestACMEDirectory(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"newNonce":"` + server.URL + `/nonce","newAccount":"` + server.URL + `/account","newOrder":"` + server.URL + `/order"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return server, caFile
+}
+
+func newTestACMEServer(directoryURL, caFile string) *Server {
+	return &Server{Config: &Config{
+		ACMEDomains:      "wol.test",
+		ACMEDirectoryURL: directoryURL + "/dir",
+		ACMECAFile:       caFile,
+	}}
+}
+
+func TestACMECAFile(t *testing.T) {
+	directory, caFile := newTestACMEDirectory(t)
+
+	manager, err := newTestACMEServer(directory.URL, caFile).newACMEManager(filepath.Join(t.TempDir(), "wol.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := manager.Client.Discover(context.Background())
+	if err != nil {
+		t.Fatalf("Discover with acme_ca_file: %v", err)
+	}
+	if dir.OrderURL != directory.URL+"/order" {
+		t.Errorf("OrderURL = %s, want %s/order", dir.OrderURL, directory.URL)
+	}
+}
+
+func TestACMEWithoutCAFile(t *testing.T) {
+	directory, _ := newTestACMEDirectory(t)
+
+	// The test CA is not in the system roots
+	manager, err := newTestACMEServer(directory.URL, "").newACMEManager(filepath.Join(t.TempDir(), "wol.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.Client.Discover(context.Background()); err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Fatalf("Discover without acme_ca_file: err = %v, want a certificate error", err)
+	}
+}
+
+func TestACMEInvalidCAFile(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newTestACMEServer("https://localhost:14000", caFile).newACMEManager(filepath.Join(t.TempDir(), "wol.db")); err == nil {
+		t.Fatal("expected an error for a file without certificates")
+	}
+}
@@ -0,0 +1,481 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Agent power commands
+const (
+	AgentCommandSleep    = "sleep"
+	AgentCommandShutdown = "shutdown"
+	AgentCommandReboot   = "reboot"
+)
+
+// validAgentCommands lists the commands that can be pushed to an agent
+var validAgentCommands = map[string]bool{
+	AgentCommandSleep:    true,
+	AgentCommandShutdown: true,
+	AgentCommandReboot:   true,
+}
+
+// AgentConfig is an agent with its own token, bound to the MAC addresses of its host
+type AgentConfig struct {
+	Name  string   `json:"name"`
+	Token string   `json:"token"`
+	MACs  []string `json:"macs"`
+}
+
+// validateAgentConfig checks a single agents entry and normalizes its MAC addresses
+func validateAgentConfig(c *AgentConfig) error {
+	if !relayNameRegex.MatchString(c.Name) {
+		return fmt.Errorf("invalid agent name '%s' (1-64 characters: letters, digits, '.', '_', '-')", c.Name)
+	}
+	if len(c.Token) < MinAgentTokenLength {
+		return fmt.Errorf("token of agent '%s' must be at least %d characters long", c.Name, MinAgentTokenLength)
+	}
+	if len(c.MACs) == 0 || len(c.MACs) > MaxAgentMACs {
+		return fmt.Errorf("agent '%s' must list 1-%d MAC addresses", c.Name, MaxAgentMACs)
+	}
+	for i, mac := range c.MACs {
+		normalized, ok := normalizeAgentMAC(mac)
+		if !ok {
+			return fmt.Errorf("invalid MAC address '%s' of agent '%s'", mac, c.Name)
+		}
+		c.MACs[i] = normalized
+	}
+	return nil
+}
+
+// errAgentMACClaimed is returned when a heartbeat reports a MAC address that an online agent
+// with a different identity already reports
+var errAgentMACClaimed = errors.New("MAC address is reported by another agent")
+
+// AgentHeartbeat is the report sent by a companion agent
+type AgentHeartbeat struct {
+	MACs          []string   `json:"macs"` // Hardware addresses of the agent's interfaces (used to match hosts)
+	Hostname      string     `json:"hostname"`
+	OS            string     `json:"os"`
+	Version       string     `json:"version"`
+	UptimeSeconds int64      `json:"uptime_seconds"`
+	Load          [3]float64 `json:"load"` // 1, 5 and 15 minute load averages
+}
+
+// AgentCommand is a power command queued for delivery to an agent
+type AgentCommand struct {
+	ID     string    `json:"id"`
+	Action string    `json:"action"`
+	Queued time.Time `json:"queued"`
+	Actor  string    `json:"-"`
+}
+
+// AgentState is the last known state of an agent, shown next to ping_success/arp_success
+type AgentState struct {
+	Online         bool          `json:"online"`
+	LastSeen       time.Time     `json:"last_seen"`
+	Hostname       string        `json:"hostname"`
+	OS             string        `json:"os"`
+	Version        string        `json:"version"`
+	UptimeSeconds  int64         `json:"uptime_seconds"`
+	Load           [3]float64    `json:"load"`
+	PendingCommand *AgentCommand `json:"pending_command,omitempty"`
+}
+
+// agentEntry is the registry record for one agent
+type agentEntry struct {
+	identity  string // Agent name of a per-agent token, or hostname@address for the shared token
+	heartbeat AgentHeartbeat
+	lastSeen  time.Time
+	pending   *AgentCommand
+}
+
+// AgentRegistry keeps agent state in memory, keyed by normalized MAC address.
+// An agent reporting several MACs is reachable under each of them, so any host
+// entry with one of those MACs shows the agent's state.
+type AgentRegistry struct {
+	mutex    sync.RWMutex
+	entries  map[string]*agentEntry // normalized MAC -> entry (shared between MACs of one agent)
+	stop     chan struct{}          // Closed by Close to end the cleanup goroutine
+	stopOnce sync.Once
+}
+
+// NewAgentRegistry creates an empty agent registry and starts its cleanup goroutine
+func NewAgentRegistry() *AgentRegistry {
+	ar := &AgentRegistry{
+		entries: make(map[string]*agentEntry),
+		stop:    make(chan struct{}),
+	}
+	go ar.cleanupStaleEntries()
+	return ar
+}
+
+// normalizeAgentMAC converts a MAC address to lowercase colon-separated form
+func normalizeAgentMAC(mac string) (string, bool) {
+	hw, err := net.ParseMAC(strings.TrimSpace(mac))
+	if err != nil || len(hw) != 6 {
+		return "", false
+	}
+	return hw.String(), true
+}
+
+// Heartbeat records an agent report and returns (and clears) a pending command.
+// A MAC address stays bound to the identity that reported it while that agent is online, so
+// another agent cannot take over a host's state and commands; once the agent is offline, the
+// MAC may move to a new identity (e.g. after an address change).
+func (ar *AgentRegistry) Heartbeat(hb AgentHeartbeat, identity string) (*AgentCommand, error) {
+	ar.mutex.Lock()
+	defer ar.mutex.Unlock()
+
+	now := time.Now()
+
+	// Reuse the agent's existing entry if any of the MACs is already known (keeps pending commands)
+	var entry *agentEntry
+	for _, mac := range hb.MACs {
+		existing, ok := ar.entries[mac]
+		if !ok {
+			continue
+		}
+		if existing.identity != identity {
+			if now.Sub(existing.lastSeen) <= AgentOfflineAfter {
+				return nil, fmt.Errorf("%w: %s", errAgentMACClaimed, mac)
+			}
+			continue
+		}
+		if entry == nil {
+			entry = existing
+		}
+	}
+	if entry == nil {
+		if len(ar.entries)+len(hb.MACs) > MaxAgentEntries {
+			Warning("Agent registry full (%d entries) - ignoring heartbeat from %s", len(ar.entries), hb.Hostname)
+			return nil, nil
+		}
+		entry = &agentEntry{identity: identity}
+	}
+
+	entry.heartbeat = hb
+	entry.lastSeen = now
+	for _, mac := range hb.MACs {
+		ar.entries[mac] = entry
+	}
+
+	command := entry.pending
+	entry.pending = nil
+	if command != nil && now.Sub(command.Queued) > AgentCommandTTL {
+		Warning("Dropping expired agent command %s (%s) for %s", command.ID, command.Action, hb.Hostname)
+		return nil, nil
+	}
+	return command, nil
+}
+
+// Get returns the state of the agent running on the host with the given MAC, or nil
+func (ar *AgentRegistry) Get(mac string) *AgentState {
+	key, ok := normalizeAgentMAC(mac)
+	if !ok {
+		return nil
+	}
+
+	ar.mutex.RLock()
+	defer ar.mutex.RUnlock()
+
+	entry, exists := ar.entries[key]
+	if !exists {
+		return nil
+	}
+
+	state := &AgentState{
+		Online:        time.Since(entry.lastSeen) <= AgentOfflineAfter,
+		LastSeen:      entry.lastSeen,
+		Hostname:      entry.heartbeat.Hostname,
+		OS:            entry.heartbeat.OS,
+		Version:       entry.heartbeat.Version,
+		UptimeSeconds: entry.heartbeat.UptimeSeconds,
+		Load:          entry.heartbeat.Load,
+	}
+	if entry.pending != nil && time.Since(entry.pending.Queued) <= AgentCommandTTL {
+		pending := *entry.pending
+		state.PendingCommand = &pending
+	}
+	return state
+}
+
+// Queue schedules a command for the agent of the host with the given MAC.
+// Returns false if no online agent is known for that MAC.
+func (ar *AgentRegistry) Queue(mac string, command *AgentCommand) bool {
+	key, ok := normalizeAgentMAC(mac)
+	if !ok {
+		return false
+	}
+
+	ar.mutex.Lock()
+	defer ar.mutex.Unlock()
+
+	entry, exists := ar.entries[key]
+	if !exists || time.Since(entry.lastSeen) > AgentOfflineAfter {
+		return false
+	}
+	entry.pending = command
+	return true
+}
+
+// cleanupStaleEntries periodically removes agents that stopped reporting
+func (ar *AgentRegistry) cleanupStaleEntries() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ar.stop:
+			return
+		}
+
+		ar.mutex.Lock()
+		for mac, entry := range ar.entries {
+			if time.Since(entry.lastSeen) > AgentStateRetention {
+				delete(ar.entries, mac)
+			}
+		}
+		ar.mutex.Unlock()
+	}
+}
+
+// Close stops the cleanup goroutine
+func (ar *AgentRegistry) Close() {
+	ar.stopOnce.Do(func() { close(ar.stop) })
+}
+
+// addAgentStatus adds the agent state of a host to a status response (if an agent has reported)
+func (s *Server) addAgentStatus(response map[string]interface{}, host Host) map[string]interface{} {
+	if s.Agents == nil {
+		return response
+	}
+	if state := s.Agents.Get(host.MAC); state != nil {
+		response["agent_online"] = state.Online
+		response["agent"] = state
+	}
+	return response
+}
+
+// agentEndpointEnabled reports whether a shared or per-agent token is configured
+func (s *Server) agentEndpointEnabled() bool {
+	return s.Config.AgentToken != "" || len(s.Config.Agents) > 0
+}
+
+// checkAgentToken verifies the agent token from the Authorization header. It returns the
+// agent a per-agent token belongs to, or nil for the shared agent_token.
+func (s *Server) checkAgentToken(r *http.Request) (*AgentConfig, bool) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		return nil, false
+	}
+	for i := range s.Config.Agents {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.Config.Agents[i].Token)) == 1 {
+			return &s.Config.Agents[i], true
+		}
+	}
+	if s.Config.AgentToken == "" {
+		return nil, false
+	}
+	return nil, subtle.ConstantTimeCompare([]byte(token), []byte(s.Config.AgentToken)) == 1
+}
+
+// agentOwningMAC returns the agent a MAC address is bound to in agents, or nil
+func (s *Server) agentOwningMAC(mac string) *AgentConfig {
+	for i := range s.Config.Agents {
+		for _, bound := range s.Config.Agents[i].MACs {
+			if bound == mac {
+				return &s.Config.Agents[i]
+			}
+		}
+	}
+	return nil
+}
+
+// handleAgentHeartbeat receives agent reports and delivers queued commands.
+// Authenticated with the shared or a per-agent token instead of a user session.
+func (s *Server) handleAgentHeartbeat(w http.ResponseWriter, r *http.Request) {
+	if !s.agentEndpointEnabled() {
+		http.NotFound(w, r)
+		return
+	}
+
+	bound, ok := s.checkAgentToken(r)
+	if !ok {
+		Warning("Agent heartbeat with invalid token from %s", s.clientIP(r))
+		sendJSONErrorWithCode(w, "Invalid agent token", ErrCodeUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	var hb AgentHeartbeat
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&hb); err != nil {
+		sendJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if len(hb.MACs) == 0 || len(hb.MACs) > MaxAgentMACs {
+		sendJSONErrorWithCode(w, fmt.Sprintf("macs must contain 1-%d addresses", MaxAgentMACs), ErrCodeInvalidInput, http.StatusBadRequest)
+		return
+	}
+	seen := make(map[string]bool)
+	macs := make([]string, 0, len(hb.MACs))
+	for _, mac := range hb.MACs {
+		normalized, ok := normalizeAgentMAC(mac)
+		if !ok {
+			sendJSONErrorWithCode(w, "Invalid MAC address: "+mac, ErrCodeInvalidMAC, http.StatusBadRequest)
+			return
+		}
+		if !seen[normalized] {
+			seen[normalized] = true
+			macs = append(macs, normalized)
+		}
+	}
+	hb.MACs = macs
+	hb.Hostname = truncateString(hb.Hostname, 255)
+	hb.OS = truncateString(hb.OS, 32)
+	hb.Version = truncateString(hb.Version, 32)
+
+	// Per-agent tokens only register their own MACs (other interfaces of the machine are
+	// ignored), and MACs bound to a per-agent token cannot be reported with the shared token
+	identity := hb.Hostname + "@" + s.clientIP(r)
+	if bound != nil {
+		identity = "agent:" + bound.Name
+		owned := make([]string, 0, len(hb.MACs))
+		for _, mac := range hb.MACs {
+			if s.agentOwningMAC(mac) == bound {
+				owned = append(owned, mac)
+			}
+		}
+		hb.MACs = owned
+		if len(hb.MACs) == 0 {
+			Warning("Agent heartbeat from %s as '%s' lists none of its MAC addresses", s.clientIP(r), bound.Name)
+			sendJSONErrorWithCode(w, fmt.Sprintf("None of the MAC addresses is bound to agent '%s'", bound.Name), ErrCodeForbidden, http.StatusForbidden)
+			return
+		}
+	} else {
+		for _, mac := range hb.MACs {
+			if owner := s.agentOwningMAC(mac); owner != nil {
+				Warning("Agent heartbeat from %s (%s) with the shared token for MAC %s of agent '%s'", s.clientIP(r), hb.Hostname, mac, owner.Name)
+				sendJSONErrorWithCode(w, "Token is not valid for MAC address "+mac, ErrCodeForbidden, http.StatusForbidden)
+				return
+			}
+		}
+	}
+
+	Debug("Agent heartbeat from %s (%s) - uptime: %ds, load: %.2f", hb.Hostname, strings.Join(hb.MACs, ","), hb.UptimeSeconds, hb.Load[0])
+
+	response := map[string]interface{}{
+		"interval_seconds": int(AgentHeartbeatInterval / time.Second),
+	}
+	command, err := s.Agents.Heartbeat(hb, identity)
+	if err != nil {
+		Warning("Agent heartbeat from %s (%s) refused: %v", s.clientIP(r), hb.Hostname, err)
+		sendJSONErrorWithCode(w, err.Error(), ErrCodeAlreadyExists, http.StatusConflict)
+		return
+	}
+	if command != nil {
+		Info("Delivering agent command %s (%s) to %s", command.ID, command.Action, hb.Hostname)
+		response["command"] = command
+	}
+	sendJSON(w, response, http.StatusOK)
+}
+
+// handleAgentCommand queues a sleep/shutdown/reboot command for a host's agent
+func (s *Server) handleAgentCommand(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r)
+	hostID := mux.Vars(r)["id"]
+
+	if !s.agentEndpointEnabled() {
+		sendJSONErrorWithCode(w, "Agent mode is disabled", ErrCodeFeatureDisabled, http.StatusForbidden)
+		return
+	}
+
+	userKey := "anonymous"
+	if user != nil {
+		userKey = user.ID
+	}
+	if !s.ShutdownRateLimit.Allow(userKey) {
+		Debug("Agent command rate limit exceeded for user: %s", userKey)
+		sendJSONErrorWithCode(w, "Rate limit exceeded. Please wait before sending more power commands.", ErrCodeRateLimited, http.StatusTooManyRequests)
+		return
+	}
+
+	host, ok := s.loadAccessibleHost(w, user, hostID)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Action string `json:"action"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !validAgentCommands[req.Action] {
+		sendJSONErrorWithCode(w, "action must be one of: sleep, shutdown, reboot", ErrCodeInvalidInput, http.StatusBadRequest)
+		return
+	}
+
+	entry := s.newAuditEntry(r, AuditActionAgentCommand, host)
+	entry.Detail = req.Action
+
+	if s.live().ReadOnlyMode || (s.Config.UseAuth && user != nil && user.ReadOnly) {
+		entry.Detail += ": denied: read-only access"
+		s.recordAudit(entry)
+		sendJSONErrorWithCode(w, "Read-only access: power commands not allowed", ErrCodeReadOnlyMode, http.StatusForbidden)
+		return
+	}
+
+	command := &AgentCommand{
+		ID:     newJobID(),
+		Action: req.Action,
+		Queued: time.Now(),
+		Actor:  entry.Actor,
+	}
+	if !s.Agents.Queue(host.MAC, command) {
+		entry.Detail += ": no online agent"
+		s.recordAudit(entry)
+		sendJSONErrorWithCode(w, "No online agent for this host", ErrCodeHostOffline, http.StatusConflict)
+		return
+	}
+
+	s.PingCache.Invalidate(host.ID)
+
+	entry.Success = true
+	entry.Detail += ": queued " + command.ID
+	s.recordAudit(entry)
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Command queued for agent",
+		"command": command,
+	}
+	sendJSON(w, response, http.StatusAccepted)
+}
+
+// newJobID returns a random identifier for an agent command or relay job
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// truncateString limits s to max bytes
+func truncateString(s string, max int) string {
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// agentOptions configures the companion agent mode (--agent)
+type agentOptions struct {
+	ServerURL     string // Base URL of the server including url_prefix (e.g. "http://wol.lan:8090/wolweb")
+	Token         string // Shared agent token (agent_token on the server)
+	AllowCommands bool   // Execute power commands pushed by the server
+}
+
+// agentHeartbeatResponse is the server reply to a heartbeat
+type agentHeartbeatResponse struct {
+	IntervalSeconds int           `json:"interval_seconds"`
+	Command         *AgentCommand `json:"command"`
+}
+
+// runAgent runs the companion agent: it reports heartbeat, uptime and load to the
+// server and executes sleep/shutdown/reboot commands delivered in the replies.
+// It only returns on configuration errors.
+func runAgent(opts agentOptions) error {
+	if opts.ServerURL == "" {
+		return fmt.Errorf("server URL is required")
+	}
+	if !strings.HasPrefix(opts.ServerURL, "http://") && !strings.HasPrefix(opts.ServerURL, "https://") {
+		return fmt.Errorf("server URL must start with http:// or https://")
+	}
+	if opts.Token == "" {
+		return fmt.Errorf("agent token is required (set AGENT_TOKEN or use -agent-token-file)")
+	}
+
+	endpoint := strings.TrimSuffix(opts.ServerURL, "/") + "/api/agent/heartbeat"
+	client := &http.Client{Timeout: AgentRequestTimeout}
+	interval := AgentHeartbeatInterval
+
+	Info("Starting agent v%s - reporting to %s every %s", Version, endpoint, interval)
+	if !opts.AllowCommands {
+		Info("Power commands are disabled (--agent-no-commands)")
+	}
+
+	for {
+		reply, err := sendAgentHeartbeat(client, endpoint, opts.Token)
+		if err != nil {
+			Warning("Heartbeat failed: %v", err)
+		} else {
+			if reply.IntervalSeconds > 0 {
+				interval = time.Duration(reply.IntervalSeconds) * time.Second
+			}
+			if reply.Command != nil {
+				executeAgentCommand(reply.Command, opts.AllowCommands)
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+// sendAgentHeartbeat collects local state and posts it to the server
+func sendAgentHeartbeat(client *http.Client, endpoint, token string) (*agentHeartbeatResponse, error) {
+	macs, err := localMACAddresses()
+	if err != nil {
+		return nil, err
+	}
+
+	hb := AgentHeartbeat{
+		MACs:    macs,
+		OS:      runtime.GOOS,
+		Version: Version,
+	}
+	hb.Hostname, _ = os.Hostname()
+	if uptime, load, err := readSystemStats(); err == nil {
+		hb.UptimeSeconds = uptime
+		hb.Load = load
+	} else {
+		Debug("Failed to read system stats: %v", err)
+	}
+
+	body, err := json.Marshal(hb)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned %s", resp.Status)
+	}
+
+	var reply agentHeartbeatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return nil, fmt.Errorf("invalid server response: %w", err)
+	}
+	Debug("Heartbeat sent (macs: %s, uptime: %ds)", strings.Join(macs, ","), hb.UptimeSeconds)
+	return &reply, nil
+}
+
+// executeAgentCommand runs a power command received from the server
+func executeAgentCommand(command *AgentCommand, allowed bool) {
+	if !validAgentCommands[command.Action] {
+		Warning("Ignoring unknown command %s (%s)", command.ID, command.Action)
+		return
+	}
+	if !allowed {
+		Warning("Ignoring command %s (%s) - power commands are disabled", command.ID, command.Action)
+		return
+	}
+
+	cmd, err := agentPowerCommand(command.Action)
+	if err != nil {
+		Error("Command %s (%s) failed: %v", command.ID, command.Action, err)
+		return
+	}
+
+	Info("Executing command %s: %s", command.ID, command.Action)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		Error("Command %s (%s) failed: %v: %s", command.ID, command.Action, err, strings.TrimSpace(string(output)))
+	}
+}
+
+// localMACAddresses returns the hardware addresses of all non-loopback interfaces
+func localMACAddresses() ([]string, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network interfaces: %w", err)
+	}
+
+	var macs []string
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback != 0 || len(iface.HardwareAddr) != 6 {
+			continue
+		}
+		macs = append(macs, iface.HardwareAddr.String())
+		if len(macs) == MaxAgentMACs {
+			break
+		}
+	}
+	if len(macs) == 0 {
+		return nil, fmt.Errorf("no network interfaces with a MAC address found")
+	}
+	return macs, nil
+}
@@ -0,0 +1,54 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// readSystemStats returns uptime in seconds and 1/5/15 minute load averages from /proc
+func readSystemStats() (int64, [3]float64, error) {
+	var load [3]float64
+
+	uptimeData, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return 0, load, fmt.Errorf("failed to read /proc/uptime: %w", err)
+	}
+	fields := strings.Fields(string(uptimeData))
+	if len(fields) < 1 {
+		return 0, load, fmt.Errorf("unexpected /proc/uptime format")
+	}
+	uptime, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, load, fmt.Errorf("failed to parse uptime: %w", err)
+	}
+
+	loadData, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return int64(uptime), load, fmt.Errorf("failed to read /proc/loadavg: %w", err)
+	}
+	fields = strings.Fields(string(loadData))
+	for i := 0; i < 3 && i < len(fields); i++ {
+		load[i], _ = strconv.ParseFloat(fields[i], 64)
+	}
+
+	return int64(uptime), load, nil
+}
+
+// agentPowerCommand returns the system command implementing an agent power action
+func agentPowerCommand(action string) (*exec.Cmd, error) {
+	switch action {
+	case AgentCommandSleep:
+		return exec.Command("systemctl", "suspend"), nil
+	case AgentCommandShutdown:
+		return exec.Command("systemctl", "poweroff"), nil
+	case AgentCommandReboot:
+		return exec.Command("systemctl", "reboot"), nil
+	}
+	return nil, fmt.Errorf("unknown action: %s", action)
+}
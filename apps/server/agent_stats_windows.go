@@ -0,0 +1,27 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// readSystemStats is not implemented on Windows - agents report zero uptime and load
+func readSystemStats() (int64, [3]float64, error) {
+	return 0, [3]float64{}, fmt.Errorf("system statistics are not supported on Windows")
+}
+
+// agentPowerCommand returns the system command implementing an agent power action
+func agentPowerCommand(action string) (*exec.Cmd, error) {
+	switch action {
+	case AgentCommandSleep:
+		return exec.Command("rundll32.exe", "powrprof.dll,SetSuspendState", "0,1,0"), nil
+	case AgentCommandShutdown:
+		return exec.Command("shutdown", "/s", "/t", "0"), nil
+	case AgentCommandReboot:
+		return exec.Command("shutdown", "/r", "/t", "0"), nil
+	}
+	return nil, fmt.Errorf("unknown action: %s", action)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAgentRegistryRefusesMACTakeover(t *testing.T) {
+	ar := NewAgentRegistry()
+	defer ar.Close()
+
+	victim := AgentHeartbeat{MACs: []string{"aa:bb:cc:dd:ee:01"}, Hostname: "nas", UptimeSeconds: 100}
+	if _, err := ar.Heartbeat(victim, "nas@192.0.2.10"); err != nil {
+		t.Fatal(err)
+	}
+	command := &AgentCommand{ID: "c1", Action: AgentCommandShutdown, Queued: time.Now()}
+	if !ar.Queue("aa:bb:cc:dd:ee:01", command) {
+		t.Fatal("command was not queued")
+	}
+
+	// Another machine with the shared token claims the victim's MAC next to its own
+	attacker := AgentHeartbeat{MACs: []string{"aa:bb:cc:dd:ee:02", "aa:bb:cc:dd:ee:01"}, Hostname: "evil", UptimeSeconds: 1}
+	got, err := ar.Heartbeat(attacker, "evil@192.0.2.66")
+	if !errors.Is(err, errAgentMACClaimed) {
+		t.Fatalf("err = %v, want errAgentMACClaimed", err)
+	}
+	if got != nil {
+		t.Fatalf("attacker received command %s", got.ID)
+	}
+	if state := ar.Get("aa:bb:cc:dd:ee:01"); state.Hostname != "nas" || state.UptimeSeconds != 100 {
+		t.Errorf("victim state overwritten: %+v", state)
+	}
+	if state := ar.Get("aa:bb:cc:dd:ee:02"); state != nil {
+		t.Errorf("refused heartbeat was recorded: %+v", state)
+	}
+
+	// The real host still receives its command
+	got, err = ar.Heartbeat(victim, "nas@192.0.2.10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.ID != "c1" {
+		t.Errorf("victim command = %v, want c1", got)
+	}
+}
+
+func TestAgentRegistryRebindsOfflineMAC(t *testing.T) {
+	ar := NewAgentRegistry()
+	defer ar.Close()
+
+	hb := AgentHeartbeat{MACs: []string{"aa:bb:cc:dd:ee:01"}, Hostname: "nas"}
+	if _, err := ar.Heartbeat(hb, "nas@192.0.2.10"); err != nil {
+		t.Fatal(err)
+	}
+	ar.entries["aa:bb:cc:dd:ee:01"].lastSeen = time.Now().Add(-AgentOfflineAfter - time.Second)
+
+	// The machine came back with a new address
+	if _, err := ar.Heartbeat(hb, "nas@192.0.2.11"); err != nil {
+		t.Fatalf("heartbeat after the old identity went offline: %v", err)
+	}
+	if state := ar.Get("aa:bb:cc:dd:ee:01"); state == nil || !state.Online {
+		t.Errorf("state = %+v, want online", state)
+	}
+}
+
+// postAgentHeartbeat sends a heartbeat with the given token and returns the status code
+func postAgentHeartbeat(t *testing.T, s *Server, token string, hb AgentHeartbeat) int {
+	t.Helper()
+	body, err := json.Marshal(hb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/agent/heartbeat", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	s.handleAgentHeartbeat(rec, req)
+	return rec.Code
+}
+
+func TestAgentHeartbeatPerAgentToken(t *testing.T) {
+	config := &Config{
+		AgentToken: "shared-token-0123456789",
+		Agents: []AgentConfig{
+			{Name: "nas", Token: "nas-token-0123456789", MACs: []string{"AA-BB-CC-DD-EE-01"}},
+		},
+	}
+	if err := validateAgentConfig(&config.Agents[0]); err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{Config: config, Agents: NewAgentRegistry()}
+	defer s.Agents.Close()
+
+	tests := []struct {
+		name  string
+		token string
+		macs  []string
+		want  int
+	}{
+		{"shared token for a bound MAC", "shared-token-0123456789", []string{"aa:bb:cc:dd:ee:01"}, http.StatusForbidden},
+		{"per-agent token for other MACs only", "nas-token-0123456789", []string{"aa:bb:cc:dd:ee:02"}, http.StatusForbidden},
+		{"per-agent token for its MAC", "nas-token-0123456789", []string{"aa:bb:cc:dd:ee:01", "02:42:ac:11:00:02"}, http.StatusOK},
+		{"shared token for an unbound MAC", "shared-token-0123456789", []string{"aa:bb:cc:dd:ee:03"}, http.StatusOK},
+		{"invalid token", "wrong-token-0123456789", []string{"aa:bb:cc:dd:ee:03"}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := postAgentHeartbeat(t, s, tt.token, AgentHeartbeat{MACs: tt.macs, Hostname: "host"}); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	// Other interfaces of the bound machine are not registered
+	if state := s.Agents.Get("02:42:ac:11:00:02"); state != nil {
+		t.Errorf("unbound interface was registered: %+v", state)
+	}
+	if state := s.Agents.Get("aa:bb:cc:dd:ee:01"); state == nil {
+		t.Error("bound MAC was not registered")
+	}
+}
@@ -5,29 +5,30 @@ package main
 
 import (
 	"fmt"
-	"os/exec"
+	"net"
 )
 
-// FlushARPEntry removes a specific IP from the ARP cache
-// Uses 'ip neigh flush' command (modern replacement for 'arp -d')
+// FlushARPEntry removes a specific IP from the neighbour (ARP/NDP) cache
+// Deletes the entries over rtnetlink, like 'ip neigh flush to <ip>'
 // Requires CAP_NET_ADMIN capability in Docker
 func FlushARPEntry(ip string) error {
 	if ip == "" {
 		return fmt.Errorf("IP address is required")
 	}
+	target := net.ParseIP(ip)
+	if target == nil {
+		return fmt.Errorf("invalid IP address: %s", ip)
+	}
 
-	Debug("Flushing ARP cache entry for IP %s", ip)
-
-	// Use 'ip neigh flush' instead of 'arp -d' (more reliable, modern approach)
-	cmd := exec.Command("ip", "neigh", "flush", "to", ip)
-	output, err := cmd.CombinedOutput()
+	netLog.Debug("Flushing ARP cache entry for IP %s", ip)
 
+	flushed, err := flushNeighbor(target)
 	if err != nil {
-		Warning("Failed to flush ARP entry for %s: %v (output: %s)", ip, err, string(output))
+		netLog.Warning("Failed to flush ARP entry for %s: %v", ip, err)
 		return fmt.Errorf("failed to flush ARP entry: %w", err)
 	}
 
-	Debug("Successfully flushed ARP cache entry for IP %s", ip)
+	netLog.Debug("Successfully flushed %d ARP cache entry(s) for IP %s", flushed, ip)
 	return nil
 }
 
@@ -36,6 +37,6 @@ func FlushARPEntry(ip string) error {
 func FlushARPEntryIfExists(ip string) {
 	if err := FlushARPEntry(ip); err != nil {
 		// Log but don't fail - entry might not exist yet or we lack permissions
-		Debug("ARP flush for %s: %v", ip, err)
+		netLog.Debug("ARP flush for %s: %v", ip, err)
 	}
 }
@@ -6,7 +6,7 @@ package main
 // FlushARPEntry is a no-op on Windows
 // Windows ARP cache management requires different commands and is not implemented
 func FlushARPEntry(ip string) error {
-	Debug("ARP flush not supported on Windows (IP: %s)", ip)
+	netLog.Debug("ARP flush not supported on Windows (IP: %s)", ip)
 	return nil
 }
 
@@ -4,13 +4,43 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
 	"strings"
+	"time"
 
 	"github.com/j-keck/arping"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+func init() {
+	// The library reads this global on every ping; changing it while pings run is a data race
+	arping.SetTimeout(ARPRequestInterval)
+}
+
+// arpPing ARP-pings an address on an interface (the interface of the route to it when
+// ifaceName is empty) until it answers or the timeout expires, resending the request every
+// ARPRequestInterval. Gives concurrent callers their own timeout without touching the
+// library's global one.
+func arpPing(ip net.IP, ifaceName string, timeout time.Duration) (net.HardwareAddr, time.Duration, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		var hwAddr net.HardwareAddr
+		var duration time.Duration
+		var err error
+		if ifaceName == "" {
+			hwAddr, duration, err = arping.Ping(ip)
+		} else {
+			hwAddr, duration, err = arping.PingOverIfaceByName(ip, ifaceName)
+		}
+		if !errors.Is(err, arping.ErrTimeout) || time.Until(deadline) <= 0 {
+			return hwAddr, duration, err
+		}
+	}
+}
+
 // ARPPingIP sends an ARP ping to a specific IP address and returns the hardware address
 //
 // IMPORTANT WARNING: When multiple interfaces are specified, this function tries each
@@ -26,7 +56,13 @@ import (
 // The calling code MUST verify the returned MAC address matches the expected device.
 //
 // Best practice: Use non-overlapping IP ranges or per-host interface configuration.
-func ARPPingIP(ip string, ifaceName string) (net.HardwareAddr, error) {
+func ARPPingIP(ctx context.Context, ip string, ifaceName string) (hwAddr net.HardwareAddr, err error) {
+	_, span := startSpan(ctx, "ARPPingIP", attribute.String("network.peer.address", ip), attribute.String("network.interface.name", ifaceName))
+	defer func() {
+		span.SetAttributes(attribute.Bool("arp.reply", hwAddr != nil))
+		endSpan(span, nil) // No reply is a result (host offline), not an error
+	}()
+
 	// Determine interface description for logging
 	ifaceDesc := "all available interfaces"
 	if ifaceName != "" {
@@ -38,7 +74,7 @@ func ARPPingIP(ip string, ifaceName string) (net.HardwareAddr, error) {
 		}
 	}
 
-	Debug("ARP ping to IP %s using %s", ip, ifaceDesc)
+	netLog.DebugContext(ctx, "ARP ping to IP %s using %s", ip, ifaceDesc)
 
 	// If multiple interfaces specified (comma-separated), try each one
 	// WARNING: Returns on first successful response - see function documentation
@@ -50,38 +86,38 @@ func ARPPingIP(ip string, ifaceName string) (net.HardwareAddr, error) {
 				continue
 			}
 
-			Debug("Sending ARP request to %s via %s", ip, iface)
-			hwAddr, duration, err := arping.PingOverIfaceByName(net.ParseIP(ip), iface)
+			netLog.DebugContext(ctx, "Sending ARP request to %s via %s", ip, iface)
+			hwAddr, duration, err := arpPing(net.ParseIP(ip), iface, ARPReplyTimeout)
 			if err == nil && hwAddr != nil {
-				Debug("ARP reply from %s: MAC %s via %s (%.3fms)",
+				netLog.DebugContext(ctx, "ARP reply from %s: MAC %s via %s (%.3fms)",
 					ip, hwAddr.String(), iface, duration.Seconds()*1000)
 				// WARNING: Returning first successful response - caller must verify MAC address
 				return hwAddr, nil
 			}
 			// Check for permission error
 			if strings.Contains(err.Error(), "operation not permitted") {
-				Warning("ARP ping requires CAP_NET_RAW capability. Run with: sudo setcap cap_net_raw+ep /path/to/wolweb")
+				netLog.WarningContext(ctx, "ARP ping requires CAP_NET_RAW capability. Run with: sudo setcap cap_net_raw+ep /path/to/wolweb")
 				return nil, fmt.Errorf("ARP ping requires elevated permissions (CAP_NET_RAW)")
 			}
-			Debug("No ARP reply from %s via %s: %v", ip, iface, err)
+			netLog.DebugContext(ctx, "No ARP reply from %s via %s: %v", ip, iface, err)
 		}
-		Debug("ARP ping to %s failed on all specified interfaces, trying default", ip)
+		netLog.DebugContext(ctx, "ARP ping to %s failed on all specified interfaces, trying default", ip)
 	}
 
 	// If no interface specified or all failed, try default (no interface specified)
-	Debug("Sending ARP request to %s via default interface", ip)
-	hwAddr, duration, err := arping.Ping(net.ParseIP(ip))
+	netLog.DebugContext(ctx, "Sending ARP request to %s via default interface", ip)
+	hwAddr, duration, err := arpPing(net.ParseIP(ip), "", ARPReplyTimeout)
 	if err != nil {
 		// Check for permission error
 		if strings.Contains(err.Error(), "operation not permitted") {
-			Warning("ARP ping requires CAP_NET_RAW capability. Run with: sudo setcap cap_net_raw+ep /path/to/wolweb")
+			netLog.WarningContext(ctx, "ARP ping requires CAP_NET_RAW capability. Run with: sudo setcap cap_net_raw+ep /path/to/wolweb")
 			return nil, fmt.Errorf("ARP ping requires elevated permissions (CAP_NET_RAW)")
 		}
-		Debug("No ARP reply from %s via default interface: %v", ip, err)
+		netLog.DebugContext(ctx, "No ARP reply from %s via default interface: %v", ip, err)
 		return nil, fmt.Errorf("ARP ping failed: %w", err)
 	}
 
-	Debug("ARP reply from %s: MAC %s via default interface (%.3fms)",
+	netLog.DebugContext(ctx, "ARP reply from %s: MAC %s via default interface (%.3fms)",
 		ip, hwAddr.String(), duration.Seconds()*1000)
 	return hwAddr, nil
 }
@@ -3,11 +3,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net"
 )
 
 // ARPPingIP is not supported on Windows
-func ARPPingIP(ip string, ifaceName string) (net.HardwareAddr, error) {
+func ARPPingIP(ctx context.Context, ip string, ifaceName string) (net.HardwareAddr, error) {
 	return nil, fmt.Errorf("ARP ping is not supported on Windows")
 }
@@ -9,14 +9,21 @@ import (
 	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/j-keck/arping"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // ARPPingMAC performs active ARP scanning to find and ping a host by MAC address
 // Uses arping library to actively scan the network (Linux only)
-func ARPPingMAC(mac string, networkInterface string, timeoutSeconds int) (string, bool, error) {
+func ARPPingMAC(ctx context.Context, mac string, networkInterface string, timeoutSeconds int) (foundIP string, pingSuccess bool, err error) {
+	ctx, span := startSpan(ctx, "ARPPingMAC", attribute.String("host.mac", mac), attribute.String("network.interface.name", networkInterface))
+	defer func() {
+		span.SetAttributes(attribute.String("host.ip", foundIP), attribute.Bool("icmp.reply", pingSuccess))
+		endSpan(span, nil) // Not found is a result (host offline), not an error
+	}()
+
 	// Normalize target MAC for comparison
 	normalizedTargetMAC := normalizeMACAddress(mac)
 
@@ -38,13 +45,12 @@ func ARPPingMAC(mac string, networkInterface string, timeoutSeconds int) (string
 		}
 	}
 
-	// Set arping timeout per IP (use a fraction of total timeout for individual pings)
+	// ARP timeout per IP (use a fraction of total timeout for individual pings)
 	// This allows scanning multiple IPs within the timeout window
 	arpTimeout := time.Duration(timeoutSeconds) * time.Second / 20 // 1/20th per IP for fast scanning
-	if arpTimeout < 50*time.Millisecond {
-		arpTimeout = 50 * time.Millisecond
+	if arpTimeout < ARPRequestInterval {
+		arpTimeout = ARPRequestInterval
 	}
-	arping.SetTimeout(arpTimeout)
 
 	// Try each interface
 	for _, ifaceName := range interfaces {
@@ -56,14 +62,14 @@ func ARPPingMAC(mac string, networkInterface string, timeoutSeconds int) (string
 		// Get interface to determine network
 		iface, err := net.InterfaceByName(ifaceName)
 		if err != nil {
-			Debug("Failed to get interface %s: %v", ifaceName, err)
+			netLog.DebugContext(ctx, "Failed to get interface %s: %v", ifaceName, err)
 			continue
 		}
 
 		// Get interface addresses to determine network CIDR
 		addrs, err := iface.Addrs()
 		if err != nil {
-			Debug("Failed to get addresses for interface %s: %v", ifaceName, err)
+			netLog.DebugContext(ctx, "Failed to get addresses for interface %s: %v", ifaceName, err)
 			continue
 		}
 
@@ -76,39 +82,40 @@ func ARPPingMAC(mac string, networkInterface string, timeoutSeconds int) (string
 			// Calculate network size
 			ones, bits := ipNet.Mask.Size()
 			networkSize := 1 << (bits - ones)
-			Debug("Scanning network %s (%d hosts) on interface %s for MAC %s (timeout: %ds)",
+			netLog.DebugContext(ctx, "Scanning network %s (%d hosts) on interface %s for MAC %s (timeout: %ds)",
 				ipNet.String(), networkSize-2, ifaceName, mac, timeoutSeconds)
 
-			// Create timeout context using provided timeout
-			ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutSeconds)*time.Second)
+			// Create timeout context using provided timeout (a client giving up on the
+			// request must not cut the scan short - the result is cached)
+			scanCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Duration(timeoutSeconds)*time.Second)
 			defer cancel()
 
 			// Scan the network range using arping with timeout
 			startTime := time.Now()
-			foundIP, found := scanNetworkForMAC(ctx, ipNet, ifaceName, normalizedTargetMAC)
+			foundIP, found := scanNetworkForMAC(scanCtx, ipNet, ifaceName, normalizedTargetMAC, arpTimeout)
 			scanDuration := time.Since(startTime)
 
 			if found {
-				Debug("MAC %s found at IP %s on interface %s after %.2fs",
+				netLog.DebugContext(ctx, "MAC %s found at IP %s on interface %s after %.2fs",
 					mac, foundIP, ifaceName, scanDuration.Seconds())
 
 				// Verify with ICMP ping
-				Debug("Verifying connectivity to %s with ICMP ping", foundIP)
-				pingSuccess := PingHostWithInterface(foundIP, ARPPingTimeoutSeconds, ifaceName)
+				netLog.DebugContext(ctx, "Verifying connectivity to %s with ICMP ping", foundIP)
+				pingSuccess := PingHostWithInterface(ctx, foundIP, ARPPingTimeoutSeconds, ifaceName)
 				if pingSuccess {
-					Debug("ICMP ping to %s successful", foundIP)
+					netLog.DebugContext(ctx, "ICMP ping to %s successful", foundIP)
 				} else {
-					Debug("ICMP ping to %s failed (host found via ARP but not responding to ICMP)", foundIP)
+					netLog.DebugContext(ctx, "ICMP ping to %s failed (host found via ARP but not responding to ICMP)", foundIP)
 				}
 				return foundIP, pingSuccess, nil
 			}
 
 			// Check if timeout occurred
-			if ctx.Err() == context.DeadlineExceeded {
-				Debug("ARP scan for MAC %s on interface %s timed out after %.2fs (network: %s)",
+			if scanCtx.Err() == context.DeadlineExceeded {
+				netLog.DebugContext(ctx, "ARP scan for MAC %s on interface %s timed out after %.2fs (network: %s)",
 					mac, ifaceName, scanDuration.Seconds(), ipNet.String())
 			} else {
-				Debug("ARP scan for MAC %s on interface %s completed in %.2fs - not found (network: %s)",
+				netLog.DebugContext(ctx, "ARP scan for MAC %s on interface %s completed in %.2fs - not found (network: %s)",
 					mac, ifaceName, scanDuration.Seconds(), ipNet.String())
 			}
 		}
@@ -119,29 +126,61 @@ func ARPPingMAC(mac string, networkInterface string, timeoutSeconds int) (string
 
 // scanNetworkForMAC scans a network range using ARP ping to find a specific MAC address
 // Uses parallel scanning with timeout context for efficiency
-func scanNetworkForMAC(ctx context.Context, ipNet *net.IPNet, ifaceName string, targetMAC string) (string, bool) {
+func scanNetworkForMAC(ctx context.Context, ipNet *net.IPNet, ifaceName string, targetMAC string, arpTimeout time.Duration) (string, bool) {
+	ctx, span := startSpan(ctx, "scanNetworkForMAC", attribute.String("network.subnet", ipNet.String()), attribute.String("network.interface.name", ifaceName))
+	foundIP := ""
+	defer func() {
+		span.SetAttributes(attribute.String("host.ip", foundIP), attribute.Bool("timeout", ctx.Err() == context.DeadlineExceeded))
+		endSpan(span, nil)
+	}()
+
+	scanSubnet(ctx, ipNet, ifaceName, arpTimeout, func(ip net.IP, hwAddr net.HardwareAddr) bool {
+		// Check if MAC matches
+		if normalizeMACAddress(hwAddr.String()) == targetMAC {
+			foundIP = ip.String()
+			return false
+		}
+		return true
+	})
+	return foundIP, foundIP != ""
+}
+
+// DiscoverSubnet ARP-pings every address of an IPv4 network and reports each reply
+// to found (see scanSubnet). Used by network discovery scans.
+func DiscoverSubnet(ctx context.Context, ipNet *net.IPNet, ifaceName string, found func(ip net.IP, hwAddr net.HardwareAddr) bool) error {
+	return scanSubnet(ctx, ipNet, ifaceName, DiscoveryARPTimeout, found)
+}
+
+// scanSubnet ARP-pings every address of an IPv4 network in parallel and calls found for
+// each reply, until found returns false, the context ends or all addresses were tried.
+// Each address gets arpTimeout to answer. found is never called concurrently.
+func scanSubnet(ctx context.Context, ipNet *net.IPNet, ifaceName string, arpTimeout time.Duration, found func(ip net.IP, hwAddr net.HardwareAddr) bool) error {
 	// Calculate network range - ensure IPv4
 	ip := ipNet.IP.To4()
 	if ip == nil {
 		// Not IPv4, skip
-		return "", false
+		return fmt.Errorf("not an IPv4 network: %s", ipNet)
 	}
 
 	ip = ip.Mask(ipNet.Mask)
 	broadcast := getBroadcastIP(ipNet)
 	if broadcast == nil {
 		// Failed to calculate broadcast
-		return "", false
+		return fmt.Errorf("failed to calculate broadcast address of %s", ipNet)
 	}
 
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	// Create channels for results and IP distribution
 	type arpResult struct {
-		ip  string
-		mac string
+		ip     net.IP
+		hwAddr net.HardwareAddr
 	}
 
 	resultChan := make(chan arpResult, 10)
 	ipChan := make(chan net.IP, 50)
+	var permissionDenied atomic.Bool
 
 	// Use worker pool for parallel scanning
 	const numWorkers = 10
@@ -161,17 +200,19 @@ func scanNetworkForMAC(ctx context.Context, ipNet *net.IPNet, ifaceName string,
 						return
 					}
 
-					// Use arping to ping this IP
-					hwAddr, _, err := arping.PingOverIfaceByName(currentIP, ifaceName)
+					// ARP-ping this IP with the scan's own timeout
+					hwAddr, _, err := arpPing(currentIP, ifaceName, arpTimeout)
 					if err == nil && hwAddr != nil {
 						select {
-						case resultChan <- arpResult{ip: currentIP.String(), mac: hwAddr.String()}:
+						case resultChan <- arpResult{ip: currentIP, hwAddr: hwAddr}:
 						case <-ctx.Done():
 							return
 						}
 					} else if err != nil && strings.Contains(err.Error(), "operation not permitted") {
 						// Permission error - stop scanning and return early
-						Warning("ARP scanning requires CAP_NET_RAW capability. Run with: sudo setcap cap_net_raw+ep /path/to/wolweb")
+						netLog.WarningContext(ctx, "ARP scanning requires CAP_NET_RAW capability. Run with: sudo setcap cap_net_raw+ep /path/to/wolweb")
+						permissionDenied.Store(true)
+						cancel()
 						return
 					}
 				}
@@ -205,17 +246,17 @@ func scanNetworkForMAC(ctx context.Context, ipNet *net.IPNet, ifaceName string,
 	for {
 		select {
 		case <-ctx.Done():
-			return "", false
+			if permissionDenied.Load() {
+				return fmt.Errorf("ARP scan requires elevated permissions (CAP_NET_RAW)")
+			}
+			return ctx.Err()
 		case result, ok := <-resultChan:
 			if !ok {
-				// All workers done, no match found
-				return "", false
+				// All workers done
+				return nil
 			}
-
-			// Check if MAC matches
-			resultMAC := normalizeMACAddress(result.mac)
-			if resultMAC == targetMAC {
-				return result.ip, true
+			if !found(result.ip, result.hwAddr) {
+				return nil
 			}
 		}
 	}
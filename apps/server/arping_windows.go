@@ -10,7 +10,7 @@ import (
 
 // ARPPingMAC is not supported on Windows - always returns error
 // On Windows, the system falls back to passive ARP table lookups only
-func ARPPingMAC(mac string, networkInterface string, timeoutSeconds int) (string, bool, error) {
+func ARPPingMAC(ctx context.Context, mac string, networkInterface string, timeoutSeconds int) (string, bool, error) {
 	return "", false, fmt.Errorf("active ARP scanning is not supported on Windows")
 }
 
@@ -20,6 +20,11 @@ func scanNetworkForMAC(ctx context.Context, ipNet *net.IPNet, ifaceName string,
 	return "", false
 }
 
+// DiscoverSubnet is not supported on Windows - discovery scans only list the ARP table
+func DiscoverSubnet(ctx context.Context, ipNet *net.IPNet, ifaceName string, found func(ip net.IP, hwAddr net.HardwareAddr) bool) error {
+	return fmt.Errorf("active ARP scanning is not supported on Windows")
+}
+
 func getBroadcastIP(ipNet *net.IPNet) net.IP {
 	ip := ipNet.IP.To4()
 	mask := ipNet.Mask
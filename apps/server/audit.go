@@ -0,0 +1,148 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AuditEntry is a single record of a security-relevant action
+type AuditEntry struct {
+	ID       int64     `json:"id"`
+	Time     time.Time `json:"time"`
+	UserID   *string   `json:"user_id"`
+	Actor    string    `json:"actor"` // Username, "anonymous", or a subsystem name such as "network"
+	Action   string    `json:"action"`
+	HostID   string    `json:"host_id,omitempty"`
+	HostName string    `json:"host_name,omitempty"`
+	Success  bool      `json:"success"`
+	Detail   string    `json:"detail,omitempty"`
+	RemoteIP string    `json:"remote_ip,omitempty"`
+}
+
+// Audit actions
+const (
+	AuditActionShutdown       = "shutdown"
+	AuditActionPowerConfigSet = "power_config_set"
+	AuditActionPowerConfigDel = "power_config_delete"
+	AuditActionAgentCommand   = "agent_command"
+	AuditActionWake           = "wake"
+	AuditActionSettingsChange = "settings_change"
+)
+
+// AuditActorNetwork is the actor of events triggered by traffic seen on the network
+const AuditActorNetwork = "network"
+
+// recordAudit stores an audit entry. Failures are logged but never fail the calling request.
+func (s *Server) recordAudit(entry AuditEntry) {
+	_, err := s.DB.Exec("INSERT INTO audit_log (time, user_id, actor, action, host_id, host_name, success, detail, remote_ip) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		time.Now(), entry.UserID, entry.Actor, entry.Action, entry.HostID, entry.HostName, entry.Success, entry.Detail, entry.RemoteIP)
+	if err != nil {
+		Error("Failed to write audit entry (action: %s, host: %s): %v", entry.Action, entry.HostID, err)
+	}
+
+	Info("AUDIT actor=%s action=%s host=%s success=%v remote_ip=%s detail=%q",
+		entry.Actor, entry.Action, entry.HostName, entry.Success, entry.RemoteIP, entry.Detail)
+}
+
+// newAuditEntry creates an audit entry pre-filled with the acting user and client address
+func (s *Server) newAuditEntry(r *http.Request, action string, host Host) AuditEntry {
+	entry := AuditEntry{
+		Actor:    "anonymous",
+		Action:   action,
+		HostID:   host.ID,
+		HostName: host.Name,
+		RemoteIP: s.clientIP(r),
+	}
+	if user := GetUserFromContext(r); user != nil {
+		entry.UserID = &user.ID
+		entry.Actor = user.Name
+	}
+	return entry
+}
+
+// clientIP returns the client address of a request. When running behind a reverse proxy,
+// the first address of X-Forwarded-For is trusted; otherwise the socket address is used.
+func (s *Server) clientIP(r *http.Request) string {
+	if s.Config.BehindProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// cleanupAuditLog removes audit entries older than the retention period
+func (s *Server) cleanupAuditLog() error {
+	_, err := s.DB.Exec("DELETE FROM audit_log WHERE time < ?", time.Now().AddDate(0, 0, -AuditLogRetentionDays))
+	return err
+}
+
+// handleAuditLog returns recent audit entries (superuser only when auth is enabled)
+//
+// Query parameters:
+//   - limit: maximum number of entries (default 100, max 1000)
+//   - host_id: only entries for this host
+func (s *Server) handleAuditLog(w http.ResponseWriter, r *http.Request) {
+	if s.Config.UseAuth {
+		if _, ok := s.checkSuperuser(w, r); !ok {
+			return
+		}
+	}
+
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 || parsed > 1000 {
+			sendJSONErrorWithCode(w, "limit must be between 1-1000", ErrCodeInvalidInput, http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	query := "SELECT id, time, user_id, actor, action, host_id, host_name, success, detail, remote_ip FROM audit_log"
+	var args []interface{}
+	if hostID := r.URL.Query().Get("host_id"); hostID != "" {
+		query += " WHERE host_id = ?"
+		args = append(args, hostID)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		sendJSONError(w, "Failed to fetch audit log", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var hostID, hostName, detail, remoteIP *string
+		if err := rows.Scan(&entry.ID, &entry.Time, &entry.UserID, &entry.Actor, &entry.Action, &hostID, &hostName, &entry.Success, &detail, &remoteIP); err != nil {
+			continue
+		}
+		if hostID != nil {
+			entry.HostID = *hostID
+		}
+		if hostName != nil {
+			entry.HostName = *hostName
+		}
+		if detail != nil {
+			entry.Detail = *detail
+		}
+		if remoteIP != nil {
+			entry.RemoteIP = *remoteIP
+		}
+		entries = append(entries, entry)
+	}
+
+	sendJSON(w, entries, http.StatusOK)
+}
@@ -25,15 +25,16 @@ type LoginRequest struct {
 func (s *Server) createSession(userID string) (*Session, error) {
 	sessionID, err := generateSecureID()
 	if err != nil {
-		Error("Failed to generate session ID: %v", err)
+		authLog.Error("Failed to generate session ID: %v", err)
 		return nil, fmt.Errorf("failed to create session: %w", err)
 	}
 
-	expires := time.Now().Add(time.Duration(s.Config.AuthExpireHours * float64(time.Hour)))
+	expireHours := s.live().AuthExpireHours
+	expires := time.Now().Add(time.Duration(expireHours * float64(time.Hour)))
 
-	Debug("Creating session: AuthExpireHours=%.4f, Duration=%v, Expires at %v",
-		s.Config.AuthExpireHours,
-		time.Duration(s.Config.AuthExpireHours*float64(time.Hour)),
+	authLog.Debug("Creating session: AuthExpireHours=%.4f, Duration=%v, Expires at %v",
+		expireHours,
+		time.Duration(expireHours*float64(time.Hour)),
 		expires)
 
 	session := &Session{
@@ -47,7 +48,7 @@ func (s *Server) createSession(userID string) (*Session, error) {
 		session.ID, session.UserID, session.Expires, session.Created)
 
 	if err != nil {
-		Error("Failed to insert session into database: %v", err)
+		authLog.Error("Failed to insert session into database: %v", err)
 		return nil, fmt.Errorf("failed to save session: %w", err)
 	}
 
@@ -70,7 +71,7 @@ func (s *Server) getSession(sessionID string) (*Session, error) {
 		sessionID, now).Scan(&session.ID, &session.UserID, &session.Expires, &session.Created)
 
 	if err != nil {
-		Debug("Session lookup failed for ID=%s, now=%v, error=%v", sessionID, now, err)
+		authLog.Debug("Session lookup failed for ID=%s, now=%v, error=%v", sessionID, now, err)
 		return nil, err
 	}
 
@@ -6,10 +6,13 @@ import (
 	"net"
 	"os"
 	"strconv"
+	"strings"
 )
 
 type Config struct {
-	ListenAddress           string  `json:"listen_address"`             // Combined address:port (e.g., "0.0.0.0:8090", ":8090", "127.0.0.1:3000")
+	ListenAddress           string  `json:"listen_address"`             // Combined address:port (e.g., "0.0.0.0:8090", ":8090", "127.0.0.1:3000"), "unix:/path" or a comma-separated list
+	ListenSocketMode        string  `json:"listen_socket_mode"`         // Permissions of unix sockets, octal (default: "0660")
+	ListenSocketGroup       string  `json:"listen_socket_group"`        // Group owning unix sockets, e.g. "www-data" (empty = the server's group)
 	URLPrefix               string  `json:"url_prefix"`                 // URL prefix for reverse proxy
 	DefaultNetworkInterface string  `json:"default_network_interface"`  // Default network interfaces when per-host disabled (Linux only)
 	EnablePerHostInterfaces bool    `json:"enable_per_host_interfaces"` // Allow hosts to specify own interfaces (Linux only)
@@ -20,29 +23,90 @@ type Config struct {
 	BehindProxy             bool    `json:"behind_proxy"`               // Running behind HTTPS reverse proxy
 	Debug                   bool    `json:"debug"`                      // Enable debug logging (deprecated, use log_level instead)
 	HealthCheckEnabled      bool    `json:"health_check_enabled"`       // Enable health check endpoint
+	EnableRemoteShutdown    bool    `json:"enable_remote_shutdown"`     // Allow per-host shutdown/sleep commands over SSH
+	SecretKeyFile           string  `json:"secret_key_file"`            // Master key for secrets stored in the database (default: wol.key next to the database)
+	AgentToken              string  `json:"agent_token"`                // Shared token for companion agents (empty = agent endpoints disabled)
+	RelayToken              string  `json:"relay_token"`                // Shared token for relay nodes (empty = relay endpoint disabled)
+	MagicPacketListen       string  `json:"magic_packet_listen"`        // UDP addresses to receive magic packets on, comma-separated (e.g. ":7,:9")
+	MagicPacketForward      bool    `json:"magic_packet_forward"`       // Re-send received magic packets via the matching host's interface/broadcast or relay
+	OUIDatabase             string  `json:"oui_database"`               // IEEE registry file (oui.csv/oui.txt) loaded on top of the bundled vendor list
+	MetricsToken            string  `json:"metrics_token"`              // Bearer token for the Prometheus /metrics endpoint (empty = endpoint disabled)
+	TracingEndpoint         string  `json:"tracing_endpoint"`           // OTLP/HTTP collector URL for OpenTelemetry traces (empty = tracing disabled)
+	TracingSampleRatio      float64 `json:"tracing_sample_ratio"`       // Fraction of new traces to record, 0-1 (default: 1)
+	ShutdownTimeout         int     `json:"shutdown_timeout_seconds"`   // Seconds to wait for in-flight requests on SIGTERM (default: 15)
+	// Requests per minute per user (0 in the config file = default)
+	PingRateLimit     int `json:"ping_rate_limit_per_minute"`     // Pings (default: 10)
+	WakeRateLimit     int `json:"wake_rate_limit_per_minute"`     // Wake-on-LAN requests (default: 5)
+	ShutdownRateLimit int `json:"shutdown_rate_limit_per_minute"` // Remote shutdown/sleep requests (default: 3)
+	// Native HTTPS (empty cert/key and tls_self_signed false = plain HTTP)
+	TLSCertFile        string `json:"tls_cert_file"`        // PEM certificate (chain), reloaded when the file changes
+	TLSKeyFile         string `json:"tls_key_file"`         // PEM private key
+	TLSSelfSigned      bool   `json:"tls_self_signed"`      // Generate a self-signed certificate if the files do not exist
+	TLSRedirectAddress string `json:"tls_redirect_address"` // Plain HTTP address redirecting to HTTPS, e.g. ":80" (empty = disabled)
+	TLSClientCAFile    string `json:"tls_client_ca_file"`   // CA bundle; API requests then require a client certificate it signed (mTLS)
+	ACMEDomains        string `json:"acme_domains"`         // Hostnames to obtain certificates for via ACME, comma-separated (empty = disabled)
+	ACMEEmail          string `json:"acme_email"`           // Contact address for the ACME account (expiry and revocation notices)
+	ACMEDirectoryURL   string `json:"acme_directory_url"`   // ACME directory (default: Let's Encrypt production)
+	ACMECAFile         string `json:"acme_ca_file"`         // CA bundle trusted for the ACME directory (private or test CAs; default: system roots)
 	// Logging configuration
 	LogLevel      string `json:"log_level"`        // Log level: "debug", "info", "warning", "error" (default: "info")
-	LogOutputMode string `json:"log_output_mode"`  // Log output: "stdout", "file", "both" (default: "stdout")
+	LogOutputMode string `json:"log_output_mode"`  // Log output: "stdout", "file", "both", "syslog", "journald" or a comma-separated list (default: "stdout")
 	LogDir        string `json:"log_dir"`          // Directory for log files when output_mode is "file" or "both"
 	LogMaxSizeMB  int    `json:"log_max_size_mb"`  // Max log file size in MB before rotation (0 = no limit, default: 100)
 	LogMaxAgeDays int    `json:"log_max_age_days"` // Max days to keep old log files (0 = keep all, default: 30)
 	LogRotation   bool   `json:"log_rotation"`     // Enable log rotation (default: true)
+	LogFormat     string `json:"log_format"`       // Log line format: "text" or "json" (default: "text")
+	// Syslog output: "udp://host:514", "tcp://host:601" or "unix:///dev/log" (default), and facility (default: "daemon")
+	LogSyslogAddress  string `json:"log_syslog_address"`
+	LogSyslogFacility string `json:"log_syslog_facility"`
+	LogJournaldSocket string `json:"log_journald_socket"` // Journal socket for journald output (default: /run/systemd/journal/socket)
+	// Per-component log levels overriding log_level, e.g. {"network": "debug", "auth": "warning"}
+	LogLevels map[string]string `json:"log_levels"`
+	// Wake-on-demand TCP proxies (config file only)
+	WakeProxies []WakeProxyConfig `json:"wake_proxies"`
+	// Waking HTTP reverse proxies under {url_prefix}/proxy/{name}/ (config file only)
+	WebProxies []WebProxyConfig `json:"web_proxies"`
+	// DHCP lease/reservation files imported at startup and watched for changes (config file only)
+	DHCPImports []DHCPImportConfig `json:"dhcp_imports"`
+	// Relay nodes with their own token, bound to the relay name (config file only)
+	Relays []RelayConfig `json:"relays"`
+
+	// Agents with their own token, bound to the MAC addresses of their host (config file only)
+	Agents []AgentConfig `json:"agents"`
 }
 
-
+// loadConfig reads and validates the configuration, exiting on errors
 func loadConfig(configPath string) *Config {
+	config, err := readConfig(configPath)
+	if err != nil {
+		Fatal("%v", err)
+	}
+	return config
+}
+
+// readConfig builds the configuration from defaults, the config file and environment
+// variables and validates it. Also used to reload the file while the server runs.
+func readConfig(configPath string) (*Config, error) {
 	config := &Config{
 		ListenAddress:           ":8090", // Default listen on all interfaces, port 8090
+		ListenSocketMode:        DefaultListenSocketMode,
 		URLPrefix:               "",
 		DefaultNetworkInterface: "",
 		EnablePerHostInterfaces: false,
 		PingTimeout:             5,
+		ShutdownTimeout:         DefaultShutdownTimeoutSeconds,
+		PingRateLimit:           PingRateLimitPerMinute,
+		WakeRateLimit:           WoLRateLimitPerMinute,
+		ShutdownRateLimit:       ShutdownRateLimitPerMinute,
 		AuthExpireHours:         4,
 		UseAuth:                 true,
 		ReadOnlyMode:            false,
 		BehindProxy:             false,
 		Debug:                   false,
 		HealthCheckEnabled:      true,
+		EnableRemoteShutdown:    false,
+		TracingSampleRatio:      DefaultTracingSampleRatio,
+		ACMEDirectoryURL:        DefaultACMEDirectoryURL,
 		// Logging defaults - stdout for development, file for production/systemd
 		LogLevel:      "info",
 		LogOutputMode: "stdout", // Can be: "stdout", "file", or "both"
@@ -50,6 +114,7 @@ func loadConfig(configPath string) *Config {
 		LogMaxSizeMB:  100,
 		LogMaxAgeDays: 30,
 		LogRotation:   true,
+		LogFormat:     LogFormatText,
 	}
 
 	// Use provided config path or default to config.json
@@ -61,13 +126,40 @@ func loadConfig(configPath string) *Config {
 	if configFile, err := os.ReadFile(configPath); err == nil {
 		tempConfig := &Config{}
 		if err := json.Unmarshal(configFile, tempConfig); err != nil {
-			Fatal("Failed to parse config file %s: %v", configPath, err)
+			return nil, fmt.Errorf("Failed to parse config file %s: %v", configPath, err)
 		}
 		config.ListenAddress = tempConfig.ListenAddress
+		if tempConfig.ListenSocketMode != "" {
+			config.ListenSocketMode = tempConfig.ListenSocketMode
+		}
+		config.ListenSocketGroup = tempConfig.ListenSocketGroup
 		config.URLPrefix = tempConfig.URLPrefix
 		config.DefaultNetworkInterface = tempConfig.DefaultNetworkInterface
 		config.EnablePerHostInterfaces = tempConfig.EnablePerHostInterfaces
 		config.PingTimeout = tempConfig.PingTimeout
+		if tempConfig.ShutdownTimeout > 0 {
+			config.ShutdownTimeout = tempConfig.ShutdownTimeout
+		}
+		if tempConfig.PingRateLimit > 0 {
+			config.PingRateLimit = tempConfig.PingRateLimit
+		}
+		if tempConfig.WakeRateLimit > 0 {
+			config.WakeRateLimit = tempConfig.WakeRateLimit
+		}
+		if tempConfig.ShutdownRateLimit > 0 {
+			config.ShutdownRateLimit = tempConfig.ShutdownRateLimit
+		}
+		config.TLSCertFile = tempConfig.TLSCertFile
+		config.TLSKeyFile = tempConfig.TLSKeyFile
+		config.TLSSelfSigned = tempConfig.TLSSelfSigned
+		config.TLSRedirectAddress = tempConfig.TLSRedirectAddress
+		config.TLSClientCAFile = tempConfig.TLSClientCAFile
+		config.ACMEDomains = tempConfig.ACMEDomains
+		config.ACMEEmail = tempConfig.ACMEEmail
+		if tempConfig.ACMEDirectoryURL != "" {
+			config.ACMEDirectoryURL = tempConfig.ACMEDirectoryURL
+		}
+		config.ACMECAFile = tempConfig.ACMECAFile
 		if tempConfig.AuthExpireHours > 0 {
 			config.AuthExpireHours = tempConfig.AuthExpireHours
 		}
@@ -76,6 +168,23 @@ func loadConfig(configPath string) *Config {
 		config.BehindProxy = tempConfig.BehindProxy
 		config.Debug = tempConfig.Debug
 		config.HealthCheckEnabled = tempConfig.HealthCheckEnabled
+		config.EnableRemoteShutdown = tempConfig.EnableRemoteShutdown
+		config.SecretKeyFile = tempConfig.SecretKeyFile
+		config.AgentToken = tempConfig.AgentToken
+		config.RelayToken = tempConfig.RelayToken
+		config.MagicPacketListen = tempConfig.MagicPacketListen
+		config.MagicPacketForward = tempConfig.MagicPacketForward
+		config.OUIDatabase = tempConfig.OUIDatabase
+		config.MetricsToken = tempConfig.MetricsToken
+		config.TracingEndpoint = tempConfig.TracingEndpoint
+		if tempConfig.TracingSampleRatio > 0 {
+			config.TracingSampleRatio = tempConfig.TracingSampleRatio
+		}
+		config.WakeProxies = tempConfig.WakeProxies
+		config.DHCPImports = tempConfig.DHCPImports
+		config.WebProxies = tempConfig.WebProxies
+		config.Relays = tempConfig.Relays
+		config.Agents = tempConfig.Agents
 		// Load logging configuration
 		if tempConfig.LogLevel != "" {
 			config.LogLevel = tempConfig.LogLevel
@@ -93,9 +202,16 @@ func loadConfig(configPath string) *Config {
 			config.LogMaxAgeDays = tempConfig.LogMaxAgeDays
 		}
 		config.LogRotation = tempConfig.LogRotation
+		if tempConfig.LogFormat != "" {
+			config.LogFormat = tempConfig.LogFormat
+		}
+		config.LogLevels = tempConfig.LogLevels
+		config.LogSyslogAddress = tempConfig.LogSyslogAddress
+		config.LogSyslogFacility = tempConfig.LogSyslogFacility
+		config.LogJournaldSocket = tempConfig.LogJournaldSocket
 		Info("Loaded configuration from: %s", configPath)
 	} else if !os.IsNotExist(err) {
-		Fatal("Failed to read config file %s: %v", configPath, err)
+		return nil, fmt.Errorf("Failed to read config file %s: %v", configPath, err)
 	} else {
 		Info("Config file not found at: %s, using defaults", configPath)
 	}
@@ -105,6 +221,14 @@ func loadConfig(configPath string) *Config {
 		config.ListenAddress = listenAddr
 	}
 
+	if socketMode := os.Getenv("LISTEN_SOCKET_MODE"); socketMode != "" {
+		config.ListenSocketMode = socketMode
+	}
+
+	if socketGroup := os.Getenv("LISTEN_SOCKET_GROUP"); socketGroup != "" {
+		config.ListenSocketGroup = socketGroup
+	}
+
 	if urlPrefix := os.Getenv("URL_PREFIX"); urlPrefix != "" {
 		config.URLPrefix = urlPrefix
 	}
@@ -125,6 +249,76 @@ func loadConfig(configPath string) *Config {
 		}
 	}
 
+	if shutdownTimeout := os.Getenv("SHUTDOWN_TIMEOUT_SECONDS"); shutdownTimeout != "" {
+		if timeout, err := strconv.Atoi(shutdownTimeout); err == nil {
+			config.ShutdownTimeout = timeout
+		} else {
+			Warning("Invalid SHUTDOWN_TIMEOUT_SECONDS value '%s', using default: %d", shutdownTimeout, config.ShutdownTimeout)
+		}
+	}
+
+	// Rate limit environment variables
+	if pingLimit := os.Getenv("PING_RATE_LIMIT_PER_MINUTE"); pingLimit != "" {
+		if limit, err := strconv.Atoi(pingLimit); err == nil {
+			config.PingRateLimit = limit
+		} else {
+			Warning("Invalid PING_RATE_LIMIT_PER_MINUTE value '%s', using default: %d", pingLimit, config.PingRateLimit)
+		}
+	}
+
+	if wakeLimit := os.Getenv("WAKE_RATE_LIMIT_PER_MINUTE"); wakeLimit != "" {
+		if limit, err := strconv.Atoi(wakeLimit); err == nil {
+			config.WakeRateLimit = limit
+		} else {
+			Warning("Invalid WAKE_RATE_LIMIT_PER_MINUTE value '%s', using default: %d", wakeLimit, config.WakeRateLimit)
+		}
+	}
+
+	if shutdownLimit := os.Getenv("SHUTDOWN_RATE_LIMIT_PER_MINUTE"); shutdownLimit != "" {
+		if limit, err := strconv.Atoi(shutdownLimit); err == nil {
+			config.ShutdownRateLimit = limit
+		} else {
+			Warning("Invalid SHUTDOWN_RATE_LIMIT_PER_MINUTE value '%s', using default: %d", shutdownLimit, config.ShutdownRateLimit)
+		}
+	}
+
+	// TLS environment variables
+	if certFile := os.Getenv("TLS_CERT_FILE"); certFile != "" {
+		config.TLSCertFile = certFile
+	}
+
+	if keyFile := os.Getenv("TLS_KEY_FILE"); keyFile != "" {
+		config.TLSKeyFile = keyFile
+	}
+
+	if selfSigned := os.Getenv("TLS_SELF_SIGNED"); selfSigned != "" {
+		config.TLSSelfSigned = selfSigned == "true" || selfSigned == "1"
+	}
+
+	if redirectAddress := os.Getenv("TLS_REDIRECT_ADDRESS"); redirectAddress != "" {
+		config.TLSRedirectAddress = redirectAddress
+	}
+
+	if clientCAFile := os.Getenv("TLS_CLIENT_CA_FILE"); clientCAFile != "" {
+		config.TLSClientCAFile = clientCAFile
+	}
+
+	if acmeDomains := os.Getenv("ACME_DOMAINS"); acmeDomains != "" {
+		config.ACMEDomains = acmeDomains
+	}
+
+	if acmeEmail := os.Getenv("ACME_EMAIL"); acmeEmail != "" {
+		config.ACMEEmail = acmeEmail
+	}
+
+	if directoryURL := os.Getenv("ACME_DIRECTORY_URL"); directoryURL != "" {
+		config.ACMEDirectoryURL = directoryURL
+	}
+
+	if acmeCAFile := os.Getenv("ACME_CA_FILE"); acmeCAFile != "" {
+		config.ACMECAFile = acmeCAFile
+	}
+
 	if authExpire := os.Getenv("AUTH_EXPIRE_HOURS"); authExpire != "" {
 		if hours, err := strconv.ParseFloat(authExpire, 64); err == nil {
 			config.AuthExpireHours = hours
@@ -153,6 +347,50 @@ func loadConfig(configPath string) *Config {
 		config.HealthCheckEnabled = healthCheck == "true" || healthCheck == "1"
 	}
 
+	if remoteShutdown := os.Getenv("ENABLE_REMOTE_SHUTDOWN"); remoteShutdown != "" {
+		config.EnableRemoteShutdown = remoteShutdown == "true" || remoteShutdown == "1"
+	}
+
+	if secretKeyFile := os.Getenv("SECRET_KEY_FILE"); secretKeyFile != "" {
+		config.SecretKeyFile = secretKeyFile
+	}
+
+	if agentToken := os.Getenv("AGENT_TOKEN"); agentToken != "" {
+		config.AgentToken = agentToken
+	}
+
+	if relayToken := os.Getenv("RELAY_TOKEN"); relayToken != "" {
+		config.RelayToken = relayToken
+	}
+
+	if magicListen := os.Getenv("MAGIC_PACKET_LISTEN"); magicListen != "" {
+		config.MagicPacketListen = magicListen
+	}
+
+	if magicForward := os.Getenv("MAGIC_PACKET_FORWARD"); magicForward != "" {
+		config.MagicPacketForward = magicForward == "true" || magicForward == "1"
+	}
+
+	if ouiDatabase := os.Getenv("OUI_DATABASE"); ouiDatabase != "" {
+		config.OUIDatabase = ouiDatabase
+	}
+
+	if metricsToken := os.Getenv("METRICS_TOKEN"); metricsToken != "" {
+		config.MetricsToken = metricsToken
+	}
+
+	if tracingEndpoint := os.Getenv("TRACING_ENDPOINT"); tracingEndpoint != "" {
+		config.TracingEndpoint = tracingEndpoint
+	}
+
+	if sampleRatio := os.Getenv("TRACING_SAMPLE_RATIO"); sampleRatio != "" {
+		if ratio, err := strconv.ParseFloat(sampleRatio, 64); err == nil {
+			config.TracingSampleRatio = ratio
+		} else {
+			Warning("Invalid TRACING_SAMPLE_RATIO value '%s', using default: %.2f", sampleRatio, config.TracingSampleRatio)
+		}
+	}
+
 	// Logging environment variables
 	if logLevel := os.Getenv("LOG_LEVEL"); logLevel != "" {
 		config.LogLevel = logLevel
@@ -186,6 +424,35 @@ func loadConfig(configPath string) *Config {
 		config.LogRotation = logRotation == "true" || logRotation == "1"
 	}
 
+	if logFormat := os.Getenv("LOG_FORMAT"); logFormat != "" {
+		config.LogFormat = logFormat
+	}
+
+	if syslogAddress := os.Getenv("LOG_SYSLOG_ADDRESS"); syslogAddress != "" {
+		config.LogSyslogAddress = syslogAddress
+	}
+
+	if syslogFacility := os.Getenv("LOG_SYSLOG_FACILITY"); syslogFacility != "" {
+		config.LogSyslogFacility = syslogFacility
+	}
+
+	if journaldSocket := os.Getenv("LOG_JOURNALD_SOCKET"); journaldSocket != "" {
+		config.LogJournaldSocket = journaldSocket
+	}
+
+	// LOG_LEVELS=network=debug,auth=warning
+	if logLevels := os.Getenv("LOG_LEVELS"); logLevels != "" {
+		config.LogLevels = make(map[string]string)
+		for _, entry := range strings.Split(logLevels, ",") {
+			component, level, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			if !ok {
+				Warning("Invalid LOG_LEVELS entry '%s', expected component=level", entry)
+				continue
+			}
+			config.LogLevels[strings.TrimSpace(component)] = strings.TrimSpace(level)
+		}
+	}
+
 	// Handle legacy Debug flag - if Debug is true, set LogLevel to debug
 	if config.Debug {
 		config.LogLevel = "debug"
@@ -193,47 +460,75 @@ func loadConfig(configPath string) *Config {
 
 	// Validate configuration
 	if err := validateConfig(config); err != nil {
-		Fatal("Invalid configuration: %v", err)
+		return nil, fmt.Errorf("Invalid configuration: %v", err)
 	}
 
-	return config
+	return config, nil
 }
 
 // validateConfig validates the configuration values
 func validateConfig(c *Config) error {
-	// Validate listen_address format
-	if c.ListenAddress == "" {
+	// Validate listen_address entries
+	addresses := listenAddresses(c.ListenAddress)
+	if len(addresses) == 0 {
 		return fmt.Errorf("listen_address cannot be empty")
 	}
+	listenSet := make(map[string]bool)
+	for _, address := range addresses {
+		if err := validateListenAddress(address); err != nil {
+			return err
+		}
+		if listenSet[address] {
+			return fmt.Errorf("duplicate listen_address '%s'", address)
+		}
+		listenSet[address] = true
+	}
+	if _, err := strconv.ParseUint(c.ListenSocketMode, 8, 32); err != nil {
+		return fmt.Errorf("invalid listen_socket_mode '%s': expected an octal mode such as 0660", c.ListenSocketMode)
+	}
 
-	// Parse and validate the address format
-	host, port, err := net.SplitHostPort(c.ListenAddress)
-	if err != nil {
-		return fmt.Errorf("invalid listen_address format '%s': %v (expected format: 'addr:port' or ':port')", c.ListenAddress, err)
+	if c.AuthExpireHours <= 0 {
+		return fmt.Errorf("auth_expire_hours must be greater than 0, got: %.2f", c.AuthExpireHours)
 	}
 
-	// Validate port
-	portNum, err := strconv.Atoi(port)
-	if err != nil || portNum < 1 || portNum > 65535 {
-		return fmt.Errorf("invalid port in listen_address '%s': must be 1-65535", c.ListenAddress)
+	if c.PingTimeout < 1 || c.PingTimeout > 60 {
+		return fmt.Errorf("ping_timeout_seconds must be between 1-60, got: %d", c.PingTimeout)
 	}
 
-	// Validate host if provided (empty is valid, means all interfaces)
-	if host != "" {
-		// Try to parse as IP
-		if ip := net.ParseIP(host); ip == nil {
-			// If not a valid IP, check if it's a valid hostname/interface
-			// We allow it to pass through - net.Listen will catch invalid addresses
-			Warning("listen_address host '%s' is not a valid IP address. Will attempt to bind anyway.", host)
+	for name, limit := range map[string]int{
+		"ping_rate_limit_per_minute":     c.PingRateLimit,
+		"wake_rate_limit_per_minute":     c.WakeRateLimit,
+		"shutdown_rate_limit_per_minute": c.ShutdownRateLimit,
+	} {
+		if limit < 1 || limit > MaxRateLimitPerMinute {
+			return fmt.Errorf("%s must be between 1-%d, got: %d", name, MaxRateLimitPerMinute, limit)
 		}
 	}
 
-	if c.AuthExpireHours <= 0 {
-		return fmt.Errorf("auth_expire_hours must be greater than 0, got: %.2f", c.AuthExpireHours)
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("tls_cert_file and tls_key_file must be set together")
+	}
+	if !c.tlsEnabled() && (c.TLSRedirectAddress != "" || c.TLSClientCAFile != "") {
+		return fmt.Errorf("tls_redirect_address and tls_client_ca_file require tls_cert_file/tls_key_file, tls_self_signed or acme_domains")
+	}
+	if c.ACMEDomains != "" {
+		if err := validateACMEConfig(c); err != nil {
+			return err
+		}
+	} else if c.ACMECAFile != "" {
+		return fmt.Errorf("acme_ca_file requires acme_domains")
+	}
+	if c.TLSRedirectAddress != "" {
+		if _, _, err := net.SplitHostPort(c.TLSRedirectAddress); err != nil {
+			return fmt.Errorf("invalid tls_redirect_address '%s': %w", c.TLSRedirectAddress, err)
+		}
+		if listenSet[c.TLSRedirectAddress] {
+			return fmt.Errorf("tls_redirect_address must differ from listen_address")
+		}
 	}
 
-	if c.PingTimeout < 1 || c.PingTimeout > 60 {
-		return fmt.Errorf("ping_timeout_seconds must be between 1-60, got: %d", c.PingTimeout)
+	if c.ShutdownTimeout < 1 || c.ShutdownTimeout > MaxShutdownTimeoutSeconds {
+		return fmt.Errorf("shutdown_timeout_seconds must be between 1-%d, got: %d", MaxShutdownTimeoutSeconds, c.ShutdownTimeout)
 	}
 
 	// Validate log level
@@ -247,19 +542,48 @@ func validateConfig(c *Config) error {
 		return fmt.Errorf("invalid log_level '%s': must be one of: debug, info, warning, error", c.LogLevel)
 	}
 
+	// Validate per-component log levels
+	for component, level := range c.LogLevels {
+		switch component {
+		case LogComponentNetwork, LogComponentAuth, LogComponentHTTP:
+		default:
+			return fmt.Errorf("invalid log_levels component '%s': must be one of: %s, %s, %s",
+				component, LogComponentNetwork, LogComponentAuth, LogComponentHTTP)
+		}
+		if !validLogLevels[level] {
+			return fmt.Errorf("invalid log_levels level '%s' for %s: must be one of: debug, info, warning, error", level, component)
+		}
+	}
+
+	if c.LogFormat != LogFormatText && c.LogFormat != LogFormatJSON {
+		return fmt.Errorf("invalid log_format '%s': must be one of: %s, %s", c.LogFormat, LogFormatText, LogFormatJSON)
+	}
+
 	// Validate log output mode
 	validOutputModes := map[string]bool{
-		"stdout": true,
-		"file":   true,
-		"both":   true,
-	}
-	if !validOutputModes[c.LogOutputMode] {
-		return fmt.Errorf("invalid log_output_mode '%s': must be one of: stdout, file, both", c.LogOutputMode)
+		"stdout":   true,
+		"file":     true,
+		"syslog":   true,
+		"journald": true,
+	}
+	for _, mode := range logOutputModes(c.LogOutputMode) {
+		if !validOutputModes[mode] {
+			return fmt.Errorf("invalid log_output_mode '%s': must be one of: stdout, file, both, syslog, journald (or a comma-separated list)", c.LogOutputMode)
+		}
+
+		// Validate log directory when needed
+		if mode == "file" && c.LogDir == "" {
+			return fmt.Errorf("log_dir must be specified when log_output_mode is '%s'", c.LogOutputMode)
+		}
 	}
 
-	// Validate log directory when needed
-	if (c.LogOutputMode == "file" || c.LogOutputMode == "both") && c.LogDir == "" {
-		return fmt.Errorf("log_dir must be specified when log_output_mode is '%s'", c.LogOutputMode)
+	if c.LogSyslogAddress != "" {
+		if _, _, err := parseSyslogAddress(c.LogSyslogAddress); err != nil {
+			return fmt.Errorf("invalid log_syslog_address: %w", err)
+		}
+	}
+	if _, ok := syslogFacilities[c.LogSyslogFacility]; c.LogSyslogFacility != "" && !ok {
+		return fmt.Errorf("invalid log_syslog_facility '%s': must be one of: kern, user, mail, daemon, auth, syslog, lpr, news, uucp, cron, authpriv, ftp, local0-local7", c.LogSyslogFacility)
 	}
 
 	// Validate log rotation settings
@@ -271,6 +595,134 @@ func validateConfig(c *Config) error {
 		return fmt.Errorf("log_max_age_days must be >= 0, got: %d", c.LogMaxAgeDays)
 	}
 
+	if c.AgentToken != "" && len(c.AgentToken) < MinAgentTokenLength {
+		return fmt.Errorf("agent_token must be at least %d characters long", MinAgentTokenLength)
+	}
+
+	if c.RelayToken != "" && len(c.RelayToken) < MinAgentTokenLength {
+		return fmt.Errorf("relay_token must be at least %d characters long", MinAgentTokenLength)
+	}
+
+	if c.MetricsToken != "" && len(c.MetricsToken) < MinAgentTokenLength {
+		return fmt.Errorf("metrics_token must be at least %d characters long", MinAgentTokenLength)
+	}
+
+	if c.TracingEndpoint != "" {
+		if err := validateTracingEndpoint(c.TracingEndpoint); err != nil {
+			return err
+		}
+	}
+	if c.TracingSampleRatio <= 0 || c.TracingSampleRatio > 1 {
+		return fmt.Errorf("tracing_sample_ratio must be greater than 0 and at most 1")
+	}
+
+	// Validate magic packet listen addresses
+	magicListeners := make(map[string]bool)
+	for _, address := range strings.Split(c.MagicPacketListen, ",") {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+		_, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return fmt.Errorf("invalid magic_packet_listen address '%s': %v", address, err)
+		}
+		if portNum, err := strconv.Atoi(port); err != nil || portNum < 1 || portNum > 65535 {
+			return fmt.Errorf("invalid port in magic_packet_listen address '%s': must be 1-65535", address)
+		}
+		if magicListeners[address] {
+			return fmt.Errorf("duplicate magic_packet_listen address '%s'", address)
+		}
+		magicListeners[address] = true
+	}
+
+	// Validate OUI database file
+	if c.OUIDatabase != "" {
+		if info, err := os.Stat(c.OUIDatabase); err != nil {
+			return fmt.Errorf("oui_database: %v", err)
+		} else if info.IsDir() {
+			return fmt.Errorf("oui_database '%s' is a directory", c.OUIDatabase)
+		}
+	}
+
+	// Validate wake proxies
+	proxyListeners := make(map[string]bool)
+	for _, proxy := range c.WakeProxies {
+		if err := validateWakeProxyConfig(proxy); err != nil {
+			return fmt.Errorf("wake_proxies: %w", err)
+		}
+		if proxyListeners[proxy.Listen] || listenSet[proxy.Listen] {
+			return fmt.Errorf("wake_proxies: listen address '%s' is already in use", proxy.Listen)
+		}
+		proxyListeners[proxy.Listen] = true
+	}
+
+	// Validate web proxies
+	proxyNames := make(map[string]bool)
+	for _, proxy := range c.WebProxies {
+		if err := validateWebProxyConfig(proxy); err != nil {
+			return fmt.Errorf("web_proxies: %w", err)
+		}
+		if proxyNames[proxy.Name] {
+			return fmt.Errorf("web_proxies: duplicate name '%s'", proxy.Name)
+		}
+		proxyNames[proxy.Name] = true
+	}
+
+	// Validate relays with their own token
+	relayNames := make(map[string]bool)
+	relayTokens := make(map[string]bool)
+	for _, relay := range c.Relays {
+		if err := validateRelayConfig(relay); err != nil {
+			return fmt.Errorf("relays: %w", err)
+		}
+		if relayNames[relay.Name] {
+			return fmt.Errorf("relays: duplicate name '%s'", relay.Name)
+		}
+		if relayTokens[relay.Token] || relay.Token == c.RelayToken {
+			return fmt.Errorf("relays: the token of relay '%s' is not unique", relay.Name)
+		}
+		relayNames[relay.Name] = true
+		relayTokens[relay.Token] = true
+	}
+
+	// Validate agents with their own token
+	agentNames := make(map[string]bool)
+	agentTokens := make(map[string]bool)
+	agentMACs := make(map[string]string)
+	for i := range c.Agents {
+		agent := &c.Agents[i]
+		if err := validateAgentConfig(agent); err != nil {
+			return fmt.Errorf("agents: %w", err)
+		}
+		if agentNames[agent.Name] {
+			return fmt.Errorf("agents: duplicate name '%s'", agent.Name)
+		}
+		if agentTokens[agent.Token] || agent.Token == c.AgentToken {
+			return fmt.Errorf("agents: the token of agent '%s' is not unique", agent.Name)
+		}
+		for _, mac := range agent.MACs {
+			if other, exists := agentMACs[mac]; exists && other != agent.Name {
+				return fmt.Errorf("agents: MAC address %s is bound to both '%s' and '%s'", mac, other, agent.Name)
+			}
+			agentMACs[mac] = agent.Name
+		}
+		agentNames[agent.Name] = true
+		agentTokens[agent.Token] = true
+	}
+
+	// Validate DHCP imports
+	importPaths := make(map[string]bool)
+	for _, dhcpImport := range c.DHCPImports {
+		if err := validateDHCPImportConfig(dhcpImport, c.UseAuth); err != nil {
+			return fmt.Errorf("dhcp_imports: %w", err)
+		}
+		if importPaths[dhcpImport.Path] {
+			return fmt.Errorf("dhcp_imports: duplicate path '%s'", dhcpImport.Path)
+		}
+		importPaths[dhcpImport.Path] = true
+	}
+
 	return nil
 }
 
@@ -318,12 +770,17 @@ func createDefaultConfig(configPath string) error {
 		DefaultNetworkInterface: "",
 		EnablePerHostInterfaces: false,
 		PingTimeout:             5,
+		ShutdownTimeout:         DefaultShutdownTimeoutSeconds,
+		PingRateLimit:           PingRateLimitPerMinute,
+		WakeRateLimit:           WoLRateLimitPerMinute,
+		ShutdownRateLimit:       ShutdownRateLimitPerMinute,
 		AuthExpireHours:         4,
 		UseAuth:                 true,
 		ReadOnlyMode:            false,
 		BehindProxy:             false,
 		Debug:                   false,
 		HealthCheckEnabled:      true,
+		EnableRemoteShutdown:    false,
 		// Logging configuration
 		LogLevel:      "info",
 		LogOutputMode: "stdout",
@@ -331,6 +788,7 @@ func createDefaultConfig(configPath string) error {
 		LogMaxSizeMB:  100,
 		LogMaxAgeDays: 30,
 		LogRotation:   true,
+		LogFormat:     LogFormatText,
 	}
 
 	configData, err := json.MarshalIndent(config, "", "  ")
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// liveConfigFields are the settings (json names) applied on reload without a restart.
+// Code reads them through s.live(); other fields keep their startup values in s.Config.
+var liveConfigFields = map[string]bool{
+	"ping_timeout_seconds":           true,
+	"ping_rate_limit_per_minute":     true,
+	"wake_rate_limit_per_minute":     true,
+	"shutdown_rate_limit_per_minute": true,
+	"readonly_mode":                  true,
+	"default_network_interface":      true,
+	"auth_expire_hours":              true,
+	"log_level":                      true,
+	"log_levels":                     true,
+	"debug":                          true,
+}
+
+// RuntimeConfig holds the current values of the live settings
+type RuntimeConfig struct {
+	current atomic.Pointer[Config]
+
+	mutex    sync.Mutex // Serializes changes by reloads and /api/settings
+	base     *Config    // Config file and environment, without the database settings
+	database []string   // Settings currently taken from the database
+}
+
+// NewRuntimeConfig starts with the startup configuration
+func NewRuntimeConfig(config *Config) *RuntimeConfig {
+	r := &RuntimeConfig{base: config}
+	r.current.Store(config)
+	return r
+}
+
+// Current returns the configuration with the live settings in effect. Never modify it;
+// changes are stored as a new copy.
+func (r *RuntimeConfig) Current() *Config {
+	return r.current.Load()
+}
+
+// live returns the configuration to read live settings (liveConfigFields) from
+func (s *Server) live() *Config {
+	if s.Runtime == nil {
+		return s.Config // Relay mode and tools
+	}
+	return s.Runtime.Current()
+}
+
+// copyLiveFields copies the live settings from src to dst
+func copyLiveFields(dst, src *Config) {
+	dst.PingTimeout = src.PingTimeout
+	dst.PingRateLimit = src.PingRateLimit
+	dst.WakeRateLimit = src.WakeRateLimit
+	dst.ShutdownRateLimit = src.ShutdownRateLimit
+	dst.ReadOnlyMode = src.ReadOnlyMode
+	dst.DefaultNetworkInterface = src.DefaultNetworkInterface
+	dst.AuthExpireHours = src.AuthExpireHours
+	dst.LogLevel = src.LogLevel
+	dst.LogLevels = src.LogLevels
+	dst.Debug = src.Debug
+}
+
+// configLogLevels returns the logger levels for a configuration
+func configLogLevels(c *Config) (LogLevel, map[string]LogLevel) {
+	level, _ := ParseLogLevel(c.LogLevel)
+	componentLevels := make(map[string]LogLevel)
+	for component, name := range c.LogLevels {
+		componentLevels[component], _ = ParseLogLevel(name)
+	}
+	return level, componentLevels
+}
+
+// changedConfigFields returns the json names of the fields that differ
+func changedConfigFields(old, next *Config) []string {
+	var changed []string
+	oldValue, nextValue := reflect.ValueOf(old).Elem(), reflect.ValueOf(next).Elem()
+	for i := 0; i < oldValue.NumField(); i++ {
+		name, _, _ := strings.Cut(oldValue.Type().Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), nextValue.Field(i).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+// applyLiveConfig puts the live settings of next into effect
+func (s *Server) applyLiveConfig(next *Config) {
+	current := *s.live()
+	copyLiveFields(&current, next)
+	s.Runtime.current.Store(&current)
+
+	s.PingCache.SetTTL(time.Duration(current.PingTimeout*PingCacheTTLMultiplier) * time.Second)
+	s.PingRateLimit.SetLimit(current.PingRateLimit)
+	s.WoLRateLimit.SetLimit(current.WakeRateLimit)
+	s.ShutdownRateLimit.SetLimit(current.ShutdownRateLimit)
+	GetLogger().SetLevels(configLogLevels(&current))
+}
+
+// applyBaseConfig makes base the configuration the database settings apply to and puts
+// the result into effect. Invalid database settings are logged and skipped. Callers hold
+// s.Runtime.mutex. Returns the configuration that was applied.
+func (s *Server) applyBaseConfig(base *Config) *Config {
+	s.Runtime.base = base
+	effective, names, err := s.withStoredSettings(base, nil)
+	if err != nil {
+		Warning("Ignoring settings stored in the database: %v", err)
+		effective, names = base, nil
+	}
+	s.Runtime.database = names
+	s.applyLiveConfig(effective)
+	return effective
+}
+
+// watchConfig reloads the configuration file when it changes or on SIGHUP. loaded is the
+// configuration as read at startup, including the -debug override, which reloads keep.
+// Settings stored through /api/settings keep precedence over the file.
+func (s *Server) watchConfig(path string, loaded *Config, debugFlag bool) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	lastModified := configModTime(path)
+	ticker := time.NewTicker(ConfigReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			modified := configModTime(path)
+			if modified.IsZero() || modified.Equal(lastModified) {
+				continue
+			}
+			lastModified = modified
+			Info("Configuration file %s changed - reloading", path)
+		case <-hangup:
+			lastModified = configModTime(path)
+			Info("Received SIGHUP - reloading configuration from %s", path)
+		case <-s.stopping():
+			return
+		}
+
+		s.reloadConfig(path, loaded, debugFlag)
+	}
+}
+
+// configModTime returns the modification time of the configuration file (zero if missing)
+func configModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// reloadConfig re-reads and validates the configuration, applies the live settings and
+// reports changed settings that need a restart. An invalid file changes nothing.
+func (s *Server) reloadConfig(path string, loaded *Config, debugFlag bool) {
+	if _, err := os.Stat(path); err != nil {
+		Warning("Cannot reload configuration: %v", err)
+		return
+	}
+
+	sdNotify("RELOADING=1", "STATUS=Reloading configuration")
+	defer sdNotify("READY=1", "STATUS=Configuration reloaded")
+
+	next, err := readConfig(path)
+	if err != nil {
+		Error("Keeping the current configuration: %v", err)
+		return
+	}
+	if debugFlag {
+		next.Debug = true
+		next.LogLevel = "debug"
+	}
+
+	s.Runtime.mutex.Lock()
+	defer s.Runtime.mutex.Unlock()
+
+	previous := s.live()
+	effective := s.applyBaseConfig(next)
+
+	var applied []string
+	for _, field := range changedConfigFields(previous, effective) {
+		if liveConfigFields[field] {
+			applied = append(applied, field)
+		}
+	}
+	// Restart-only changes are compared with the startup values, so they are reported
+	// on every reload until the server is restarted
+	var restart []string
+	for _, field := range changedConfigFields(loaded, next) {
+		if !liveConfigFields[field] {
+			restart = append(restart, field)
+		}
+	}
+
+	if len(applied) > 0 {
+		Info("Configuration reloaded - applied: %s", strings.Join(applied, ", "))
+	} else {
+		Info("Configuration reloaded - no live settings changed")
+	}
+	if len(restart) > 0 {
+		Warning("Configuration changes that need a restart to take effect: %s", strings.Join(restart, ", "))
+	}
+}
@@ -4,12 +4,18 @@ import "time"
 
 // Rate limiting constants
 const (
-	// PingRateLimitPerMinute is the maximum number of ping requests per minute per user
+	// PingRateLimitPerMinute is the default maximum number of ping requests per minute per user
 	PingRateLimitPerMinute = 10
 
-	// WoLRateLimitPerMinute is the maximum number of Wake-on-LAN requests per minute per user
+	// WoLRateLimitPerMinute is the default maximum number of Wake-on-LAN requests per minute per user
 	WoLRateLimitPerMinute = 5
 
+	// ShutdownRateLimitPerMinute is the default maximum number of remote shutdown requests per minute per user
+	ShutdownRateLimitPerMinute = 3
+
+	// MaxRateLimitPerMinute is the largest accepted *_rate_limit_per_minute setting
+	MaxRateLimitPerMinute = 1000
+
 	// RateLimiterMaxKeys is the maximum number of keys to track in rate limiter to prevent unbounded memory growth
 	RateLimiterMaxKeys = 10000
 
@@ -34,6 +40,13 @@ const (
 	// ARPPingTimeoutSeconds is the timeout for ARP ping operations in seconds
 	ARPPingTimeoutSeconds = 2
 
+	// ARPRequestInterval is how long the arping library waits for the reply to one request.
+	// Its timeout is a package-global, so it is set once; longer ARP pings resend the request.
+	ARPRequestInterval = 100 * time.Millisecond
+
+	// ARPReplyTimeout is how long an ARP ping of a single address waits for a reply
+	ARPReplyTimeout = 500 * time.Millisecond
+
 	// PingCacheTTLMultiplier is multiplied by PingTimeout to determine cache TTL
 	// Cache TTL = PingTimeout * PingCacheTTLMultiplier seconds
 	// This ensures cached results are fresh relative to network conditions
@@ -61,3 +74,363 @@ const (
 	// MinSkeletonDisplayTime is the minimum time to display loading skeletons to prevent UI flicker
 	MinSkeletonDisplayTime = 150 * time.Millisecond
 )
+
+// Wake chain constants
+const (
+	// MaxWakeChainLength is the maximum number of hosts (including the target) in a single wake chain
+	MaxWakeChainLength = 32
+
+	// MaxHostDependencies is the maximum number of direct dependencies a host may declare
+	MaxHostDependencies = 16
+
+	// MaxWakeChainDelaySeconds is the maximum fixed delay allowed for a single dependency step
+	MaxWakeChainDelaySeconds = 600
+
+	// DefaultWakeChainTimeoutSeconds is how long to wait for a dependency to come online when no timeout is set
+	DefaultWakeChainTimeoutSeconds = 120
+
+	// MaxWakeChainTimeoutSeconds is the maximum "wait until online" timeout for a single dependency step
+	MaxWakeChainTimeoutSeconds = 600
+
+	// WakeChainPollInterval is how often a dependency is re-checked while waiting for it to come online
+	WakeChainPollInterval = 5 * time.Second
+
+	// WakeChainDeadlineMargin is added to the sum of a chain's waits for its background deadline
+	WakeChainDeadlineMargin = time.Minute
+
+	// WakeChainRetention is how long finished background wake chains can be queried
+	WakeChainRetention = 15 * time.Minute
+)
+
+// Remote shutdown constants
+const (
+	// SSHCommandTimeout is the maximum time for connecting and running a remote power command
+	SSHCommandTimeout = 15 * time.Second
+
+	// SSHMaxOutputBytes is the maximum amount of command output kept for responses and audit entries
+	SSHMaxOutputBytes = 4096
+
+	// DefaultShutdownCommand is used when a power action is configured without a command
+	DefaultShutdownCommand = "sudo systemctl poweroff"
+)
+
+// Audit log constants
+const (
+	// AuditLogRetentionDays is how long audit entries are kept
+	AuditLogRetentionDays = 90
+
+	// AuditLogCleanupInterval is how often old audit entries are removed
+	AuditLogCleanupInterval = 24 * time.Hour
+)
+
+// Service check constants
+const (
+	// MaxHostServices is the maximum number of service checks per host
+	MaxHostServices = 10
+
+	// DefaultServiceTimeoutSeconds is the timeout of a service check without its own timeout
+	DefaultServiceTimeoutSeconds = 3
+
+	// MaxServiceTimeoutSeconds is the longest allowed service check timeout
+	MaxServiceTimeoutSeconds = 5
+
+	// MaxServiceNameLength is the maximum length of a service check name
+	MaxServiceNameLength = 50
+
+	// MaxServiceExpectBodyLength is the maximum length of the expected response text
+	MaxServiceExpectBodyLength = 200
+
+	// MaxServiceResponseBytes is how much of an HTTP response body is searched for the expected text
+	MaxServiceResponseBytes = 64 * 1024
+)
+
+// Host address tracking constants
+const (
+	// MaxHostIPHistory is the number of IP history entries kept per host
+	MaxHostIPHistory = 50
+
+	// StaticIPSuggestionSightings is how many status checks must find a fallback host at the same
+	// new address before updating its static IP is suggested
+	StaticIPSuggestionSightings = 3
+
+	// HostAlertRetentionDays is how long dismissed host alerts are kept
+	HostAlertRetentionDays = 30
+
+	// MaxHostAlerts is the maximum number of alerts returned by the alert endpoints
+	MaxHostAlerts = 500
+)
+
+// Companion agent constants
+const (
+	// MinAgentTokenLength is the minimum length of the shared agent token
+	MinAgentTokenLength = 16
+
+	// AgentHeartbeatInterval is how often agents report their state
+	AgentHeartbeatInterval = 15 * time.Second
+
+	// AgentOfflineAfter is how long after the last heartbeat an agent is considered offline
+	AgentOfflineAfter = 3 * AgentHeartbeatInterval
+
+	// AgentCommandTTL is how long a queued command waits for delivery before it is dropped
+	AgentCommandTTL = 2 * time.Minute
+
+	// AgentStateRetention is how long the state of a silent agent is kept in memory
+	AgentStateRetention = 24 * time.Hour
+
+	// MaxAgentEntries limits the number of tracked agents to prevent memory exhaustion
+	MaxAgentEntries = 1024
+
+	// MaxAgentMACs is the maximum number of MAC addresses an agent may report
+	MaxAgentMACs = 32
+
+	// AgentRequestTimeout is the HTTP timeout used by the agent
+	AgentRequestTimeout = 10 * time.Second
+)
+
+// Relay constants
+const (
+	// RelayPollTimeout is how long a relay long-poll request waits for jobs before returning empty
+	RelayPollTimeout = 25 * time.Second
+
+	// RelayOfflineAfter is how long after its last poll a relay is considered offline
+	RelayOfflineAfter = RelayPollTimeout + 15*time.Second
+
+	// RelayRetryInterval is how long a relay waits before reconnecting after an error
+	RelayRetryInterval = 5 * time.Second
+
+	// RelayWakeTimeout is how long to wait for a relay to confirm a wake job
+	RelayWakeTimeout = 10 * time.Second
+
+	// RelayJobQueueSize is the number of jobs that can be queued per relay
+	RelayJobQueueSize = 64
+
+	// RelayMaxJobsPerPoll is the maximum number of jobs returned by a single poll
+	RelayMaxJobsPerPoll = 32
+
+	// MaxRelays limits the number of relays tracked by the server
+	MaxRelays = 256
+)
+
+// Wake proxy constants
+const (
+	// DefaultWakeProxyWaitSeconds is how long a proxied connection waits for the host when not configured
+	DefaultWakeProxyWaitSeconds = 120
+
+	// MaxWakeProxyWaitSeconds is the maximum configurable wait for a proxied connection
+	MaxWakeProxyWaitSeconds = 600
+
+	// DefaultWakeProxyMaxConnections is the default number of concurrent connections per proxy
+	DefaultWakeProxyMaxConnections = 32
+
+	// MaxWakeProxyConnections is the maximum configurable number of concurrent connections per proxy
+	MaxWakeProxyConnections = 1024
+
+	// WakeProxyDialTimeout is the timeout of a single connection attempt to the target
+	WakeProxyDialTimeout = 3 * time.Second
+
+	// WakeProxyDialRetryInterval is the delay between connection attempts while the service starts
+	WakeProxyDialRetryInterval = 2 * time.Second
+
+	// WebProxyInterstitialDelay is how long a request waits for the upstream before the waking page is shown
+	WebProxyInterstitialDelay = 1500 * time.Millisecond
+
+	// WebProxyStatusPollInterval is how often the waking page checks whether the upstream is ready
+	WebProxyStatusPollInterval = 3 * time.Second
+
+	// WebProxyProbeTimeout is the timeout of a single upstream readiness request
+	WebProxyProbeTimeout = 3 * time.Second
+
+	// WebProxyContentSecurityPolicy is added to every proxied response. The sandbox gives proxied
+	// pages an opaque origin (no allow-same-origin), so their scripts cannot use wol-web's API
+	// with the visitor's session.
+	WebProxyContentSecurityPolicy = "sandbox allow-scripts allow-forms allow-popups"
+)
+
+// ICMP constants
+const (
+	// ICMPRTTTimeout is how long to wait for an echo reply when measuring the RTT of a host that answered ARP
+	ICMPRTTTimeout = time.Second
+
+	// ICMPSilentRecheckInterval is how long the RTT of an address that did not answer an echo
+	// request is not measured (status checks of hosts that drop ICMP skip the echo)
+	ICMPSilentRecheckInterval = 10 * time.Minute
+)
+
+// IPv6 constants
+const (
+	// IPv6AllNodesAddress is the link-local all-nodes multicast group, used for IPv6 wake packets and discovery
+	IPv6AllNodesAddress = "ff02::1"
+
+	// NDPSolicitTimeout is how long to wait for a Neighbor Advertisement after a Neighbor Solicitation
+	NDPSolicitTimeout = time.Second
+
+	// NDPDiscoveryTimeout is how long to collect echo replies to an all-nodes ping per interface
+	NDPDiscoveryTimeout = 2 * time.Second
+
+	// NDPDiscoveryReuse is how long the responders of an all-nodes ping are reused by later discoveries
+	NDPDiscoveryReuse = 30 * time.Second
+)
+
+// Discovery scan constants
+const (
+	// DiscoveryDefaultTimeout is the overall timeout of a discovery scan when the request sets none
+	DiscoveryDefaultTimeout = 30 * time.Second
+
+	// DiscoveryMaxTimeout is the longest timeout a discovery scan may request
+	DiscoveryMaxTimeout = 120 * time.Second
+
+	// DiscoveryMaxAddresses is the largest network a discovery scan sweeps (a /20)
+	DiscoveryMaxAddresses = 4096
+
+	// DiscoveryARPTimeout is how long a discovery sweep waits for each ARP reply
+	DiscoveryARPTimeout = 300 * time.Millisecond
+
+	// DiscoveryNameTimeout bounds the reverse DNS, mDNS and NetBIOS lookups of one device
+	DiscoveryNameTimeout = 2 * time.Second
+
+	// DiscoveryNameWorkers is the number of devices whose names are looked up concurrently
+	DiscoveryNameWorkers = 32
+)
+
+// Magic packet listener constants
+const (
+	// MagicPacketForwardInterval is how long repeated packets for the same MAC are only logged, not re-sent
+	MagicPacketForwardInterval = 5 * time.Second
+
+	// MagicPacketRateLimitPerMinute is the maximum number of magic packets per minute handled per source address
+	MagicPacketRateLimitPerMinute = 30
+)
+
+// OUI vendor database constants
+const (
+	// OUIReloadInterval is how often the configured OUI database file is checked for changes
+	OUIReloadInterval = time.Minute
+)
+
+// DHCP import constants
+const (
+	// DHCPImportInterval is how often watched DHCP lease and reservation files are checked for changes
+	DHCPImportInterval = 30 * time.Second
+
+	// MaxDHCPImportBytes is the largest lease or reservation file accepted by the import endpoint
+	MaxDHCPImportBytes = 8 << 20
+)
+
+// Tracing constants
+const (
+	// TraceIDHeader carries the trace ID of a traced request in the response
+	TraceIDHeader = "X-Trace-Id"
+
+	// DefaultTracingSampleRatio is the fraction of new traces recorded when tracing is enabled
+	DefaultTracingSampleRatio = 1.0
+)
+
+// Logging constants
+const (
+	// Log output formats (log_format)
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+
+	// Log components with their own level (log_levels)
+	LogComponentNetwork = "network" // ARP, NDP, ICMP, neighbour table and status checks
+	LogComponentAuth    = "auth"    // Logins, sessions and user management
+	LogComponentHTTP    = "http"    // Request log and response errors
+
+	// RequestIDHeader carries the request ID in requests (from a proxy) and responses
+	RequestIDHeader = "X-Request-ID"
+
+	// MaxRequestIDLength is the longest incoming X-Request-ID that is kept
+	MaxRequestIDLength = 64
+)
+
+// Syslog and journald output constants
+const (
+	// LogAppName is the syslog APP-NAME and journal SYSLOG_IDENTIFIER
+	LogAppName = "wol-server"
+
+	// DefaultSyslogAddress is the local syslog socket
+	DefaultSyslogAddress = "unix:///dev/log"
+
+	// DefaultSyslogFacility is used when log_syslog_facility is not set
+	DefaultSyslogFacility = "daemon"
+
+	// SyslogStructuredDataID names the structured data element carrying request fields
+	// (32473 is the private enterprise number reserved for examples, RFC 5612)
+	SyslogStructuredDataID = "wol@32473"
+
+	// SyslogDialTimeout bounds connecting to the syslog collector
+	SyslogDialTimeout = 5 * time.Second
+
+	// SyslogWriteTimeout bounds sending one message to a stream (TCP/unix) collector
+	SyslogWriteTimeout = 2 * time.Second
+
+	// SyslogReconnectMinBackoff and SyslogReconnectMaxBackoff bound the wait between reconnect
+	// attempts to an unreachable collector (doubling after each failure)
+	SyslogReconnectMinBackoff = time.Second
+	SyslogReconnectMaxBackoff = time.Minute
+
+	// LogSinkQueueSize is the number of entries queued per syslog/journald sink; entries
+	// logged while the queue is full are dropped
+	LogSinkQueueSize = 1024
+
+	// LogSinkCloseTimeout bounds delivering queued entries when the logger is closed
+	LogSinkCloseTimeout = 2 * time.Second
+
+	// LogSinkDropReportInterval limits how often dropped entries are reported on stderr
+	LogSinkDropReportInterval = time.Minute
+
+	// DefaultJournaldSocket is the systemd journal's native protocol socket
+	DefaultJournaldSocket = "/run/systemd/journal/socket"
+)
+
+// Shutdown constants
+const (
+	// DefaultShutdownTimeoutSeconds is how long in-flight requests may run after SIGTERM
+	DefaultShutdownTimeoutSeconds = 15
+
+	// MaxShutdownTimeoutSeconds is the largest accepted shutdown_timeout_seconds
+	MaxShutdownTimeoutSeconds = 300
+)
+
+// Config reload constants
+const (
+	// ConfigReloadInterval is how often the configuration file is checked for changes
+	ConfigReloadInterval = 5 * time.Second
+)
+
+// Listener constants
+const (
+	// UnixListenPrefix marks listen_address entries that are unix socket paths
+	UnixListenPrefix = "unix:"
+
+	// DefaultListenSocketMode lets the server's group (e.g. a reverse proxy) connect to unix sockets
+	DefaultListenSocketMode = "0660"
+
+	// SDListenFDsStart is the first file descriptor passed by systemd socket activation
+	SDListenFDsStart = 3
+)
+
+// TLS constants
+const (
+	// TLSReloadInterval is how often the certificate files are checked for changes
+	TLSReloadInterval = 30 * time.Second
+
+	// SelfSignedCertFile and SelfSignedKeyFile are created next to the database by tls_self_signed
+	SelfSignedCertFile = "wol-tls.crt"
+	SelfSignedKeyFile  = "wol-tls.key"
+
+	// SelfSignedCertValidity is the lifetime of a generated self-signed certificate
+	SelfSignedCertValidity = 5 * 365 * 24 * time.Hour
+
+	// SelfSignedCertRenewBefore regenerates a self-signed certificate this long before it expires
+	SelfSignedCertRenewBefore = 30 * 24 * time.Hour
+
+	// SelfSignedCertOrganization marks generated certificates, so only those are ever regenerated
+	SelfSignedCertOrganization = "wol-web self-signed"
+
+	// DefaultACMEDirectoryURL is the Let's Encrypt production directory
+	DefaultACMEDirectoryURL = "https://acme-v02.api.letsencrypt.org/directory"
+
+	// ACMECacheDir holds the ACME account key and certificates, next to the database
+	ACMECacheDir = "wol-acme"
+)
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync/atomic"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	_ "modernc.org/sqlite"
+)
+
+// countingDriverName is the SQLite driver that counts failed database operations and
+// traces statements run with the context of a traced request
+const countingDriverName = "sqlite-counted"
+
+// dbErrors counts failed statements, transactions and connection attempts since startup.
+// sql.ErrNoRows is not a driver error and is not counted.
+var dbErrors atomic.Uint64
+
+func init() {
+	// sql.Open only looks up the registered driver; it does not connect
+	db, err := sql.Open("sqlite", "")
+	if err != nil {
+		panic(err)
+	}
+	sql.Register(countingDriverName, &countingDriver{Driver: db.Driver()})
+	db.Close()
+}
+
+// isDBError reports whether err is a failure rather than a driver protocol signal
+// (ErrSkip, ErrBadConn - database/sql retries those) or a cancelled request
+func isDBError(err error) bool {
+	return err != nil && !errors.Is(err, driver.ErrSkip) && !errors.Is(err, driver.ErrBadConn) && !errors.Is(err, context.Canceled)
+}
+
+// countError counts a driver error and returns it unchanged
+func countError(err error) error {
+	if isDBError(err) {
+		dbErrors.Add(1)
+	}
+	return err
+}
+
+// traceStatement starts a span for a statement when ctx belongs to a traced operation and
+// returns the function that ends it. Statements outside a trace (background jobs, calls
+// without a context) are only counted.
+func traceStatement(ctx context.Context, query string) func(error) error {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return countError
+	}
+	operation, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	operation = strings.ToUpper(operation)
+	_, span := startSpan(ctx, "sqlite "+operation, semconv.DBSystemNameSQLite, semconv.DBOperationName(operation), semconv.DBQueryText(query))
+	return func(err error) error {
+		if isDBError(err) {
+			endSpan(span, err)
+		} else {
+			endSpan(span, nil)
+		}
+		return countError(err)
+	}
+}
+
+// countingDriver wraps the SQLite driver to count errors
+type countingDriver struct {
+	driver.Driver
+}
+
+func (d *countingDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, countError(err)
+	}
+	return &countingConn{Conn: conn}, nil
+}
+
+// countingConn passes the optional driver interfaces the SQLite connection implements
+// through, so database/sql uses the same code paths as without the wrapper
+type countingConn struct {
+	driver.Conn
+}
+
+func (c *countingConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *countingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, countError(err)
+	}
+	return &countingStmt{Stmt: stmt, query: query}, nil
+}
+
+func (c *countingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var tx driver.Tx
+	var err error
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = beginner.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	if err != nil {
+		return nil, countError(err)
+	}
+	return &countingTx{Tx: tx}, nil
+}
+
+func (c *countingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	done := traceStatement(ctx, query)
+	result, err := execer.ExecContext(ctx, query, args)
+	return result, done(err)
+}
+
+func (c *countingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	done := traceStatement(ctx, query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	return rows, done(err)
+}
+
+func (c *countingConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return countError(pinger.Ping(ctx))
+	}
+	return nil
+}
+
+func (c *countingConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *countingConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// countingStmt counts errors of prepared statements
+type countingStmt struct {
+	driver.Stmt
+	query string
+}
+
+func (s *countingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	result, err := s.Stmt.Exec(args)
+	return result, countError(err)
+}
+
+func (s *countingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, err := s.Stmt.Query(args)
+	return rows, countError(err)
+}
+
+func (s *countingStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		done := traceStatement(ctx, s.query)
+		result, err := execer.ExecContext(ctx, args)
+		return result, done(err)
+	}
+	values, err := namedValuesToValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Exec(values)
+}
+
+func (s *countingStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		done := traceStatement(ctx, s.query)
+		rows, err := queryer.QueryContext(ctx, args)
+		return rows, done(err)
+	}
+	values, err := namedValuesToValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Query(values)
+}
+
+// namedValuesToValues converts positional arguments for statements without context support
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("sql: driver does not support named parameters")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+// countingTx counts failed commits and rollbacks
+type countingTx struct {
+	driver.Tx
+}
+
+func (t *countingTx) Commit() error {
+	return countError(t.Tx.Commit())
+}
+
+func (t *countingTx) Rollback() error {
+	return countError(t.Tx.Rollback())
+}
@@ -0,0 +1,316 @@
+// Package dhcp reads the lease and reservation files of common DHCP servers:
+//
+//   - dnsmasq: the lease file (dnsmasq.leases), dhcp-host= and dhcp-range= lines of the
+//     configuration and dhcp-hostsfile files
+//   - ISC dhcpd: dhcpd.leases and the host and subnet declarations of dhcpd.conf
+//   - Kea: memfile lease files (kea-leases4.csv, kea-leases6.csv) and the reservations
+//     and subnets of kea-dhcp4.conf / kea-dhcp6.conf
+//
+// Only entries with a hardware (MAC) address are returned; IPv6 leases identified by
+// DUID alone cannot be matched to a host. Leases that are no longer held (expired,
+// released, declined) are skipped.
+package dhcp
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// File formats
+const (
+	FormatAuto      = "auto"
+	FormatDnsmasq   = "dnsmasq"
+	FormatISC       = "isc"
+	FormatKeaLeases = "kea-leases"
+	FormatKeaConfig = "kea-config"
+)
+
+// Formats lists the accepted format names
+var Formats = []string{FormatAuto, FormatDnsmasq, FormatISC, FormatKeaLeases, FormatKeaConfig}
+
+// Entry is one lease or reservation
+type Entry struct {
+	MAC      string // Lower-case, colon separated
+	IP       net.IP
+	Hostname string    // "" = unknown
+	Reserved bool      // Static reservation (fixed address) rather than a dynamic lease
+	Expires  time.Time // Lease expiry (zero = never, or a reservation)
+}
+
+// File is the content of a lease or reservation file
+type File struct {
+	Format  string
+	Entries []Entry
+	Subnets []*net.IPNet // Subnets declared in the file (configuration files only)
+}
+
+// Device is the combined view of the entries of one MAC address
+type Device struct {
+	MAC      string
+	Hostname string
+	IPv4     net.IP // nil = none
+	IPv6     net.IP // nil = none
+	Reserved bool   // At least one address comes from a reservation
+}
+
+// isc: "lease 192.168.1.10 {", "host printer {", "subnet 192.168.1.0 netmask 255.255.255.0 {"
+var iscDeclaration = regexp.MustCompile(`(?m)^\s*(lease6?|host|subnet6?)\s+[^\s{]+.*\{`)
+
+// ValidFormat reports whether a format name is known
+func ValidFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectFormat guesses the format of a lease or reservation file from its content
+func DetectFormat(data []byte) string {
+	if trimmed := bytes.TrimSpace(stripComments(data)); len(trimmed) > 0 && trimmed[0] == '{' {
+		return FormatKeaConfig
+	}
+	if firstLine, _, _ := bytes.Cut(bytes.TrimSpace(data), []byte("\n")); bytes.HasPrefix(firstLine, []byte("address,")) {
+		return FormatKeaLeases
+	}
+	if iscDeclaration.Match(data) {
+		return FormatISC
+	}
+	return FormatDnsmasq
+}
+
+// Parse reads a lease or reservation file. An empty format or FormatAuto detects the format.
+func Parse(data []byte, format string) (*File, error) {
+	if format == "" || format == FormatAuto {
+		format = DetectFormat(data)
+	}
+
+	file := &File{Format: format}
+	var err error
+	switch format {
+	case FormatDnsmasq:
+		err = parseDnsmasq(data, file)
+	case FormatISC:
+		err = parseISC(data, file)
+	case FormatKeaLeases:
+		err = parseKeaLeases(data, file)
+	case FormatKeaConfig:
+		err = parseKeaConfig(data, file)
+	default:
+		return nil, fmt.Errorf("unknown format '%s' (expected one of: %s)", format, strings.Join(Formats, ", "))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", format, err)
+	}
+	return file, nil
+}
+
+// ParseFile reads a lease or reservation file from disk
+func ParseFile(path, format string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := Parse(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return file, nil
+}
+
+// Devices combines the entries by MAC address, sorted by MAC. Per address family a
+// reservation wins over leases, and of several leases the one expiring last wins.
+func (f *File) Devices() []Device {
+	type choice struct {
+		entry Entry
+		set   bool
+	}
+	type device struct {
+		ipv4, ipv6 choice
+		hostname   string
+	}
+
+	better := func(current choice, entry Entry) bool {
+		switch {
+		case !current.set:
+			return true
+		case current.entry.Reserved != entry.Reserved:
+			return entry.Reserved
+		case current.entry.Reserved:
+			return false // First reservation wins
+		case current.entry.Expires.IsZero():
+			return false
+		}
+		return entry.Expires.IsZero() || !entry.Expires.Before(current.entry.Expires)
+	}
+
+	devices := make(map[string]*device)
+	for _, entry := range f.Entries {
+		d := devices[entry.MAC]
+		if d == nil {
+			d = &device{}
+			devices[entry.MAC] = d
+		}
+		slot := &d.ipv6
+		if entry.IP.To4() != nil {
+			slot = &d.ipv4
+		}
+		if better(*slot, entry) {
+			*slot = choice{entry: entry, set: true}
+		}
+		if d.hostname == "" {
+			d.hostname = entry.Hostname
+		}
+	}
+
+	result := make([]Device, 0, len(devices))
+	for mac, d := range devices {
+		device := Device{MAC: mac, Hostname: d.hostname}
+		for _, c := range []choice{d.ipv6, d.ipv4} { // The IPv4 hostname wins
+			if !c.set {
+				continue
+			}
+			if c.entry.IP.To4() != nil {
+				device.IPv4 = c.entry.IP.To4()
+			} else {
+				device.IPv6 = c.entry.IP
+			}
+			device.Reserved = device.Reserved || c.entry.Reserved
+			if c.entry.Hostname != "" {
+				device.Hostname = c.entry.Hostname
+			}
+		}
+		result = append(result, device)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].MAC < result[j].MAC })
+	return result
+}
+
+// Subnet returns the smallest subnet declared in the file that contains ip, or nil
+func (f *File) Subnet(ip net.IP) *net.IPNet {
+	var best *net.IPNet
+	bestSize := -1
+	for _, subnet := range f.Subnets {
+		if !subnet.Contains(ip) {
+			continue
+		}
+		if ones, _ := subnet.Mask.Size(); ones > bestSize {
+			best, bestSize = subnet, ones
+		}
+	}
+	return best
+}
+
+// addEntry appends an entry after normalizing its MAC address and hostname.
+// Entries without a usable MAC or IP address are dropped.
+func (f *File) addEntry(mac string, ip net.IP, hostname string, reserved bool, expires time.Time) {
+	mac = normalizeMAC(mac)
+	if mac == "" || ip == nil || ip.IsUnspecified() {
+		return
+	}
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	hostname = strings.TrimSuffix(strings.TrimSpace(hostname), ".")
+	if hostname == "*" {
+		hostname = ""
+	}
+	f.Entries = append(f.Entries, Entry{MAC: mac, IP: ip, Hostname: hostname, Reserved: reserved, Expires: expires})
+}
+
+// expired reports whether a lease ended before now (a zero time never expires)
+func expired(expires time.Time) bool {
+	return !expires.IsZero() && expires.Before(time.Now())
+}
+
+// removeLeases drops the leases (not reservations) read so far for an address
+func (f *File) removeLeases(ip net.IP) {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	entries := f.Entries[:0]
+	for _, entry := range f.Entries {
+		if entry.Reserved || !entry.IP.Equal(ip) {
+			entries = append(entries, entry)
+		}
+	}
+	f.Entries = entries
+}
+
+// addSubnet records a declared subnet
+func (f *File) addSubnet(subnet *net.IPNet) {
+	if subnet != nil {
+		f.Subnets = append(f.Subnets, &net.IPNet{IP: subnet.IP.Mask(subnet.Mask), Mask: subnet.Mask})
+	}
+}
+
+// normalizeMAC returns a 6-byte MAC address as lower-case colon separated hex, or "" when
+// the value is not one. Single-digit octets ("0:1b:21:...", as written by dhcpd) are accepted.
+func normalizeMAC(value string) string {
+	parts := strings.Split(strings.ReplaceAll(strings.TrimSpace(value), "-", ":"), ":")
+	if len(parts) != 6 {
+		return ""
+	}
+	octets := make([]string, 6)
+	for i, part := range parts {
+		if part == "" || len(part) > 2 {
+			return ""
+		}
+		octet, err := strconv.ParseUint(part, 16, 8)
+		if err != nil {
+			return ""
+		}
+		octets[i] = fmt.Sprintf("%02x", octet)
+	}
+	mac := strings.Join(octets, ":")
+	if mac == "00:00:00:00:00:00" || mac == "ff:ff:ff:ff:ff:ff" {
+		return ""
+	}
+	return mac
+}
+
+// stripComments removes #, // and /* */ comments outside of double-quoted strings
+func stripComments(data []byte) []byte {
+	var out bytes.Buffer
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case inString:
+			out.WriteByte(c)
+			if c == '\\' && i+1 < len(data) {
+				i++
+				out.WriteByte(data[i])
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+			out.WriteByte(c)
+		case c == '#' || (c == '/' && i+1 < len(data) && data[i+1] == '/'):
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			if i < len(data) {
+				out.WriteByte('\n')
+			}
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				return out.Bytes()
+			}
+			i += end + 3
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,227 @@
+package dhcp
+
+import (
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testDevice is a Device with printable addresses ("" = none)
+type testDevice struct {
+	mac, hostname, ipv4, ipv6 string
+	reserved                  bool
+}
+
+func toTestDevices(devices []Device) []testDevice {
+	result := []testDevice{}
+	for _, d := range devices {
+		device := testDevice{mac: d.MAC, hostname: d.Hostname, reserved: d.Reserved}
+		if d.IPv4 != nil {
+			device.ipv4 = d.IPv4.String()
+		}
+		if d.IPv6 != nil {
+			device.ipv6 = d.IPv6.String()
+		}
+		result = append(result, device)
+	}
+	return result
+}
+
+func TestParseFile(t *testing.T) {
+	tests := []struct {
+		file    string
+		format  string
+		devices []testDevice
+		subnets []string
+	}{
+		{
+			// Expired lease dropped, the later of two leases wins, IPv6 lines without a MAC dropped
+			file:   "dnsmasq.leases",
+			format: FormatDnsmasq,
+			devices: []testDevice{
+				{mac: "52:54:00:00:00:01", ipv4: "192.168.1.12"},
+				{mac: "52:54:00:12:34:56", hostname: "nas", ipv4: "192.168.1.10"},
+			},
+		},
+		{
+			file:   "dnsmasq.conf",
+			format: FormatDnsmasq,
+			devices: []testDevice{
+				{mac: "52:54:00:0a:0b:0c", hostname: "printer", ipv6: "fd00::20", reserved: true},
+				{mac: "52:54:00:0d:0e:0f", ipv4: "192.168.1.6", reserved: true},
+				{mac: "52:54:00:12:34:56", hostname: "nas", ipv4: "192.168.1.5", reserved: true},
+			},
+			subnets: []string{"192.168.1.0/24"},
+		},
+		{
+			// Single-digit octets, last declaration of an address wins, freed and expired leases dropped
+			file:   "dhcpd.leases",
+			format: FormatISC,
+			devices: []testDevice{
+				{mac: "00:1b:21:0a:0b:0c", hostname: "workstation", ipv4: "192.168.1.50"},
+				{mac: "52:54:00:00:00:54", hostname: "phone", ipv4: "192.168.1.53"},
+				{mac: "52:54:00:00:00:55", ipv4: "192.168.1.55"},
+			},
+		},
+		{
+			file:   "dhcpd.conf",
+			format: FormatISC,
+			devices: []testDevice{
+				{mac: "52:54:00:aa:00:01", hostname: "printer", ipv4: "192.168.1.5", reserved: true},
+				{mac: "52:54:00:aa:00:02", hostname: "storage", ipv4: "192.168.1.6", ipv6: "fd00::6", reserved: true},
+			},
+			subnets: []string{"192.168.1.0/24", "fd00::/64"},
+		},
+		{
+			// Declined, reclaimed and expired leases and leases without a MAC dropped
+			file:   "kea-leases4.csv",
+			format: FormatKeaLeases,
+			devices: []testDevice{
+				{mac: "52:54:00:00:00:60", hostname: "desktop", ipv4: "192.168.1.60"},
+				{mac: "52:54:00:00:00:64", hostname: "media,server", ipv4: "192.168.1.64"},
+			},
+		},
+		{
+			// Delegated prefixes dropped
+			file:   "kea-leases6.csv",
+			format: FormatKeaLeases,
+			devices: []testDevice{
+				{mac: "52:54:00:00:00:60", hostname: "desktop", ipv6: "fd00::60"},
+			},
+		},
+		{
+			file:   "kea-dhcp4.conf",
+			format: FormatKeaConfig,
+			devices: []testDevice{
+				{mac: "52:54:00:bb:00:01", hostname: "printer", ipv4: "192.168.1.7", reserved: true},
+				{mac: "52:54:00:bb:00:02", hostname: "ap.example.com", ipv4: "192.168.2.9", reserved: true},
+			},
+			subnets: []string{"192.168.1.0/24"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			file, err := ParseFile(filepath.Join("testdata", tt.file), FormatAuto)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if file.Format != tt.format {
+				t.Errorf("format = %s, want %s", file.Format, tt.format)
+			}
+			if got := toTestDevices(file.Devices()); !reflect.DeepEqual(got, tt.devices) {
+				t.Errorf("devices = %+v\nwant %+v", got, tt.devices)
+			}
+			subnets := []string{}
+			for _, subnet := range file.Subnets {
+				subnets = append(subnets, subnet.String())
+			}
+			if tt.subnets == nil {
+				tt.subnets = []string{}
+			}
+			if !reflect.DeepEqual(subnets, tt.subnets) {
+				t.Errorf("subnets = %v, want %v", subnets, tt.subnets)
+			}
+		})
+	}
+}
+
+func TestDevicesPrecedence(t *testing.T) {
+	const mac = "52:54:00:12:34:56"
+	soon, later := time.Now().Add(time.Hour), time.Now().Add(2*time.Hour)
+	lease := func(ip string, expires time.Time) Entry {
+		return Entry{MAC: mac, IP: net.ParseIP(ip).To4(), Expires: expires}
+	}
+	reservation := func(ip string) Entry {
+		return Entry{MAC: mac, IP: net.ParseIP(ip).To4(), Reserved: true}
+	}
+
+	tests := []struct {
+		name     string
+		entries  []Entry
+		ipv4     string
+		reserved bool
+	}{
+		{"reservation before lease", []Entry{reservation("192.168.1.5"), lease("192.168.1.10", later)}, "192.168.1.5", true},
+		{"reservation after lease", []Entry{lease("192.168.1.10", later), reservation("192.168.1.5")}, "192.168.1.5", true},
+		{"first reservation wins", []Entry{reservation("192.168.1.5"), reservation("192.168.1.6")}, "192.168.1.5", true},
+		{"later expiry wins", []Entry{lease("192.168.1.10", later), lease("192.168.1.11", soon)}, "192.168.1.10", false},
+		{"later expiry declared last", []Entry{lease("192.168.1.11", soon), lease("192.168.1.10", later)}, "192.168.1.10", false},
+		{"infinite lease wins", []Entry{lease("192.168.1.12", time.Time{}), lease("192.168.1.10", later)}, "192.168.1.12", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devices := (&File{Entries: tt.entries}).Devices()
+			if len(devices) != 1 {
+				t.Fatalf("got %d devices, want 1", len(devices))
+			}
+			if got := devices[0].IPv4.String(); got != tt.ipv4 || devices[0].Reserved != tt.reserved {
+				t.Errorf("got %s (reserved %v), want %s (reserved %v)", got, devices[0].Reserved, tt.ipv4, tt.reserved)
+			}
+		})
+	}
+}
+
+func TestRemoveLeases(t *testing.T) {
+	file := &File{}
+	file.addEntry("52:54:00:00:00:01", net.ParseIP("192.168.1.5"), "", true, time.Time{})
+	file.addEntry("52:54:00:00:00:02", net.ParseIP("192.168.1.5"), "", false, time.Time{})
+	file.addEntry("52:54:00:00:00:03", net.ParseIP("192.168.1.6"), "", false, time.Time{})
+
+	// An IPv4-mapped address matches the 4-byte form stored by addEntry
+	file.removeLeases(net.ParseIP("::ffff:192.168.1.5"))
+
+	var macs []string
+	for _, entry := range file.Entries {
+		macs = append(macs, entry.MAC)
+	}
+	if want := []string{"52:54:00:00:00:01", "52:54:00:00:00:03"}; !reflect.DeepEqual(macs, want) {
+		t.Errorf("remaining entries = %v, want %v", macs, want)
+	}
+}
+
+func TestNormalizeMAC(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"52:54:00:AB:CD:EF", "52:54:00:ab:cd:ef"},
+		{"52-54-00-ab-cd-ef", "52:54:00:ab:cd:ef"},
+		{"0:1b:21:a:b:c", "00:1b:21:0a:0b:0c"},
+		{" 52:54:00:ab:cd:ef ", "52:54:00:ab:cd:ef"},
+		{"00:00:00:00:00:00", ""},
+		{"ff:ff:ff:ff:ff:ff", ""},
+		{"52:54:00:ab:cd", ""},
+		{"52:54:00:ab:cd:ef:01", ""},
+		{"52:54:00:ab:cd:123", ""},
+		{"52:54:00:ab::ef", ""},
+		{"52:54:00:ab:cd:gg", ""},
+		{"305419896", ""},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeMAC(tt.value); got != tt.want {
+			t.Errorf("normalizeMAC(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestExpired(t *testing.T) {
+	tests := []struct {
+		name    string
+		expires time.Time
+		want    bool
+	}{
+		{"never", time.Time{}, false},
+		{"past", time.Now().Add(-time.Second), true},
+		{"future", time.Now().Add(time.Hour), false},
+	}
+
+	for _, tt := range tests {
+		if got := expired(tt.expires); got != tt.want {
+			t.Errorf("%s: expired = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
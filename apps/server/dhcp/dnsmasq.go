@@ -0,0 +1,112 @@
+package dhcp
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dnsmasq lease times: "infinite", "45m", "12h", "3600"
+var dnsmasqLeaseTime = regexp.MustCompile(`^(\d+[smhdw]?|infinite)$`)
+
+// parseDnsmasq reads dnsmasq lease files ("expiry mac ip hostname client-id" lines),
+// dhcp-host= and dhcp-range= configuration lines and dhcp-hostsfile lines. Other
+// configuration lines are ignored, so a complete dnsmasq.conf can be read.
+func parseDnsmasq(data []byte, file *File) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if key, value, ok := strings.Cut(line, "="); ok && !strings.Contains(key, ",") {
+			switch strings.TrimSpace(key) {
+			case "dhcp-host":
+				parseDnsmasqHost(value, file)
+			case "dhcp-range":
+				parseDnsmasqRange(value, file)
+			}
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) >= 4 && !strings.Contains(fields[0], ",") {
+			// Lease line. IPv6 leases carry an IAID instead of a MAC address and are
+			// dropped by addEntry, as is the "duid" line.
+			expiry, err := strconv.ParseInt(fields[0], 10, 64)
+			if err != nil {
+				continue
+			}
+			var expires time.Time
+			if expiry > 0 {
+				expires = time.Unix(expiry, 0)
+			}
+			if expired(expires) {
+				continue // dnsmasq rewrites the file lazily, so it may list ended leases
+			}
+			file.addEntry(fields[1], net.ParseIP(fields[2]), fields[3], false, expires)
+			continue
+		}
+
+		// dhcp-hostsfile line: the value of a dhcp-host= option
+		parseDnsmasqHost(line, file)
+	}
+	return scanner.Err()
+}
+
+// parseDnsmasqHost reads a dhcp-host value: a comma-separated list of MAC addresses,
+// addresses, a hostname, tags, client IDs and a lease time in any order
+func parseDnsmasqHost(value string, file *File) {
+	var macs []string
+	var ips []net.IP
+	hostname := ""
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		switch {
+		case field == "" || field == "ignore" || dnsmasqLeaseTime.MatchString(field):
+		case strings.HasPrefix(field, "id:") || strings.HasPrefix(field, "set:") || strings.HasPrefix(field, "tag:"):
+		case normalizeMAC(field) != "":
+			macs = append(macs, field)
+		case strings.HasPrefix(field, "[") && strings.HasSuffix(field, "]"):
+			if ip := net.ParseIP(field[1 : len(field)-1]); ip != nil {
+				ips = append(ips, ip)
+			}
+		case net.ParseIP(field) != nil:
+			ips = append(ips, net.ParseIP(field))
+		default:
+			hostname = field
+		}
+	}
+	for _, mac := range macs {
+		for _, ip := range ips {
+			file.addEntry(mac, ip, hostname, true, time.Time{})
+		}
+	}
+}
+
+// parseDnsmasqRange reads the subnet of an IPv4 dhcp-range with a netmask:
+// [tag:...,][set:...,]start[,end][,mode][,netmask[,broadcast]][,lease time]
+func parseDnsmasqRange(value string, file *File) {
+	var addresses []net.IP
+	for _, field := range strings.Split(value, ",") {
+		if ip := net.ParseIP(strings.TrimSpace(field)).To4(); ip != nil {
+			addresses = append(addresses, ip)
+		}
+	}
+	if len(addresses) < 2 {
+		return
+	}
+	// start, end, netmask - or start, netmask for a static range
+	for _, candidate := range addresses[1:min(len(addresses), 3)] {
+		mask := net.IPMask(candidate)
+		if ones, bits := mask.Size(); bits == 32 && ones > 0 {
+			file.addSubnet(&net.IPNet{IP: addresses[0], Mask: mask})
+			return
+		}
+	}
+}
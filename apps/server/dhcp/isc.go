@@ -0,0 +1,198 @@
+package dhcp
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// iscBlock is a declaration being read: its head ("lease 192.168.1.10") and statements
+type iscBlock struct {
+	head       []string
+	statements [][]string
+}
+
+// parseISC reads the lease, host and subnet declarations of ISC dhcpd files
+// (dhcpd.leases, dhcpd6.leases, dhcpd.conf). Other declarations and statements are ignored.
+func parseISC(data []byte, file *File) error {
+	tokens, err := tokenizeISC(string(data))
+	if err != nil {
+		return err
+	}
+
+	var stack []*iscBlock
+	var words []string
+	for _, token := range tokens {
+		switch token {
+		case ";":
+			if len(stack) > 0 && len(words) > 0 {
+				top := stack[len(stack)-1]
+				top.statements = append(top.statements, words)
+			}
+			words = nil
+		case "{":
+			stack = append(stack, &iscBlock{head: words})
+			words = nil
+		case "}":
+			if len(stack) == 0 {
+				return errors.New("unbalanced '}'")
+			}
+			block := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			readISCBlock(block, file)
+			words = nil
+		default:
+			words = append(words, token)
+		}
+	}
+	if len(stack) > 0 {
+		return errors.New("unterminated block (missing '}')")
+	}
+	return nil
+}
+
+// readISCBlock adds the entries or subnet of a completed declaration
+func readISCBlock(block *iscBlock, file *File) {
+	if len(block.head) < 2 {
+		return
+	}
+
+	switch block.head[0] {
+	case "subnet":
+		// subnet 192.168.1.0 netmask 255.255.255.0
+		if len(block.head) >= 4 && block.head[2] == "netmask" {
+			ip, mask := net.ParseIP(block.head[1]).To4(), net.ParseIP(block.head[3]).To4()
+			if ip != nil && mask != nil {
+				file.addSubnet(&net.IPNet{IP: ip, Mask: net.IPMask(mask)})
+			}
+		}
+	case "subnet6":
+		if _, subnet, err := net.ParseCIDR(block.head[1]); err == nil {
+			file.addSubnet(subnet)
+		}
+	case "lease":
+		readISCLease(block, file)
+	case "host":
+		readISCHost(block, file)
+	}
+}
+
+// readISCLease reads a dhcpd.leases lease declaration. dhcpd appends a new declaration
+// whenever a lease changes, so the last declaration of an address replaces earlier ones.
+// Only leases in binding state active that have not ended are kept; free, expired,
+// released and abandoned leases remove the address.
+func readISCLease(block *iscBlock, file *File) {
+	ip := net.ParseIP(block.head[1])
+	if ip == nil {
+		return
+	}
+	file.removeLeases(ip)
+
+	mac, hostname := "", ""
+	var expires time.Time
+	for _, statement := range block.statements {
+		switch {
+		case len(statement) >= 3 && statement[0] == "hardware" && statement[1] == "ethernet":
+			mac = statement[2]
+		case len(statement) >= 3 && statement[0] == "binding" && statement[1] == "state":
+			if statement[2] != "active" {
+				return
+			}
+		case len(statement) >= 2 && statement[0] == "client-hostname":
+			hostname = statement[1]
+		case len(statement) >= 2 && statement[0] == "ends":
+			expires = parseISCTime(statement[1:])
+		}
+	}
+	if expired(expires) {
+		return
+	}
+	file.addEntry(mac, ip, hostname, false, expires)
+}
+
+// readISCHost reads a dhcpd.conf host declaration with fixed addresses
+func readISCHost(block *iscBlock, file *File) {
+	mac, hostname := "", ""
+	var ips []net.IP
+	for _, statement := range block.statements {
+		switch {
+		case len(statement) >= 3 && statement[0] == "hardware" && statement[1] == "ethernet":
+			mac = statement[2]
+		case len(statement) >= 2 && (statement[0] == "fixed-address" || statement[0] == "fixed-address6"):
+			for _, value := range statement[1:] {
+				// Host names are resolved by dhcpd at startup - only addresses are used
+				if ip := net.ParseIP(strings.TrimSuffix(value, ",")); ip != nil {
+					ips = append(ips, ip)
+				}
+			}
+		case len(statement) >= 3 && statement[0] == "option" && statement[1] == "host-name":
+			hostname = statement[2]
+		case len(statement) >= 2 && statement[0] == "ddns-hostname" && hostname == "":
+			hostname = statement[1]
+		}
+	}
+	if hostname == "" {
+		hostname = block.head[1]
+	}
+	for _, ip := range ips {
+		file.addEntry(mac, ip, hostname, true, time.Time{})
+	}
+}
+
+// parseISCTime reads "4 2024/01/01 12:00:00" (UTC), "epoch 1704110400" or "never"
+func parseISCTime(fields []string) time.Time {
+	switch {
+	case len(fields) >= 2 && fields[0] == "epoch":
+		if seconds, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			return time.Unix(seconds, 0)
+		}
+	case len(fields) >= 3:
+		if t, err := time.Parse("2006/01/02 15:04:05", fields[1]+" "+fields[2]); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// tokenizeISC splits dhcpd syntax into words, quoted strings (unquoted) and the
+// punctuation tokens "{", "}" and ";". Comments start with '#'.
+func tokenizeISC(data string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '#':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+		case c == '{' || c == '}' || c == ';':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"':
+			var value strings.Builder
+			i++
+			for ; i < len(data) && data[i] != '"'; i++ {
+				if data[i] == '\\' && i+1 < len(data) {
+					i++
+				}
+				value.WriteByte(data[i])
+			}
+			if i >= len(data) {
+				return nil, errors.New("unterminated string")
+			}
+			tokens = append(tokens, value.String())
+			i++
+		default:
+			start := i
+			for i < len(data) && !strings.ContainsRune(" \t\r\n{};\"#", rune(data[i])) {
+				i++
+			}
+			tokens = append(tokens, data[start:i])
+		}
+	}
+	return tokens, nil
+}
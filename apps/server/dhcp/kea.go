@@ -0,0 +1,139 @@
+package dhcp
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kea lease states
+const (
+	keaStateDeclined         = "1"
+	keaStateExpiredReclaimed = "2"
+)
+
+// parseKeaLeases reads a Kea memfile lease file (kea-leases4.csv or kea-leases6.csv).
+// The columns are looked up by name in the header row, so both files and all schema
+// versions are read the same way.
+func parseKeaLeases(data []byte, file *File) error {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("missing header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, required := range []string{"address", "hwaddr", "expire"} {
+		if _, ok := columns[required]; !ok {
+			return fmt.Errorf("missing column '%s' in header", required)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if state := field(record, "state"); state == keaStateDeclined || state == keaStateExpiredReclaimed {
+			continue
+		}
+		if leaseType := field(record, "lease_type"); leaseType != "" && leaseType != "0" {
+			continue // Temporary address or delegated prefix, not the host's address
+		}
+
+		var expires time.Time
+		if expire, err := strconv.ParseInt(field(record, "expire"), 10, 64); err == nil && expire > 0 {
+			expires = time.Unix(expire, 0)
+		}
+		if expired(expires) {
+			continue
+		}
+		// Commas in host names are escaped as "&#x2c"
+		hostname := strings.ReplaceAll(field(record, "hostname"), "&#x2c", ",")
+		file.addEntry(field(record, "hwaddr"), net.ParseIP(field(record, "address")), hostname, false, expires)
+	}
+}
+
+// parseKeaConfig reads the host reservations and subnets of a Kea DHCPv4 or DHCPv6
+// configuration file, wherever they are declared (global, shared networks or subnets)
+func parseKeaConfig(data []byte, file *File) error {
+	var config interface{}
+	if err := json.Unmarshal(stripComments(data), &config); err != nil {
+		return err
+	}
+	root, ok := config.(map[string]interface{})
+	if !ok {
+		return errors.New("configuration is not a JSON object")
+	}
+	readKeaObject(root, file)
+	return nil
+}
+
+// readKeaObject walks a configuration object for subnets and reservations
+func readKeaObject(object map[string]interface{}, file *File) {
+	if subnet, ok := object["subnet"].(string); ok {
+		if _, network, err := net.ParseCIDR(subnet); err == nil {
+			file.addSubnet(network)
+		}
+	}
+
+	for key, value := range object {
+		switch value := value.(type) {
+		case map[string]interface{}:
+			readKeaObject(value, file)
+		case []interface{}:
+			for _, item := range value {
+				child, ok := item.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if key == "reservations" {
+					readKeaReservation(child, file)
+				} else {
+					readKeaObject(child, file)
+				}
+			}
+		}
+	}
+}
+
+// readKeaReservation reads a host reservation identified by its hardware address
+func readKeaReservation(reservation map[string]interface{}, file *File) {
+	mac, _ := reservation["hw-address"].(string)
+	hostname, _ := reservation["hostname"].(string)
+
+	var addresses []string
+	if address, ok := reservation["ip-address"].(string); ok {
+		addresses = append(addresses, address)
+	}
+	if list, ok := reservation["ip-addresses"].([]interface{}); ok {
+		for _, item := range list {
+			if address, ok := item.(string); ok {
+				addresses = append(addresses, address)
+			}
+		}
+	}
+	for _, address := range addresses {
+		file.addEntry(mac, net.ParseIP(address), hostname, true, time.Time{})
+	}
+}
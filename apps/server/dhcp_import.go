@@ -0,0 +1,433 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"server/dhcp"
+)
+
+// DHCPImportConfig is a DHCP lease or reservation file that is imported at startup and
+// re-imported whenever it changes, keeping the static IPs of hosts current
+type DHCPImportConfig struct {
+	Path       string `json:"path"`        // Lease or reservation file
+	Format     string `json:"format"`      // "auto" (default), "dnsmasq", "isc", "kea-leases" or "kea-config"
+	Owner      string `json:"owner"`       // User the hosts belong to (required when use_auth is enabled)
+	UpdateOnly bool   `json:"update_only"` // Only update hosts that already exist, do not create new ones
+}
+
+// DHCPImportRequest is the body of POST /api/import/dhcp
+type DHCPImportRequest struct {
+	Content    string `json:"content"`     // File content
+	Format     string `json:"format"`      // "auto" (default), "dnsmasq", "isc", "kea-leases" or "kea-config"
+	UpdateOnly bool   `json:"update_only"` // Only update hosts that already exist
+	DryRun     bool   `json:"dry_run"`     // Report the changes without saving them
+}
+
+// DHCPImportResult summarizes an import
+type DHCPImportResult struct {
+	Format    string           `json:"format"` // Format the file was read as
+	Created   int              `json:"created"`
+	Updated   int              `json:"updated"`
+	Unchanged int              `json:"unchanged"`
+	Skipped   int              `json:"skipped"`
+	DryRun    bool             `json:"dry_run,omitempty"`
+	Hosts     []DHCPImportHost `json:"hosts"`
+}
+
+// DHCPImportHost is the outcome of one device of an import
+type DHCPImportHost struct {
+	Action    string `json:"action"` // "created", "updated", "unchanged" or "skipped"
+	HostID    string `json:"host_id,omitempty"`
+	Name      string `json:"name"`
+	MAC       string `json:"mac"`
+	StaticIP  string `json:"static_ip,omitempty"`
+	Broadcast string `json:"broadcast,omitempty"` // Suggested broadcast (created hosts)
+	Reason    string `json:"reason,omitempty"`    // Why the device was skipped
+}
+
+// DHCP import actions
+const (
+	DHCPImportCreated   = "created"
+	DHCPImportUpdated   = "updated"
+	DHCPImportUnchanged = "unchanged"
+	DHCPImportSkipped   = "skipped"
+)
+
+// validateDHCPImportConfig checks a single dhcp_imports entry
+func validateDHCPImportConfig(c DHCPImportConfig, useAuth bool) error {
+	if c.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+	if c.Format != "" && !dhcp.ValidFormat(c.Format) {
+		return fmt.Errorf("'%s': unknown format '%s' (expected one of: %s)", c.Path, c.Format, strings.Join(dhcp.Formats, ", "))
+	}
+	if useAuth && c.Owner == "" {
+		return fmt.Errorf("'%s': owner is required when use_auth is enabled", c.Path)
+	}
+	return nil
+}
+
+// importDHCP creates or updates the hosts of ownerID (nil = no-auth hosts) from the devices
+// of a lease or reservation file. Hosts are matched by MAC address. Existing hosts get
+// the addresses from the file as their static IPs and keep their name and broadcast; new
+// hosts also get a name from the DHCP hostname and a broadcast suggested from the subnet.
+func (s *Server) importDHCP(file *dhcp.File, ownerID *string, updateOnly, dryRun bool) (*DHCPImportResult, error) {
+	scope, args := "user_id IS NULL", []interface{}{}
+	if ownerID != nil {
+		scope, args = "user_id = ?", []interface{}{*ownerID}
+	}
+
+	rows, err := s.DB.Query("SELECT id, name, mac, static_ip FROM hosts WHERE "+scope, args...)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string][]Host)
+	for rows.Next() {
+		var host Host
+		var staticIP sql.NullString
+		if err := rows.Scan(&host.ID, &host.Name, &host.MAC, &staticIP); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		host.StaticIP = staticIP.String
+		mac := normalizeMACAddress(host.MAC)
+		existing[mac] = append(existing[mac], host)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &DHCPImportResult{Format: file.Format, DryRun: dryRun, Hosts: []DHCPImportHost{}}
+	var changed []string
+	for _, device := range file.Devices() {
+		var addresses []string
+		for _, ip := range []net.IP{device.IPv4, device.IPv6} {
+			if ip != nil && sanitizeStaticIP(ip.String()) == nil {
+				addresses = append(addresses, ip.String())
+			}
+		}
+		entry := DHCPImportHost{Name: dhcpHostName(device), MAC: device.MAC}
+		if err := sanitizeMACAddress(device.MAC); err != nil {
+			entry.Action, entry.Reason = DHCPImportSkipped, err.Error()
+			result.Hosts = append(result.Hosts, entry)
+			continue
+		}
+		if len(addresses) == 0 {
+			entry.Action, entry.Reason = DHCPImportSkipped, "no usable address"
+			result.Hosts = append(result.Hosts, entry)
+			continue
+		}
+
+		hosts := existing[device.MAC]
+		if len(hosts) == 0 {
+			if updateOnly {
+				entry.Action, entry.Reason = DHCPImportSkipped, "no host with this MAC address"
+				result.Hosts = append(result.Hosts, entry)
+				continue
+			}
+			entry.StaticIP = strings.Join(addresses, ",")
+			entry.Broadcast = suggestBroadcast(device, file)
+			if err := validateDHCPImportHost(entry); err != nil {
+				entry.Action, entry.Reason = DHCPImportSkipped, err.Error()
+				result.Hosts = append(result.Hosts, entry)
+				continue
+			}
+			entry.Action = DHCPImportCreated
+			if !dryRun {
+				if entry.HostID, err = generateID(); err != nil {
+					return nil, err
+				}
+				// Leased addresses may change - ARP stays first, the lease is the fallback
+				_, err = tx.Exec("INSERT INTO hosts (id, name, mac, broadcast, interface, static_ip, use_as_fallback, user_id) VALUES (?, ?, ?, ?, '', ?, ?, ?)",
+					entry.HostID, entry.Name, device.MAC, entry.Broadcast, entry.StaticIP, !device.Reserved, ownerID)
+				if err != nil {
+					return nil, err
+				}
+			}
+			result.Hosts = append(result.Hosts, entry)
+			continue
+		}
+
+		// Several hosts may share a MAC address (e.g. one per interface) - update them all
+		for _, host := range hosts {
+			staticIP := host.StaticIP
+			for _, address := range addresses {
+				staticIP = replaceStaticIP(staticIP, address)
+			}
+			hostEntry := entry
+			hostEntry.HostID, hostEntry.Name, hostEntry.StaticIP = host.ID, host.Name, staticIP
+			if err := sanitizeStaticIP(staticIP); err != nil {
+				hostEntry.Action, hostEntry.Reason = DHCPImportSkipped, err.Error()
+			} else if staticIP == host.StaticIP {
+				hostEntry.Action = DHCPImportUnchanged
+			} else {
+				hostEntry.Action = DHCPImportUpdated
+				if !dryRun {
+					if _, err := tx.Exec("UPDATE hosts SET static_ip = ?, updated = CURRENT_TIMESTAMP WHERE id = ?", staticIP, host.ID); err != nil {
+						return nil, err
+					}
+				}
+				changed = append(changed, host.ID)
+			}
+			result.Hosts = append(result.Hosts, hostEntry)
+		}
+	}
+
+	if !dryRun {
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		// Cached status results used the old addresses
+		for _, hostID := range changed {
+			s.PingCache.Invalidate(hostID)
+		}
+	}
+
+	for _, host := range result.Hosts {
+		switch host.Action {
+		case DHCPImportCreated:
+			result.Created++
+		case DHCPImportUpdated:
+			result.Updated++
+		case DHCPImportUnchanged:
+			result.Unchanged++
+		default:
+			result.Skipped++
+		}
+	}
+	return result, nil
+}
+
+// validateDHCPImportHost runs the checks of POST /api/hosts on a host about to be created,
+// so an import cannot add hosts the API would refuse
+func validateDHCPImportHost(entry DHCPImportHost) error {
+	if err := sanitizeHostName(entry.Name); err != nil {
+		return err
+	}
+	if err := sanitizeMACAddress(entry.MAC); err != nil {
+		return err
+	}
+	if err := sanitizeBroadcastAddress(entry.Broadcast); err != nil {
+		return err
+	}
+	return sanitizeStaticIP(entry.StaticIP)
+}
+
+// dhcpHostName returns a host name for a device: the first label of its DHCP hostname,
+// or its MAC address when it has none that is a valid host name
+func dhcpHostName(device dhcp.Device) string {
+	name, _, _ := strings.Cut(device.Hostname, ".")
+	if sanitizeHostName(name) == nil {
+		return name
+	}
+	return strings.ReplaceAll(device.MAC, ":", "-")
+}
+
+// suggestBroadcast derives a broadcast address for a new host: the directed broadcast of
+// the subnet declared in the file or of the local network containing its IPv4 address,
+// the limited broadcast otherwise, and the all-nodes group for IPv6-only hosts
+func suggestBroadcast(device dhcp.Device, file *dhcp.File) string {
+	if device.IPv4 == nil {
+		return net.JoinHostPort(IPv6AllNodesAddress, "9")
+	}
+
+	subnet := file.Subnet(device.IPv4)
+	if subnet == nil {
+		subnet = localSubnet(device.IPv4)
+	}
+	if subnet != nil {
+		if ones, bits := subnet.Mask.Size(); bits == 32 && ones <= 30 {
+			return net.JoinHostPort(getBroadcastIP(subnet).String(), "9")
+		}
+	}
+	return "255.255.255.255:9"
+}
+
+// localSubnet returns the IPv4 network of a local interface that contains ip, or nil
+func localSubnet(ip net.IP) *net.IPNet {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil && !ipNet.IP.IsLoopback() && ipNet.Contains(ip) {
+			return ipNet
+		}
+	}
+	return nil
+}
+
+// lookupUserID returns the ID of a user by name
+func (s *Server) lookupUserID(name string) (string, error) {
+	var id string
+	err := s.DB.QueryRow("SELECT id FROM users WHERE name = ?", name).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("user '%s' not found", name)
+	}
+	return id, err
+}
+
+// dhcpImportOwner resolves the owner of imported hosts. Hosts have no owner without
+// authentication; with authentication an owner is required.
+func (s *Server) dhcpImportOwner(name string) (*string, error) {
+	if !s.Config.UseAuth {
+		if name != "" {
+			Warning("DHCP import: owner '%s' ignored - hosts have no owner when use_auth is disabled", name)
+		}
+		return nil, nil
+	}
+	if name == "" {
+		return nil, fmt.Errorf("an owner is required when use_auth is enabled")
+	}
+	id, err := s.lookupUserID(name)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// handleDHCPImport imports hosts from an uploaded DHCP lease or reservation file
+// (superuser only when auth is enabled). Hosts are created for the calling user.
+func (s *Server) handleDHCPImport(w http.ResponseWriter, r *http.Request) {
+	var user *User
+	if s.Config.UseAuth {
+		var ok bool
+		if user, ok = s.checkSuperuser(w, r); !ok {
+			return
+		}
+	}
+
+	var req DHCPImportRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxDHCPImportBytes)).Decode(&req); err != nil {
+		sendJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if !req.DryRun && (s.live().ReadOnlyMode || (s.Config.UseAuth && user != nil && user.ReadOnly)) {
+		sendJSONError(w, "Read-only access: modification not allowed", http.StatusForbidden)
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		sendJSONErrorWithCode(w, "content is required", ErrCodeMissingField, http.StatusBadRequest)
+		return
+	}
+
+	file, err := dhcp.Parse([]byte(req.Content), req.Format)
+	if err != nil {
+		sendJSONErrorWithCode(w, err.Error(), ErrCodeInvalidImport, http.StatusBadRequest)
+		return
+	}
+
+	var ownerID *string
+	if user != nil {
+		ownerID = &user.ID
+	}
+	result, err := s.importDHCP(file, ownerID, req.UpdateOnly, req.DryRun)
+	if err != nil {
+		Error("DHCP import failed: %v", err)
+		sendJSONError(w, "Failed to import hosts", http.StatusInternalServerError)
+		return
+	}
+
+	userDesc := "anonymous"
+	if user != nil {
+		userDesc = user.Name
+	}
+	Info("DHCP import (%s) by %s: %d created, %d updated, %d unchanged, %d skipped (dry run: %v)",
+		result.Format, userDesc, result.Created, result.Updated, result.Unchanged, result.Skipped, result.DryRun)
+	sendJSON(w, result, http.StatusOK)
+}
+
+// runDHCPImportFile imports hosts from a DHCP lease or reservation file
+func (s *Server) runDHCPImportFile(c DHCPImportConfig, dryRun bool) (*DHCPImportResult, error) {
+	ownerID, err := s.dhcpImportOwner(c.Owner)
+	if err != nil {
+		return nil, err
+	}
+	file, err := dhcp.ParseFile(c.Path, c.Format)
+	if err != nil {
+		return nil, err
+	}
+	return s.importDHCP(file, ownerID, c.UpdateOnly, dryRun)
+}
+
+// watchDHCPImport imports a configured lease or reservation file at startup and again
+// whenever its modification time changes
+func (s *Server) watchDHCPImport(c DHCPImportConfig) {
+	var lastModified time.Time
+	check := func() {
+		info, err := os.Stat(c.Path)
+		if err != nil {
+			if lastModified.IsZero() {
+				Warning("DHCP import: %v", err)
+				lastModified = time.Unix(0, 0) // Warn once until the file appears
+			}
+			return
+		}
+		if info.ModTime().Equal(lastModified) {
+			return
+		}
+		lastModified = info.ModTime()
+
+		result, err := s.runDHCPImportFile(c, false)
+		if err != nil {
+			Warning("DHCP import of %s failed: %v", c.Path, err)
+			return
+		}
+		if result.Created > 0 || result.Updated > 0 {
+			Info("DHCP import of %s (%s): %d created, %d updated", c.Path, result.Format, result.Created, result.Updated)
+		} else {
+			Debug("DHCP import of %s (%s): no changes", c.Path, result.Format)
+		}
+	}
+
+	check()
+	ticker := time.NewTicker(DHCPImportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			check()
+		case <-s.stopping():
+			return
+		}
+	}
+}
+
+// runDHCPImportCLI performs a one-off import from the command line and prints the result
+func (s *Server) runDHCPImportCLI(c DHCPImportConfig, dryRun bool) error {
+	result, err := s.runDHCPImportFile(c, dryRun)
+	if err != nil {
+		return err
+	}
+
+	for _, host := range result.Hosts {
+		detail := host.StaticIP
+		if host.Reason != "" {
+			detail = host.Reason
+		} else if host.Broadcast != "" {
+			detail += " (broadcast " + host.Broadcast + ")"
+		}
+		fmt.Printf("  %-9s  %-17s  %-24s  %s\n", host.Action, host.MAC, host.Name, detail)
+	}
+	fmt.Printf("\nImported %s (%s): %d created, %d updated, %d unchanged, %d skipped\n",
+		c.Path, result.Format, result.Created, result.Updated, result.Unchanged, result.Skipped)
+	if dryRun {
+		fmt.Printf("Dry run - no changes were saved.\n")
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"server/dhcp"
+)
+
+func TestImportDHCPValidatesDevices(t *testing.T) {
+	s := newTestDependencyServer(t)
+	if _, err := s.DB.Exec("INSERT INTO hosts (id, name, mac, broadcast, interface, static_ip) VALUES ('nas', 'nas', '52:54:00:00:00:02', '192.168.1.255:9', '', '192.168.1.20')"); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := dhcp.Parse([]byte(`dhcp-range=192.168.1.100,192.168.1.200,255.255.255.0,12h
+dhcp-host=52:54:00:00:00:01,192.168.1.5,printer
+dhcp-host=52:54:00:00:00:02,192.168.1.6,nas
+dhcp-host=01:00:5e:00:00:fb,192.168.1.7,mdns
+dhcp-host=33:33:00:00:00:01,192.168.1.8,all-nodes
+dhcp-host=52:54:00:00:00:03,127.0.0.1,loopback
+`), dhcp.FormatDnsmasq)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := s.importDHCP(file, nil, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"52:54:00:00:00:01": DHCPImportCreated,
+		"52:54:00:00:00:02": DHCPImportUpdated,
+		"01:00:5e:00:00:fb": DHCPImportSkipped, // Multicast
+		"33:33:00:00:00:01": DHCPImportSkipped, // Multicast
+		"52:54:00:00:00:03": DHCPImportSkipped, // Loopback address
+	}
+	if len(result.Hosts) != len(want) {
+		t.Fatalf("got %d hosts, want %d: %+v", len(result.Hosts), len(want), result.Hosts)
+	}
+	for _, host := range result.Hosts {
+		if host.Action != want[host.MAC] {
+			t.Errorf("%s: action = %s (%s), want %s", host.MAC, host.Action, host.Reason, want[host.MAC])
+		}
+		if host.Action == DHCPImportCreated && host.Broadcast != "192.168.1.255:9" {
+			t.Errorf("%s: broadcast = %s, want 192.168.1.255:9", host.MAC, host.Broadcast)
+		}
+	}
+	if result.Created != 1 || result.Updated != 1 || result.Skipped != 3 {
+		t.Errorf("created %d, updated %d, skipped %d; want 1, 1, 3", result.Created, result.Updated, result.Skipped)
+	}
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiscoveryRequest selects the networks covered by a discovery scan
+type DiscoveryRequest struct {
+	Interfaces     string   `json:"interfaces"`      // Comma-separated interface names (empty = all active interfaces)
+	CIDRs          []string `json:"cidrs"`           // IPv4 networks to scan (empty = the networks of the interfaces)
+	TimeoutSeconds int      `json:"timeout_seconds"` // Overall scan timeout (default DiscoveryDefaultTimeout)
+	IPv6           bool     `json:"ipv6"`            // Also list IPv6 nodes answering an all-nodes ping
+	ResolveNames   *bool    `json:"resolve_names"`   // Look up DNS/mDNS/NetBIOS names (default true)
+}
+
+// DiscoveredDevice is one IP/MAC pair that answered a discovery scan
+type DiscoveredDevice struct {
+	IP                  string   `json:"ip"`
+	MAC                 string   `json:"mac"`
+	Interface           string   `json:"interface"`
+	Broadcast           string   `json:"broadcast,omitempty"` // Suggested broadcast address for new hosts
+	Name                string   `json:"name,omitempty"`      // Suggested host name (from the names below)
+	DNSName             string   `json:"dns_name,omitempty"`
+	MDNSName            string   `json:"mdns_name,omitempty"`
+	NetBIOSName         string   `json:"netbios_name,omitempty"`
+	Vendor              string   `json:"vendor,omitempty"` // Organization of the MAC address prefix (OUI database)
+	LocallyAdministered bool     `json:"mac_locally_administered,omitempty"`
+	RandomizedMAC       bool     `json:"mac_randomized,omitempty"`
+	Known               bool     `json:"known"`              // A host with this MAC address already exists
+	HostIDs             []string `json:"host_ids,omitempty"` // Hosts with this MAC address
+}
+
+// DiscoveryResult is the response of a discovery scan
+type DiscoveryResult struct {
+	Devices    []DiscoveredDevice `json:"devices"`
+	Scanned    []string           `json:"scanned"`           // Networks that were swept ("192.168.1.0/24 on eth0")
+	Skipped    []string           `json:"skipped,omitempty"` // Networks that were not swept, with the reason
+	TimedOut   bool               `json:"timed_out"`
+	DurationMS int64              `json:"duration_ms"`
+}
+
+// discoveryTarget is an IPv4 network to sweep through one interface
+type discoveryTarget struct {
+	network   *net.IPNet
+	iface     string
+	broadcast string
+}
+
+// discoveryMutex allows one discovery scan at a time - sweeps put real load on the network
+var discoveryMutex sync.Mutex
+
+// hostNameCleanup removes characters that are not allowed in host names
+var hostNameCleanup = regexp.MustCompile(`[^\p{L}\p{N}\-\._\s]+`)
+
+// handleDiscoveryScan handles POST /api/discovery/scan (superuser only when auth is enabled)
+//
+// Sweeps the selected interfaces or CIDRs with ARP and returns every IP/MAC pair that
+// answered, together with the names the devices are known by and whether a host with
+// the MAC address already exists, so devices can be added without copying MAC addresses.
+func (s *Server) handleDiscoveryScan(w http.ResponseWriter, r *http.Request) {
+	if s.Config.UseAuth {
+		if _, ok := s.checkSuperuser(w, r); !ok {
+			return
+		}
+	}
+
+	var req DiscoveryRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendJSONErrorWithCode(w, "Invalid request body", ErrCodeInvalidInput, http.StatusBadRequest)
+			return
+		}
+	}
+
+	req.Interfaces = strings.TrimSpace(req.Interfaces)
+	if err := validateNetworkInterface(req.Interfaces); err != nil {
+		handleValidationError(w, err, http.StatusBadRequest)
+		return
+	}
+	if req.TimeoutSeconds == 0 {
+		req.TimeoutSeconds = int(DiscoveryDefaultTimeout / time.Second)
+	}
+	if req.TimeoutSeconds < 1 || req.TimeoutSeconds > int(DiscoveryMaxTimeout/time.Second) {
+		sendJSONErrorWithCode(w, fmt.Sprintf("timeout_seconds must be between 1-%d", int(DiscoveryMaxTimeout/time.Second)), ErrCodeInvalidInput, http.StatusBadRequest)
+		return
+	}
+
+	targets, skipped, err := planDiscovery(req)
+	if err != nil {
+		handleValidationError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if !discoveryMutex.TryLock() {
+		sendJSONErrorWithCode(w, "A discovery scan is already running", ErrCodeRateLimited, http.StatusConflict)
+		return
+	}
+	defer discoveryMutex.Unlock()
+
+	Info("Discovery scan of %d network(s) started (timeout: %ds, IPv6: %v)", len(targets), req.TimeoutSeconds, req.IPv6)
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(req.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	result := DiscoveryResult{Devices: []DiscoveredDevice{}, Scanned: []string{}, Skipped: skipped}
+	devices := s.runDiscovery(ctx, req, targets, &result)
+	result.TimedOut = ctx.Err() == context.DeadlineExceeded
+
+	if req.ResolveNames == nil || *req.ResolveNames {
+		resolveDeviceNames(devices)
+	}
+	if err := s.markKnownDevices(devices); err != nil {
+		Error("Discovery scan: failed to compare with hosts: %v", err)
+		sendJSONErrorWithCode(w, "Failed to load hosts", ErrCodeDatabaseError, http.StatusInternalServerError)
+		return
+	}
+
+	result.Devices = devices
+	result.DurationMS = time.Since(start).Milliseconds()
+	Info("Discovery scan finished in %dms: %d device(s), timed out: %v", result.DurationMS, len(devices), result.TimedOut)
+	sendJSON(w, result, http.StatusOK)
+}
+
+// planDiscovery determines the networks and interfaces to sweep
+func planDiscovery(req DiscoveryRequest) ([]discoveryTarget, []string, error) {
+	interfaces, err := discoveryInterfaces(req.Interfaces)
+	if err != nil {
+		return nil, nil, &ValidationError{Code: ErrCodeNetworkError, Message: err.Error()}
+	}
+
+	// The IPv4 networks of the interfaces
+	var onLink []discoveryTarget
+	for _, iface := range interfaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.To4() == nil || ipNet.IP.IsLoopback() {
+				continue
+			}
+			network := &net.IPNet{IP: ipNet.IP.To4().Mask(ipNet.Mask), Mask: ipNet.Mask}
+			onLink = append(onLink, discoveryTarget{
+				network:   network,
+				iface:     iface.Name,
+				broadcast: net.JoinHostPort(getBroadcastIP(ipNet).String(), "9"),
+			})
+		}
+	}
+
+	var targets, result []discoveryTarget
+	var skipped []string
+	if len(req.CIDRs) == 0 {
+		targets = onLink
+	}
+	for _, cidr := range req.CIDRs {
+		_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil || network.IP.To4() == nil {
+			return nil, nil, &ValidationError{Code: ErrCodeInvalidIP, Message: fmt.Sprintf("invalid IPv4 CIDR: %s", cidr)}
+		}
+		if addresses := networkSize(network); addresses > DiscoveryMaxAddresses {
+			return nil, nil, &ValidationError{Code: ErrCodeInvalidInput, Message: fmt.Sprintf("%s has %d addresses (max %d)", network, addresses, DiscoveryMaxAddresses)}
+		}
+
+		// ARP only reaches directly connected networks
+		found := false
+		for _, link := range onLink {
+			if link.network.Contains(network.IP) {
+				targets = append(targets, discoveryTarget{network: network, iface: link.iface, broadcast: link.broadcast})
+				found = true
+				break
+			}
+		}
+		if !found {
+			skipped = append(skipped, fmt.Sprintf("%s: not on a directly connected network", network))
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, target := range targets {
+		key := target.network.String() + "|" + target.iface
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if addresses := networkSize(target.network); addresses > DiscoveryMaxAddresses {
+			skipped = append(skipped, fmt.Sprintf("%s on %s: %d addresses (max %d) - select a smaller CIDR", target.network, target.iface, addresses, DiscoveryMaxAddresses))
+			continue
+		}
+		result = append(result, target)
+	}
+	return result, skipped, nil
+}
+
+// discoveryInterfaces returns the selected interfaces, or all active non-loopback interfaces
+func discoveryInterfaces(names string) ([]net.Interface, error) {
+	if names != "" {
+		var result []net.Interface
+		for _, name := range strings.Split(names, ",") {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			iface, err := net.InterfaceByName(name)
+			if err != nil {
+				return nil, fmt.Errorf("interface %s not found: %w", name, err)
+			}
+			result = append(result, *iface)
+		}
+		return result, nil
+	}
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network interfaces: %w", err)
+	}
+	var result []net.Interface
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp != 0 && iface.Flags&net.FlagLoopback == 0 {
+			result = append(result, iface)
+		}
+	}
+	return result, nil
+}
+
+// networkSize returns the number of addresses in a network
+func networkSize(network *net.IPNet) int {
+	ones, bits := network.Mask.Size()
+	if bits-ones >= 31 {
+		return 1 << 30
+	}
+	return 1 << (bits - ones)
+}
+
+// runDiscovery sweeps all targets in parallel and collects the answering devices.
+// Neighbour table entries inside the targets are included as well - they cover devices
+// that ignore ARP from unknown senders and platforms without active ARP scanning.
+func (s *Server) runDiscovery(ctx context.Context, req DiscoveryRequest, targets []discoveryTarget, result *DiscoveryResult) []DiscoveredDevice {
+	var mutex sync.Mutex
+	devices := make(map[string]*DiscoveredDevice) // "ip|mac" -> device
+	add := func(ip net.IP, hwAddr net.HardwareAddr, iface, broadcast string) {
+		mac := normalizeMACAddress(hwAddr.String())
+		key := ip.String() + "|" + mac
+		mutex.Lock()
+		defer mutex.Unlock()
+		if _, exists := devices[key]; !exists {
+			devices[key] = &DiscoveredDevice{IP: ip.String(), MAC: mac, Interface: iface, Broadcast: broadcast}
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target discoveryTarget) {
+			defer wg.Done()
+			err := DiscoverSubnet(ctx, target.network, target.iface, func(ip net.IP, hwAddr net.HardwareAddr) bool {
+				add(ip, hwAddr, target.iface, target.broadcast)
+				return true
+			})
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			desc := fmt.Sprintf("%s on %s", target.network, target.iface)
+			if err != nil && err != context.DeadlineExceeded && err != context.Canceled {
+				Debug("Discovery sweep of %s: %v", desc, err)
+				result.Skipped = append(result.Skipped, fmt.Sprintf("%s: %v (neighbour table only)", desc, err))
+				return
+			}
+			result.Scanned = append(result.Scanned, desc)
+		}(target)
+	}
+
+	if req.IPv6 {
+		interfaces, _ := ipv6Interfaces(req.Interfaces)
+		for _, iface := range interfaces {
+			wg.Add(1)
+			go func(iface net.Interface) {
+				defer wg.Done()
+				neighbors, err := DiscoverIPv6Neighbors(ctx, iface)
+				mutex.Lock()
+				if err != nil {
+					result.Skipped = append(result.Skipped, fmt.Sprintf("IPv6 on %s: %v", iface.Name, err))
+				} else {
+					result.Scanned = append(result.Scanned, fmt.Sprintf("IPv6 all-nodes on %s", iface.Name))
+				}
+				mutex.Unlock()
+				for _, n := range neighbors {
+					add(n.IP, n.MAC, iface.Name, net.JoinHostPort(IPv6AllNodesAddress, "9"))
+				}
+			}(iface)
+		}
+	}
+	wg.Wait()
+
+	if neighbors, err := ListNeighbors(NeighborFilter{Interfaces: req.Interfaces, States: NUDValid, Family: 4}); err == nil {
+		for _, n := range neighbors {
+			for _, target := range targets {
+				if target.network.Contains(n.IP) && (n.Interface == "" || n.Interface == target.iface) {
+					add(n.IP, n.MAC, target.iface, target.broadcast)
+					break
+				}
+			}
+		}
+	}
+
+	list := make([]DiscoveredDevice, 0, len(devices))
+	for _, device := range devices {
+		if len(device.MAC) == 17 && device.MAC != "00:00:00:00:00:00" {
+			info := s.Vendors.Lookup(device.MAC)
+			device.Vendor = info.Vendor
+			device.LocallyAdministered = info.LocallyAdministered
+			device.RandomizedMAC = info.Randomized
+			list = append(list, *device)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := net.ParseIP(list[i].IP), net.ParseIP(list[j].IP)
+		if (a.To4() == nil) != (b.To4() == nil) {
+			return a.To4() != nil // IPv4 first
+		}
+		return string(a.To16()) < string(b.To16())
+	})
+	return list
+}
+
+// resolveDeviceNames looks up reverse DNS, mDNS and NetBIOS names for all devices in parallel
+func resolveDeviceNames(devices []DiscoveredDevice) {
+	semaphore := make(chan struct{}, DiscoveryNameWorkers)
+	var wg sync.WaitGroup
+	for i := range devices {
+		wg.Add(1)
+		go func(device *DiscoveredDevice) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			ctx, cancel := context.WithTimeout(context.Background(), DiscoveryNameTimeout)
+			defer cancel()
+
+			ip := net.ParseIP(device.IP)
+			var names sync.WaitGroup
+			names.Add(3)
+			go func() { defer names.Done(); device.DNSName = lookupReverseDNS(ctx, ip) }()
+			go func() { defer names.Done(); device.MDNSName = lookupMDNS(ctx, ip, device.Interface) }()
+			go func() { defer names.Done(); device.NetBIOSName = lookupNetBIOS(ctx, ip) }()
+			names.Wait()
+
+			device.Name = suggestHostName(device.MDNSName, device.DNSName, device.NetBIOSName)
+		}(&devices[i])
+	}
+	wg.Wait()
+}
+
+// suggestHostName derives a valid host name from the first available device name
+func suggestHostName(mdnsName, dnsName, netbiosName string) string {
+	name := ""
+	switch {
+	case mdnsName != "":
+		name = strings.TrimSuffix(mdnsName, ".local")
+	case dnsName != "":
+		name = strings.SplitN(dnsName, ".", 2)[0]
+	case netbiosName != "":
+		name = netbiosName
+	}
+
+	name = strings.TrimSpace(hostNameCleanup.ReplaceAllString(name, "-"))
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	if sanitizeHostName(name) != nil {
+		return ""
+	}
+	return name
+}
+
+// markKnownDevices flags devices whose MAC address belongs to an existing host (of any owner)
+func (s *Server) markKnownDevices(devices []DiscoveredDevice) error {
+	rows, err := s.DB.Query("SELECT id, mac FROM hosts ORDER BY created")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	hostsByMAC := make(map[string][]string)
+	for rows.Next() {
+		var id, mac string
+		if err := rows.Scan(&id, &mac); err != nil {
+			return err
+		}
+		mac = normalizeMACAddress(mac)
+		hostsByMAC[mac] = append(hostsByMAC[mac], id)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range devices {
+		devices[i].HostIDs = hostsByMAC[devices[i].MAC]
+		devices[i].Known = len(devices[i].HostIDs) > 0
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// DNS record types and classes used by name lookups
+const (
+	dnsTypePTR    = 12
+	dnsTypeNBSTAT = 33
+	dnsClassIN    = 1
+)
+
+// lookupReverseDNS returns the PTR name of an address from the system resolver
+func lookupReverseDNS(ctx context.Context, ip net.IP) string {
+	names, err := net.DefaultResolver.LookupAddr(ctx, ip.String())
+	if err != nil || len(names) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(names[0], ".")
+}
+
+// lookupMDNS asks the device itself for its name with a unicast mDNS PTR query to port 5353.
+// Responders such as Avahi and Bonjour answer one-shot queries from ordinary ports directly.
+func lookupMDNS(ctx context.Context, ip net.IP, iface string) string {
+	id := randomUint16()
+	query := marshalDNSQuery(id, reverseDNSName(ip), dnsTypePTR)
+
+	response, err := exchangeUDP(ctx, ip, iface, 5353, query)
+	if err != nil {
+		return ""
+	}
+	name, err := parsePTRResponse(response, id)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(name, ".")
+}
+
+// lookupNetBIOS queries the NetBIOS node status of a device (UDP 137) and returns its
+// workstation name - useful for Windows machines and Samba servers without DNS entries
+func lookupNetBIOS(ctx context.Context, ip net.IP) string {
+	if ip.To4() == nil {
+		return "" // NetBIOS over TCP/IP is IPv4 only
+	}
+
+	id := randomUint16()
+	// The wildcard name "*" padded with NUL bytes, in NetBIOS first-level encoding
+	query := marshalDNSQuery(id, "CK"+strings.Repeat("A", 30), dnsTypeNBSTAT)
+	binary.BigEndian.PutUint16(query[2:4], 0) // No recursion desired
+
+	response, err := exchangeUDP(ctx, ip, "", 137, query)
+	if err != nil {
+		return ""
+	}
+	return parseNetBIOSStatus(response, id)
+}
+
+// exchangeUDP sends a query and returns the first reply from the queried address
+func exchangeUDP(ctx context.Context, ip net.IP, iface string, port int, query []byte) ([]byte, error) {
+	zone := ""
+	if ip.IsLinkLocalUnicast() {
+		zone = iface
+	}
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: ip, Port: port, Zone: zone})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(DiscoveryNameTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 1500)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// marshalDNSQuery builds a single-question DNS query (recursion desired)
+func marshalDNSQuery(id uint16, name string, qtype uint16) []byte {
+	msg := make([]byte, 12, 12+len(name)+6)
+	binary.BigEndian.PutUint16(msg[0:2], id)
+	binary.BigEndian.PutUint16(msg[2:4], 0x0100)
+	binary.BigEndian.PutUint16(msg[4:6], 1)
+
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+	msg = binary.BigEndian.AppendUint16(msg, qtype)
+	msg = binary.BigEndian.AppendUint16(msg, dnsClassIN)
+	return msg
+}
+
+// parsePTRResponse returns the first PTR name in a DNS response
+func parsePTRResponse(msg []byte, id uint16) (string, error) {
+	offset, answers, err := skipDNSQuestions(msg, id)
+	if err != nil {
+		return "", err
+	}
+
+	for i := 0; i < answers; i++ {
+		if _, offset, err = readDNSName(msg, offset); err != nil {
+			return "", err
+		}
+		if offset+10 > len(msg) {
+			return "", errors.New("truncated DNS answer")
+		}
+		rrType := binary.BigEndian.Uint16(msg[offset : offset+2])
+		length := int(binary.BigEndian.Uint16(msg[offset+8 : offset+10]))
+		offset += 10
+		if offset+length > len(msg) {
+			return "", errors.New("truncated DNS answer")
+		}
+		if rrType == dnsTypePTR {
+			name, _, err := readDNSName(msg, offset)
+			return name, err
+		}
+		offset += length
+	}
+	return "", errors.New("no PTR record in response")
+}
+
+// parseNetBIOSStatus returns the unique workstation (suffix 0x00) name from a node status response
+func parseNetBIOSStatus(msg []byte, id uint16) string {
+	offset, answers, err := skipDNSQuestions(msg, id)
+	if err != nil || answers == 0 {
+		return ""
+	}
+	if _, offset, err = readDNSName(msg, offset); err != nil || offset+11 > len(msg) {
+		return ""
+	}
+	offset += 10 // Type, class, TTL and length
+
+	count := int(msg[offset])
+	offset++
+	for i := 0; i < count && offset+18 <= len(msg); i++ {
+		entry := msg[offset : offset+18]
+		offset += 18
+
+		suffix := entry[15]
+		group := binary.BigEndian.Uint16(entry[16:18])&0x8000 != 0
+		if suffix == 0x00 && !group {
+			return strings.TrimRight(string(entry[:15]), " \x00")
+		}
+	}
+	return ""
+}
+
+// skipDNSQuestions validates a response header and returns the offset of the first answer
+// and the number of answers
+func skipDNSQuestions(msg []byte, id uint16) (int, int, error) {
+	if len(msg) < 12 {
+		return 0, 0, errors.New("truncated DNS header")
+	}
+	// mDNS responders answer with id 0 as well as with the query id
+	if responseID := binary.BigEndian.Uint16(msg[0:2]); responseID != id && responseID != 0 {
+		return 0, 0, errors.New("DNS response id mismatch")
+	}
+	if msg[2]&0x80 == 0 {
+		return 0, 0, errors.New("not a DNS response")
+	}
+
+	questions := int(binary.BigEndian.Uint16(msg[4:6]))
+	answers := int(binary.BigEndian.Uint16(msg[6:8]))
+	offset := 12
+	for i := 0; i < questions; i++ {
+		var err error
+		if _, offset, err = readDNSName(msg, offset); err != nil {
+			return 0, 0, err
+		}
+		offset += 4 // Type and class
+	}
+	if offset > len(msg) {
+		return 0, 0, errors.New("truncated DNS question")
+	}
+	return offset, answers, nil
+}
+
+// readDNSName decodes a (possibly compressed) domain name and returns it with the offset after it
+func readDNSName(msg []byte, offset int) (string, int, error) {
+	var labels []string
+	next := -1 // Offset after the name, set at the first compression pointer
+	for jumps := 0; ; {
+		if offset >= len(msg) {
+			return "", 0, errors.New("truncated DNS name")
+		}
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			if next < 0 {
+				next = offset + 1
+			}
+			return strings.Join(labels, ".") + ".", next, nil
+		case length&0xC0 == 0xC0:
+			if offset+1 >= len(msg) {
+				return "", 0, errors.New("truncated DNS name")
+			}
+			if jumps++; jumps > 10 {
+				return "", 0, errors.New("DNS name compression loop")
+			}
+			if next < 0 {
+				next = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(msg[offset:offset+2]) & 0x3FFF)
+		default:
+			if offset+1+length > len(msg) {
+				return "", 0, errors.New("truncated DNS label")
+			}
+			labels = append(labels, string(msg[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+}
+
+// reverseDNSName returns the in-addr.arpa or ip6.arpa name of an address
+func reverseDNSName(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa.", ip4[3], ip4[2], ip4[1], ip4[0])
+	}
+	const hexDigits = "0123456789abcdef"
+	ip16 := ip.To16()
+	var b strings.Builder
+	for i := len(ip16) - 1; i >= 0; i-- {
+		b.WriteByte(hexDigits[ip16[i]&0x0F])
+		b.WriteByte('.')
+		b.WriteByte(hexDigits[ip16[i]>>4])
+		b.WriteByte('.')
+	}
+	b.WriteString("ip6.arpa.")
+	return b.String()
+}
+
+// randomUint16 returns a random DNS transaction id
+func randomUint16() uint16 {
+	b := make([]byte, 2)
+	rand.Read(b)
+	return binary.BigEndian.Uint16(b)
+}
@@ -19,6 +19,7 @@ const (
 	ErrCodeInvalidCredentials = "ERR_INVALID_CREDENTIALS"
 	ErrCodeSessionExpired     = "ERR_SESSION_EXPIRED"
 	ErrCodeForbidden          = "ERR_FORBIDDEN"
+	ErrCodeClientCertRequired = "ERR_CLIENT_CERT_REQUIRED"
 
 	// Validation errors
 	ErrCodeInvalidInput      = "ERR_INVALID_INPUT"
@@ -43,6 +44,7 @@ const (
 	ErrCodeOperationFailed  = "ERR_OPERATION_FAILED"
 	ErrCodeNetworkError     = "ERR_NETWORK_ERROR"
 	ErrCodeDatabaseError    = "ERR_DATABASE_ERROR"
+	ErrCodeSettingLocked    = "ERR_SETTING_LOCKED"
 
 	// Wake-on-LAN errors
 	ErrCodeWakeFailed       = "ERR_WAKE_FAILED"
@@ -64,4 +66,18 @@ const (
 	// Host errors
 	ErrCodeHostNotFound     = "ERR_HOST_NOT_FOUND"
 	ErrCodeHostExists       = "ERR_HOST_EXISTS"
+
+	// Wake chain errors
+	ErrCodeDependencyCycle    = "ERR_DEPENDENCY_CYCLE"
+	ErrCodeInvalidDependency  = "ERR_INVALID_DEPENDENCY"
+
+	// Service check errors
+	ErrCodeInvalidService     = "ERR_INVALID_SERVICE"
+
+	// DHCP import errors
+	ErrCodeInvalidImport      = "ERR_INVALID_IMPORT"
+
+	// Power action errors
+	ErrCodeFeatureDisabled    = "ERR_FEATURE_DISABLED"
+	ErrCodeHostOffline        = "ERR_HOST_OFFLINE"
 )
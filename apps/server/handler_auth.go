@@ -43,7 +43,7 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		Value:    session.ID,
 		Expires:  session.Expires,
 		HttpOnly: true,
-		Secure:   s.Config.BehindProxy, // Secure when behind reverse proxy (Nginx, Caddy, etc.)
+		Secure:   s.Config.BehindProxy || r.TLS != nil, // Secure behind a reverse proxy (Nginx, Caddy, etc.) or with native HTTPS
 		SameSite: http.SameSiteStrictMode,
 		Path:     "/",
 	}
@@ -166,7 +166,7 @@ func (s *Server) handleInitialSetup(w http.ResponseWriter, r *http.Request) {
 	// Create superuser
 	userID, err := generateID()
 	if err != nil {
-		Error("Failed to generate user ID: %v", err)
+		authLog.Error("Failed to generate user ID: %v", err)
 		sendJSONError(w, "Failed to create superuser", http.StatusInternalServerError)
 		return
 	}
@@ -199,7 +199,7 @@ func (s *Server) handleHasSuperuser(w http.ResponseWriter, r *http.Request) {
 	var count int
 	err := s.DB.QueryRow("SELECT COUNT(*) FROM users WHERE is_superuser = TRUE").Scan(&count)
 	if err != nil {
-		Error("Failed to check superuser status: %v", err)
+		authLog.Error("Failed to check superuser status: %v", err)
 		sendJSONError(w, "Database error", http.StatusInternalServerError)
 		return
 	}
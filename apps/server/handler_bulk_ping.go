@@ -55,7 +55,7 @@ func (s *Server) handleBulkPing(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Use hardcoded time window from ping timeout config
-	pingWindow := time.Duration(s.Config.PingTimeout) * time.Second
+	pingWindow := time.Duration(s.live().PingTimeout) * time.Second
 
 	// Check rate limit with dynamic limit
 	if !s.checkDynamicRateLimit(userKey, dynamicLimit, pingWindow) {
@@ -122,8 +122,6 @@ func (s *Server) handleBulkPing(w http.ResponseWriter, r *http.Request) {
 	for i, host := range hosts {
 		go func(idx int, h Host) {
 			cacheKey := h.ID
-			var pingSuccess bool
-			var arpSuccess bool
 
 			// Check cache first
 			if cachedEntry := s.PingCache.Get(cacheKey); cachedEntry != nil {
@@ -134,7 +132,9 @@ func (s *Server) handleBulkPing(w http.ResponseWriter, r *http.Request) {
 					"ping_success": cachedEntry.PingSuccess,
 					"arp_success":  cachedEntry.ARPSuccess,
 				}
-				resultChan <- pingResult{index: idx, result: result}
+				addRTT(result, cachedEntry.RTT)
+				addServices(result, cachedEntry.Services)
+				resultChan <- pingResult{index: idx, result: s.addAgentStatus(result, h)}
 				return
 			}
 
@@ -151,9 +151,11 @@ func (s *Server) handleBulkPing(w http.ResponseWriter, r *http.Request) {
 						"ping_success": res.PingSuccess,
 						"arp_success":  res.ARPSuccess,
 					}
-					resultChan <- pingResult{index: idx, result: result}
+					addRTT(result, res.RTT)
+					addServices(result, res.Services)
+					resultChan <- pingResult{index: idx, result: s.addAgentStatus(result, h)}
 					return
-				case <-time.After(time.Duration(s.Config.PingTimeout+5) * time.Second):
+				case <-time.After(time.Duration(s.live().PingTimeout+5) * time.Second):
 					// Timeout - return offline
 					result := map[string]interface{}{
 						"host_id":      h.ID,
@@ -161,121 +163,30 @@ func (s *Server) handleBulkPing(w http.ResponseWriter, r *http.Request) {
 						"ping_success": false,
 						"arp_success":  false,
 					}
-					resultChan <- pingResult{index: idx, result: result}
+					resultChan <- pingResult{index: idx, result: s.addAgentStatus(result, h)}
 					return
 				}
 			}
 
-			// Determine which network interface(s) to use
-			interfaceToUse := s.determineNetworkInterface(h)
-
-			// Check if static IP is configured (not fallback)
-			if h.StaticIP != "" && !h.UseAsFallback {
-				// Use static IP directly
-				hwAddr, err := ARPPingIP(h.StaticIP, interfaceToUse)
-				if err == nil && hwAddr != nil {
-					arpSuccess = true
-					pingSuccess = true
-				} else {
-					pingSuccess = false
-					arpSuccess = false
-				}
-
-				s.PingCache.Set(cacheKey, pingSuccess, arpSuccess)
-				result := map[string]interface{}{
-					"host_id":      h.ID,
-					"host_name":    h.Name,
-					"ping_success": pingSuccess,
-					"arp_success":  arpSuccess,
-				}
-				resultChan <- pingResult{index: idx, result: result}
-				return
-			}
-
-			// Try to resolve IP from MAC first (passive ARP table lookup)
-			hostIP, ipErr := GetIPFromMAC(h.MAC)
-
-			if ipErr == nil {
-				// IP found in ARP table - now verify host is actually online using ARP ping
-				hwAddr, err := ARPPingIP(hostIP, interfaceToUse)
-				if err == nil && hwAddr != nil {
-					// Host responded to ARP ping
-					arpSuccess = true
-					pingSuccess = true
-
-					// Verify MAC matches
-					if normalizeMACAddress(hwAddr.String()) != normalizeMACAddress(h.MAC) {
-						Warning("Host '%s' MAC mismatch - stored: %s, detected: %s at %s",
-							h.Name, h.MAC, hwAddr.String(), hostIP)
-					}
-				} else {
-					// Host in ARP table but not responding - flush stale entry for recovery
-					FlushARPEntryIfExists(hostIP)
-					Warning("Bulk ping - host '%s' not responding, flushed ARP cache for IP %s", h.Name, hostIP)
-					pingSuccess = false
-					arpSuccess = false
-				}
-			} else {
-				// IP not in ARP table - do full network scan to find host by MAC
-				_, pingOk, arpErr := ARPPingMAC(h.MAC, interfaceToUse, s.Config.PingTimeout)
-				if arpErr == nil {
-					// Active ARP scan succeeded
-					pingSuccess = pingOk
-					arpSuccess = true
-				} else {
-					// ARP methods failed - try static IP as fallback if configured
-					if h.StaticIP != "" && h.UseAsFallback {
-						hwAddr, fallbackErr := ARPPingIP(h.StaticIP, interfaceToUse)
-						if fallbackErr == nil && hwAddr != nil {
-							arpSuccess = true
-							pingSuccess = true
-						} else {
-							// Both ARP and fallback failed
-							if ipErr == nil && hostIP != "" {
-								FlushARPEntryIfExists(hostIP)
-							}
-							s.PingCache.Set(cacheKey, false, false)
-							result := map[string]interface{}{
-								"host_id":      h.ID,
-								"host_name":    h.Name,
-								"ping_success": false,
-								"arp_success":  false,
-							}
-							resultChan <- pingResult{index: idx, result: result}
-							return
-						}
-					} else {
-						// Both ARP methods failed - host is offline
-						// Flush any potential stale ARP entry from earlier lookup
-						if ipErr == nil && hostIP != "" {
-							FlushARPEntryIfExists(hostIP)
-							Debug("Bulk ping - both ARP methods failed for host '%s', flushed cache for IP %s", h.Name, hostIP)
-						}
-						s.PingCache.Set(cacheKey, false, false)
-						result := map[string]interface{}{
-							"host_id":      h.ID,
-							"host_name":    h.Name,
-							"ping_success": false,
-							"arp_success":  false,
-						}
-						resultChan <- pingResult{index: idx, result: result}
-						return
-					}
-				}
-			}
+			// Check host status using passive ARP lookup, active ARP scan and static IP
+			probe := s.probeHost(r.Context(), h, false)
+			s.checkHostServices(r.Context(), h, &probe)
 
 			// Store result in cache
-			s.PingCache.Set(cacheKey, pingSuccess, arpSuccess)
+			s.PingCache.Set(cacheKey, probe)
+			s.trackHostAddress(r.Context(), h, probe)
 
 			// Frontend response (no sensitive data - no MAC, no IP)
 			result := map[string]interface{}{
 				"host_id":      h.ID,
 				"host_name":    h.Name,
-				"ping_success": pingSuccess,
-				"arp_success":  arpSuccess,
+				"ping_success": probe.PingSuccess,
+				"arp_success":  probe.ARPSuccess,
 			}
+			addRTT(result, probe.RTT)
+			addServices(result, probe.Services)
 
-			resultChan <- pingResult{index: idx, result: result}
+			resultChan <- pingResult{index: idx, result: s.addAgentStatus(result, h)}
 		}(i, host)
 	}
 
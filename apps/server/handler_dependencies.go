@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// handleHostDependencies handles GET (list) and PUT (replace) for a host's wake dependencies
+func (s *Server) handleHostDependencies(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r)
+	hostID := mux.Vars(r)["id"]
+
+	host, ok := s.loadAccessibleHost(w, user, hostID)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		deps, err := s.loadHostDependencies(hostID)
+		if err != nil {
+			Error("Failed to load dependencies for host %s: %v", hostID, err)
+			sendJSONError(w, "Failed to fetch dependencies", http.StatusInternalServerError)
+			return
+		}
+		if deps == nil {
+			deps = []HostDependency{}
+		}
+		sendJSON(w, deps, http.StatusOK)
+	case "PUT":
+		s.updateHostDependencies(w, r, user, hostID, host.Name)
+	}
+}
+
+// updateHostDependencies replaces the dependency list of a host after validating
+// ownership, limits and that the resulting graph contains no cycles
+func (s *Server) updateHostDependencies(w http.ResponseWriter, r *http.Request, user *User, hostID, hostName string) {
+	userDesc := "anonymous"
+	if user != nil {
+		userDesc = user.ID
+	}
+
+	// Check if modifications are allowed
+	if s.live().ReadOnlyMode || (s.Config.UseAuth && user != nil && user.ReadOnly) {
+		Debug("Update dependencies denied for user %s (readonly mode)", userDesc)
+		sendJSONError(w, "Read-only access: modification not allowed", http.StatusForbidden)
+		return
+	}
+
+	var deps []HostDependency
+	if err := json.NewDecoder(r.Body).Decode(&deps); err != nil {
+		sendJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if len(deps) > MaxHostDependencies {
+		sendJSONErrorWithCode(w, fmt.Sprintf("Too many dependencies (max %d)", MaxHostDependencies), ErrCodeInvalidDependency, http.StatusBadRequest)
+		return
+	}
+
+	scope, scopeArgs := s.hostScopeQuery(user)
+	seen := make(map[string]bool)
+	for i := range deps {
+		dep := &deps[i]
+		dep.DependsOnID = strings.TrimSpace(dep.DependsOnID)
+		dep.DependsOnName = ""
+
+		if dep.DependsOnID == "" {
+			sendJSONErrorWithCode(w, "Dependency host ID is required", ErrCodeMissingField, http.StatusBadRequest)
+			return
+		}
+		if seen[dep.DependsOnID] {
+			sendJSONErrorWithCode(w, "Duplicate dependency: "+dep.DependsOnID, ErrCodeInvalidDependency, http.StatusBadRequest)
+			return
+		}
+		seen[dep.DependsOnID] = true
+
+		if dep.DelaySeconds < 0 || dep.DelaySeconds > MaxWakeChainDelaySeconds {
+			sendJSONErrorWithCode(w, fmt.Sprintf("delay_seconds must be between 0-%d", MaxWakeChainDelaySeconds), ErrCodeInvalidDependency, http.StatusBadRequest)
+			return
+		}
+		if dep.TimeoutSeconds < 0 || dep.TimeoutSeconds > MaxWakeChainTimeoutSeconds {
+			sendJSONErrorWithCode(w, fmt.Sprintf("timeout_seconds must be between 0-%d", MaxWakeChainTimeoutSeconds), ErrCodeInvalidDependency, http.StatusBadRequest)
+			return
+		}
+
+		// Dependencies must be hosts visible to the same user
+		var exists bool
+		err := s.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM hosts WHERE id = ? AND "+scope+")", append([]interface{}{dep.DependsOnID}, scopeArgs...)...).Scan(&exists)
+		if err != nil {
+			sendJSONError(w, "Failed to validate dependencies", http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			sendJSONErrorWithCode(w, "Dependency host not found: "+dep.DependsOnID, ErrCodeHostNotFound, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Replace dependencies atomically. The graph is read inside the transaction, so concurrent
+	// updates (A->B and B->A) cannot both pass the cycle check: SQLite serializes the writers and
+	// a transaction whose snapshot is stale fails instead of committing.
+	tx, err := s.DB.Begin()
+	if err != nil {
+		sendJSONError(w, "Failed to update dependencies", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Detect cycles against the graph as it would look after this update
+	graph, err := loadDependencyGraph(tx, scope, scopeArgs)
+	if err != nil {
+		Error("Failed to load dependency graph: %v", err)
+		sendJSONError(w, "Failed to validate dependencies", http.StatusInternalServerError)
+		return
+	}
+	graph[hostID] = nil
+	for _, dep := range deps {
+		graph[hostID] = append(graph[hostID], dep.DependsOnID)
+	}
+	if cycle := findDependencyCycle(graph, hostID); cycle != nil {
+		tx.Rollback()
+		description := s.describeCycle(cycle)
+		Debug("Rejected dependencies for host '%s' - cycle: %s", hostName, description)
+		sendJSONErrorWithCode(w, "Dependency cycle detected: "+description, ErrCodeDependencyCycle, http.StatusBadRequest)
+		return
+	}
+
+	if _, err := tx.Exec("DELETE FROM host_dependencies WHERE host_id = ?", hostID); err != nil {
+		sendJSONError(w, "Failed to update dependencies", http.StatusInternalServerError)
+		return
+	}
+	for i, dep := range deps {
+		_, err := tx.Exec("INSERT INTO host_dependencies (host_id, depends_on_id, position, delay_seconds, wait_online, timeout_seconds) VALUES (?, ?, ?, ?, ?, ?)",
+			hostID, dep.DependsOnID, i, dep.DelaySeconds, dep.WaitOnline, dep.TimeoutSeconds)
+		if err != nil {
+			Debug("Failed to insert dependency %s -> %s: %v", hostID, dep.DependsOnID, err)
+			sendJSONError(w, "Failed to update dependencies", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		sendJSONError(w, "Failed to update dependencies", http.StatusInternalServerError)
+		return
+	}
+
+	Debug("Updated %d dependencies for host '%s' (ID: %s) by user: %s", len(deps), hostName, hostID, userDesc)
+
+	saved, err := s.loadHostDependencies(hostID)
+	if err != nil || saved == nil {
+		saved = []HostDependency{}
+	}
+	sendJSON(w, saved, http.StatusOK)
+}
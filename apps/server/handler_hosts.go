@@ -72,7 +72,7 @@ func (s *Server) getHosts(w http.ResponseWriter, r *http.Request, user *User) {
 		}
 
 		// Apply readonly restrictions for sensitive data
-		if (s.Config.UseAuth && user != nil && user.ReadOnly) || s.Config.ReadOnlyMode {
+		if (s.Config.UseAuth && user != nil && user.ReadOnly) || s.live().ReadOnlyMode {
 			host.MAC = ""
 			host.Broadcast = ""
 			host.Interface = ""
@@ -85,9 +85,12 @@ func (s *Server) getHosts(w http.ResponseWriter, r *http.Request, user *User) {
 			host.Interface = ""
 		}
 
+		s.annotateHostVendor(&host)
 		hosts = append(hosts, host)
 	}
 
+	s.annotateHostAddresses(hosts)
+
 	Debug("Returning %d hosts for user: %s", len(hosts), userDesc)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(hosts)
@@ -102,7 +105,7 @@ func (s *Server) createHost(w http.ResponseWriter, r *http.Request, user *User)
 	Debug("Create host request from user: %s", userDesc)
 
 	// Check if modifications are allowed
-	if s.Config.ReadOnlyMode || (s.Config.UseAuth && user != nil && user.ReadOnly) {
+	if s.live().ReadOnlyMode || (s.Config.UseAuth && user != nil && user.ReadOnly) {
 		Debug("Create host denied for user %s (readonly mode)", userDesc)
 		sendJSONError(w, "Read-only access: modification not allowed", http.StatusForbidden)
 		return
@@ -145,7 +148,7 @@ func (s *Server) createHost(w http.ResponseWriter, r *http.Request, user *User)
 	}
 
 	// Validate static IP if provided
-	if err := sanitizeStaticIPv4(host.StaticIP); err != nil {
+	if err := sanitizeStaticIP(host.StaticIP); err != nil {
 		handleValidationError(w, err, http.StatusBadRequest)
 		return
 	}
@@ -196,6 +199,7 @@ func (s *Server) createHost(w http.ResponseWriter, r *http.Request, user *User)
 	Debug("Host '%s' (ID: %s, MAC: %s) created successfully for user: %s",
 		host.Name, host.ID, host.MAC, userDesc)
 
+	s.annotateHostVendor(&host)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(host)
@@ -237,7 +241,7 @@ func (s *Server) getHost(w http.ResponseWriter, r *http.Request, user *User, hos
 	}
 
 	// Apply readonly restrictions for sensitive data
-	if (s.Config.UseAuth && user != nil && user.ReadOnly) || s.Config.ReadOnlyMode {
+	if (s.Config.UseAuth && user != nil && user.ReadOnly) || s.live().ReadOnlyMode {
 		host.MAC = ""
 		host.Interface = ""
 		host.StaticIP = ""
@@ -249,8 +253,11 @@ func (s *Server) getHost(w http.ResponseWriter, r *http.Request, user *User, hos
 		host.Interface = ""
 	}
 
+	s.annotateHostVendor(&host)
+	hosts := []Host{host}
+	s.annotateHostAddresses(hosts)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(host)
+	json.NewEncoder(w).Encode(hosts[0])
 }
 
 // updateHost updates an existing host
@@ -262,7 +269,7 @@ func (s *Server) updateHost(w http.ResponseWriter, r *http.Request, user *User,
 	Debug("Update host request for ID %s from user: %s", hostID, userDesc)
 
 	// Check if modifications are allowed
-	if s.Config.ReadOnlyMode || (s.Config.UseAuth && user != nil && user.ReadOnly) {
+	if s.live().ReadOnlyMode || (s.Config.UseAuth && user != nil && user.ReadOnly) {
 		Debug("Update host denied for user %s (readonly mode)", userDesc)
 		sendJSONError(w, "Read-only access: modification not allowed", http.StatusForbidden)
 		return
@@ -298,7 +305,7 @@ func (s *Server) updateHost(w http.ResponseWriter, r *http.Request, user *User,
 	}
 
 	// Validate static IP if provided
-	if err := sanitizeStaticIPv4(host.StaticIP); err != nil {
+	if err := sanitizeStaticIP(host.StaticIP); err != nil {
 		handleValidationError(w, err, http.StatusBadRequest)
 		return
 	}
@@ -355,6 +362,7 @@ func (s *Server) updateHost(w http.ResponseWriter, r *http.Request, user *User,
 		host.Name, hostID, host.MAC, userDesc)
 
 	host.ID = hostID
+	s.annotateHostVendor(&host)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(host)
 }
@@ -368,7 +376,7 @@ func (s *Server) deleteHost(w http.ResponseWriter, r *http.Request, user *User,
 	Debug("Delete host request for ID %s from user: %s", hostID, userDesc)
 
 	// Check if modifications are allowed
-	if s.Config.ReadOnlyMode || (s.Config.UseAuth && user != nil && user.ReadOnly) {
+	if s.live().ReadOnlyMode || (s.Config.UseAuth && user != nil && user.ReadOnly) {
 		Debug("Delete host denied for user %s (readonly mode)", userDesc)
 		sendJSONError(w, "Read-only access: modification not allowed", http.StatusForbidden)
 		return
@@ -409,6 +417,62 @@ func (s *Server) deleteHost(w http.ResponseWriter, r *http.Request, user *User,
 		return
 	}
 
+	// Remove wake chain edges referencing this host
+	if _, err := s.DB.Exec("DELETE FROM host_dependencies WHERE host_id = ? OR depends_on_id = ?", hostID, hostID); err != nil {
+		Warning("Failed to remove dependencies of deleted host %s: %v", hostID, err)
+	}
+
+	// Remove stored SSH credentials
+	if _, err := s.DB.Exec("DELETE FROM host_power_actions WHERE host_id = ?", hostID); err != nil {
+		Warning("Failed to remove power action of deleted host %s: %v", hostID, err)
+	}
+	if _, err := s.DB.Exec("DELETE FROM host_relays WHERE host_id = ?", hostID); err != nil {
+		Warning("Failed to remove relay assignment of deleted host %s: %v", hostID, err)
+	}
+
+	if _, err := s.DB.Exec("DELETE FROM host_services WHERE host_id = ?", hostID); err != nil {
+		Warning("Failed to remove service checks of deleted host %s: %v", hostID, err)
+	}
+
+	// Remove address tracking
+	if _, err := s.DB.Exec("DELETE FROM host_ip_history WHERE host_id = ?", hostID); err != nil {
+		Warning("Failed to remove IP history of deleted host %s: %v", hostID, err)
+	}
+	if _, err := s.DB.Exec("DELETE FROM host_alerts WHERE host_id = ?", hostID); err != nil {
+		Warning("Failed to remove alerts of deleted host %s: %v", hostID, err)
+	}
+
 	Debug("Host ID %s deleted successfully by user: %s", hostID, userDesc)
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// loadAccessibleHost fetches a host visible to the current user (own hosts in auth mode,
+// hosts with NULL user_id in no-auth mode). On failure it writes the appropriate error
+// response and returns false.
+func (s *Server) loadAccessibleHost(w http.ResponseWriter, user *User, hostID string) (Host, bool) {
+	var host Host
+	scope, scopeArgs := s.hostScopeQuery(user)
+
+	err := s.DB.QueryRow("SELECT id, name, mac, broadcast, interface, static_ip, use_as_fallback, user_id, created, updated FROM hosts WHERE id = ? AND "+scope,
+		append([]interface{}{hostID}, scopeArgs...)...).
+		Scan(&host.ID, &host.Name, &host.MAC, &host.Broadcast, &host.Interface, &host.StaticIP, &host.UseAsFallback, &host.UserID, &host.Created, &host.Updated)
+	if err == sql.ErrNoRows {
+		// In no-auth mode, check if host exists but has a user_id
+		if !s.Config.UseAuth {
+			var exists bool
+			err := s.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM hosts WHERE id = ?)", hostID).Scan(&exists)
+			if err == nil && exists {
+				sendJSONError(w, "Access denied: host not accessible in no-auth mode", http.StatusForbidden)
+				return host, false
+			}
+		}
+		sendJSONError(w, "Host not found", http.StatusNotFound)
+		return host, false
+	}
+	if err != nil {
+		sendJSONError(w, "Failed to fetch host", http.StatusInternalServerError)
+		return host, false
+	}
+
+	return host, true
+}
@@ -66,7 +66,7 @@ func (s *Server) handlePing(w http.ResponseWriter, r *http.Request) {
 		args = []interface{}{data.ID}
 	}
 
-	err := s.DB.QueryRow(query, args...).Scan(&host.ID, &host.Name, &host.MAC, &host.Broadcast, &host.Interface, &host.StaticIP, &host.UseAsFallback, &host.UserID)
+	err := s.DB.QueryRowContext(r.Context(), query, args...).Scan(&host.ID, &host.Name, &host.MAC, &host.Broadcast, &host.Interface, &host.StaticIP, &host.UseAsFallback, &host.UserID)
 	if err == sql.ErrNoRows {
 		// In no-auth mode, check if host exists but has a user_id
 		if !s.Config.UseAuth {
@@ -109,12 +109,15 @@ func (s *Server) handlePing(w http.ResponseWriter, r *http.Request) {
 	// Check cache first
 	cacheKey := host.ID
 	if cachedEntry := s.PingCache.Get(cacheKey); cachedEntry != nil {
-		Debug("Cache HIT for host '%s' (MAC: %s) - returning cached result", host.Name, host.MAC)
+		DebugContext(r.Context(), "Cache HIT for host '%s' (MAC: %s) - returning cached result", host.Name, host.MAC)
 		response := map[string]interface{}{
 			"ping_success": cachedEntry.PingSuccess,
 			"arp_success":  cachedEntry.ARPSuccess,
 			"cached":       true,
 		}
+		addRTT(response, cachedEntry.RTT)
+		addServices(response, cachedEntry.Services)
+		s.addAgentStatus(response, host)
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(response)
 		return
@@ -124,7 +127,7 @@ func (s *Server) handlePing(w http.ResponseWriter, r *http.Request) {
 	isFirstRequest, waitChan := s.PingCache.StartPing(cacheKey)
 	if !isFirstRequest {
 		// Another request is already pinging this host - wait for result
-		Debug("Ping already in progress for host '%s' (MAC: %s) - coalescing request", host.Name, host.MAC)
+		DebugContext(r.Context(), "Ping already in progress for host '%s' (MAC: %s) - coalescing request", host.Name, host.MAC)
 
 		select {
 		case result := <-waitChan:
@@ -144,10 +147,13 @@ func (s *Server) handlePing(w http.ResponseWriter, r *http.Request) {
 				"arp_success":  result.ARPSuccess,
 				"coalesced":    true,
 			}
+			addRTT(response, result.RTT)
+			addServices(response, result.Services)
+			s.addAgentStatus(response, host)
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(response)
 			return
-		case <-time.After(time.Duration(s.Config.PingTimeout+5) * time.Second):
+		case <-time.After(time.Duration(s.live().PingTimeout+5) * time.Second):
 			// Timeout waiting for result
 			response := map[string]interface{}{
 				"ping_success": false,
@@ -161,168 +167,23 @@ func (s *Server) handlePing(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Determine which network interface(s) to use
-	interfaceToUse := s.determineNetworkInterface(host)
+	// Check host status (flush ARP cache first so manual checks use fresh data)
+	result := s.probeHost(r.Context(), host, true)
+	s.checkHostServices(r.Context(), host, &result)
 
-	// Log interface configuration
-	ifaceDesc := "all available interfaces"
-	if interfaceToUse != "" {
-		ifaceDesc = interfaceToUse
-	}
-	Debug("Checking status of host '%s' (MAC: %s) using %s", host.Name, host.MAC, ifaceDesc)
-
-	var pingSuccess bool
-	var arpSuccess bool
-
-	// Check if static IP is configured
-	if host.StaticIP != "" && !host.UseAsFallback {
-		// Use static IP directly (ignore ARP resolution)
-		Debug("Using configured static IP %s for host '%s' (MAC: %s)", host.StaticIP, host.Name, host.MAC)
-
-		// Verify host is online using ARP ping
-		hwAddr, err := ARPPingIP(host.StaticIP, interfaceToUse)
-		if err == nil && hwAddr != nil {
-			// Host responded to ARP ping
-			arpSuccess = true
-			pingSuccess = true
-			Debug("Host '%s' is ONLINE at static IP %s (MAC: %s verified)", host.Name, host.StaticIP, hwAddr.String())
-
-			// Verify MAC matches (warning if mismatch)
-			detectedMAC := normalizeMACAddress(hwAddr.String())
-			storedMAC := normalizeMACAddress(host.MAC)
-			if detectedMAC != storedMAC {
-				Warning("Host '%s' MAC mismatch - stored: %s, detected: %s at static IP %s",
-					host.Name, storedMAC, detectedMAC, host.StaticIP)
-				Warning("This may indicate network complexity (overlapping IP ranges, VLAN issues, or incorrect static IP configuration)")
-			}
-		} else {
-			// Static IP not responding
-			Debug("Host '%s' (MAC: %s) not responding at static IP %s", host.Name, host.MAC, host.StaticIP)
-			pingSuccess = false
-			arpSuccess = false
-		}
-
-		// Store result in cache
-		s.PingCache.Set(cacheKey, pingSuccess, arpSuccess)
-
-		response := map[string]interface{}{
-			"ping_success": pingSuccess,
-			"arp_success":  arpSuccess,
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
-		return
-	}
-
-	// Try to resolve IP from MAC first (passive ARP table lookup)
-	Debug("Looking up IP for MAC %s in ARP table", host.MAC)
-	hostIP, ipErr := GetIPFromMAC(host.MAC)
-
-	// For manual ping, flush ARP cache if entry exists to ensure fresh data
-	if ipErr == nil && hostIP != "" {
-		FlushARPEntryIfExists(hostIP)
-		Debug("Manual ping - flushed ARP cache for IP %s to ensure fresh data", hostIP)
-		// Re-lookup after flush
-		hostIP, ipErr = GetIPFromMAC(host.MAC)
-	}
-
-	if ipErr == nil {
-		// IP found in ARP table - now verify host is actually online using ARP ping
-		Debug("Host '%s' (MAC: %s) found in ARP table at IP %s", host.Name, host.MAC, hostIP)
-		Debug("Verifying host '%s' is online with active ARP ping", host.Name)
-
-		// Use arping to actively verify the host responds
-		hwAddr, err := ARPPingIP(hostIP, interfaceToUse)
-		if err == nil && hwAddr != nil {
-			// Host responded to ARP ping
-			arpSuccess = true
-			pingSuccess = true
-			Debug("Host '%s' is ONLINE at IP %s (MAC: %s verified)", host.Name, hostIP, hwAddr.String())
-
-			// Verify MAC matches
-			detectedMAC := normalizeMACAddress(hwAddr.String())
-			storedMAC := normalizeMACAddress(host.MAC)
-			if detectedMAC != storedMAC {
-				Warning("Host '%s' MAC mismatch - stored: %s, detected: %s at IP %s",
-					host.Name, storedMAC, detectedMAC, hostIP)
-			}
-		} else {
-			// Host in ARP table but not responding - flush stale entry
-			FlushARPEntryIfExists(hostIP)
-			Debug("Host '%s' (MAC: %s) in ARP table but not responding - flushed cache entry for IP %s", host.Name, host.MAC, hostIP)
-			pingSuccess = false
-			arpSuccess = false
-		}
-	} else {
-		// IP not in ARP table - do full network scan to find host by MAC
-		Debug("Host '%s' (MAC: %s) not in ARP table", host.Name, host.MAC)
-		Debug("Starting full network ARP scan for host '%s' (timeout: %ds)", host.Name, s.Config.PingTimeout)
-
-		foundIP, pingOk, arpErr := ARPPingMAC(host.MAC, interfaceToUse, s.Config.PingTimeout)
-		if arpErr == nil {
-			// Active ARP scan succeeded - host found
-			pingSuccess = pingOk
-			arpSuccess = true
-			Debug("Host '%s' (MAC: %s) found via network scan at IP %s - status: %s",
-				host.Name, host.MAC, foundIP, func() string {
-					if pingOk {
-						return "ONLINE"
-					}
-					return "FOUND"
-				}())
-		} else {
-			// Host not found - try static IP as fallback if configured
-			if host.StaticIP != "" && host.UseAsFallback {
-				Debug("ARP resolution failed for host '%s', trying static IP %s as fallback", host.Name, host.StaticIP)
-
-				hwAddr, fallbackErr := ARPPingIP(host.StaticIP, interfaceToUse)
-				if fallbackErr == nil && hwAddr != nil {
-					// Host responded at static IP
-					arpSuccess = true
-					pingSuccess = true
-					Debug("Host '%s' is ONLINE at fallback static IP %s (MAC: %s)", host.Name, host.StaticIP, hwAddr.String())
-
-					// Verify MAC matches
-					detectedMAC := normalizeMACAddress(hwAddr.String())
-					storedMAC := normalizeMACAddress(host.MAC)
-					if detectedMAC != storedMAC {
-						Warning("Host '%s' MAC mismatch - stored: %s, detected: %s at fallback static IP %s",
-							host.Name, storedMAC, detectedMAC, host.StaticIP)
-					}
-				} else {
-					Debug("Host '%s' (MAC: %s) NOT FOUND on network or at fallback static IP - OFFLINE", host.Name, host.MAC)
-					s.PingCache.Set(cacheKey, false, false)
-					response := map[string]interface{}{
-						"ping_success": false,
-						"arp_success":  false,
-					}
-					w.Header().Set("Content-Type", "application/json")
-					json.NewEncoder(w).Encode(response)
-					return
-				}
-			} else {
-				Debug("Host '%s' (MAC: %s) NOT FOUND on network - OFFLINE", host.Name, host.MAC)
-				s.PingCache.Set(cacheKey, false, false)
-				response := map[string]interface{}{
-					"ping_success": false,
-					"arp_success":  false,
-				}
-				w.Header().Set("Content-Type", "application/json")
-				json.NewEncoder(w).Encode(response)
-				return
-			}
-		}
-	}
-
-	Debug("Host '%s' final status - ping_success: %v, arp_success: %v", host.Name, pingSuccess, arpSuccess)
+	DebugContext(r.Context(), "Host '%s' final status - ping_success: %v, arp_success: %v", host.Name, result.PingSuccess, result.ARPSuccess)
 
 	// Store result in cache
-	s.PingCache.Set(cacheKey, pingSuccess, arpSuccess)
+	s.PingCache.Set(cacheKey, result)
+	s.trackHostAddress(r.Context(), host, result)
 
 	response := map[string]interface{}{
-		"ping_success": pingSuccess,
-		"arp_success":  arpSuccess,
+		"ping_success": result.PingSuccess,
+		"arp_success":  result.ARPSuccess,
 	}
+	addRTT(response, result.RTT)
+	addServices(response, result.Services)
+	s.addAgentStatus(response, host)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
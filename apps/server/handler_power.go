@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// PowerAction is the SSH power-off configuration of a host.
+//
+// The private key is write-only: it is accepted on PUT, stored encrypted with the
+// server SecretBox and never returned. Responses only expose the matching public key
+// so it can be added to the target's authorized_keys.
+type PowerAction struct {
+	SSHAddress         string    `json:"ssh_address"`           // Optional; defaults to the host's resolved IP
+	SSHPort            int       `json:"ssh_port"`              // Default 22
+	SSHUser            string    `json:"ssh_user"`              // Remote user
+	Command            string    `json:"command"`               // Command to run (e.g. "sudo systemctl suspend")
+	PrivateKey         string    `json:"private_key,omitempty"` // Write-only PEM private key (empty on PUT = keep or generate)
+	HasPrivateKey      bool      `json:"has_private_key"`
+	PublicKey          string    `json:"public_key,omitempty"`
+	HostKeyFingerprint string    `json:"host_key_fingerprint"` // Pinned SHA256 host key (empty = trust on first use)
+	Updated            time.Time `json:"updated"`
+}
+
+// handleHostPower handles GET, PUT and DELETE of a host's power action configuration
+func (s *Server) handleHostPower(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r)
+	hostID := mux.Vars(r)["id"]
+
+	if !s.Config.EnableRemoteShutdown || s.Secrets == nil {
+		sendJSONErrorWithCode(w, "Remote shutdown is disabled", ErrCodeFeatureDisabled, http.StatusForbidden)
+		return
+	}
+
+	host, ok := s.loadAccessibleHost(w, user, hostID)
+	if !ok {
+		return
+	}
+
+	// Power configuration contains credentials - hide it from readonly users entirely
+	if s.live().ReadOnlyMode || (s.Config.UseAuth && user != nil && user.ReadOnly) {
+		sendJSONError(w, "Read-only access: power actions not available", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		action, err := s.loadPowerAction(host.ID)
+		if err == sql.ErrNoRows {
+			sendJSONError(w, "No power action configured for this host", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			Error("Failed to load power action for host '%s': %v", host.Name, err)
+			sendJSONError(w, "Failed to fetch power action", http.StatusInternalServerError)
+			return
+		}
+		sendJSON(w, action, http.StatusOK)
+	case "PUT":
+		s.updatePowerAction(w, r, host)
+	case "DELETE":
+		if _, err := s.DB.Exec("DELETE FROM host_power_actions WHERE host_id = ?", host.ID); err != nil {
+			sendJSONError(w, "Failed to delete power action", http.StatusInternalServerError)
+			return
+		}
+		entry := s.newAuditEntry(r, AuditActionPowerConfigDel, host)
+		entry.Success = true
+		s.recordAudit(entry)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// loadPowerAction loads the power configuration of a host without the private key
+func (s *Server) loadPowerAction(hostID string) (*PowerAction, error) {
+	action, sealedKey, err := s.loadPowerActionWithKey(hostID)
+	if err != nil {
+		return nil, err
+	}
+
+	action.HasPrivateKey = len(sealedKey) > 0
+	if action.HasPrivateKey {
+		if keyPEM, err := s.Secrets.Open(sealedKey, powerKeyAAD(hostID)); err == nil {
+			if signer, err := parseSSHPrivateKey(keyPEM); err == nil {
+				action.PublicKey = authorizedKeyLine(signer)
+			}
+		} else {
+			Warning("Stored SSH key for host %s cannot be decrypted: %v", hostID, err)
+		}
+	}
+	return action, nil
+}
+
+// powerKeyAAD binds a sealed SSH key to the host it was stored for
+func powerKeyAAD(hostID string) []byte {
+	return []byte("host_power_actions.private_key:" + hostID)
+}
+
+// resealLegacyPowerKeys binds SSH keys stored before keys were sealed with their host ID
+// to their host. Keys that already open for their host are left alone.
+func (s *Server) resealLegacyPowerKeys() error {
+	rows, err := s.DB.Query("SELECT host_id, private_key FROM host_power_actions")
+	if err != nil {
+		return err
+	}
+	sealedKeys := make(map[string][]byte)
+	for rows.Next() {
+		var hostID string
+		var sealedKey []byte
+		if err := rows.Scan(&hostID, &sealedKey); err != nil {
+			rows.Close()
+			return err
+		}
+		sealedKeys[hostID] = sealedKey
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for hostID, sealedKey := range sealedKeys {
+		if _, err := s.Secrets.Open(sealedKey, powerKeyAAD(hostID)); err == nil {
+			continue
+		}
+		keyPEM, err := s.Secrets.Open(sealedKey, nil)
+		if err != nil {
+			continue // Reported when the key is used
+		}
+		resealed, err := s.Secrets.Seal(keyPEM, powerKeyAAD(hostID))
+		if err != nil {
+			return err
+		}
+		if _, err := s.DB.Exec("UPDATE host_power_actions SET private_key = ? WHERE host_id = ?", resealed, hostID); err != nil {
+			return err
+		}
+		Info("Bound stored SSH key to host %s", hostID)
+	}
+	return nil
+}
+
+// loadPowerActionWithKey loads the power configuration and the sealed private key
+func (s *Server) loadPowerActionWithKey(hostID string) (*PowerAction, []byte, error) {
+	var action PowerAction
+	var sshAddress, fingerprint sql.NullString
+	var sealedKey []byte
+
+	err := s.DB.QueryRow("SELECT ssh_address, ssh_port, ssh_user, command, private_key, host_key_fingerprint, updated FROM host_power_actions WHERE host_id = ?", hostID).
+		Scan(&sshAddress, &action.SSHPort, &action.SSHUser, &action.Command, &sealedKey, &fingerprint, &action.Updated)
+	if err != nil {
+		return nil, nil, err
+	}
+	action.SSHAddress = sshAddress.String
+	action.HostKeyFingerprint = fingerprint.String
+	return &action, sealedKey, nil
+}
+
+// updatePowerAction validates and stores a host's power configuration
+func (s *Server) updatePowerAction(w http.ResponseWriter, r *http.Request, host Host) {
+	var req PowerAction
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	req.SSHAddress = strings.TrimSpace(req.SSHAddress)
+	req.SSHUser = strings.TrimSpace(req.SSHUser)
+	req.Command = strings.TrimSpace(req.Command)
+	req.PrivateKey = strings.TrimSpace(req.PrivateKey)
+	req.HostKeyFingerprint = strings.TrimSpace(req.HostKeyFingerprint)
+
+	if req.SSHPort == 0 {
+		req.SSHPort = 22
+	}
+	if req.SSHPort < 1 || req.SSHPort > 65535 {
+		sendJSONErrorWithCode(w, "ssh_port must be between 1-65535", ErrCodeInvalidInput, http.StatusBadRequest)
+		return
+	}
+	if req.SSHUser == "" {
+		sendJSONErrorWithCode(w, "ssh_user is required", ErrCodeMissingField, http.StatusBadRequest)
+		return
+	}
+	if strings.ContainsAny(req.SSHUser, " \t\r\n@:") {
+		sendJSONErrorWithCode(w, "ssh_user contains invalid characters", ErrCodeInvalidInput, http.StatusBadRequest)
+		return
+	}
+	if req.Command == "" {
+		req.Command = DefaultShutdownCommand
+	}
+	if len(req.Command) > 512 || strings.ContainsAny(req.Command, "\r\n") {
+		sendJSONErrorWithCode(w, "command must be a single line of at most 512 characters", ErrCodeInvalidInput, http.StatusBadRequest)
+		return
+	}
+	if req.SSHAddress != "" {
+		if ip := net.ParseIP(req.SSHAddress); ip == nil {
+			if err := sanitizeHostName(req.SSHAddress); err != nil || strings.ContainsAny(req.SSHAddress, " \t") {
+				sendJSONErrorWithCode(w, "ssh_address must be an IP address or hostname", ErrCodeInvalidIP, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if req.HostKeyFingerprint != "" && !strings.HasPrefix(req.HostKeyFingerprint, "SHA256:") {
+		sendJSONErrorWithCode(w, "host_key_fingerprint must be in SHA256:... format", ErrCodeInvalidInput, http.StatusBadRequest)
+		return
+	}
+
+	// Determine which private key to store: uploaded, existing, or newly generated
+	var sealedKey []byte
+	if req.PrivateKey != "" {
+		if _, err := parseSSHPrivateKey([]byte(req.PrivateKey)); err != nil {
+			sendJSONErrorWithCode(w, err.Error(), ErrCodeInvalidInput, http.StatusBadRequest)
+			return
+		}
+		sealed, err := s.Secrets.Seal([]byte(req.PrivateKey), powerKeyAAD(host.ID))
+		if err != nil {
+			Error("Failed to encrypt SSH key for host '%s': %v", host.Name, err)
+			sendJSONError(w, "Failed to store private key", http.StatusInternalServerError)
+			return
+		}
+		sealedKey = sealed
+	} else {
+		_, existingKey, err := s.loadPowerActionWithKey(host.ID)
+		if err != nil && err != sql.ErrNoRows {
+			sendJSONError(w, "Failed to fetch power action", http.StatusInternalServerError)
+			return
+		}
+		sealedKey = existingKey
+		if len(sealedKey) == 0 {
+			keyPEM, err := generateSSHKey()
+			if err != nil {
+				Error("Failed to generate SSH key for host '%s': %v", host.Name, err)
+				sendJSONError(w, "Failed to generate private key", http.StatusInternalServerError)
+				return
+			}
+			sealedKey, err = s.Secrets.Seal(keyPEM, powerKeyAAD(host.ID))
+			if err != nil {
+				sendJSONError(w, "Failed to store private key", http.StatusInternalServerError)
+				return
+			}
+			Info("Generated new SSH key for host '%s'", host.Name)
+		}
+	}
+
+	_, err := s.DB.Exec(`INSERT INTO host_power_actions (host_id, ssh_address, ssh_port, ssh_user, command, private_key, host_key_fingerprint)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(host_id) DO UPDATE SET ssh_address = excluded.ssh_address, ssh_port = excluded.ssh_port,
+			ssh_user = excluded.ssh_user, command = excluded.command, private_key = excluded.private_key,
+			host_key_fingerprint = excluded.host_key_fingerprint, updated = CURRENT_TIMESTAMP`,
+		host.ID, req.SSHAddress, req.SSHPort, req.SSHUser, req.Command, sealedKey, req.HostKeyFingerprint)
+	if err != nil {
+		Error("Failed to save power action for host '%s': %v", host.Name, err)
+		sendJSONError(w, "Failed to save power action", http.StatusInternalServerError)
+		return
+	}
+
+	entry := s.newAuditEntry(r, AuditActionPowerConfigSet, host)
+	entry.Success = true
+	entry.Detail = fmt.Sprintf("%s@%s:%d command=%q key_uploaded=%v", req.SSHUser, req.SSHAddress, req.SSHPort, req.Command, req.PrivateKey != "")
+	s.recordAudit(entry)
+
+	action, err := s.loadPowerAction(host.ID)
+	if err != nil {
+		sendJSONError(w, "Failed to fetch power action", http.StatusInternalServerError)
+		return
+	}
+	sendJSON(w, action, http.StatusOK)
+}
+
+// handleShutdown runs the configured power-off command of a host over SSH
+func (s *Server) handleShutdown(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r)
+	hostID := mux.Vars(r)["id"]
+
+	if !s.Config.EnableRemoteShutdown || s.Secrets == nil {
+		sendJSONErrorWithCode(w, "Remote shutdown is disabled", ErrCodeFeatureDisabled, http.StatusForbidden)
+		return
+	}
+
+	userKey := "anonymous"
+	if user != nil {
+		userKey = user.ID
+	}
+
+	if !s.ShutdownRateLimit.Allow(userKey) {
+		Debug("Shutdown rate limit exceeded for user: %s", userKey)
+		sendJSONErrorWithCode(w, "Rate limit exceeded. Please wait before sending more shutdown requests.", ErrCodeRateLimited, http.StatusTooManyRequests)
+		return
+	}
+
+	host, ok := s.loadAccessibleHost(w, user, hostID)
+	if !ok {
+		return
+	}
+
+	// Unlike Wake-on-LAN, shutting a machine down is disruptive - deny readonly access
+	if s.live().ReadOnlyMode || (s.Config.UseAuth && user != nil && user.ReadOnly) {
+		entry := s.newAuditEntry(r, AuditActionShutdown, host)
+		entry.Detail = "denied: read-only access"
+		s.recordAudit(entry)
+		sendJSONErrorWithCode(w, "Read-only access: shutdown not allowed", ErrCodeReadOnlyMode, http.StatusForbidden)
+		return
+	}
+
+	action, sealedKey, err := s.loadPowerActionWithKey(host.ID)
+	if err == sql.ErrNoRows {
+		sendJSONError(w, "No power action configured for this host", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		sendJSONError(w, "Failed to fetch power action", http.StatusInternalServerError)
+		return
+	}
+
+	entry := s.newAuditEntry(r, AuditActionShutdown, host)
+
+	keyPEM, err := s.Secrets.Open(sealedKey, powerKeyAAD(host.ID))
+	if err != nil {
+		Error("Failed to decrypt SSH key for host '%s': %v", host.Name, err)
+		entry.Detail = "failed to decrypt private key"
+		s.recordAudit(entry)
+		sendJSONError(w, "Failed to decrypt private key", http.StatusInternalServerError)
+		return
+	}
+	signer, err := parseSSHPrivateKey(keyPEM)
+	if err != nil {
+		entry.Detail = err.Error()
+		s.recordAudit(entry)
+		sendJSONError(w, "Stored private key is invalid", http.StatusInternalServerError)
+		return
+	}
+
+	address := s.resolvePowerAddress(r.Context(), host, action)
+	if address == "" {
+		entry.Detail = "host address could not be resolved"
+		s.recordAudit(entry)
+		sendJSONErrorWithCode(w, "Host address could not be resolved (host offline or not in ARP table)", ErrCodeHostOffline, http.StatusConflict)
+		return
+	}
+
+	Info("Running shutdown command on host '%s' via %s@%s:%d", host.Name, action.SSHUser, address, action.SSHPort)
+	result, err := runSSHCommand(address, action.SSHPort, action.SSHUser, signer, action.HostKeyFingerprint, action.Command, SSHCommandTimeout)
+
+	// Pin the host key on first successful connection (trust on first use)
+	if action.HostKeyFingerprint == "" && result != nil && result.HostKeyFingerprint != "" && err == nil {
+		if _, dbErr := s.DB.Exec("UPDATE host_power_actions SET host_key_fingerprint = ? WHERE host_id = ? AND (host_key_fingerprint IS NULL OR host_key_fingerprint = '')",
+			result.HostKeyFingerprint, host.ID); dbErr != nil {
+			Warning("Failed to pin SSH host key for host '%s': %v", host.Name, dbErr)
+		} else {
+			Info("Pinned SSH host key %s for host '%s'", result.HostKeyFingerprint, host.Name)
+		}
+	}
+
+	if err != nil {
+		Warning("Shutdown of host '%s' failed: %v", host.Name, err)
+		entry.Detail = fmt.Sprintf("%s@%s:%d: %v", action.SSHUser, address, action.SSHPort, err)
+		s.recordAudit(entry)
+		response := map[string]interface{}{
+			"success": false,
+			"message": "Failed to run shutdown command",
+			"error":   err.Error(),
+			"output":  result.Output,
+		}
+		sendJSON(w, response, http.StatusBadGateway)
+		return
+	}
+
+	// Host status will change - drop cached ping result
+	s.PingCache.Invalidate(host.ID)
+
+	entry.Success = true
+	entry.Detail = fmt.Sprintf("%s@%s:%d command=%q disconnected=%v", action.SSHUser, address, action.SSHPort, action.Command, result.Disconnected)
+	s.recordAudit(entry)
+
+	response := map[string]interface{}{
+		"success":      true,
+		"message":      "Shutdown command sent",
+		"output":       result.Output,
+		"disconnected": result.Disconnected,
+	}
+	sendJSON(w, response, http.StatusOK)
+}
+
+// resolvePowerAddress determines the address to connect to for a power action:
+// the configured SSH address, or the host's current address
+func (s *Server) resolvePowerAddress(ctx context.Context, host Host, action *PowerAction) string {
+	if action.SSHAddress != "" {
+		return action.SSHAddress
+	}
+	return s.resolveHostAddress(ctx, host)
+}
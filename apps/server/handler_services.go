@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// handleHostServices handles GET (list) and PUT (replace) for a host's service checks
+func (s *Server) handleHostServices(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r)
+	hostID := mux.Vars(r)["id"]
+
+	host, ok := s.loadAccessibleHost(w, user, hostID)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		services, err := s.loadHostServices(r.Context(), hostID)
+		if err != nil {
+			Error("Failed to load service checks for host %s: %v", hostID, err)
+			sendJSONError(w, "Failed to fetch service checks", http.StatusInternalServerError)
+			return
+		}
+		if services == nil {
+			services = []HostService{}
+		}
+		sendJSON(w, services, http.StatusOK)
+	case "PUT":
+		s.updateHostServices(w, r, user, host)
+	}
+}
+
+// updateHostServices replaces the service checks of a host
+func (s *Server) updateHostServices(w http.ResponseWriter, r *http.Request, user *User, host Host) {
+	userDesc := "anonymous"
+	if user != nil {
+		userDesc = user.ID
+	}
+
+	// Check if modifications are allowed
+	if s.live().ReadOnlyMode || (s.Config.UseAuth && user != nil && user.ReadOnly) {
+		Debug("Update service checks denied for user %s (readonly mode)", userDesc)
+		sendJSONError(w, "Read-only access: modification not allowed", http.StatusForbidden)
+		return
+	}
+
+	var services []HostService
+	if err := json.NewDecoder(r.Body).Decode(&services); err != nil {
+		sendJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if len(services) > MaxHostServices {
+		sendJSONErrorWithCode(w, fmt.Sprintf("Too many service checks (max %d)", MaxHostServices), ErrCodeInvalidService, http.StatusBadRequest)
+		return
+	}
+
+	seen := make(map[string]bool)
+	for i := range services {
+		if err := validateHostService(&services[i]); err != nil {
+			sendJSONErrorWithCode(w, err.Error(), ErrCodeInvalidService, http.StatusBadRequest)
+			return
+		}
+		if seen[services[i].Name] {
+			sendJSONErrorWithCode(w, "Duplicate service name: "+services[i].Name, ErrCodeInvalidService, http.StatusBadRequest)
+			return
+		}
+		seen[services[i].Name] = true
+	}
+
+	// Replace service checks atomically
+	tx, err := s.DB.Begin()
+	if err != nil {
+		sendJSONError(w, "Failed to update service checks", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM host_services WHERE host_id = ?", host.ID); err != nil {
+		sendJSONError(w, "Failed to update service checks", http.StatusInternalServerError)
+		return
+	}
+	for i, service := range services {
+		_, err := tx.Exec(`INSERT INTO host_services (host_id, position, name, type, port, path, expect_status, expect_body, tls_skip_verify, timeout_seconds)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			host.ID, i, service.Name, service.Type, service.Port, service.Path, service.ExpectStatus, service.ExpectBody, service.TLSSkipVerify, service.TimeoutSeconds)
+		if err != nil {
+			Debug("Failed to insert service check '%s' of host %s: %v", service.Name, host.ID, err)
+			sendJSONError(w, "Failed to update service checks", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		sendJSONError(w, "Failed to update service checks", http.StatusInternalServerError)
+		return
+	}
+
+	// Cached status results carry the old checks
+	s.PingCache.Invalidate(host.ID)
+
+	Debug("Updated %d service checks for host '%s' (ID: %s) by user: %s", len(services), host.Name, host.ID, userDesc)
+
+	saved, err := s.loadHostServices(r.Context(), host.ID)
+	if err != nil || saved == nil {
+		saved = []HostService{}
+	}
+	sendJSON(w, saved, http.StatusOK)
+}
@@ -335,13 +335,14 @@ func (s *Server) serve404Page(w http.ResponseWriter, r *http.Request, staticPath
 func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
 	// Config endpoint requires auth only when auth is enabled
 	// This allows the frontend to determine auth requirements before login
+	readOnlyMode := s.live().ReadOnlyMode
 	if s.Config.UseAuth {
 		// When auth is enabled, we still allow unauthenticated access to get basic config
 		user := s.getCurrentUser(r)
 		response := map[string]interface{}{}
 
 		// Determine if user has readonly access
-		isReadOnly := s.Config.ReadOnlyMode || (user != nil && user.ReadOnly)
+		isReadOnly := readOnlyMode || (user != nil && user.ReadOnly)
 
 		// Indicate if interface selection is supported (but don't return interfaces here)
 		// Use the dedicated /api/network-interfaces endpoint for that
@@ -361,7 +362,7 @@ func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
 
 		// Include auth and readonly mode status
 		response["use_auth"] = s.Config.UseAuth
-		response["readonly_mode"] = s.Config.ReadOnlyMode
+		response["readonly_mode"] = readOnlyMode
 		response["os"] = runtime.GOOS
 		response["url_prefix"] = s.Config.URLPrefix
 
@@ -371,14 +372,14 @@ func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
 		// No auth mode - return basic config
 		response := map[string]interface{}{
 			"use_auth":      false,
-			"readonly_mode": s.Config.ReadOnlyMode,
+			"readonly_mode": readOnlyMode,
 			"os":            runtime.GOOS,
 			"url_prefix":    s.Config.URLPrefix,
 		}
 
 		// Indicate if interface selection is supported (but don't return interfaces here)
 		// Use the dedicated /api/network-interfaces endpoint for that
-		if !s.Config.ReadOnlyMode {
+		if !readOnlyMode {
 			response["supports_interface_selection"] = true
 		}
 
@@ -401,7 +402,7 @@ func (s *Server) handleNetworkInterfaces(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Check if in readonly mode
-	if s.Config.ReadOnlyMode {
+	if s.live().ReadOnlyMode {
 		sendJSONError(w, "Network interface selection not available in readonly mode", http.StatusForbidden)
 		return
 	}
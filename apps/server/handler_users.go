@@ -102,7 +102,7 @@ func (s *Server) createUser(w http.ResponseWriter, r *http.Request) {
 
 	userID, err := generateID()
 	if err != nil {
-		Error("Failed to generate user ID: %v", err)
+		authLog.Error("Failed to generate user ID: %v", err)
 		sendJSONError(w, "Failed to create user", http.StatusInternalServerError)
 		return
 	}
@@ -267,10 +267,10 @@ func (s *Server) updateUser(w http.ResponseWriter, r *http.Request, userID strin
 	if req.Password != "" {
 		_, err = s.DB.Exec("DELETE FROM sessions WHERE user_id = ?", userID)
 		if err != nil {
-			Warning("Failed to delete sessions for user %s: %v", userID, err)
+			authLog.Warning("Failed to delete sessions for user %s: %v", userID, err)
 			// Don't fail the request, just log the error
 		} else {
-			Info("Terminated all sessions for user %s after password change", userID)
+			authLog.Info("Terminated all sessions for user %s after password change", userID)
 		}
 	}
 
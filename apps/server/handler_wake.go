@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"net"
 	"net/http"
 	"strconv"
-	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func (s *Server) handleWake(w http.ResponseWriter, r *http.Request) {
@@ -17,13 +21,13 @@ func (s *Server) handleWake(w http.ResponseWriter, r *http.Request) {
 	userKey := "anonymous"
 	if user != nil {
 		userKey = user.ID
-		Debug("WoL request from user: %s", userKey)
+		DebugContext(r.Context(), "WoL request from user: %s", userKey)
 	} else {
-		Debug("WoL request from anonymous user")
+		DebugContext(r.Context(), "WoL request from anonymous user")
 	}
 
 	if !s.WoLRateLimit.Allow(userKey) {
-		Debug("WoL rate limit exceeded for user: %s", userKey)
+		DebugContext(r.Context(), "WoL rate limit exceeded for user: %s", userKey)
 		response := map[string]string{
 			"message": "Rate limit exceeded",
 			"error":   "Please wait before sending more Wake-on-LAN requests",
@@ -38,80 +42,85 @@ func (s *Server) handleWake(w http.ResponseWriter, r *http.Request) {
 		ID string `json:"id"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-		Debug("Failed to decode WoL request body: %v", err)
+		DebugContext(r.Context(), "Failed to decode WoL request body: %v", err)
 		sendJSONError(w, "Failed to read request data", http.StatusBadRequest)
 		return
 	}
 
-	Debug("WoL request for host ID: %s", data.ID)
+	DebugContext(r.Context(), "WoL request for host ID: %s", data.ID)
 
 	var host Host
 	var query string
 	var args []interface{}
 
 	if s.Config.UseAuth && user != nil {
-		query = "SELECT id, name, mac, broadcast, interface, user_id FROM hosts WHERE id = ? AND user_id = ?"
+		query = "SELECT id, name, mac, broadcast, interface, static_ip, use_as_fallback, user_id FROM hosts WHERE id = ? AND user_id = ?"
 		args = []interface{}{data.ID, user.ID}
 	} else {
 		// In no-auth mode, ONLY allow access to hosts with NULL user_id
-		query = "SELECT id, name, mac, broadcast, interface, user_id FROM hosts WHERE id = ? AND user_id IS NULL"
+		query = "SELECT id, name, mac, broadcast, interface, static_ip, use_as_fallback, user_id FROM hosts WHERE id = ? AND user_id IS NULL"
 		args = []interface{}{data.ID}
 	}
 
-	err := s.DB.QueryRow(query, args...).Scan(&host.ID, &host.Name, &host.MAC, &host.Broadcast, &host.Interface, &host.UserID)
+	err := s.DB.QueryRowContext(r.Context(), query, args...).Scan(&host.ID, &host.Name, &host.MAC, &host.Broadcast, &host.Interface, &host.StaticIP, &host.UseAsFallback, &host.UserID)
 	if err == sql.ErrNoRows {
 		// In no-auth mode, check if host exists but has a user_id
 		if !s.Config.UseAuth {
 			var exists bool
 			err := s.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM hosts WHERE id = ?)", data.ID).Scan(&exists)
 			if err == nil && exists {
-				Debug("WoL access denied for host ID %s (belongs to user in no-auth mode)", data.ID)
+				DebugContext(r.Context(), "WoL access denied for host ID %s (belongs to user in no-auth mode)", data.ID)
 				sendJSONError(w, "Access denied: host not accessible in no-auth mode", http.StatusForbidden)
 				return
 			}
 		}
-		Debug("WoL failed - host ID %s not found", data.ID)
+		DebugContext(r.Context(), "WoL failed - host ID %s not found", data.ID)
 		sendJSONError(w, "Host not found", http.StatusNotFound)
 		return
 	}
 	if err != nil {
-		Debug("WoL failed - database error for host ID %s: %v", data.ID, err)
+		DebugContext(r.Context(), "WoL failed - database error for host ID %s: %v", data.ID, err)
 		sendJSONError(w, "Failed to find host", http.StatusInternalServerError)
 		return
 	}
 
-	Debug("Found host '%s' (ID: %s, MAC: %s, Broadcast: %s)",
+	DebugContext(r.Context(), "Found host '%s' (ID: %s, MAC: %s, Broadcast: %s)",
 		host.Name, host.ID, host.MAC, host.Broadcast)
 
-	parts := strings.Split(host.Broadcast, ":")
-	if len(parts) != 2 {
-		Debug("WoL failed - invalid broadcast format for host '%s': %s", host.Name, host.Broadcast)
-		sendJSONError(w, "Invalid broadcast format", http.StatusBadRequest)
+	// Walk the dependency chain when this host depends on other hosts
+	chain, err := s.buildWakeChain(host)
+	if err != nil {
+		ErrorContext(r.Context(), "Failed to load wake dependencies for host '%s': %v", host.Name, err)
+		sendJSONError(w, "Failed to load host dependencies", http.StatusInternalServerError)
 		return
 	}
-
-	targetIp := parts[0]
-	port, err := strconv.Atoi(parts[1])
-	if err != nil {
-		Debug("WoL failed - invalid port for host '%s': %s", host.Name, parts[1])
-		sendJSONError(w, "Invalid port in broadcast field", http.StatusBadRequest)
+	if len(chain) > 1 {
+		DebugContext(r.Context(), "Host '%s' has %d dependencies - starting wake chain", host.Name, len(chain)-1)
+		run, err := s.startWakeChain(r.Context(), chain)
+		if err != nil {
+			ErrorContext(r.Context(), "Failed to start wake chain for host '%s': %v", host.Name, err)
+			sendJSONError(w, "Failed to start wake chain", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Location", s.buildURL("/api/wake/chains/"+run.ID))
+		sendJSON(w, map[string]interface{}{
+			"message":  "Wake chain started",
+			"chain_id": run.ID,
+			"steps":    run.Steps,
+		}, http.StatusAccepted)
 		return
 	}
 
-	// Determine which network interface(s) to use for WoL
-	interfaceToUse := s.determineNetworkInterface(host)
-
-	ifaceDesc := "all available interfaces"
-	if interfaceToUse != "" {
-		ifaceDesc = interfaceToUse
+	targetIp, port, err := parseBroadcastAddress(host.Broadcast)
+	if err != nil {
+		DebugContext(r.Context(), "WoL failed - invalid broadcast '%s' for host '%s': %v", host.Broadcast, host.Name, err)
+		sendJSONError(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	Debug("Sending WoL magic packet for host '%s' (MAC: %s) to %s:%d using %s",
-		host.Name, host.MAC, targetIp, port, ifaceDesc)
-
-	err = SendWakeOnLanWithInterface(host.MAC, targetIp, port, interfaceToUse)
+	err = s.wakeHost(r.Context(), host)
 	if err != nil {
-		Debug("WoL packet send FAILED for host '%s' (MAC: %s) to %s:%d - %v",
+		DebugContext(r.Context(), "WoL packet send FAILED for host '%s' (MAC: %s) to %s:%d - %v",
 			host.Name, host.MAC, targetIp, port, err)
 		response := map[string]string{
 			"message": "Failed to wake host",
@@ -123,6 +132,67 @@ func (s *Server) handleWake(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	DebugContext(r.Context(), "WoL magic packet SUCCESSFULLY sent for host '%s' (MAC: %s) to %s:%d",
+		host.Name, host.MAC, targetIp, port)
+	response := map[string]string{"message": "WakeOnLan Magic Packet Sent"}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// parseBroadcastAddress splits a host broadcast field ("IP:PORT" or "[IPv6]:PORT") into IP and port
+func parseBroadcastAddress(broadcast string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(broadcast)
+	if err != nil {
+		return "", 0, fmt.Errorf("Invalid broadcast format")
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", 0, fmt.Errorf("Invalid port in broadcast field")
+	}
+
+	return host, port, nil
+}
+
+// wakeHost sends a Wake-on-LAN magic packet to a host using its configured
+// broadcast address and network interface(s), then records the event for
+// ping prioritization and invalidates the cached status of the host.
+func (s *Server) wakeHost(ctx context.Context, host Host) (err error) {
+	ctx, span := startSpan(ctx, "wakeHost", attribute.String("host.id", host.ID), attribute.String("host.name", host.Name))
+	ctx = withLogHostID(ctx, host.ID)
+	defer func() {
+		s.Metrics.observeWake(host, err)
+		endSpan(span, err)
+	}()
+
+	targetIp, port, err := parseBroadcastAddress(host.Broadcast)
+	if err != nil {
+		return err
+	}
+
+	if relay := s.hostRelay(ctx, host.ID); relay != "" {
+		span.SetAttributes(attribute.String("relay", relay))
+		// Magic packets don't cross routers - let the relay on the host's network send it
+		if err := s.wakeViaRelay(host, relay); err != nil {
+			return err
+		}
+	} else {
+		// Determine which network interface(s) to use for WoL
+		interfaceToUse := s.determineNetworkInterface(host)
+
+		ifaceDesc := "all available interfaces"
+		if interfaceToUse != "" {
+			ifaceDesc = interfaceToUse
+		}
+
+		DebugContext(ctx, "Sending WoL magic packet for host '%s' (MAC: %s) to %s:%d using %s",
+			host.Name, host.MAC, targetIp, port, ifaceDesc)
+
+		if err := SendWakeOnLanWithInterface(ctx, host.MAC, targetIp, port, interfaceToUse); err != nil {
+			return err
+		}
+	}
+
 	// Record WoL usage for prioritization in ping queue
 	s.WoLHistory.RecordWoL(host.ID)
 	Debug("Recorded WoL event for host '%s' (ID: %s) in priority queue", host.Name, host.ID)
@@ -131,9 +201,5 @@ func (s *Server) handleWake(w http.ResponseWriter, r *http.Request) {
 	s.PingCache.Invalidate(host.ID)
 	Debug("Invalidated ping cache for host '%s' (ID: %s) after WoL", host.Name, host.ID)
 
-	Debug("WoL magic packet SUCCESSFULLY sent for host '%s' (MAC: %s) to %s:%d",
-		host.Name, host.MAC, targetIp, port)
-	response := map[string]string{"message": "WakeOnLan Magic Packet Sent"}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	return nil
 }
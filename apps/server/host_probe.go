@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// hostProbeResult holds the outcome of a single network status check for a host
+type hostProbeResult struct {
+	PingSuccess bool
+	ARPSuccess  bool
+	IP          string          // IP address the host was found at (empty if not found)
+	MAC         string          // MAC address that answered at IP (may differ from the host's MAC)
+	RTT         time.Duration   // ICMP round-trip time (0 if the host did not answer ICMP)
+	Services    []ServiceResult // Service check results, filled in by checkHostServices
+	Method      string          // Resolution step that decided the status (ProbeMethod*)
+}
+
+// Status check resolution steps, reported in metrics
+const (
+	ProbeMethodStatic         = "static"
+	ProbeMethodARPTable       = "arp_table"
+	ProbeMethodARPScan        = "arp_scan"
+	ProbeMethodNDP            = "ndp"
+	ProbeMethodStaticFallback = "static_fallback"
+	ProbeMethodRelay          = "relay"
+)
+
+// probeHost checks whether a host is online using the configured resolution strategy.
+//
+// Resolution order:
+//  1. Static IP (when configured and not used as fallback) - ARP/NDP ping directly
+//  2. Passive neighbour table lookup by MAC, verified with active ARP/NDP ping
+//  3. Active ARP scan of the interface subnet(s) by MAC
+//  4. IPv6 all-nodes discovery by MAC
+//  5. Static IP as fallback (when use_as_fallback is enabled)
+//
+// Dual-stack hosts (an IPv4 and an IPv6 static address, or both in the neighbour table)
+// are checked on all addresses at once; the first address that answers wins.
+//
+// Hosts assigned to a relay are checked by the relay using the same strategy.
+//
+// When flushARP is true, an existing ARP table entry is flushed and looked up again
+// before verification so that manual checks always work with fresh data.
+//
+// probeHost does not consult or update the PingCache - callers are responsible for that.
+func (s *Server) probeHost(ctx context.Context, host Host, flushARP bool) (result hostProbeResult) {
+	ctx, span := startSpan(ctx, "probeHost", attribute.String("host.id", host.ID), attribute.String("host.name", host.Name))
+	ctx = withLogHostID(ctx, host.ID)
+	start := time.Now()
+	defer func() {
+		s.Metrics.observeProbe(result, time.Since(start))
+		span.SetAttributes(
+			attribute.String("probe.method", result.Method),
+			attribute.Bool("probe.online", result.PingSuccess || result.ARPSuccess),
+			attribute.String("host.ip", result.IP))
+		endSpan(span, nil)
+	}()
+
+	// Hosts on remote networks are checked by their relay
+	if relay := s.hostRelay(ctx, host.ID); relay != "" {
+		span.SetAttributes(attribute.String("relay", relay))
+		return s.probeViaRelay(host, relay, flushARP)
+	}
+
+	// Determine which network interface(s) to use
+	interfaceToUse := s.determineNetworkInterface(host)
+
+	ifaceDesc := "all available interfaces"
+	if interfaceToUse != "" {
+		ifaceDesc = interfaceToUse
+	}
+	netLog.DebugContext(ctx, "Checking status of host '%s' (MAC: %s) using %s", host.Name, host.MAC, ifaceDesc)
+
+	staticIPs := splitStaticIPs(host.StaticIP)
+
+	// Check if static IP is configured
+	if len(staticIPs) > 0 && !host.UseAsFallback {
+		// Use static IP directly (ignore ARP resolution)
+		netLog.DebugContext(ctx, "Using configured static IP %s for host '%s' (MAC: %s)", host.StaticIP, host.Name, host.MAC)
+
+		ip, hwAddr, ok := pingFirstNeighbor(ctx, staticIPs, interfaceToUse)
+		if !ok {
+			netLog.DebugContext(ctx, "Host '%s' (MAC: %s) not responding at static IP %s", host.Name, host.MAC, host.StaticIP)
+			return hostProbeResult{Method: ProbeMethodStatic}
+		}
+
+		netLog.DebugContext(ctx, "Host '%s' is ONLINE at static IP %s (MAC: %s verified)", host.Name, ip, hwAddr.String())
+		s.checkMACMismatch(host, hwAddr.String(), ip)
+		return hostProbeResult{PingSuccess: true, ARPSuccess: true, IP: ip, MAC: hwAddr.String(), RTT: measureRTT(ctx, ip, interfaceToUse), Method: ProbeMethodStatic}
+	}
+
+	// Try to resolve IP from MAC first (passive ARP/NDP table lookup)
+	netLog.DebugContext(ctx, "Looking up IP for MAC %s in ARP table", host.MAC)
+	hostIPs, ipErr := GetIPsFromMAC(host.MAC, interfaceToUse)
+
+	// For manual checks, flush ARP cache if entry exists to ensure fresh data
+	if flushARP && ipErr == nil {
+		for _, hostIP := range hostIPs {
+			FlushARPEntryIfExists(hostIP)
+		}
+		netLog.DebugContext(ctx, "Manual ping - flushed ARP cache for %s to ensure fresh data", strings.Join(hostIPs, ", "))
+		// Re-lookup after flush
+		hostIPs, ipErr = GetIPsFromMAC(host.MAC, interfaceToUse)
+	}
+
+	if ipErr == nil {
+		// IP found in ARP table - now verify host is actually online using ARP/NDP ping
+		netLog.DebugContext(ctx, "Host '%s' (MAC: %s) found in ARP table at %s", host.Name, host.MAC, strings.Join(hostIPs, ", "))
+
+		hostIP, hwAddr, ok := pingFirstNeighbor(ctx, hostIPs, interfaceToUse)
+		if ok {
+			netLog.DebugContext(ctx, "Host '%s' is ONLINE at IP %s (MAC: %s verified)", host.Name, hostIP, hwAddr.String())
+			s.checkMACMismatch(host, hwAddr.String(), hostIP)
+			return hostProbeResult{PingSuccess: true, ARPSuccess: true, IP: hostIP, MAC: hwAddr.String(), RTT: measureRTT(ctx, hostIP, interfaceToUse), Method: ProbeMethodARPTable}
+		}
+
+		// Host in ARP table but not responding - flush stale entries
+		for _, hostIP := range hostIPs {
+			FlushARPEntryIfExists(hostIP)
+		}
+		netLog.DebugContext(ctx, "Host '%s' (MAC: %s) in ARP table but not responding - flushed cache entries for %s", host.Name, host.MAC, strings.Join(hostIPs, ", "))
+		return hostProbeResult{Method: ProbeMethodARPTable}
+	}
+
+	// IP not in ARP table - do full network scan to find host by MAC
+	netLog.DebugContext(ctx, "Host '%s' (MAC: %s) not in ARP table", host.Name, host.MAC)
+	netLog.DebugContext(ctx, "Starting full network ARP scan for host '%s' (timeout: %ds)", host.Name, s.live().PingTimeout)
+
+	foundIP, pingOk, arpErr := ARPPingMAC(ctx, host.MAC, interfaceToUse, s.live().PingTimeout)
+	if arpErr == nil {
+		netLog.DebugContext(ctx, "Host '%s' (MAC: %s) found via network scan at IP %s - ping: %v", host.Name, host.MAC, foundIP, pingOk)
+		result := hostProbeResult{PingSuccess: pingOk, ARPSuccess: true, IP: foundIP, MAC: host.MAC, Method: ProbeMethodARPScan}
+		if pingOk {
+			result.RTT = measureRTT(ctx, foundIP, interfaceToUse)
+		}
+		return result
+	}
+
+	// IPv6 subnets cannot be scanned - ask all IPv6 nodes on the link instead, unless
+	// IPv6 is not in use for this host
+	if ipv6DiscoveryUseful(staticIPs, interfaceToUse) {
+		foundIP, ndpErr := NDPDiscoverMAC(ctx, host.MAC, interfaceToUse)
+		if ndpErr == nil {
+			netLog.DebugContext(ctx, "Host '%s' (MAC: %s) found via IPv6 all-nodes discovery at IP %s", host.Name, host.MAC, foundIP)
+			return hostProbeResult{PingSuccess: true, ARPSuccess: true, IP: foundIP, MAC: host.MAC, RTT: measureRTT(ctx, foundIP, interfaceToUse), Method: ProbeMethodNDP}
+		}
+		netLog.DebugContext(ctx, "IPv6 discovery for host '%s': %v", host.Name, ndpErr)
+	}
+
+	// Host not found - try static IP as fallback if configured
+	if len(staticIPs) > 0 && host.UseAsFallback {
+		netLog.DebugContext(ctx, "ARP resolution failed for host '%s', trying static IP %s as fallback", host.Name, host.StaticIP)
+
+		ip, hwAddr, ok := pingFirstNeighbor(ctx, staticIPs, interfaceToUse)
+		if ok {
+			netLog.DebugContext(ctx, "Host '%s' is ONLINE at fallback static IP %s (MAC: %s)", host.Name, ip, hwAddr.String())
+			s.checkMACMismatch(host, hwAddr.String(), ip)
+			return hostProbeResult{PingSuccess: true, ARPSuccess: true, IP: ip, MAC: hwAddr.String(), RTT: measureRTT(ctx, ip, interfaceToUse), Method: ProbeMethodStaticFallback}
+		}
+		netLog.DebugContext(ctx, "Host '%s' (MAC: %s) NOT FOUND on network or at fallback static IP - OFFLINE", host.Name, host.MAC)
+		return hostProbeResult{Method: ProbeMethodStaticFallback}
+	}
+
+	netLog.DebugContext(ctx, "Host '%s' (MAC: %s) NOT FOUND on network - OFFLINE", host.Name, host.MAC)
+	return hostProbeResult{Method: ProbeMethodARPScan}
+}
+
+// pingFirstNeighbor checks several addresses of a host at once (e.g. both addresses of a
+// dual-stack host) and returns the first one that answers ARP or NDP
+func pingFirstNeighbor(ctx context.Context, ips []string, networkInterface string) (string, net.HardwareAddr, bool) {
+	type answer struct {
+		ip     string
+		hwAddr net.HardwareAddr
+	}
+	answers := make(chan answer, len(ips))
+	for _, ip := range ips {
+		go func(ip string) {
+			hwAddr, err := pingNeighbor(ctx, ip, networkInterface)
+			if err != nil {
+				hwAddr = nil
+			}
+			answers <- answer{ip: ip, hwAddr: hwAddr}
+		}(ip)
+	}
+
+	for range ips {
+		if a := <-answers; a.hwAddr != nil {
+			return a.ip, a.hwAddr, true
+		}
+	}
+	return "", nil, false
+}
+
+// silentAddresses remembers addresses that did not answer an RTT echo request
+type silentAddresses struct {
+	mutex sync.Mutex
+	until map[string]time.Time // Address -> no echo requests before this time
+}
+
+var icmpSilentHosts = &silentAddresses{until: make(map[string]time.Time)}
+
+// record notes that ip did not answer and forgets addresses whose wait has passed
+func (sa *silentAddresses) record(ip string) {
+	sa.mutex.Lock()
+	defer sa.mutex.Unlock()
+	now := time.Now()
+	for address, until := range sa.until {
+		if now.After(until) {
+			delete(sa.until, address)
+		}
+	}
+	sa.until[ip] = now.Add(ICMPSilentRecheckInterval)
+}
+
+// silent reports whether ip did not answer within the last ICMPSilentRecheckInterval
+func (sa *silentAddresses) silent(ip string) bool {
+	sa.mutex.Lock()
+	defer sa.mutex.Unlock()
+	until, ok := sa.until[ip]
+	return ok && time.Now().Before(until)
+}
+
+// answered forgets that ip did not answer
+func (sa *silentAddresses) answered(ip string) {
+	sa.mutex.Lock()
+	defer sa.mutex.Unlock()
+	delete(sa.until, ip)
+}
+
+// measureRTT sends one ICMP echo to a host that answered ARP to report its round-trip time.
+// Hosts that drop ICMP are still online; their RTT is reported as unknown (0).
+//
+// The status is already known, so a single echo is sent: through the host's interface
+// when it has exactly one, else by the routing table. Addresses that did not answer are
+// not asked again for ICMPSilentRecheckInterval, so checks of hosts that drop ICMP do not
+// wait for the timeout every time.
+func measureRTT(ctx context.Context, ip string, networkInterface string) time.Duration {
+	parsed := net.ParseIP(ip)
+	if parsed == nil || icmpSilentHosts.silent(ip) {
+		return 0
+	}
+	iface := ""
+	if !strings.Contains(networkInterface, ",") {
+		iface = strings.TrimSpace(networkInterface)
+	}
+
+	rtt, err := pingICMP(parsed, ICMPRTTTimeout, iface)
+	if errors.Is(err, errICMPUnavailable) {
+		var ok bool
+		if rtt, ok = pingWithCommand(ip, ICMPRTTTimeout, iface); ok {
+			err = nil
+		}
+	}
+	if err != nil {
+		netLog.DebugContext(ctx, "No ICMP echo reply from %s - RTT unknown, not measured again for %s", ip, ICMPSilentRecheckInterval)
+		icmpSilentHosts.record(ip)
+		return 0
+	}
+	icmpSilentHosts.answered(ip)
+	return rtt
+}
+
+// checkMACMismatch logs a warning when the MAC address that answered at an IP
+// differs from the MAC address stored for the host
+func (s *Server) checkMACMismatch(host Host, detected string, ip string) {
+	detectedMAC := normalizeMACAddress(detected)
+	storedMAC := normalizeMACAddress(host.MAC)
+	if detectedMAC != storedMAC {
+		netLog.Warning("Host '%s' MAC mismatch - stored: %s, detected: %s at IP %s",
+			host.Name, s.describeMAC(storedMAC), s.describeMAC(detectedMAC), ip)
+		if slices.Contains(splitStaticIPs(host.StaticIP), ip) {
+			netLog.Warning("This may indicate network complexity (overlapping IP ranges, VLAN issues, or incorrect static IP configuration)")
+		}
+	}
+}
+
+// resolveHostAddress determines the current IP address of a host: the static IP,
+// the ARP table entry for its MAC, or the address found by a status check.
+// Returns "" if the host cannot be found.
+func (s *Server) resolveHostAddress(ctx context.Context, host Host) string {
+	if staticIPs := splitStaticIPs(host.StaticIP); len(staticIPs) > 0 && !host.UseAsFallback {
+		if len(staticIPs) == 1 {
+			return staticIPs[0]
+		}
+		// Dual-stack: use whichever address answers
+		if ip, _, ok := pingFirstNeighbor(ctx, staticIPs, s.determineNetworkInterface(host)); ok {
+			return ip
+		}
+		return staticIPs[0]
+	}
+	if s.hostRelay(ctx, host.ID) == "" {
+		if ip, err := GetIPFromMAC(host.MAC, s.determineNetworkInterface(host)); err == nil && ip != "" {
+			return ip
+		}
+	}
+	if result := s.probeHost(ctx, host, false); result.IP != "" {
+		return result.IP
+	}
+	return ""
+}
+
+// getHostStatus returns the current status of a host, using the ping cache when possible.
+// Used by internal callers (e.g. wake chains) that are not subject to ping rate limits.
+func (s *Server) getHostStatus(ctx context.Context, host Host) hostProbeResult {
+	if cachedEntry := s.PingCache.Get(host.ID); cachedEntry != nil && !cachedEntry.InProgress {
+		return hostProbeResult{PingSuccess: cachedEntry.PingSuccess, ARPSuccess: cachedEntry.ARPSuccess, RTT: cachedEntry.RTT}
+	}
+
+	result := s.probeHost(ctx, host, false)
+	s.PingCache.Set(host.ID, result)
+	return result
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// HostIPRecord is one entry of a host's IP history
+type HostIPRecord struct {
+	IP        string    `json:"ip"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Sightings int       `json:"sightings"` // Number of status checks that found the host at this address
+}
+
+// HostAlert is a problem or suggestion found by a status check
+type HostAlert struct {
+	ID             int64     `json:"id"`
+	HostID         string    `json:"host_id"`
+	HostName       string    `json:"host_name,omitempty"` // Only in the alert list of all hosts
+	Type           string    `json:"type"`
+	IP             string    `json:"ip,omitempty"`          // Address the host (or another device) answered at
+	PreviousIP     string    `json:"previous_ip,omitempty"` // ip_changed: the address before the change; static_ip_suggestion: the static IP to replace
+	ExpectedMAC    string    `json:"expected_mac,omitempty"`
+	DetectedMAC    string    `json:"detected_mac,omitempty"`
+	DetectedVendor string    `json:"detected_vendor,omitempty"`
+	Message        string    `json:"message"`
+	Count          int       `json:"count"` // Number of status checks that raised the alert
+	Created        time.Time `json:"created"`
+	LastSeen       time.Time `json:"last_seen"`
+	Dismissed      bool      `json:"dismissed"`
+}
+
+// Host alert types
+const (
+	HostAlertMACMismatch        = "mac_mismatch"         // Another MAC address answered at the host's address
+	HostAlertIPChanged          = "ip_changed"           // The host was found at a new address
+	HostAlertStaticIPSuggestion = "static_ip_suggestion" // A fallback host keeps appearing at an address other than its static IP
+)
+
+// hostTrackingMutex serializes IP history updates so concurrent checks of a host
+// (single and bulk ping) do not record the same change twice
+var hostTrackingMutex sync.Mutex
+
+// trackHostAddress records the address a status check found a host at and raises alerts
+// for MAC mismatches, IP changes and outdated static IPs. Failures are logged only.
+func (s *Server) trackHostAddress(ctx context.Context, host Host, result hostProbeResult) {
+	if result.IP == "" || !(result.PingSuccess || result.ARPSuccess) {
+		return
+	}
+	ip := net.ParseIP(result.IP)
+	if ip == nil {
+		return
+	}
+
+	hostTrackingMutex.Lock()
+	defer hostTrackingMutex.Unlock()
+
+	expectedMAC := normalizeMACAddress(host.MAC)
+	if result.MAC != "" {
+		if detectedMAC := normalizeMACAddress(result.MAC); detectedMAC != expectedMAC {
+			// The address belongs to another device - do not record it as the host's address
+			s.raiseHostAlert(HostAlert{
+				HostID:      host.ID,
+				Type:        HostAlertMACMismatch,
+				IP:          result.IP,
+				ExpectedMAC: expectedMAC,
+				DetectedMAC: detectedMAC,
+				Message: fmt.Sprintf("%s answered at %s instead of %s",
+					s.describeMAC(detectedMAC), result.IP, s.describeMAC(expectedMAC)),
+			})
+			return
+		}
+	}
+
+	previousIP, sightings, err := s.recordHostIP(ctx, host.ID, ip)
+	if err != nil {
+		Error("Failed to record IP %s of host '%s': %v", result.IP, host.Name, err)
+		return
+	}
+
+	if previousIP != "" && previousIP != ip.String() {
+		Info("Host '%s' (MAC: %s) changed IP address from %s to %s", host.Name, expectedMAC, previousIP, ip)
+		s.raiseHostAlert(HostAlert{
+			HostID:     host.ID,
+			Type:       HostAlertIPChanged,
+			IP:         ip.String(),
+			PreviousIP: previousIP,
+			Message:    fmt.Sprintf("IP address changed from %s to %s", previousIP, ip),
+		})
+
+		// A suggestion to move the static IP to the previous address is outdated now
+		if _, err := s.DB.Exec("UPDATE host_alerts SET dismissed = TRUE WHERE host_id = ? AND type = ? AND ip = ? AND dismissed = FALSE",
+			host.ID, HostAlertStaticIPSuggestion, previousIP); err != nil {
+			Warning("Failed to dismiss outdated static IP suggestion of host '%s': %v", host.Name, err)
+		}
+	}
+
+	staticIPs := splitStaticIPs(host.StaticIP)
+	if host.UseAsFallback && len(staticIPs) > 0 && !slices.Contains(staticIPs, ip.String()) &&
+		sightings >= StaticIPSuggestionSightings {
+		staticIP := sameFamilyIP(staticIPs, ip)
+		message := fmt.Sprintf("Host keeps appearing at %s - update the fallback static IP %s?", ip, staticIP)
+		if staticIP == "" {
+			message = fmt.Sprintf("Host keeps appearing at %s - add it to the fallback static IP?", ip)
+		}
+		s.raiseHostAlert(HostAlert{
+			HostID:     host.ID,
+			Type:       HostAlertStaticIPSuggestion,
+			IP:         ip.String(),
+			PreviousIP: staticIP,
+			Message:    message,
+		})
+	}
+}
+
+// recordHostIP adds a sighting of a host at an address. Returns the previous address of
+// the same family (IPv4/IPv6) and the number of consecutive sightings at the address.
+func (s *Server) recordHostIP(ctx context.Context, hostID string, ip net.IP) (string, int, error) {
+	family := 6
+	if ip.To4() != nil {
+		family = 4
+	}
+	now := time.Now()
+
+	var id int64
+	var lastIP string
+	var sightings int
+	err := s.DB.QueryRowContext(ctx, "SELECT id, ip, sightings FROM host_ip_history WHERE host_id = ? AND family = ? ORDER BY id DESC LIMIT 1",
+		hostID, family).Scan(&id, &lastIP, &sightings)
+	if err != nil && err != sql.ErrNoRows {
+		return "", 0, err
+	}
+
+	if err == nil && lastIP == ip.String() {
+		_, err := s.DB.ExecContext(ctx, "UPDATE host_ip_history SET last_seen = ?, sightings = sightings + 1 WHERE id = ?", now, id)
+		return lastIP, sightings + 1, err
+	}
+
+	if _, err := s.DB.ExecContext(ctx, "INSERT INTO host_ip_history (host_id, ip, family, first_seen, last_seen) VALUES (?, ?, ?, ?, ?)",
+		hostID, ip.String(), family, now, now); err != nil {
+		return "", 0, err
+	}
+	_, err = s.DB.ExecContext(ctx, "DELETE FROM host_ip_history WHERE host_id = ? AND id NOT IN (SELECT id FROM host_ip_history WHERE host_id = ? ORDER BY id DESC LIMIT ?)",
+		hostID, hostID, MaxHostIPHistory)
+	return lastIP, 1, err
+}
+
+// raiseHostAlert stores an alert. An open alert of the same kind (same type, addresses
+// and MAC) is updated instead, so a recurring problem shows up once with a count.
+func (s *Server) raiseHostAlert(alert HostAlert) {
+	now := time.Now()
+	result, err := s.DB.Exec(`UPDATE host_alerts SET count = count + 1, last_seen = ?, message = ?
+		WHERE host_id = ? AND type = ? AND ip = ? AND previous_ip = ? AND detected_mac = ? AND dismissed = FALSE`,
+		now, alert.Message, alert.HostID, alert.Type, alert.IP, alert.PreviousIP, alert.DetectedMAC)
+	if err != nil {
+		Error("Failed to update %s alert of host %s: %v", alert.Type, alert.HostID, err)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows > 0 {
+		return
+	}
+
+	_, err = s.DB.Exec(`INSERT INTO host_alerts (host_id, type, ip, previous_ip, expected_mac, detected_mac, message, created, last_seen)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		alert.HostID, alert.Type, alert.IP, alert.PreviousIP, alert.ExpectedMAC, alert.DetectedMAC, alert.Message, now, now)
+	if err != nil {
+		Error("Failed to store %s alert of host %s: %v", alert.Type, alert.HostID, err)
+		return
+	}
+	Info("Host alert (%s) for host %s: %s", alert.Type, alert.HostID, alert.Message)
+}
+
+// cleanupHostAlerts removes dismissed alerts older than the retention period
+func (s *Server) cleanupHostAlerts() error {
+	_, err := s.DB.Exec("DELETE FROM host_alerts WHERE dismissed = TRUE AND last_seen < ?", time.Now().AddDate(0, 0, -HostAlertRetentionDays))
+	return err
+}
+
+// annotateHostAddresses fills in the last known address and the number of open alerts of
+// hosts. Hosts whose address details are hidden (read-only access) are left alone.
+func (s *Server) annotateHostAddresses(hosts []Host) {
+	var ids []interface{}
+	index := make(map[string]int)
+	for i, host := range hosts {
+		if host.MAC != "" {
+			ids = append(ids, host.ID)
+			index[host.ID] = i
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+
+	rows, err := s.DB.Query("SELECT host_id, ip, last_seen FROM host_ip_history WHERE host_id IN ("+placeholders+")", ids...)
+	if err != nil {
+		Error("Failed to load IP history: %v", err)
+		return
+	}
+	for rows.Next() {
+		var hostID, ip string
+		var lastSeen time.Time
+		if err := rows.Scan(&hostID, &ip, &lastSeen); err != nil {
+			continue
+		}
+		host := &hosts[index[hostID]]
+		if host.LastSeen == nil || lastSeen.After(*host.LastSeen) {
+			host.LastIP = ip
+			host.LastSeen = &lastSeen
+		}
+	}
+	rows.Close()
+
+	rows, err = s.DB.Query("SELECT host_id, COUNT(*) FROM host_alerts WHERE dismissed = FALSE AND host_id IN ("+placeholders+") GROUP BY host_id", ids...)
+	if err != nil {
+		Error("Failed to count host alerts: %v", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hostID string
+		var count int
+		if err := rows.Scan(&hostID, &count); err == nil {
+			hosts[index[hostID]].Alerts = count
+		}
+	}
+}
+
+// hostAddressesHidden reports whether address details are hidden from a user
+func (s *Server) hostAddressesHidden(user *User) bool {
+	return s.live().ReadOnlyMode || (s.Config.UseAuth && user != nil && user.ReadOnly)
+}
+
+// handleHostIPHistory returns the addresses a host was found at, newest first
+func (s *Server) handleHostIPHistory(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r)
+	host, ok := s.loadAccessibleHost(w, user, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+	if s.hostAddressesHidden(user) {
+		sendJSONErrorWithCode(w, "Read-only access: address details are not available", ErrCodeForbidden, http.StatusForbidden)
+		return
+	}
+
+	rows, err := s.DB.Query("SELECT ip, first_seen, last_seen, sightings FROM host_ip_history WHERE host_id = ? ORDER BY id DESC", host.ID)
+	if err != nil {
+		sendJSONError(w, "Failed to fetch IP history", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	history := []HostIPRecord{}
+	for rows.Next() {
+		var record HostIPRecord
+		if err := rows.Scan(&record.IP, &record.FirstSeen, &record.LastSeen, &record.Sightings); err != nil {
+			continue
+		}
+		history = append(history, record)
+	}
+	sendJSON(w, history, http.StatusOK)
+}
+
+// handleHostAlerts returns the alerts of a host, newest first
+//
+// Query parameters:
+//   - all: include dismissed alerts ("true")
+func (s *Server) handleHostAlerts(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r)
+	host, ok := s.loadAccessibleHost(w, user, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+	if s.hostAddressesHidden(user) {
+		sendJSONErrorWithCode(w, "Read-only access: address details are not available", ErrCodeForbidden, http.StatusForbidden)
+		return
+	}
+
+	query := "SELECT a.id, a.host_id, '', a.type, a.ip, a.previous_ip, a.expected_mac, a.detected_mac, a.message, a.count, a.created, a.last_seen, a.dismissed FROM host_alerts a WHERE a.host_id = ?"
+	if r.URL.Query().Get("all") != "true" {
+		query += " AND a.dismissed = FALSE"
+	}
+	alerts, err := s.queryHostAlerts(query+" ORDER BY a.id DESC LIMIT ?", host.ID, MaxHostAlerts)
+	if err != nil {
+		sendJSONError(w, "Failed to fetch alerts", http.StatusInternalServerError)
+		return
+	}
+	sendJSON(w, alerts, http.StatusOK)
+}
+
+// handleAlerts returns the open alerts of all hosts visible to the user, newest first
+func (s *Server) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r)
+	if s.hostAddressesHidden(user) {
+		sendJSONErrorWithCode(w, "Read-only access: address details are not available", ErrCodeForbidden, http.StatusForbidden)
+		return
+	}
+
+	scope, scopeArgs := s.hostScopeQuery(user)
+	query := "SELECT a.id, a.host_id, hosts.name, a.type, a.ip, a.previous_ip, a.expected_mac, a.detected_mac, a.message, a.count, a.created, a.last_seen, a.dismissed " +
+		"FROM host_alerts a JOIN hosts ON hosts.id = a.host_id WHERE a.dismissed = FALSE AND hosts." + scope + " ORDER BY a.id DESC LIMIT ?"
+	alerts, err := s.queryHostAlerts(query, append(scopeArgs, MaxHostAlerts)...)
+	if err != nil {
+		sendJSONError(w, "Failed to fetch alerts", http.StatusInternalServerError)
+		return
+	}
+	sendJSON(w, alerts, http.StatusOK)
+}
+
+// queryHostAlerts runs an alert query selecting all HostAlert columns (with the host name third)
+func (s *Server) queryHostAlerts(query string, args ...interface{}) ([]HostAlert, error) {
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []HostAlert{}
+	for rows.Next() {
+		var alert HostAlert
+		if err := rows.Scan(&alert.ID, &alert.HostID, &alert.HostName, &alert.Type, &alert.IP, &alert.PreviousIP,
+			&alert.ExpectedMAC, &alert.DetectedMAC, &alert.Message, &alert.Count, &alert.Created, &alert.LastSeen, &alert.Dismissed); err != nil {
+			continue
+		}
+		if alert.DetectedMAC != "" {
+			alert.DetectedVendor = s.Vendors.Lookup(alert.DetectedMAC).Vendor
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
+
+// handleHostAlert dismisses (DELETE) an alert of a host
+func (s *Server) handleHostAlert(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r)
+	host, alert, ok := s.loadHostAlertForUpdate(w, r, user)
+	if !ok {
+		return
+	}
+
+	if _, err := s.DB.Exec("UPDATE host_alerts SET dismissed = TRUE WHERE id = ?", alert.ID); err != nil {
+		sendJSONError(w, "Failed to dismiss alert", http.StatusInternalServerError)
+		return
+	}
+	Debug("Alert %d (%s) of host '%s' dismissed", alert.ID, alert.Type, host.Name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleApplyHostAlert applies a static IP suggestion: the static IP of the same address
+// family is replaced with the suggested address and the alert is dismissed.
+// Returns the updated host.
+func (s *Server) handleApplyHostAlert(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r)
+	host, alert, ok := s.loadHostAlertForUpdate(w, r, user)
+	if !ok {
+		return
+	}
+	if alert.Type != HostAlertStaticIPSuggestion {
+		sendJSONErrorWithCode(w, "Only static IP suggestions can be applied", ErrCodeInvalidInput, http.StatusBadRequest)
+		return
+	}
+
+	staticIP := replaceStaticIP(host.StaticIP, alert.IP)
+	if err := sanitizeStaticIP(staticIP); err != nil {
+		handleValidationError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	scope, scopeArgs := hostScopeForHost(host)
+	if _, err := s.DB.Exec("UPDATE hosts SET static_ip = ?, updated = CURRENT_TIMESTAMP WHERE id = ? AND "+scope,
+		append([]interface{}{staticIP, host.ID}, scopeArgs...)...); err != nil {
+		sendJSONError(w, "Failed to update host", http.StatusInternalServerError)
+		return
+	}
+	if _, err := s.DB.Exec("UPDATE host_alerts SET dismissed = TRUE WHERE id = ?", alert.ID); err != nil {
+		Warning("Failed to dismiss applied alert %d: %v", alert.ID, err)
+	}
+	s.PingCache.Invalidate(host.ID)
+
+	Info("Host '%s' static IP updated from %s to %s (suggestion applied)", host.Name, host.StaticIP, staticIP)
+	host.StaticIP = staticIP
+	host.Updated = time.Now()
+	if !s.Config.EnablePerHostInterfaces {
+		host.Interface = ""
+	}
+	s.annotateHostVendor(&host)
+	sendJSON(w, host, http.StatusOK)
+}
+
+// loadHostAlertForUpdate loads the host and alert of an alert modification request,
+// checking ownership and write access
+func (s *Server) loadHostAlertForUpdate(w http.ResponseWriter, r *http.Request, user *User) (Host, HostAlert, bool) {
+	var alert HostAlert
+	if s.hostAddressesHidden(user) {
+		sendJSONError(w, "Read-only access: modification not allowed", http.StatusForbidden)
+		return Host{}, alert, false
+	}
+
+	host, ok := s.loadAccessibleHost(w, user, mux.Vars(r)["id"])
+	if !ok {
+		return host, alert, false
+	}
+
+	alertID, err := strconv.ParseInt(mux.Vars(r)["alertId"], 10, 64)
+	if err != nil {
+		sendJSONErrorWithCode(w, "Alert not found", ErrCodeNotFound, http.StatusNotFound)
+		return host, alert, false
+	}
+	err = s.DB.QueryRow("SELECT id, type, ip FROM host_alerts WHERE id = ? AND host_id = ?", alertID, host.ID).
+		Scan(&alert.ID, &alert.Type, &alert.IP)
+	if err == sql.ErrNoRows {
+		sendJSONErrorWithCode(w, "Alert not found", ErrCodeNotFound, http.StatusNotFound)
+		return host, alert, false
+	}
+	if err != nil {
+		sendJSONError(w, "Failed to fetch alert", http.StatusInternalServerError)
+		return host, alert, false
+	}
+	return host, alert, true
+}
+
+// replaceStaticIP replaces the address of the same family (IPv4/IPv6) in a static IP
+// setting, keeping the other address of dual-stack hosts
+func replaceStaticIP(staticIP, ip string) string {
+	isIPv4 := net.ParseIP(ip).To4() != nil
+	result := []string{ip}
+	for _, existing := range splitStaticIPs(staticIP) {
+		if (net.ParseIP(existing).To4() != nil) != isIPv4 {
+			result = append(result, existing)
+		}
+	}
+	if !isIPv4 {
+		slices.Reverse(result) // IPv4 first
+	}
+	return strings.Join(result, ",")
+}
+
+// sameFamilyIP returns the first address of the same family as ip
+func sameFamilyIP(ips []string, ip net.IP) string {
+	for _, candidate := range ips {
+		if (net.ParseIP(candidate).To4() != nil) == (ip.To4() != nil) {
+			return candidate
+		}
+	}
+	return ""
+}
@@ -7,9 +7,11 @@ import (
 
 // ErrorResponse represents a JSON error response
 type ErrorResponse struct {
-	Error  string `json:"error"`
-	Code   string `json:"code,omitempty"`
-	Status string `json:"status,omitempty"`
+	Error     string `json:"error"`
+	Code      string `json:"code,omitempty"`
+	Status    string `json:"status,omitempty"`
+	RequestID string `json:"request_id,omitempty"` // Matches the request_id of the request's log lines
+	TraceID   string `json:"trace_id,omitempty"`   // Trace of the failed request (when tracing is enabled)
 }
 
 // sendJSONError sends a consistent JSON error response
@@ -17,8 +19,10 @@ func sendJSONError(w http.ResponseWriter, message string, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 	json.NewEncoder(w).Encode(ErrorResponse{
-		Error:  message,
-		Status: http.StatusText(statusCode),
+		Error:     message,
+		Status:    http.StatusText(statusCode),
+		RequestID: w.Header().Get(RequestIDHeader),
+		TraceID:   w.Header().Get(TraceIDHeader),
 	})
 }
 
@@ -27,9 +31,11 @@ func sendJSONErrorWithCode(w http.ResponseWriter, message string, code string, s
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 	json.NewEncoder(w).Encode(ErrorResponse{
-		Error:  message,
-		Code:   code,
-		Status: http.StatusText(statusCode),
+		Error:     message,
+		Code:      code,
+		Status:    http.StatusText(statusCode),
+		RequestID: w.Header().Get(RequestIDHeader),
+		TraceID:   w.Header().Get(TraceIDHeader),
 	})
 }
 
@@ -75,13 +81,13 @@ func (s *Server) determineNetworkInterface(host Host) string {
 	// Per-host interface selection disabled: use global network_interface
 	if !s.Config.EnablePerHostInterfaces {
 		// Return configured interface or empty (all interfaces)
-		return s.Config.DefaultNetworkInterface
+		return s.live().DefaultNetworkInterface
 	}
 
 	// Per-host interface selection enabled
 	// In readonly mode, ignore host-specific interface
-	if s.Config.ReadOnlyMode {
-		return s.Config.DefaultNetworkInterface
+	if s.live().ReadOnlyMode {
+		return s.live().DefaultNetworkInterface
 	}
 
 	// Use host-specific interface if specified
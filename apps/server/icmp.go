@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+)
+
+// ICMP message types used for echo (ping)
+const (
+	icmpTypeEchoReply   = 0
+	icmpTypeEchoRequest = 8
+
+	icmpv6TypeEchoRequest = 128
+	icmpv6TypeEchoReply   = 129
+)
+
+// errICMPUnavailable is returned when native ICMP sockets cannot be used on this system
+// (no datagram or raw socket permission, or unsupported platform). Callers fall back
+// to the system ping binary.
+var errICMPUnavailable = errors.New("native ICMP not available")
+
+// errICMPTimeout is returned when no echo reply arrives within the timeout
+var errICMPTimeout = errors.New("no ICMP echo reply")
+
+// marshalICMPEcho builds an ICMP echo message with a valid checksum
+// (for ICMPv6 the kernel replaces it with one covering the IPv6 pseudo-header)
+func marshalICMPEcho(msgType byte, id, seq uint16, payload []byte) []byte {
+	msg := make([]byte, 8+len(payload))
+	msg[0] = msgType
+	msg[1] = 0 // Code
+	binary.BigEndian.PutUint16(msg[4:6], id)
+	binary.BigEndian.PutUint16(msg[6:8], seq)
+	copy(msg[8:], payload)
+	binary.BigEndian.PutUint16(msg[2:4], icmpChecksum(msg))
+	return msg
+}
+
+// parseICMPEcho extracts type, identifier and sequence number from an ICMP echo message
+func parseICMPEcho(msg []byte) (msgType byte, id, seq uint16, ok bool) {
+	if len(msg) < 8 {
+		return 0, 0, 0, false
+	}
+	return msg[0], binary.BigEndian.Uint16(msg[4:6]), binary.BigEndian.Uint16(msg[6:8]), true
+}
+
+// icmpChecksum computes the Internet checksum (RFC 1071)
+func icmpChecksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+// addRTT adds the ICMP round-trip time in milliseconds to a status response (if measured)
+func addRTT(response map[string]interface{}, rtt time.Duration) map[string]interface{} {
+	if rtt > 0 {
+		response["rtt_ms"] = math.Round(float64(rtt)/float64(time.Millisecond)*100) / 100
+	}
+	return response
+}
@@ -0,0 +1,251 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// icmpPinger sends ICMP echo requests over a single socket and matches replies by
+// sequence number, so concurrent pings (e.g. a bulk ping of all hosts) share one socket
+// instead of spawning a ping process per host.
+type icmpPinger struct {
+	conn       net.PacketConn
+	iface      string
+	ipv6       bool
+	privileged bool   // Raw socket: receives replies for every process, filtered by id
+	id         uint16 // Echo identifier (rewritten by the kernel for datagram sockets)
+
+	mutex   sync.Mutex
+	seq     uint16
+	pending map[uint16]*icmpRequest // sequence -> waiting request
+}
+
+// icmpRequest is an echo request waiting for its reply
+type icmpRequest struct {
+	dst   net.IP
+	reply chan time.Time
+}
+
+// icmpPingerKey identifies a shared pinger: one per interface ("" = any) and address family
+type icmpPingerKey struct {
+	iface string
+	ipv6  bool
+}
+
+var (
+	icmpPingersMutex sync.Mutex
+	icmpPingers      = make(map[icmpPingerKey]*icmpPinger)
+)
+
+// pingICMP sends one echo request to ip (optionally bound to an interface) and returns the RTT
+func pingICMP(ip net.IP, timeout time.Duration, iface string) (time.Duration, error) {
+	key := icmpPingerKey{iface: iface, ipv6: ip.To4() == nil}
+	if !key.ipv6 {
+		ip = ip.To4()
+	}
+	pinger, err := getICMPPinger(key)
+	if err != nil {
+		return 0, err
+	}
+	return pinger.ping(ip, timeout)
+}
+
+// getICMPPinger returns the shared pinger for an interface and family, opening its socket on first use
+func getICMPPinger(key icmpPingerKey) (*icmpPinger, error) {
+	icmpPingersMutex.Lock()
+	defer icmpPingersMutex.Unlock()
+
+	if pinger, exists := icmpPingers[key]; exists {
+		return pinger, nil
+	}
+
+	pinger, err := newICMPPinger(key.iface, key.ipv6)
+	if err != nil {
+		return nil, err
+	}
+	icmpPingers[key] = pinger
+	go pinger.readReplies()
+
+	kind := "datagram"
+	if pinger.privileged {
+		kind = "raw"
+	}
+	if pinger.ipv6 {
+		kind += " ICMPv6"
+	}
+	ifaceDesc := key.iface
+	if ifaceDesc == "" {
+		ifaceDesc = "any interface"
+	}
+	netLog.Debug("Opened %s ICMP socket for %s", kind, ifaceDesc)
+	return pinger, nil
+}
+
+// newICMPPinger opens an unprivileged datagram ICMP socket (allowed by
+// net.ipv4.ping_group_range, which also covers ICMPv6) or, failing that, a raw
+// socket (needs CAP_NET_RAW)
+func newICMPPinger(iface string, ipv6 bool) (*icmpPinger, error) {
+	family, proto := unix.AF_INET, unix.IPPROTO_ICMP
+	var bindAddr unix.Sockaddr = &unix.SockaddrInet4{}
+	if ipv6 {
+		family, proto = unix.AF_INET6, unix.IPPROTO_ICMPV6
+		bindAddr = &unix.SockaddrInet6{}
+	}
+
+	privileged := false
+	fd, err := unix.Socket(family, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, proto)
+	if err != nil {
+		fd, err = unix.Socket(family, unix.SOCK_RAW|unix.SOCK_CLOEXEC, proto)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errICMPUnavailable, err)
+		}
+		privileged = true
+
+		// Raw ICMPv6 sockets also receive neighbour discovery traffic - only pass echo replies
+		if ipv6 {
+			var filter unix.ICMPv6Filter
+			for i := range filter.Data {
+				filter.Data[i] = 0xffffffff
+			}
+			filter.Data[icmpv6TypeEchoReply>>5] &^= 1 << (icmpv6TypeEchoReply & 31)
+			if err := unix.SetsockoptICMPv6Filter(fd, unix.SOL_ICMPV6, unix.ICMPV6_FILTER, &filter); err != nil {
+				unix.Close(fd)
+				return nil, fmt.Errorf("failed to set ICMPv6 filter: %w", err)
+			}
+		}
+	}
+
+	if iface != "" {
+		if err := unix.SetsockoptString(fd, unix.SOL_SOCKET, unix.SO_BINDTODEVICE, iface); err != nil {
+			unix.Close(fd)
+			return nil, fmt.Errorf("%w: bind to interface %s: %v", errICMPUnavailable, iface, err)
+		}
+	}
+	if err := unix.Bind(fd, bindAddr); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to bind ICMP socket: %w", err)
+	}
+
+	file := os.NewFile(uintptr(fd), "icmp")
+	conn, err := net.FilePacketConn(file)
+	file.Close() // FilePacketConn duplicates the descriptor
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ICMP socket: %w", err)
+	}
+
+	idBytes := make([]byte, 2)
+	rand.Read(idBytes)
+
+	return &icmpPinger{
+		conn:       conn,
+		iface:      iface,
+		ipv6:       ipv6,
+		privileged: privileged,
+		id:         binary.BigEndian.Uint16(idBytes),
+		pending:    make(map[uint16]*icmpRequest),
+	}, nil
+}
+
+// ping sends an echo request and waits for the matching reply
+func (p *icmpPinger) ping(ip net.IP, timeout time.Duration) (time.Duration, error) {
+	request := &icmpRequest{dst: ip, reply: make(chan time.Time, 1)}
+
+	p.mutex.Lock()
+	p.seq++
+	seq := p.seq
+	p.pending[seq] = request
+	p.mutex.Unlock()
+
+	defer func() {
+		p.mutex.Lock()
+		delete(p.pending, seq)
+		p.mutex.Unlock()
+	}()
+
+	msgType := byte(icmpTypeEchoRequest)
+	if p.ipv6 {
+		msgType = icmpv6TypeEchoRequest
+	}
+	msg := marshalICMPEcho(msgType, p.id, seq, []byte("wol-web-ping"))
+
+	// Link-local addresses are only meaningful together with an interface
+	zone := ""
+	if ip.IsLinkLocalUnicast() {
+		zone = p.iface
+	}
+	var dst net.Addr = &net.IPAddr{IP: ip, Zone: zone}
+	if !p.privileged {
+		dst = &net.UDPAddr{IP: ip, Zone: zone}
+	}
+
+	start := time.Now()
+	if _, err := p.conn.WriteTo(msg, dst); err != nil {
+		return 0, fmt.Errorf("failed to send ICMP echo to %s: %w", ip, err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case received := <-request.reply:
+		return received.Sub(start), nil
+	case <-timer.C:
+		return 0, errICMPTimeout
+	}
+}
+
+// readReplies delivers echo replies to waiting requests until the socket fails
+func (p *icmpPinger) readReplies() {
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := p.conn.ReadFrom(buf)
+		if err != nil {
+			netLog.Warning("ICMP socket for interface '%s' failed: %v - reopening on next ping", p.iface, err)
+			key := icmpPingerKey{iface: p.iface, ipv6: p.ipv6}
+			icmpPingersMutex.Lock()
+			if icmpPingers[key] == p {
+				delete(icmpPingers, key)
+			}
+			icmpPingersMutex.Unlock()
+			p.conn.Close()
+			return
+		}
+		received := time.Now()
+
+		msgType, id, seq, ok := parseICMPEcho(buf[:n])
+		if !ok || (msgType != icmpTypeEchoReply && msgType != icmpv6TypeEchoReply) {
+			continue
+		}
+		// Raw sockets see every reply on the system; datagram sockets only their own
+		if p.privileged && id != p.id {
+			continue
+		}
+
+		var src net.IP
+		switch a := addr.(type) {
+		case *net.IPAddr:
+			src = a.IP
+		case *net.UDPAddr:
+			src = a.IP
+		}
+
+		p.mutex.Lock()
+		request, exists := p.pending[seq]
+		if exists && request.dst.Equal(src) {
+			select {
+			case request.reply <- received:
+			default:
+			}
+		}
+		p.mutex.Unlock()
+	}
+}
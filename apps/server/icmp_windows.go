@@ -0,0 +1,14 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"net"
+	"time"
+)
+
+// pingICMP is not implemented natively on Windows - callers fall back to the system ping binary
+func pingICMP(ip net.IP, timeout time.Duration, iface string) (time.Duration, error) {
+	return 0, errICMPUnavailable
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listenAddresses splits the comma-separated listen_address setting
+func listenAddresses(addresses string) []string {
+	var result []string
+	for _, address := range strings.Split(addresses, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			result = append(result, address)
+		}
+	}
+	return result
+}
+
+// unixSocketPath returns the socket path of a "unix:/path" listen address
+func unixSocketPath(address string) (string, bool) {
+	if !strings.HasPrefix(address, UnixListenPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(address, UnixListenPrefix), true
+}
+
+// validateListenAddress checks one listen_address entry: "addr:port", ":port" or "unix:/path"
+func validateListenAddress(address string) error {
+	if path, ok := unixSocketPath(address); ok {
+		if path == "" {
+			return fmt.Errorf("invalid listen_address '%s': expected unix:/path/to/socket", address)
+		}
+		return nil
+	}
+
+	// Parse and validate the address format
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid listen_address format '%s': %v (expected format: 'addr:port', ':port' or 'unix:/path')", address, err)
+	}
+
+	// Validate port
+	portNum, err := strconv.Atoi(port)
+	if err != nil || portNum < 1 || portNum > 65535 {
+		return fmt.Errorf("invalid port in listen_address '%s': must be 1-65535", address)
+	}
+
+	// Validate host if provided (empty is valid, means all interfaces)
+	if host != "" {
+		// Try to parse as IP
+		if ip := net.ParseIP(host); ip == nil {
+			// If not a valid IP, check if it's a valid hostname/interface
+			// We allow it to pass through - net.Listen will catch invalid addresses
+			Warning("listen_address host '%s' is not a valid IP address. Will attempt to bind anyway.", host)
+		}
+	}
+	return nil
+}
+
+// openListeners opens the HTTP listeners: the sockets passed by systemd socket activation
+// if there are any, otherwise every listen_address entry
+func (s *Server) openListeners() ([]net.Listener, error) {
+	activated, err := systemdListeners()
+	if err != nil {
+		return nil, err
+	}
+	if len(activated) > 0 {
+		Info("Using %d socket(s) passed by systemd (listen_address is ignored)", len(activated))
+		return activated, nil
+	}
+
+	var listeners []net.Listener
+	for _, address := range listenAddresses(s.Config.ListenAddress) {
+		var listener net.Listener
+		if path, ok := unixSocketPath(address); ok {
+			listener, err = listenUnix(path, s.Config.ListenSocketMode, s.Config.ListenSocketGroup)
+		} else {
+			listener, err = net.Listen("tcp", address)
+		}
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// listenUnix creates a unix socket with the configured permissions. A stale socket left
+// by a crashed process is replaced; one still accepting connections is an error.
+func listenUnix(path, mode, group string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is already in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// Removed again when the listener is closed
+	if err := setSocketPermissions(path, mode, group); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set permissions of %s: %w", path, err)
+	}
+	return listener, nil
+}
+
+// setSocketPermissions applies listen_socket_mode (octal) and listen_socket_group
+func setSocketPermissions(path, mode, group string) error {
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return fmt.Errorf("invalid listen_socket_mode '%s'", mode)
+	}
+	if err := os.Chmod(path, os.FileMode(perm)); err != nil {
+		return err
+	}
+	if group == "" {
+		return nil
+	}
+
+	gid, err := strconv.Atoi(group)
+	if err != nil {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			return err
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return err
+		}
+	}
+	return os.Chown(path, -1, gid)
+}
+
+// systemdListeners returns the listening sockets passed by systemd socket activation
+// (LISTEN_FDS, starting at fd 3), or nil when the process was not socket-activated. The
+// variables are unset so child processes don't inherit them.
+func systemdListeners() ([]net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	listeners := make([]net.Listener, 0, count)
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("LISTEN_FD_%d", SDListenFDsStart+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		file := os.NewFile(uintptr(SDListenFDsStart+i), name)
+		listener, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("socket %s passed by systemd: %w (only stream sockets are supported)", name, err)
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// listenerURL describes where a listener can be reached, for the startup log
+func listenerURL(listener net.Listener, scheme, prefix string) string {
+	if listener.Addr().Network() == "unix" {
+		return UnixListenPrefix + listener.Addr().String()
+	}
+	return scheme + "://" + listener.Addr().String() + prefix
+}
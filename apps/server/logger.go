@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -36,6 +40,22 @@ func (l LogLevel) String() string {
 	}
 }
 
+// ParseLogLevel converts a configured level name ("debug", "info", "warning", "error")
+func ParseLogLevel(name string) (LogLevel, bool) {
+	switch name {
+	case "debug":
+		return LogLevelDebug, true
+	case "info":
+		return LogLevelInfo, true
+	case "warning":
+		return LogLevelWarning, true
+	case "error":
+		return LogLevelError, true
+	default:
+		return LogLevelInfo, false
+	}
+}
+
 // Logger provides structured logging with rotation and cleanup
 type Logger struct {
 	mu              sync.RWMutex
@@ -47,43 +67,59 @@ type Logger struct {
 	maxAge          int   // Maximum number of days to retain old log files (0 = keep all)
 	currentFileSize int64
 	rotationEnabled bool
+	format          string              // LogFormatText or LogFormatJSON
+	componentLevels map[string]LogLevel // Levels overriding level for a component
+	stdout          bool                // Lines are also written to stdout
+	sinks           []logSink           // syslog/journald
 }
 
 // LoggerConfig holds configuration for the logger
 type LoggerConfig struct {
 	Level           LogLevel // Minimum log level to output
-	OutputMode      string   // "stdout", "file", or "both"
+	OutputMode      string   // "stdout", "file", "both", "syslog", "journald" or a comma-separated combination
 	LogDir          string   // Directory for log files (used when OutputMode is "file" or "both")
 	MaxFileSizeMB   int      // Maximum log file size in MB before rotation (0 = no limit)
 	MaxAgeDays      int      // Maximum number of days to retain old log files (0 = keep all)
 	RotationEnabled bool     // Enable log rotation
+	Format          string   // "text" (default) or "json" (one object per line)
+
+	SyslogAddress  string // "udp://host:514", "tcp://host:601" or "unix:///dev/log" (syslog output)
+	SyslogFacility string // Syslog facility name, e.g. "daemon" or "local0" (syslog output)
+	JournaldSocket string // Journal socket path (journald output, default: /run/systemd/journal/socket)
+
+	// ComponentLevels overrides Level for messages of a component (LogComponentNetwork, ...)
+	ComponentLevels map[string]LogLevel
 }
 
-var (
-	globalLogger     *Logger
-	globalLoggerOnce sync.Once
-)
+var globalLogger atomic.Pointer[Logger]
 
-// InitLogger initializes the global logger with the given configuration
+// InitLogger initializes the global logger with the given configuration, replacing
+// the fallback logger used by messages logged before the configuration was loaded
 func InitLogger(config LoggerConfig) error {
-	var err error
-	globalLoggerOnce.Do(func() {
-		globalLogger, err = NewLogger(config)
-	})
-	return err
+	logger, err := NewLogger(config)
+	if err != nil {
+		return err
+	}
+	if previous := globalLogger.Swap(logger); previous != nil {
+		previous.Close()
+	}
+	return nil
 }
 
 // GetLogger returns the global logger instance
 func GetLogger() *Logger {
-	if globalLogger == nil {
-		// Fallback to a basic logger if not initialized
-		config := LoggerConfig{
-			Level:      LogLevelInfo,
-			OutputMode: "stdout",
-		}
-		_ = InitLogger(config)
+	if logger := globalLogger.Load(); logger != nil {
+		return logger
+	}
+	// Fallback to a basic logger if not initialized
+	logger, _ := NewLogger(LoggerConfig{
+		Level:      LogLevelInfo,
+		OutputMode: "stdout",
+	})
+	if !globalLogger.CompareAndSwap(nil, logger) {
+		return globalLogger.Load()
 	}
-	return globalLogger
+	return logger
 }
 
 // NewLogger creates a new logger instance
@@ -94,55 +130,90 @@ func NewLogger(config LoggerConfig) (*Logger, error) {
 		maxFileSize:     int64(config.MaxFileSizeMB) * 1024 * 1024,
 		maxAge:          config.MaxAgeDays,
 		rotationEnabled: config.RotationEnabled,
+		format:          config.Format,
+		componentLevels: config.ComponentLevels,
 	}
 
 	var writers []io.Writer
 
-	// Configure output based on OutputMode
-	switch config.OutputMode {
-	case "file":
-		if config.LogDir == "" {
-			return nil, fmt.Errorf("log_dir must be specified when output_mode is 'file'")
-		}
-		if err := os.MkdirAll(config.LogDir, 0755); err != nil {
-			return nil, fmt.Errorf("failed to create log directory: %w", err)
-		}
-		logFile, err := logger.openLogFile()
-		if err != nil {
-			return nil, fmt.Errorf("failed to open log file: %w", err)
-		}
-		logger.logFile = logFile
-		writers = append(writers, logFile)
+	// Configure outputs based on OutputMode
+	for _, mode := range logOutputModes(config.OutputMode) {
+		switch mode {
+		case "file":
+			if config.LogDir == "" {
+				return nil, fmt.Errorf("log_dir must be specified when output_mode is '%s'", config.OutputMode)
+			}
+			if err := os.MkdirAll(config.LogDir, 0755); err != nil {
+				return nil, fmt.Errorf("failed to create log directory: %w", err)
+			}
+			logFile, err := logger.openLogFile()
+			if err != nil {
+				return nil, fmt.Errorf("failed to open log file: %w", err)
+			}
+			logger.logFile = logFile
+			writers = append(writers, logFile)
+
+		case "syslog":
+			sink, err := newSyslogSink(config.SyslogAddress, config.SyslogFacility)
+			if err != nil {
+				logger.Close()
+				return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+			}
+			logger.sinks = append(logger.sinks, newAsyncSink(sink))
 
-	case "both":
-		if config.LogDir == "" {
-			return nil, fmt.Errorf("log_dir must be specified when output_mode is 'both'")
-		}
-		if err := os.MkdirAll(config.LogDir, 0755); err != nil {
-			return nil, fmt.Errorf("failed to create log directory: %w", err)
-		}
-		logFile, err := logger.openLogFile()
-		if err != nil {
-			return nil, fmt.Errorf("failed to open log file: %w", err)
+		case "journald":
+			sink, err := newJournaldSink(config.JournaldSocket)
+			if err != nil {
+				logger.Close()
+				return nil, fmt.Errorf("failed to connect to journald: %w", err)
+			}
+			logger.sinks = append(logger.sinks, newAsyncSink(sink))
+
+		default:
+			logger.stdout = true
+			writers = append(writers, os.Stdout)
 		}
-		logger.logFile = logFile
-		writers = append(writers, logFile, os.Stdout)
+	}
 
-	case "stdout":
-		fallthrough
-	default:
-		writers = append(writers, os.Stdout)
+	if len(writers) == 0 {
+		// Only syslog/journald - no text lines
+		return logger.startCleanup(), nil
 	}
 
 	multiWriter := io.MultiWriter(writers...)
-	logger.logger = log.New(multiWriter, "", log.LstdFlags)
+	flags := log.LstdFlags
+	if logger.format == LogFormatJSON {
+		flags = 0 // The JSON entry carries the time
+	}
+	logger.logger = log.New(multiWriter, "", flags)
+
+	return logger.startCleanup(), nil
+}
 
-	// Start cleanup goroutine if rotation is enabled and maxAge is set
-	if logger.rotationEnabled && logger.maxAge > 0 && config.OutputMode != "stdout" {
-		go logger.cleanupRoutine()
+// logOutputModes splits a log_output_mode value into its outputs ("both" is stdout and file)
+func logOutputModes(outputMode string) []string {
+	var modes []string
+	for _, mode := range strings.Split(outputMode, ",") {
+		switch mode = strings.TrimSpace(mode); mode {
+		case "both":
+			modes = append(modes, "stdout", "file")
+		case "":
+		default:
+			modes = append(modes, mode)
+		}
 	}
+	if len(modes) == 0 {
+		modes = append(modes, "stdout")
+	}
+	return modes
+}
 
-	return logger, nil
+// startCleanup starts the cleanup goroutine if rotation is enabled and maxAge is set
+func (l *Logger) startCleanup() *Logger {
+	if l.rotationEnabled && l.maxAge > 0 && l.logFile != nil {
+		go l.cleanupRoutine()
+	}
+	return l
 }
 
 // openLogFile opens a new log file with the current timestamp
@@ -198,12 +269,9 @@ func (l *Logger) rotate() error {
 
 	// Update logger to write to new file
 	writers := []io.Writer{newFile}
-	if l.logger.Writer() != newFile {
+	if l.stdout {
 		// If we were writing to both stdout and file, maintain that
-		_, ok := l.logger.Writer().(io.Writer)
-		if ok {
-			writers = append(writers, os.Stdout)
-		}
+		writers = append(writers, os.Stdout)
 	}
 	l.logger.SetOutput(io.MultiWriter(writers...))
 
@@ -297,48 +365,152 @@ func isLogFile(filename string) bool {
 	return ext == ".log" || ext == "" && len(filename) > 4 && filename[len(filename)-4:] == ".log"
 }
 
+// logEntry is a log message with its request fields, written as one line in the JSON
+// format and passed to the syslog/journald sinks
+type logEntry struct {
+	Time      string `json:"time"`
+	Level     string `json:"level"`
+	Component string `json:"component,omitempty"`
+	Msg       string `json:"msg"`
+	RequestID string `json:"request_id,omitempty"`
+	UserID    string `json:"user_id,omitempty"`
+	HostID    string `json:"host_id,omitempty"`
+	RemoteIP  string `json:"remote_ip,omitempty"`
+	TraceID   string `json:"trace_id,omitempty"`
+
+	at       time.Time
+	severity LogLevel
+}
+
+// SetLevels changes the minimum level and the per-component levels while the logger is in use
+func (l *Logger) SetLevels(level LogLevel, componentLevels map[string]LogLevel) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = level
+	l.componentLevels = componentLevels
+}
+
+// logSink receives every written log entry (syslog, journald)
+type logSink interface {
+	writeEntry(entry *logEntry) error
+	Close() error
+}
+
+// enabled reports whether messages of a component at level are written
+func (l *Logger) enabled(component string, level LogLevel) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if componentLevel, ok := l.componentLevels[component]; ok && component != "" {
+		return level >= componentLevel
+	}
+	return level >= l.level
+}
+
+// newLogEntry collects a message with the request fields and trace ID of ctx
+func newLogEntry(ctx context.Context, component string, level LogLevel, msg string) *logEntry {
+	now := time.Now()
+	entry := &logEntry{
+		Time:      now.Format(time.RFC3339Nano),
+		Level:     strings.ToLower(level.String()),
+		Component: component,
+		Msg:       msg,
+		TraceID:   traceID(ctx),
+		at:        now,
+		severity:  level,
+	}
+	if fields := logFieldsFromContext(ctx); fields != nil {
+		entry.RequestID = fields.RequestID
+		entry.UserID = fields.userID()
+		entry.HostID = fields.HostID
+		entry.RemoteIP = fields.RemoteIP
+	}
+	return entry
+}
+
+// formatMessage renders an entry as a stdout/file line in the configured format
+func (l *Logger) formatMessage(entry *logEntry) string {
+	if l.format == LogFormatJSON {
+		if line, err := json.Marshal(entry); err == nil {
+			return string(line)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] ", entry.severity)
+	if entry.Component != "" {
+		fmt.Fprintf(&b, "[%s] ", entry.Component)
+	}
+	if tags := entry.tags(); len(tags) > 0 {
+		fmt.Fprintf(&b, "[%s] ", strings.Join(tags, " "))
+	}
+	b.WriteString(entry.Msg)
+	return b.String()
+}
+
+// tags returns the request fields of a text log line as name=value pairs
+func (e *logEntry) tags() []string {
+	var tags []string
+	for _, tag := range [][2]string{
+		{"request_id", e.RequestID},
+		{"user_id", e.UserID},
+		{"host_id", e.HostID},
+		{"trace_id", e.TraceID},
+	} {
+		if tag[1] != "" {
+			tags = append(tags, tag[0]+"="+tag[1])
+		}
+	}
+	return tags
+}
+
 // log writes a log message at the specified level
-func (l *Logger) log(level LogLevel, format string, v ...interface{}) {
-	if level < l.level {
+func (l *Logger) log(ctx context.Context, component string, level LogLevel, format string, v ...interface{}) {
+	if !l.enabled(component, level) {
 		return // Skip messages below the configured level
 	}
 
-	message := fmt.Sprintf("[%s] %s", level, fmt.Sprintf(format, v...))
+	entry := newLogEntry(ctx, component, level, fmt.Sprintf(format, v...))
+	message := l.formatMessage(entry)
 
 	// Check if rotation is needed before writing
 	l.checkRotation(len(message))
 
 	l.mu.Lock()
-	l.logger.Print(message)
+	if l.logger != nil {
+		l.logger.Print(message)
+	}
 	if l.logFile != nil {
 		l.currentFileSize += int64(len(message) + 1) // +1 for newline
 	}
+	for _, sink := range l.sinks {
+		sink.writeEntry(entry) // Queued, see asyncSink
+	}
 	l.mu.Unlock()
 }
 
 // Debug logs a debug message
 func (l *Logger) Debug(format string, v ...interface{}) {
-	l.log(LogLevelDebug, format, v...)
+	l.log(context.Background(), "", LogLevelDebug, format, v...)
 }
 
 // Info logs an info message
 func (l *Logger) Info(format string, v ...interface{}) {
-	l.log(LogLevelInfo, format, v...)
+	l.log(context.Background(), "", LogLevelInfo, format, v...)
 }
 
 // Warning logs a warning message
 func (l *Logger) Warning(format string, v ...interface{}) {
-	l.log(LogLevelWarning, format, v...)
+	l.log(context.Background(), "", LogLevelWarning, format, v...)
 }
 
 // Error logs an error message
 func (l *Logger) Error(format string, v ...interface{}) {
-	l.log(LogLevelError, format, v...)
+	l.log(context.Background(), "", LogLevelError, format, v...)
 }
 
 // Fatal logs a fatal error message and exits the program
 func (l *Logger) Fatal(format string, v ...interface{}) {
-	l.log(LogLevelError, format, v...)
+	l.log(context.Background(), "", LogLevelError, format, v...)
 	os.Exit(1)
 }
 
@@ -347,6 +519,10 @@ func (l *Logger) Close() error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	for _, sink := range l.sinks {
+		sink.Close()
+	}
+	l.sinks = nil
 	if l.logFile != nil {
 		return l.logFile.Close()
 	}
@@ -379,3 +555,126 @@ func Error(format string, v ...interface{}) {
 func Fatal(format string, v ...interface{}) {
 	GetLogger().Fatal(format, v...)
 }
+
+// Context variants add the request fields (request ID, user, host, client IP) and the trace ID
+// of ctx to the message. Without them they log like the plain functions.
+
+// DebugContext logs a debug message with the request fields of ctx
+func DebugContext(ctx context.Context, format string, v ...interface{}) {
+	GetLogger().log(ctx, "", LogLevelDebug, format, v...)
+}
+
+// InfoContext logs an info message with the request fields of ctx
+func InfoContext(ctx context.Context, format string, v ...interface{}) {
+	GetLogger().log(ctx, "", LogLevelInfo, format, v...)
+}
+
+// WarningContext logs a warning message with the request fields of ctx
+func WarningContext(ctx context.Context, format string, v ...interface{}) {
+	GetLogger().log(ctx, "", LogLevelWarning, format, v...)
+}
+
+// ErrorContext logs an error message with the request fields of ctx
+func ErrorContext(ctx context.Context, format string, v ...interface{}) {
+	GetLogger().log(ctx, "", LogLevelError, format, v...)
+}
+
+// componentLogger logs messages of a component, filtered by its level in log_levels
+type componentLogger string
+
+var (
+	netLog  = componentLogger(LogComponentNetwork)
+	authLog = componentLogger(LogComponentAuth)
+	httpLog = componentLogger(LogComponentHTTP)
+)
+
+// Debug logs a debug message of the component
+func (c componentLogger) Debug(format string, v ...interface{}) {
+	GetLogger().log(context.Background(), string(c), LogLevelDebug, format, v...)
+}
+
+// Info logs an info message of the component
+func (c componentLogger) Info(format string, v ...interface{}) {
+	GetLogger().log(context.Background(), string(c), LogLevelInfo, format, v...)
+}
+
+// Warning logs a warning message of the component
+func (c componentLogger) Warning(format string, v ...interface{}) {
+	GetLogger().log(context.Background(), string(c), LogLevelWarning, format, v...)
+}
+
+// Error logs an error message of the component
+func (c componentLogger) Error(format string, v ...interface{}) {
+	GetLogger().log(context.Background(), string(c), LogLevelError, format, v...)
+}
+
+// DebugContext logs a debug message of the component with the request fields of ctx
+func (c componentLogger) DebugContext(ctx context.Context, format string, v ...interface{}) {
+	GetLogger().log(ctx, string(c), LogLevelDebug, format, v...)
+}
+
+// InfoContext logs an info message of the component with the request fields of ctx
+func (c componentLogger) InfoContext(ctx context.Context, format string, v ...interface{}) {
+	GetLogger().log(ctx, string(c), LogLevelInfo, format, v...)
+}
+
+// WarningContext logs a warning message of the component with the request fields of ctx
+func (c componentLogger) WarningContext(ctx context.Context, format string, v ...interface{}) {
+	GetLogger().log(ctx, string(c), LogLevelWarning, format, v...)
+}
+
+// ErrorContext logs an error message of the component with the request fields of ctx
+func (c componentLogger) ErrorContext(ctx context.Context, format string, v ...interface{}) {
+	GetLogger().log(ctx, string(c), LogLevelError, format, v...)
+}
+
+// logFields are the request attributes added to log lines written with a request context
+type logFields struct {
+	RequestID string
+	RemoteIP  string
+	HostID    string
+
+	user atomic.Pointer[string] // Set by AuthMiddleware once the session is validated
+}
+
+type logFieldsKey struct{}
+
+// withLogFields returns a context whose log lines carry fields
+func withLogFields(ctx context.Context, fields *logFields) context.Context {
+	return context.WithValue(ctx, logFieldsKey{}, fields)
+}
+
+// logFieldsFromContext returns the log fields of ctx, or nil
+func logFieldsFromContext(ctx context.Context) *logFields {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(logFieldsKey{}).(*logFields)
+	return fields
+}
+
+// userID returns the authenticated user of the request
+func (f *logFields) userID() string {
+	if id := f.user.Load(); id != nil {
+		return *id
+	}
+	return ""
+}
+
+// setLogUserID records the authenticated user for the log lines of the request in ctx
+func setLogUserID(ctx context.Context, userID string) {
+	if fields := logFieldsFromContext(ctx); fields != nil {
+		fields.user.Store(&userID)
+	}
+}
+
+// withLogHostID returns a context whose log lines also carry the host ID
+func withLogHostID(ctx context.Context, hostID string) context.Context {
+	fields := &logFields{HostID: hostID}
+	if parent := logFieldsFromContext(ctx); parent != nil {
+		fields.RequestID = parent.RequestID
+		fields.RemoteIP = parent.RemoteIP
+		fields.user.Store(parent.user.Load())
+	}
+	return withLogFields(ctx, fields)
+}
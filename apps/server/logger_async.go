@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// asyncSink delivers entries to a sink from its own goroutine, so a slow or unreachable
+// syslog collector never blocks the code that logs. Entries are dropped while the queue
+// is full or the sink fails; drops are reported on stderr.
+type asyncSink struct {
+	sink    logSink
+	entries chan *logEntry
+	done    chan struct{}
+	dropped atomic.Int64 // Entries dropped because the queue was full
+}
+
+// newAsyncSink starts delivering to sink
+func newAsyncSink(sink logSink) *asyncSink {
+	a := &asyncSink{
+		sink:    sink,
+		entries: make(chan *logEntry, LogSinkQueueSize),
+		done:    make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// writeEntry queues an entry without waiting for the sink
+func (a *asyncSink) writeEntry(entry *logEntry) error {
+	select {
+	case a.entries <- entry:
+	default:
+		a.dropped.Add(1)
+	}
+	return nil
+}
+
+// run writes queued entries until the queue is closed, then closes the sink
+func (a *asyncSink) run() {
+	defer close(a.done)
+	defer a.sink.Close()
+
+	var failing bool
+	var failed int64
+	var lastReport time.Time
+	for entry := range a.entries {
+		if err := a.sink.writeEntry(entry); err != nil {
+			failed++
+			if !failing {
+				fmt.Fprintf(os.Stderr, "ERROR: Log sink write failed: %v (dropping entries until it recovers)\n", err)
+				failing = true
+			}
+			continue
+		}
+		if failing {
+			fmt.Fprintf(os.Stderr, "Log sink recovered, %d entries dropped\n", failed+a.dropped.Swap(0))
+			failing, failed, lastReport = false, 0, time.Now()
+		}
+		if time.Since(lastReport) >= LogSinkDropReportInterval {
+			if dropped := a.dropped.Swap(0); dropped > 0 {
+				fmt.Fprintf(os.Stderr, "WARNING: Log sink queue full, %d entries dropped\n", dropped)
+				lastReport = time.Now()
+			}
+		}
+	}
+}
+
+// Close stops accepting entries and waits a short time for queued entries to be delivered
+func (a *asyncSink) Close() error {
+	close(a.entries)
+	select {
+	case <-a.done:
+	case <-time.After(LogSinkCloseTimeout):
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingSink blocks every write until release is closed, like a collector that stopped
+// reading
+type blockingSink struct {
+	release chan struct{}
+	mutex   sync.Mutex
+	written []string
+	closed  bool
+}
+
+func (b *blockingSink) writeEntry(entry *logEntry) error {
+	<-b.release
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.written = append(b.written, entry.Msg)
+	return nil
+}
+
+func (b *blockingSink) Close() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.closed = true
+	return nil
+}
+
+func TestAsyncSinkDoesNotBlock(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{})}
+	async := newAsyncSink(sink)
+
+	// More entries than the queue holds: the extra ones are dropped instead of blocking
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < LogSinkQueueSize+100; i++ {
+			async.writeEntry(testLogEntry("entry"))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("writeEntry blocked on a stuck sink")
+	}
+	// One entry may already be held by the blocked write
+	if dropped := async.dropped.Load(); dropped < 99 {
+		t.Errorf("dropped = %d, want at least 99", dropped)
+	}
+
+	// Queued entries are delivered once the sink recovers, and Close closes the sink
+	close(sink.release)
+	async.Close()
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if len(sink.written) < LogSinkQueueSize {
+		t.Errorf("delivered %d entries, want at least %d", len(sink.written), LogSinkQueueSize)
+	}
+	if !sink.closed {
+		t.Error("sink was not closed")
+	}
+}
+
+// failingSink fails every write
+type failingSink struct{ writes int }
+
+func (f *failingSink) writeEntry(entry *logEntry) error {
+	f.writes++
+	return errors.New("collector unreachable")
+}
+
+func (f *failingSink) Close() error { return nil }
+
+func TestAsyncSinkFailingSink(t *testing.T) {
+	sink := &failingSink{}
+	async := newAsyncSink(sink)
+	for i := 0; i < 10; i++ {
+		async.writeEntry(testLogEntry("entry"))
+	}
+	async.Close()
+	if sink.writes != 10 {
+		t.Errorf("writes = %d, want 10", sink.writes)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestJournaldMessage(t *testing.T) {
+	message := journaldMessage(LogLevelWarning, "disk almost full", map[string]string{
+		"COMPONENT":  "network",
+		"REQUEST_ID": "3c667b0288e7472",
+		"HOST_ID":    "",
+	})
+
+	want := "MESSAGE=disk almost full\n" +
+		"PRIORITY=4\n" +
+		"SYSLOG_IDENTIFIER=" + LogAppName + "\n" +
+		"COMPONENT=network\n" +
+		"REQUEST_ID=3c667b0288e7472\n"
+	if string(message) != want {
+		t.Errorf("message = %q, want %q", message, want)
+	}
+}
+
+func TestJournaldMessageMultiline(t *testing.T) {
+	value := "panic: boom\ngoroutine 1 [running]:\n"
+	message := journaldMessage(LogLevelError, value, nil)
+
+	// Binary form: NAME\n, little-endian uint64 length, the raw value, \n
+	var want bytes.Buffer
+	want.WriteString("MESSAGE\n")
+	binary.Write(&want, binary.LittleEndian, uint64(len(value)))
+	want.WriteString(value + "\n")
+	want.WriteString("PRIORITY=3\n")
+	want.WriteString("SYSLOG_IDENTIFIER=" + LogAppName + "\n")
+
+	if !bytes.Equal(message, want.Bytes()) {
+		t.Errorf("message = %q, want %q", message, want.Bytes())
+	}
+}
+
+func TestJournaldSink(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("journald output is only available on Linux")
+	}
+
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sink, err := newJournaldSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	if err := sink.writeEntry(testLogEntry("GET /api/hosts 200")); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "MESSAGE=GET /api/hosts 200\n" +
+		"PRIORITY=6\n" +
+		"SYSLOG_IDENTIFIER=" + LogAppName + "\n" +
+		"COMPONENT=http\n" +
+		"REQUEST_ID=3c667b0288e7472\n" +
+		"HOST_ID=h\"1]\n" +
+		"REMOTE_IP=192.0.2.10\n"
+	if string(buf[:n]) != want {
+		t.Errorf("datagram = %q, want %q", buf[:n], want)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// syslogFacilities maps facility names to their RFC 5424 codes
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogSeverity maps log levels to RFC 5424 severities
+func syslogSeverity(level LogLevel) int {
+	switch level {
+	case LogLevelDebug:
+		return 7
+	case LogLevelInfo:
+		return 6
+	case LogLevelWarning:
+		return 4
+	default:
+		return 3
+	}
+}
+
+// syslogSink sends log entries as RFC 5424 messages over UDP, TCP (octet-counting framing,
+// RFC 6587) or a unix socket. Request fields are sent as structured data.
+type syslogSink struct {
+	network  string // "udp", "tcp", "unixgram" or "unix"
+	address  string
+	facility int
+	hostname string
+	conn     net.Conn
+	backoff  time.Duration // Wait after the last failed connect
+	retryAt  time.Time     // No reconnect attempts before this time
+}
+
+// parseSyslogAddress splits "udp://host:514", "tcp://host:601" or "unix:///dev/log"
+// into a network and address
+func parseSyslogAddress(address string) (network, addr string, err error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid syslog address '%s': %w", address, err)
+	}
+	switch u.Scheme {
+	case "udp", "tcp":
+		if _, _, err := net.SplitHostPort(u.Host); err != nil {
+			return "", "", fmt.Errorf("invalid syslog address '%s': expected %s://host:port", address, u.Scheme)
+		}
+		return u.Scheme, u.Host, nil
+	case "unix":
+		if u.Path == "" {
+			return "", "", fmt.Errorf("invalid syslog address '%s': expected unix:///path/to/socket", address)
+		}
+		return "unix", u.Path, nil
+	default:
+		return "", "", fmt.Errorf("invalid syslog address '%s': scheme must be udp, tcp or unix", address)
+	}
+}
+
+// newSyslogSink connects to the syslog daemon or collector at address
+func newSyslogSink(address, facility string) (*syslogSink, error) {
+	if address == "" {
+		address = DefaultSyslogAddress
+	}
+	if facility == "" {
+		facility = DefaultSyslogFacility
+	}
+	code, ok := syslogFacilities[facility]
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility '%s'", facility)
+	}
+	network, addr, err := parseSyslogAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	s := &syslogSink{network: network, address: addr, facility: code, hostname: hostname}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// reconnect reopens a lost connection, waiting longer after each failed attempt so an
+// unreachable collector is not dialed for every entry
+func (s *syslogSink) reconnect() error {
+	if time.Now().Before(s.retryAt) {
+		return fmt.Errorf("not connected, retrying in %s", time.Until(s.retryAt).Round(time.Second))
+	}
+	if err := s.connect(); err != nil {
+		s.backoff = min(max(2*s.backoff, SyslogReconnectMinBackoff), SyslogReconnectMaxBackoff)
+		s.retryAt = time.Now().Add(s.backoff)
+		return err
+	}
+	s.backoff = 0
+	return nil
+}
+
+// connect (re)opens the connection. Local syslog sockets (/dev/log) are usually datagram
+// sockets, so unix addresses try unixgram first.
+func (s *syslogSink) connect() error {
+	if s.network == "unix" || s.network == "unixgram" {
+		conn, err := net.DialTimeout("unixgram", s.address, SyslogDialTimeout)
+		if err == nil {
+			s.network, s.conn = "unixgram", conn
+			return nil
+		}
+		s.network = "unix"
+	}
+	conn, err := net.DialTimeout(s.network, s.address, SyslogDialTimeout)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+// writeEntry sends an entry, reconnecting once if the connection was lost. It is only
+// called from the sink's asyncSink goroutine.
+func (s *syslogSink) writeEntry(entry *logEntry) error {
+	message := s.format(entry)
+	if s.network == "tcp" || s.network == "unix" {
+		// Stream sockets need framing
+		message = fmt.Sprintf("%d %s", len(message), message)
+	}
+
+	if s.conn != nil {
+		s.conn.SetWriteDeadline(time.Now().Add(SyslogWriteTimeout))
+		if _, err := s.conn.Write([]byte(message)); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	if err := s.reconnect(); err != nil {
+		return fmt.Errorf("syslog %s: %w", s.address, err)
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(SyslogWriteTimeout))
+	_, err := s.conn.Write([]byte(message))
+	return err
+}
+
+// format renders an entry as an RFC 5424 message:
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+// The component is the MSGID; request fields go in a "wol@32473" SD element.
+func (s *syslogSink) format(entry *logEntry) string {
+	msgID := entry.Component
+	if msgID == "" {
+		msgID = "-"
+	}
+
+	structuredData := "-"
+	var params []string
+	for _, param := range [][2]string{
+		{"request_id", entry.RequestID},
+		{"user_id", entry.UserID},
+		{"host_id", entry.HostID},
+		{"remote_ip", entry.RemoteIP},
+		{"trace_id", entry.TraceID},
+	} {
+		if param[1] != "" {
+			params = append(params, fmt.Sprintf(`%s="%s"`, param[0], syslogParamEscaper.Replace(param[1])))
+		}
+	}
+	if len(params) > 0 {
+		structuredData = "[" + SyslogStructuredDataID + " " + strings.Join(params, " ") + "]"
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s",
+		s.facility*8+syslogSeverity(entry.severity),
+		entry.at.Format("2006-01-02T15:04:05.000000Z07:00"),
+		s.hostname, LogAppName, os.Getpid(), msgID, structuredData, entry.Msg)
+}
+
+// syslogParamEscaper escapes SD-PARAM values (RFC 5424 section 6.3.3)
+var syslogParamEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// Close closes the connection
+func (s *syslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testLogEntry returns an info entry of the http component with request fields set
+func testLogEntry(msg string) *logEntry {
+	return &logEntry{
+		Component: "http",
+		Msg:       msg,
+		RequestID: "3c667b0288e7472",
+		HostID:    `h"1]`,
+		RemoteIP:  "192.0.2.10",
+		at:        time.Date(2026, 10, 18, 12, 30, 45, 123456000, time.UTC),
+		severity:  LogLevelInfo,
+	}
+}
+
+// rfc5424Pattern matches <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG
+var rfc5424Pattern = regexp.MustCompile(`^<(\d+)>1 (\S+) (\S+) (\S+) (\d+) (\S+) (-|\[.*\]) (.*)$`)
+
+// checkSyslogMessage verifies the header fields and structured data of a message
+// produced from testLogEntry
+func checkSyslogMessage(t *testing.T, message, msg string) {
+	t.Helper()
+
+	parts := rfc5424Pattern.FindStringSubmatch(message)
+	if parts == nil {
+		t.Fatalf("not an RFC 5424 message: %q", message)
+	}
+	if parts[1] != "30" { // daemon (3) * 8 + info (6)
+		t.Errorf("PRI = %s, want 30", parts[1])
+	}
+	if parts[2] != "2026-10-18T12:30:45.123456Z" {
+		t.Errorf("TIMESTAMP = %s", parts[2])
+	}
+	if parts[4] != LogAppName {
+		t.Errorf("APP-NAME = %s, want %s", parts[4], LogAppName)
+	}
+	if parts[5] != strconv.Itoa(os.Getpid()) {
+		t.Errorf("PROCID = %s, want %d", parts[5], os.Getpid())
+	}
+	if parts[6] != "http" {
+		t.Errorf("MSGID = %s, want http", parts[6])
+	}
+	wantSD := `[` + SyslogStructuredDataID + ` request_id="3c667b0288e7472" host_id="h\"1\]" remote_ip="192.0.2.10"]`
+	if parts[7] != wantSD {
+		t.Errorf("STRUCTURED-DATA = %s, want %s", parts[7], wantSD)
+	}
+	if parts[8] != msg {
+		t.Errorf("MSG = %q, want %q", parts[8], msg)
+	}
+}
+
+func TestSyslogSinkUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sink, err := newSyslogSink("udp://"+conn.LocalAddr().String(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	if err := sink.writeEntry(testLogEntry("GET /api/hosts 200")); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Datagrams are not framed
+	checkSyslogMessage(t, string(buf[:n]), "GET /api/hosts 200")
+}
+
+func TestSyslogSinkTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	sink, err := newSyslogSink("tcp://"+listener.Addr().String(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	messages := []string{"first", "second with spaces"}
+	for _, msg := range messages {
+		if err := sink.writeEntry(testLogEntry(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Octet-counting framing (RFC 6587): MSG-LEN SP SYSLOG-MSG, no separator between frames
+	reader := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for _, msg := range messages {
+		length, err := reader.ReadString(' ')
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+		if err != nil {
+			t.Fatalf("invalid frame length %q", length)
+		}
+		frame := make([]byte, n)
+		if _, err := io.ReadFull(reader, frame); err != nil {
+			t.Fatal(err)
+		}
+		checkSyslogMessage(t, string(frame), msg)
+	}
+}
+
+func TestSyslogSinkUnixgram(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram sockets not available: %v", err)
+	}
+	defer conn.Close()
+
+	sink, err := newSyslogSink("unix://"+path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	if sink.network != "unixgram" {
+		t.Fatalf("network = %s, want unixgram", sink.network)
+	}
+
+	if err := sink.writeEntry(testLogEntry("via /dev/log")); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSyslogMessage(t, string(buf[:n]), "via /dev/log")
+}
+
+func TestSyslogSinkReconnectBackoff(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+
+	sink, err := newSyslogSink("tcp://"+address, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	// Collector goes away
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	listener.Close()
+
+	// Writes fail once the reset is noticed; the failed reconnect starts the backoff
+	deadline := time.Now().Add(5 * time.Second)
+	for sink.retryAt.IsZero() && time.Now().Before(deadline) {
+		sink.writeEntry(testLogEntry("lost"))
+	}
+	if sink.retryAt.IsZero() {
+		t.Fatal("reconnect backoff was not started")
+	}
+	if sink.backoff != SyslogReconnectMinBackoff {
+		t.Errorf("backoff = %s, want %s", sink.backoff, SyslogReconnectMinBackoff)
+	}
+
+	// Within the backoff no reconnect is attempted
+	err = sink.writeEntry(testLogEntry("dropped"))
+	if err == nil || !strings.Contains(err.Error(), "retrying in") {
+		t.Errorf("err = %v, want a backoff error", err)
+	}
+}
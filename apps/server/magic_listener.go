@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// magicPacketListener receives magic packets from devices that can only broadcast on
+// their own segment (NAS UIs, router apps) and re-sends them the way the server would.
+// All listening sockets share one instance, so a burst to ports 7 and 9 is re-sent once.
+type magicPacketListener struct {
+	server  *Server
+	limiter *RateLimiter // Per source address
+
+	mutex       sync.Mutex
+	lastForward map[string]time.Time // MAC -> last time the packet was re-sent
+}
+
+// startMagicPacketListeners opens a UDP listener for every configured address
+func (s *Server) startMagicPacketListeners() error {
+	if s.Config.MagicPacketListen == "" {
+		return nil
+	}
+
+	listener := &magicPacketListener{
+		server:      s,
+		limiter:     NewRateLimiter(MagicPacketRateLimitPerMinute, time.Minute),
+		lastForward: make(map[string]time.Time),
+	}
+
+	for _, address := range strings.Split(s.Config.MagicPacketListen, ",") {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+
+		udpAddr, err := net.ResolveUDPAddr("udp", address)
+		if err != nil {
+			return fmt.Errorf("magic packet listener on %s: %w", address, err)
+		}
+		conn, err := net.ListenUDP("udp", udpAddr)
+		if err != nil {
+			return fmt.Errorf("magic packet listener on %s: %w", address, err)
+		}
+		s.closeOnStop(conn)
+		go listener.serve(conn, address)
+
+		Info("Magic packet listener on udp %s (forwarding: %v)", address, s.Config.MagicPacketForward)
+	}
+	return nil
+}
+
+// serve reads packets from one socket until it is closed
+func (l *magicPacketListener) serve(conn *net.UDPConn, address string) {
+	buf := make([]byte, 1500)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if !l.server.isStopping() {
+				Warning("Magic packet listener on %s stopped: %v", address, err)
+			}
+			return
+		}
+
+		packet := make([]byte, n)
+		copy(packet, buf[:n])
+
+		// Forwarding through a relay blocks - don't hold up the read loop
+		go l.handlePacket(packet, src, address)
+	}
+}
+
+// handlePacket decodes a packet, records it as a wake event attributed to "network"
+// and re-sends it through each matching host's interface/broadcast or relay
+func (l *magicPacketListener) handlePacket(packet []byte, src *net.UDPAddr, address string) {
+	s := l.server
+
+	mac, err := parseMagicPacket(packet)
+	if err != nil {
+		Debug("Magic packet listener on %s: %v from %s", address, err, src)
+		return
+	}
+
+	// Packets sent by this server (wakes and re-sent packets) arrive here again when they
+	// share the segment
+	if !src.IP.IsLoopback() && isLocalIP(src.IP) && sentMagicPackets.recent(mac) {
+		Debug("Magic packet listener on %s: ignoring own packet for %s from %s", address, mac, src)
+		return
+	}
+
+	sourceIP := src.IP.String()
+	if !l.limiter.Allow(sourceIP) {
+		Debug("Magic packet listener on %s: rate limit exceeded for %s", address, sourceIP)
+		return
+	}
+
+	hosts, err := s.findHostsByMAC(mac)
+	if err != nil {
+		Error("Magic packet listener on %s: failed to look up MAC %s: %v", address, mac, err)
+		return
+	}
+
+	detail := "magic packet on udp " + address
+	if len(hosts) == 0 {
+		s.recordAudit(AuditEntry{
+			Actor:    AuditActorNetwork,
+			Action:   AuditActionWake,
+			Detail:   detail + ": unknown MAC " + mac,
+			RemoteIP: sourceIP,
+		})
+		return
+	}
+
+	ctx, span := startSpan(context.Background(), "magic packet",
+		attribute.String("host.mac", mac), attribute.String("client.address", sourceIP))
+	defer span.End()
+
+	// Devices usually send bursts (several copies, ports 7 and 9) - re-send once
+	forward := s.Config.MagicPacketForward && l.claimForward(mac)
+	sent := make(map[string]bool)
+
+	for _, host := range hosts {
+		entry := AuditEntry{
+			Actor:    AuditActorNetwork,
+			Action:   AuditActionWake,
+			HostID:   host.ID,
+			HostName: host.Name,
+			RemoteIP: sourceIP,
+			Detail:   detail,
+		}
+
+		// Hosts sharing a MAC and the same network path only need one packet
+		relay := s.hostRelay(ctx, host.ID)
+		path := relay + "|" + host.Broadcast + "|" + s.determineNetworkInterface(host)
+
+		switch {
+		case !s.Config.MagicPacketForward:
+			entry.Success = true
+			entry.Detail += ": logged"
+			s.WoLHistory.RecordWoL(host.ID)
+			s.PingCache.Invalidate(host.ID)
+		case !forward:
+			entry.Success = true
+			entry.Detail += ": repeated packet, not re-sent"
+		case sent[path]:
+			entry.Success = true
+			entry.Detail += ": already forwarded"
+			s.WoLHistory.RecordWoL(host.ID)
+			s.PingCache.Invalidate(host.ID)
+		default:
+			sent[path] = true
+			if err := s.wakeHost(ctx, host); err != nil {
+				entry.Detail += ": forward failed: " + err.Error()
+				Warning("Magic packet listener on %s: failed to forward wake of host '%s': %v", address, host.Name, err)
+			} else {
+				entry.Success = true
+				entry.Detail += ": forwarded"
+				if relay != "" {
+					entry.Detail += " via relay " + relay
+				}
+			}
+		}
+		s.recordAudit(entry)
+	}
+}
+
+// claimForward reports whether a packet for this MAC should be re-sent now
+func (l *magicPacketListener) claimForward(mac string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	if last, exists := l.lastForward[mac]; exists && now.Sub(last) < MagicPacketForwardInterval {
+		return false
+	}
+	l.lastForward[mac] = now
+
+	// Drop stale entries so the map stays small
+	for key, last := range l.lastForward {
+		if now.Sub(last) >= MagicPacketForwardInterval {
+			delete(l.lastForward, key)
+		}
+	}
+	return true
+}
+
+// sentMACs records the MAC addresses this process sent magic packets for
+type sentMACs struct {
+	mutex sync.Mutex
+	last  map[string]time.Time
+}
+
+// sentMagicPackets is filled by SendWakeOnLanWithInterface and read by the listeners
+var sentMagicPackets = &sentMACs{last: make(map[string]time.Time)}
+
+// record notes that a magic packet for mac is being sent
+func (m *sentMACs) record(mac string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	m.last[normalizeMACAddress(mac)] = now
+	for key, last := range m.last {
+		if now.Sub(last) >= MagicPacketForwardInterval {
+			delete(m.last, key)
+		}
+	}
+}
+
+// recent reports whether a packet for mac was sent within the forward interval
+func (m *sentMACs) recent(mac string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	last, exists := m.last[normalizeMACAddress(mac)]
+	return exists && time.Since(last) < MagicPacketForwardInterval
+}
+
+// findHostsByMAC returns all hosts (any owner) with the given MAC address
+func (s *Server) findHostsByMAC(mac string) ([]Host, error) {
+	rows, err := s.DB.Query("SELECT id, name, mac, broadcast, interface, static_ip, use_as_fallback, user_id FROM hosts ORDER BY created")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	target := normalizeMACAddress(mac)
+	var hosts []Host
+	for rows.Next() {
+		var host Host
+		if err := rows.Scan(&host.ID, &host.Name, &host.MAC, &host.Broadcast, &host.Interface, &host.StaticIP, &host.UseAsFallback, &host.UserID); err != nil {
+			return nil, err
+		}
+		if normalizeMACAddress(host.MAC) == target {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts, rows.Err()
+}
+
+// isLocalIP reports whether ip is assigned to one of this machine's interfaces
+func isLocalIP(ip net.IP) bool {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,15 +1,21 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	"os"
 	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
 	_ "modernc.org/sqlite"
+
+	"server/oui"
 )
 
 // RateLimiter implements a rate limiter with memory leak prevention
@@ -19,6 +25,7 @@ type RateLimiter struct {
 	limit    int
 	window   time.Duration
 	maxKeys  int
+	rejected atomic.Uint64 // Requests denied since startup
 }
 
 type requestWindow struct {
@@ -72,6 +79,7 @@ func (rl *RateLimiter) Allow(key string) bool {
 	// Check if we're within the limit
 	if len(validRequests) >= rl.limit {
 		window.times = validRequests
+		rl.rejected.Add(1)
 		return false
 	}
 
@@ -82,6 +90,18 @@ func (rl *RateLimiter) Allow(key string) bool {
 	return true
 }
 
+// Rejected returns the number of requests denied since startup
+func (rl *RateLimiter) Rejected() uint64 {
+	return rl.rejected.Load()
+}
+
+// SetLimit changes the number of requests allowed per window
+func (rl *RateLimiter) SetLimit(limit int) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	rl.limit = limit
+}
+
 // cleanupOldest removes entries not accessed within the cleanup threshold
 func (rl *RateLimiter) cleanupOldest(now time.Time) {
 	threshold := now.Add(-1 * RateLimiterCleanupThreshold)
@@ -140,6 +160,18 @@ func printHelp() {
 	fmt.Println("  -db <path>              Path to database file (default: ./wol.db)")
 	fmt.Println("  -debug                  Enable debug logging (overrides config)")
 	fmt.Println("  --reset-admin           Reset password for a superuser (interactive)")
+	fmt.Println("  --agent <server-url>    Run as companion agent reporting to the server (token from AGENT_TOKEN)")
+	fmt.Println("  -agent-token-file <path> Read the agent token from a file")
+	fmt.Println("  --agent-no-commands     Agent mode: ignore sleep/shutdown/reboot commands")
+	fmt.Println("  --relay <server-url>    Run as relay for a remote network (token from RELAY_TOKEN)")
+	fmt.Println("  -relay-name <name>      Relay name that hosts are assigned to (required with --relay)")
+	fmt.Println("  -relay-interface <if>   Relay mode: default network interface(s) for WoL and ARP")
+	fmt.Println("  -relay-token-file <path> Read the relay token from a file")
+	fmt.Println("  --import-dhcp <file>    Create/update hosts from a DHCP lease or reservation file and exit")
+	fmt.Println("  -import-format <format> Import file format: auto (default), dnsmasq, isc, kea-leases, kea-config")
+	fmt.Println("  -import-owner <user>    Import: user the hosts belong to (required when use_auth is enabled)")
+	fmt.Println("  -import-update-only     Import: only update the static IPs of existing hosts")
+	fmt.Println("  -import-dry-run         Import: show the changes without saving them")
 	fmt.Println()
 	fmt.Println("EXAMPLES:")
 	fmt.Println("  # Run with defaults")
@@ -151,33 +183,96 @@ func printHelp() {
 	fmt.Println("  # Reset superuser password (interactive)")
 	fmt.Printf("  %s --reset-admin\n", os.Args[0])
 	fmt.Println()
+	fmt.Println("  # Import hosts from the dnsmasq lease file (preview first)")
+	fmt.Printf("  %s --import-dhcp /var/lib/misc/dnsmasq.leases -import-owner admin -import-dry-run\n", os.Args[0])
+	fmt.Println()
 	fmt.Println("  # Run as systemd service")
 	fmt.Println("  sudo systemctl start wolweb")
 	fmt.Println()
 	fmt.Println("CONFIGURATION:")
 	fmt.Println("  Config file (config.json) fields:")
-	fmt.Println("    listen_address               Bind address (e.g., ':8090', '0.0.0.0:8090', '127.0.0.1:3000')")
+	fmt.Println("    listen_address               Bind address (e.g., ':8090', '0.0.0.0:8090', 'unix:/run/wol/wol.sock'), comma-separated for several")
+	fmt.Println("    listen_socket_mode           Permissions of unix sockets (default: 0660)")
+	fmt.Println("    listen_socket_group          Group owning unix sockets (e.g., 'www-data')")
 	fmt.Println("    url_prefix                   URL prefix for reverse proxy (e.g., '/wolweb')")
 	fmt.Println("    default_network_interface    Default network interface when per-host disabled")
 	fmt.Println("    enable_per_host_interfaces   Allow hosts to specify own interfaces (true/false)")
 	fmt.Println("    ping_timeout_seconds         Ping timeout in seconds (1-60)")
+	fmt.Println("    tls_cert_file, tls_key_file  Serve HTTPS with this certificate (reloaded on change)")
+	fmt.Println("    tls_self_signed              Generate a self-signed certificate next to the database (true/false)")
+	fmt.Println("    tls_redirect_address         Plain HTTP address redirecting to HTTPS (e.g. ':80')")
+	fmt.Println("    tls_client_ca_file           Require client certificates signed by this CA for the API (mTLS)")
+	fmt.Println("    acme_domains                 Obtain certificates via ACME for these host names (comma-separated)")
+	fmt.Println("    acme_email                   Contact address for the ACME account")
+	fmt.Println("    acme_directory_url           ACME directory (default: Let's Encrypt)")
+	fmt.Println("    acme_ca_file                 CA bundle trusted for the ACME directory (private/test CAs)")
+	fmt.Println("    shutdown_timeout_seconds     Seconds to drain requests on SIGTERM (1-300, default: 15)")
+	fmt.Println("    ping_rate_limit_per_minute   Pings per minute per user (default: 10)")
+	fmt.Println("    wake_rate_limit_per_minute   Wake-on-LAN requests per minute per user (default: 5)")
+	fmt.Println("    shutdown_rate_limit_per_minute  Remote shutdown requests per minute per user (default: 3)")
 	fmt.Println("    auth_expire_hours            Session expiration in hours")
 	fmt.Println("    use_auth                     Enable authentication (true/false)")
 	fmt.Println("    readonly_mode                Disable host modifications (true/false)")
 	fmt.Println("    behind_proxy                 Running behind HTTPS proxy (true/false)")
 	fmt.Println("    debug                        Enable debug logging (true/false)")
+	fmt.Println("    log_format                   Log line format: text or json (default: text)")
+	fmt.Println("    log_output_mode              stdout, file, both, syslog, journald or a comma-separated list")
+	fmt.Println("    log_syslog_address           Syslog target: udp://host:514, tcp://host:601 or unix:///dev/log (default)")
+	fmt.Println("    log_syslog_facility          Syslog facility (default: daemon)")
+	fmt.Println("    log_journald_socket          Journal socket (default: /run/systemd/journal/socket)")
+	fmt.Println("    log_levels                   Per-component levels, e.g. {\"network\": \"debug\"} (network, auth, http)")
+	fmt.Println("    enable_remote_shutdown       Allow powering hosts off over SSH (true/false)")
+	fmt.Println("    secret_key_file              Key file for encrypting SSH keys (default: wol.key next to database)")
+	fmt.Println("    agent_token                  Shared token for companion agents (empty = disabled)")
+	fmt.Println("    agents                       Agents with their own token bound to their MACs (see CONFIG.md)")
+	fmt.Println("    relay_token                  Shared token for relay nodes (empty = disabled)")
+	fmt.Println("    relays                       Relays with their own token bound to the name (see CONFIG.md)")
+	fmt.Println("    metrics_token                Bearer token for the Prometheus /metrics endpoint (empty = disabled)")
+	fmt.Println("    tracing_endpoint             OTLP/HTTP collector URL for OpenTelemetry traces (empty = disabled)")
+	fmt.Println("    tracing_sample_ratio         Fraction of new traces to record, 0-1 (default: 1)")
+	fmt.Println("    magic_packet_listen          UDP addresses to receive magic packets on (e.g. ':7,:9')")
+	fmt.Println("    magic_packet_forward         Re-send received magic packets for known hosts (true/false)")
+	fmt.Println("    wake_proxies                 Wake-on-demand TCP proxies (see CONFIG.md)")
+	fmt.Println("    web_proxies                  Waking HTTP reverse proxies under /proxy/{name}/ (see CONFIG.md)")
+	fmt.Println("    dhcp_imports                 DHCP lease/reservation files imported and watched for changes (see CONFIG.md)")
 	fmt.Println()
-	fmt.Println("  Environment variables (override config file):")
+	fmt.Println("  Environment variables (override config file and /api/settings):")
 	fmt.Println("    LISTEN_ADDRESS               Server listen address (e.g., ':8090')")
+	fmt.Println("    LISTEN_SOCKET_MODE, LISTEN_SOCKET_GROUP  Unix socket permissions")
 	fmt.Println("    URL_PREFIX                   URL prefix for the application")
 	fmt.Println("    DEFAULT_NETWORK_INTERFACE    Default network interface(s)")
 	fmt.Println("    ENABLE_PER_HOST_INTERFACES   Allow per-host interfaces (true/1)")
 	fmt.Println("    PING_TIMEOUT_SECONDS         Ping timeout in seconds")
+	fmt.Println("    TLS_CERT_FILE, TLS_KEY_FILE  HTTPS certificate and key")
+	fmt.Println("    TLS_SELF_SIGNED              Generate a self-signed certificate (true/1)")
+	fmt.Println("    TLS_REDIRECT_ADDRESS         HTTP to HTTPS redirect address")
+	fmt.Println("    TLS_CLIENT_CA_FILE           CA for API client certificates")
+	fmt.Println("    ACME_DOMAINS, ACME_EMAIL     ACME host names and contact address")
+	fmt.Println("    ACME_DIRECTORY_URL           ACME directory URL")
+	fmt.Println("    ACME_CA_FILE                 CA bundle for the ACME directory")
+	fmt.Println("    SHUTDOWN_TIMEOUT_SECONDS     Seconds to drain requests on SIGTERM")
+	fmt.Println("    PING_RATE_LIMIT_PER_MINUTE, WAKE_RATE_LIMIT_PER_MINUTE, SHUTDOWN_RATE_LIMIT_PER_MINUTE  Rate limits")
 	fmt.Println("    AUTH_EXPIRE_HOURS            Session expiration in hours")
 	fmt.Println("    USE_AUTH                     Enable authentication (true/1)")
 	fmt.Println("    READONLY_MODE                Disable host modifications (true/1)")
 	fmt.Println("    BEHIND_PROXY                 Behind reverse proxy (true/1)")
 	fmt.Println("    DEBUG                        Enable debug logging (true/1)")
+	fmt.Println("    LOG_FORMAT                   Log line format: text or json (also agent/relay mode)")
+	fmt.Println("    LOG_OUTPUT_MODE              Log outputs (e.g. 'stdout,syslog')")
+	fmt.Println("    LOG_SYSLOG_ADDRESS           Syslog target address")
+	fmt.Println("    LOG_SYSLOG_FACILITY          Syslog facility")
+	fmt.Println("    LOG_JOURNALD_SOCKET          Journal socket path")
+	fmt.Println("    LOG_LEVELS                   Per-component levels (e.g. 'network=debug,auth=warning')")
+	fmt.Println("    ENABLE_REMOTE_SHUTDOWN       Allow SSH shutdown (true/1)")
+	fmt.Println("    SECRET_KEY_FILE              Path to secret key file")
+	fmt.Println("    AGENT_TOKEN                  Shared agent token (server and agent mode)")
+	fmt.Println("    RELAY_TOKEN                  Shared relay token (server and relay mode)")
+	fmt.Println("    METRICS_TOKEN                Prometheus metrics token")
+	fmt.Println("    TRACING_ENDPOINT             OTLP/HTTP collector URL")
+	fmt.Println("    TRACING_SAMPLE_RATIO         Fraction of new traces to record")
+	fmt.Println("    MAGIC_PACKET_LISTEN          Magic packet listen addresses")
+	fmt.Println("    MAGIC_PACKET_FORWARD         Re-send received magic packets (true/1)")
+	fmt.Println("    OUI_DATABASE                 IEEE OUI registry file for vendor lookups")
 	fmt.Println()
 	fmt.Println("NETWORK INTERFACE MODES:")
 	fmt.Println("  1. Global Interface (enable_per_host_interfaces: false, default)")
@@ -258,26 +353,18 @@ func checkFilePermissions(path string, needWrite bool) error {
 }
 
 func main() {
-	// Initialize rate limiters
-	pingRateLimiter := NewRateLimiter(PingRateLimitPerMinute, time.Minute)
-	wolRateLimiter := NewRateLimiter(WoLRateLimitPerMinute, time.Minute)
-
-	// Start cleanup goroutine for rate limiters
-	go func() {
-		ticker := time.NewTicker(RateLimiterCleanupInterval)
-		defer ticker.Stop()
-		for range ticker.C {
-			pingRateLimiter.CleanupOldEntries()
-			wolRateLimiter.CleanupOldEntries()
-		}
-	}()
-
 	// Parse command line arguments
 	configPath := "./config.json"
 	dbPath := "./wol.db"
 	resetAdmin := false
 	showHelp := false
 	debugFlag := false
+	agentMode := false
+	agentOpts := agentOptions{Token: os.Getenv("AGENT_TOKEN"), AllowCommands: true}
+	relayMode := false
+	relayOpts := relayOptions{Token: os.Getenv("RELAY_TOKEN")}
+	var dhcpImport DHCPImportConfig
+	dhcpImportDryRun := false
 
 	args := os.Args[1:]
 	for i := 0; i < len(args); i++ {
@@ -302,6 +389,85 @@ func main() {
 			debugFlag = true
 		case "--reset-admin":
 			resetAdmin = true
+		case "--agent":
+			if i+1 < len(args) {
+				agentMode = true
+				agentOpts.ServerURL = args[i+1]
+				i++
+			} else {
+				Fatal("Error: --agent requires a server URL argument")
+			}
+		case "-agent-token-file":
+			if i+1 < len(args) {
+				token, err := os.ReadFile(args[i+1])
+				if err != nil {
+					Fatal("Error: failed to read agent token file: %v", err)
+				}
+				agentOpts.Token = strings.TrimSpace(string(token))
+				i++
+			} else {
+				Fatal("Error: -agent-token-file requires a path argument")
+			}
+		case "--agent-no-commands":
+			agentOpts.AllowCommands = false
+		case "--relay":
+			if i+1 < len(args) {
+				relayMode = true
+				relayOpts.ServerURL = args[i+1]
+				i++
+			} else {
+				Fatal("Error: --relay requires a server URL argument")
+			}
+		case "-relay-name":
+			if i+1 < len(args) {
+				relayOpts.Name = args[i+1]
+				i++
+			} else {
+				Fatal("Error: -relay-name requires a name argument")
+			}
+		case "-relay-interface":
+			if i+1 < len(args) {
+				relayOpts.Interface = args[i+1]
+				i++
+			} else {
+				Fatal("Error: -relay-interface requires an interface argument")
+			}
+		case "-relay-token-file":
+			if i+1 < len(args) {
+				token, err := os.ReadFile(args[i+1])
+				if err != nil {
+					Fatal("Error: failed to read relay token file: %v", err)
+				}
+				relayOpts.Token = strings.TrimSpace(string(token))
+				i++
+			} else {
+				Fatal("Error: -relay-token-file requires a path argument")
+			}
+		case "--import-dhcp":
+			if i+1 < len(args) {
+				dhcpImport.Path = args[i+1]
+				i++
+			} else {
+				Fatal("Error: --import-dhcp requires a file argument")
+			}
+		case "-import-format":
+			if i+1 < len(args) {
+				dhcpImport.Format = args[i+1]
+				i++
+			} else {
+				Fatal("Error: -import-format requires a format argument")
+			}
+		case "-import-owner":
+			if i+1 < len(args) {
+				dhcpImport.Owner = args[i+1]
+				i++
+			} else {
+				Fatal("Error: -import-owner requires a username argument")
+			}
+		case "-import-update-only":
+			dhcpImport.UpdateOnly = true
+		case "-import-dry-run":
+			dhcpImportDryRun = true
 		default:
 			if args[i] != "" && args[i][0] == '-' {
 				Warning("Unknown flag '%s' (use -h or --help for usage)", args[i])
@@ -315,6 +481,30 @@ func main() {
 		return
 	}
 
+	// Agent and relay modes run on remote machines - no config file or database needed
+	if agentMode || relayMode {
+		remoteLogConfig := LoggerConfig{Level: LogLevelInfo, OutputMode: "stdout"}
+		if debugFlag {
+			remoteLogConfig.Level = LogLevelDebug
+		}
+		if os.Getenv("LOG_FORMAT") == LogFormatJSON {
+			remoteLogConfig.Format = LogFormatJSON
+		}
+		if err := InitLogger(remoteLogConfig); err != nil {
+			Fatal("Failed to initialize logger: %v", err)
+		}
+		if agentMode {
+			if err := runAgent(agentOpts); err != nil {
+				Fatal("Agent error: %v", err)
+			}
+			return
+		}
+		if err := runRelay(relayOpts); err != nil {
+			Fatal("Relay error: %v", err)
+		}
+		return
+	}
+
 	// Check file permissions BEFORE loading anything
 	Info("Checking file permissions...")
 
@@ -350,17 +540,7 @@ func main() {
 	}
 
 	// Initialize logger based on configuration
-	logLevel := LogLevelInfo
-	switch config.LogLevel {
-	case "debug":
-		logLevel = LogLevelDebug
-	case "info":
-		logLevel = LogLevelInfo
-	case "warning":
-		logLevel = LogLevelWarning
-	case "error":
-		logLevel = LogLevelError
-	}
+	logLevel, componentLevels := configLogLevels(config)
 
 	loggerConfig := LoggerConfig{
 		Level:           logLevel,
@@ -369,6 +549,11 @@ func main() {
 		MaxFileSizeMB:   config.LogMaxSizeMB,
 		MaxAgeDays:      config.LogMaxAgeDays,
 		RotationEnabled: config.LogRotation,
+		Format:          config.LogFormat,
+		SyslogAddress:   config.LogSyslogAddress,
+		SyslogFacility:  config.LogSyslogFacility,
+		JournaldSocket:  config.LogJournaldSocket,
+		ComponentLevels: componentLevels,
 	}
 
 	if err := InitLogger(loggerConfig); err != nil {
@@ -387,8 +572,14 @@ func main() {
 		} else {
 			Debug("Created default config file: %s", configPath)
 			config = loadConfig(configPath)
+			if debugFlag {
+				config.Debug = true
+				config.LogLevel = "debug"
+			}
 		}
 	}
+	// Reloads are compared with the configuration as loaded, before startup adjusts it
+	loadedConfig := *config
 
 	// Initialize database
 	db, err := initDatabase(dbPath)
@@ -407,22 +598,137 @@ func main() {
 
 	// Create server with database and configuration
 	pingCacheTTL := time.Duration(config.PingTimeout*PingCacheTTLMultiplier) * time.Second
+	lifecycle, stop := context.WithCancel(context.Background())
+	defer stop()
 	server := &Server{
 		DB:            db,
 		Config:        config,
-		PingRateLimit: pingRateLimiter,
-		WoLRateLimit:  wolRateLimiter,
+		PingRateLimit: NewRateLimiter(config.PingRateLimit, time.Minute),
+		WoLRateLimit:  NewRateLimiter(config.WakeRateLimit, time.Minute),
 		WoLHistory:    NewWoLHistory(MaxWoLHistoryEntries),
 		PingCache:     NewPingCache(pingCacheTTL),
+		Agents:        NewAgentRegistry(),
+		Relays:        NewRelayHub(),
+
+		ShutdownRateLimit: NewRateLimiter(config.ShutdownRateLimit, time.Minute),
+		ProxyActivity:     NewProxyActivity(),
+		Vendors:           oui.New(),
+		Lifecycle:         lifecycle,
+		Runtime:           NewRuntimeConfig(config),
+		WakeChains:        NewWakeChainRuns(),
+	}
+	if config.MetricsToken != "" {
+		server.Metrics = server.newMetrics()
+	}
+
+	// Apply settings changed through /api/settings on top of the config file
+	server.loadSettings()
+
+	// Handle --import-dhcp flag
+	if dhcpImport.Path != "" {
+		if err := validateDHCPImportConfig(dhcpImport, false); err != nil {
+			Fatal("DHCP import failed: %v", err)
+		}
+		if err := server.runDHCPImportCLI(dhcpImport, dhcpImportDryRun); err != nil {
+			Fatal("DHCP import failed: %v", err)
+		}
+		return
+	}
+
+	// Export OpenTelemetry traces
+	if config.TracingEndpoint != "" {
+		shutdownTracing, err := initTracing(config)
+		if err != nil {
+			Fatal("Failed to initialize tracing: %v", err)
+		}
+		defer shutdownTracing(context.Background())
+	}
+
+	// Load the full OUI registry on top of the bundled vendor list
+	if err := server.loadVendorDatabase(); err != nil {
+		Fatal("Failed to load OUI database: %v", err)
+	}
+
+	// Load the secret key used to encrypt SSH private keys
+	if config.EnableRemoteShutdown {
+		keyPath := config.SecretKeyFile
+		if keyPath == "" {
+			keyPath = defaultSecretKeyPath(dbPath)
+		}
+		secrets, err := LoadOrCreateSecretBox(keyPath)
+		if err != nil {
+			Fatal("Failed to load secret key: %v\nHint: Ensure the process can read/write %s", err, keyPath)
+		}
+		server.Secrets = secrets
+		if err := server.resealLegacyPowerKeys(); err != nil {
+			Fatal("Failed to update stored SSH keys: %v", err)
+		}
+	}
+
+	// Start wake-on-demand proxies
+	if err := server.startWakeProxies(); err != nil {
+		Fatal("Failed to start wake proxy: %v", err)
+	}
+	if err := server.startWebProxies(); err != nil {
+		Fatal("Failed to start web proxy: %v", err)
+	}
+
+	// Start magic packet listeners
+	if err := server.startMagicPacketListeners(); err != nil {
+		Fatal("Failed to start magic packet listener: %v\nHint: Ports below 1024 require root or CAP_NET_BIND_SERVICE", err)
+	}
+
+	// Reload the OUI database when the file is updated
+	if config.OUIDatabase != "" {
+		go server.watchVendorDatabase()
+	}
+
+	// Import DHCP lease and reservation files and keep host addresses current
+	for _, dhcpImport := range config.DHCPImports {
+		go server.watchDHCPImport(dhcpImport)
 	}
 
+	// Start cleanup goroutine for rate limiters
+	go server.cleanupRateLimiters()
+
+	// Apply configuration file changes (and SIGHUP) while running
+	go server.watchConfig(configPath, &loadedConfig, debugFlag)
+
+	// Start audit log and dismissed host alert cleanup goroutine
+	go func() {
+		cleanup := func() {
+			if err := server.cleanupAuditLog(); err != nil {
+				Warning("Failed to clean up audit log: %v", err)
+			}
+			if err := server.cleanupHostAlerts(); err != nil {
+				Warning("Failed to clean up host alerts: %v", err)
+			}
+		}
+		cleanup()
+		ticker := time.NewTicker(AuditLogCleanupInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				cleanup()
+			case <-server.stopping():
+				return
+			}
+		}
+	}()
+
 	// Start session cleanup goroutine if auth is enabled
 	if config.UseAuth {
 		go func() {
 			ticker := time.NewTicker(SessionCleanupInterval)
 			defer ticker.Stop()
-			for range ticker.C {
-				server.cleanupExpiredSessions()
+			for {
+				select {
+				case <-ticker.C:
+					server.cleanupExpiredSessions()
+				case <-server.stopping():
+					return
+				}
 			}
 		}()
 	}
@@ -440,6 +746,18 @@ func main() {
 	Info("Database:          %s", dbPath)
 	Info("Authentication:    %v", config.UseAuth)
 	Info("Log level:         %s", config.LogLevel)
+	Info("Remote shutdown:   %v", config.EnableRemoteShutdown)
+	Info("Agent endpoint:    %v", config.AgentToken != "" || len(config.Agents) > 0)
+	Info("Relay endpoint:    %v", config.RelayToken != "" || len(config.Relays) > 0)
+	Info("Metrics endpoint:  %v", config.MetricsToken != "")
+	if config.TracingEndpoint != "" {
+		Info("Tracing:           %s (sample ratio: %g)", config.TracingEndpoint, config.TracingSampleRatio)
+	}
+	Info("Wake proxies:      %d", len(config.WakeProxies))
+	Info("Web proxies:       %d", len(config.WebProxies))
+	if config.MagicPacketListen != "" {
+		Info("Magic packets:     %s (forwarding: %v)", config.MagicPacketListen, config.MagicPacketForward)
+	}
 	Info("Log output:        %s", config.LogOutputMode)
 	if config.LogOutputMode != "stdout" {
 		Info("Log directory:     %s", config.LogDir)
@@ -482,19 +800,57 @@ func main() {
 	}
 
 	// Security warning for insecure deployment
-	if config.UseAuth && !config.BehindProxy {
+	if config.UseAuth && !config.BehindProxy && !config.tlsEnabled() {
 		Warning("Authentication is enabled but BehindProxy is false")
 		Warning("Service is running without HTTPS protection")
 		Warning("Credentials and session cookies will be transmitted in PLAINTEXT")
 		Warning("This configuration is INSECURE for production use")
 		Warning("Set 'behind_proxy: true' in config.json when using reverse proxy with HTTPS")
+		Warning("or serve HTTPS directly with tls_cert_file/tls_key_file, tls_self_signed or acme_domains")
+	}
+
+	listeners, err := server.openListeners()
+	if err != nil {
+		Fatal("Failed to start server: %v", err)
+	}
+
+	// Serve HTTPS directly on TCP listeners; unix sockets are for a local reverse proxy
+	scheme := "http"
+	if config.tlsEnabled() {
+		tlsConfig, err := server.newTLSConfig(dbPath)
+		if err != nil {
+			Fatal("Failed to set up TLS: %v", err)
+		}
+		for i, listener := range listeners {
+			if listener.Addr().Network() != "unix" {
+				listeners[i] = tls.NewListener(listener, tlsConfig)
+			}
+		}
+		scheme = "https"
+		if server.ACME != nil {
+			Info("TLS certificate:   ACME for %s via %s (client certificates: %v)", config.ACMEDomains, config.ACMEDirectoryURL, config.TLSClientCAFile != "")
+		} else {
+			Info("TLS certificate:   %s (client certificates: %v)", config.TLSCertFile, config.TLSClientCAFile != "")
+		}
+
+		if config.TLSRedirectAddress != "" {
+			if err := server.startHTTPSRedirect(); err != nil {
+				Fatal("Failed to start HTTP redirect listener: %v", err)
+			}
+			Info("HTTP redirect:     %s -> https", config.TLSRedirectAddress)
+		}
+
+		// After the redirect listener, which enables HTTP-01 challenges
+		if server.ACME != nil {
+			go server.obtainACMECertificates()
+		}
 	}
 
 	Info("=================================================")
-	Info("Server ready - Access at: http://%s%s", config.ListenAddress, config.URLPrefix)
+	for _, listener := range listeners {
+		Info("Server ready - Access at: %s", listenerURL(listener, scheme, config.URLPrefix))
+	}
 	Info("=================================================")
 
-	if err := http.ListenAndServe(config.ListenAddress, router); err != nil {
-		Fatal("Failed to start server: %v", err)
-	}
+	server.serve(&http.Server{Handler: router}, listeners, stop)
 }
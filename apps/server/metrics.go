@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"server/metrics"
+)
+
+// Metrics holds the Prometheus metrics exported on /metrics.
+// All methods are safe to call on a nil *Metrics (metrics disabled, relay mode).
+type Metrics struct {
+	registry     *metrics.Registry
+	httpRequests *metrics.Vec
+	httpDuration *metrics.Histogram
+	wakeAttempts *metrics.Vec
+	wakeFailures *metrics.Vec
+	pings        *metrics.Vec
+	pingDuration *metrics.Histogram
+}
+
+// newMetrics registers the server metrics. Values kept elsewhere (ping cache, rate
+// limiters, sessions, database errors) are read when /metrics is scraped.
+func (s *Server) newMetrics() *Metrics {
+	registry := metrics.NewRegistry()
+	m := &Metrics{
+		registry: registry,
+		httpRequests: registry.Counter("wol_http_requests_total",
+			"HTTP requests by route template, method and status code.", "route", "method", "code"),
+		httpDuration: registry.Histogram("wol_http_request_duration_seconds",
+			"HTTP request latency by route template and method.", metrics.DefaultBuckets, "route", "method"),
+		// Host names can change, so wake series are labelled by ID only; wol_host_info maps IDs to names
+		wakeAttempts: registry.Counter("wol_wake_attempts_total",
+			"Wake-on-LAN attempts per host.", "host_id"),
+		wakeFailures: registry.Counter("wol_wake_failures_total",
+			"Wake-on-LAN attempts per host that failed to send the magic packet.", "host_id"),
+		pings: registry.Counter("wol_ping_total",
+			"Host status checks by resolution method and result (online/offline).", "method", "result"),
+		pingDuration: registry.Histogram("wol_ping_duration_seconds",
+			"Host status check duration by resolution method.", metrics.DefaultBuckets, "method"),
+	}
+
+	registry.GaugeFunc("wol_build_info", "Server version.", []string{"version"}, func(emit metrics.Emit) {
+		emit(1, Version)
+	})
+	registry.GaugeFunc("wol_host_info", "Host names by host ID, for joining with the per-host metrics.", []string{"host_id", "name"}, func(emit metrics.Emit) {
+		rows, err := s.DB.Query("SELECT id, name FROM hosts ORDER BY id")
+		if err != nil {
+			Debug("Failed to list hosts for metrics: %v", err)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id, name string
+			if err := rows.Scan(&id, &name); err != nil {
+				Debug("Failed to read host for metrics: %v", err)
+				return
+			}
+			emit(1, id, name)
+		}
+	})
+	registry.CounterFunc("wol_ping_cache_hits_total", "Status lookups answered from the ping cache.", nil, func(emit metrics.Emit) {
+		emit(float64(s.PingCache.hits.Load()))
+	})
+	registry.CounterFunc("wol_ping_cache_misses_total", "Status lookups that found no valid cached result.", nil, func(emit metrics.Emit) {
+		emit(float64(s.PingCache.misses.Load()))
+	})
+	registry.CounterFunc("wol_ping_cache_coalesced_total", "Status requests that waited for a check already in progress.", nil, func(emit metrics.Emit) {
+		emit(float64(s.PingCache.coalesced.Load()))
+	})
+	registry.GaugeFunc("wol_ping_cache_entries", "Ping cache entries by state.", []string{"state"}, func(emit metrics.Emit) {
+		stats := s.PingCache.GetStats()
+		emit(float64(stats["cached_results"].(int)), "cached")
+		emit(float64(stats["in_progress"].(int)), "in_progress")
+	})
+	registry.CounterFunc("wol_rate_limit_rejections_total", "Requests denied by rate limiting.", []string{"limiter"}, func(emit metrics.Emit) {
+		emit(float64(s.PingRateLimit.Rejected()), "ping")
+		emit(float64(s.WoLRateLimit.Rejected()), "wake")
+		emit(float64(s.ShutdownRateLimit.Rejected()), "shutdown")
+	})
+	registry.GaugeFunc("wol_active_sessions", "Unexpired login sessions.", nil, func(emit metrics.Emit) {
+		var count int
+		if err := s.DB.QueryRow("SELECT COUNT(*) FROM sessions WHERE expires > ?", time.Now()).Scan(&count); err != nil {
+			Debug("Failed to count sessions for metrics: %v", err)
+			return
+		}
+		emit(float64(count))
+	})
+	registry.CounterFunc("wol_db_errors_total", "Failed database statements, transactions and connection attempts.", nil, func(emit metrics.Emit) {
+		emit(float64(dbErrors.Load()))
+	})
+	return m
+}
+
+// observeRequest records a completed HTTP request
+func (m *Metrics) observeRequest(route, method string, code int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.httpRequests.Inc(route, method, strconv.Itoa(code))
+	m.httpDuration.Observe(duration.Seconds(), route, method)
+}
+
+// observeWake records a wake attempt and whether sending the magic packet failed
+func (m *Metrics) observeWake(host Host, err error) {
+	if m == nil {
+		return
+	}
+	m.wakeAttempts.Inc(host.ID)
+	if err != nil {
+		m.wakeFailures.Inc(host.ID)
+	}
+}
+
+// observeProbe records a host status check
+func (m *Metrics) observeProbe(result hostProbeResult, duration time.Duration) {
+	if m == nil || result.Method == "" {
+		return
+	}
+	status := "offline"
+	if result.PingSuccess || result.ARPSuccess {
+		status = "online"
+	}
+	m.pings.Inc(result.Method, status)
+	m.pingDuration.Observe(duration.Seconds(), result.Method)
+}
+
+// statusRecorder captures the status code of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush supports streaming responses (bulk ping)
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap gives http.ResponseController (used by the web proxies) the original writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// metricsMiddleware counts requests and their latency by route template without the
+// URL prefix (e.g. "/api/hosts/{id}"), so host IDs do not create a series each
+func (s *Server) metricsMiddleware(prefix string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := "unknown"
+			if current := mux.CurrentRoute(r); current != nil {
+				if template, err := current.GetPathTemplate(); err == nil {
+					route = strings.TrimPrefix(template, prefix)
+				}
+			}
+
+			start := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, r)
+			if recorder.status == 0 {
+				recorder.status = http.StatusOK
+			}
+			s.Metrics.observeRequest(route, r.Method, recorder.status, time.Since(start))
+		})
+	}
+}
+
+// handleMetrics serves the metrics in the Prometheus text format.
+// Requires "Authorization: Bearer <metrics_token>".
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.Config.MetricsToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", metrics.ContentType)
+	if err := s.Metrics.registry.Write(w); err != nil {
+		Debug("Failed to write metrics: %v", err)
+	}
+}
@@ -0,0 +1,296 @@
+// Package metrics is a minimal Prometheus client: counters, gauges and histograms with
+// labels, and collectors that read their values at scrape time, written in the Prometheus
+// text exposition format (version 0.0.4).
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are latency histogram buckets in seconds, from 5ms to 10s
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Emit reports one sample of a collector
+type Emit func(value float64, labelValues ...string)
+
+// collector is a metric family
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// Registry holds the metric families in registration order
+type Registry struct {
+	mutex      sync.Mutex
+	collectors []collector
+	names      map[string]bool
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// register adds a collector; metric names must be unique
+func (r *Registry) register(name string, c collector) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.names[name] {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.names[name] = true
+	r.collectors = append(r.collectors, c)
+}
+
+// Write writes all metrics in the text exposition format
+func (r *Registry) Write(w io.Writer) error {
+	r.mutex.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mutex.Unlock()
+
+	buffered := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(buffered)
+	}
+	return buffered.Flush()
+}
+
+// desc is the name, help text, type and label names of a metric family
+type desc struct {
+	name   string
+	help   string
+	kind   string // "counter", "gauge" or "histogram"
+	labels []string
+}
+
+// writeHeader writes the HELP and TYPE lines
+func (d *desc) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.kind)
+}
+
+// key joins label values into a map key
+func (d *desc) key(labelValues []string) string {
+	if len(labelValues) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labels), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+// Vec is a counter or gauge with labels
+type Vec struct {
+	desc
+	mutex  sync.Mutex
+	values map[string]float64
+}
+
+// Counter registers a counter. Counters only go up; use Inc or Add.
+func (r *Registry) Counter(name, help string, labels ...string) *Vec {
+	v := &Vec{desc: desc{name: name, help: help, kind: "counter", labels: labels}, values: make(map[string]float64)}
+	r.register(name, v)
+	return v
+}
+
+// Gauge registers a gauge
+func (r *Registry) Gauge(name, help string, labels ...string) *Vec {
+	v := &Vec{desc: desc{name: name, help: help, kind: "gauge", labels: labels}, values: make(map[string]float64)}
+	r.register(name, v)
+	return v
+}
+
+// Inc adds one
+func (v *Vec) Inc(labelValues ...string) {
+	v.Add(1, labelValues...)
+}
+
+// Add adds a value
+func (v *Vec) Add(value float64, labelValues ...string) {
+	key := v.key(labelValues)
+	v.mutex.Lock()
+	v.values[key] += value
+	v.mutex.Unlock()
+}
+
+// Set sets a gauge value
+func (v *Vec) Set(value float64, labelValues ...string) {
+	key := v.key(labelValues)
+	v.mutex.Lock()
+	v.values[key] = value
+	v.mutex.Unlock()
+}
+
+func (v *Vec) write(w *bufio.Writer) {
+	v.mutex.Lock()
+	keys := sortedKeys(v.values)
+	values := make([]float64, len(keys))
+	for i, key := range keys {
+		values[i] = v.values[key]
+	}
+	v.mutex.Unlock()
+
+	v.writeHeader(w)
+	for i, key := range keys {
+		writeSample(w, v.name, v.labels, splitKey(key, len(v.labels)), "", "", values[i])
+	}
+}
+
+// Func is a counter or gauge whose samples are collected at scrape time
+type Func struct {
+	desc
+	collect func(emit Emit)
+}
+
+// CounterFunc registers a counter read at scrape time, e.g. from an atomic counter
+func (r *Registry) CounterFunc(name, help string, labels []string, collect func(emit Emit)) {
+	r.register(name, &Func{desc: desc{name: name, help: help, kind: "counter", labels: labels}, collect: collect})
+}
+
+// GaugeFunc registers a gauge read at scrape time
+func (r *Registry) GaugeFunc(name, help string, labels []string, collect func(emit Emit)) {
+	r.register(name, &Func{desc: desc{name: name, help: help, kind: "gauge", labels: labels}, collect: collect})
+}
+
+func (f *Func) write(w *bufio.Writer) {
+	f.writeHeader(w)
+	f.collect(func(value float64, labelValues ...string) {
+		f.key(labelValues) // Validates the label count
+		writeSample(w, f.name, f.labels, labelValues, "", "", value)
+	})
+}
+
+// Histogram counts observations in cumulative buckets
+type Histogram struct {
+	desc
+	buckets []float64
+	mutex   sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64 // Per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// Histogram registers a histogram with the given upper bucket bounds (ascending)
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		desc:    desc{name: name, help: help, kind: "histogram", labels: labels},
+		buckets: buckets,
+		series:  make(map[string]*histogramSeries),
+	}
+	r.register(name, h)
+	return h
+}
+
+// Observe records one observation
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	series := h.series[key]
+	if series == nil {
+		series = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = series
+	}
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		series.counts[i]++
+	}
+	series.count++
+	series.sum += value
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mutex.Lock()
+	keys := sortedKeys(h.series)
+	snapshot := make([]histogramSeries, len(keys))
+	for i, key := range keys {
+		series := h.series[key]
+		snapshot[i] = histogramSeries{counts: append([]uint64(nil), series.counts...), count: series.count, sum: series.sum}
+	}
+	h.mutex.Unlock()
+
+	h.writeHeader(w)
+	for i, key := range keys {
+		labelValues := splitKey(key, len(h.labels))
+		var cumulative uint64
+		for b, bound := range h.buckets {
+			cumulative += snapshot[i].counts[b]
+			writeSample(w, h.name+"_bucket", h.labels, labelValues, "le", formatFloat(bound), float64(cumulative))
+		}
+		writeSample(w, h.name+"_bucket", h.labels, labelValues, "le", "+Inf", float64(snapshot[i].count))
+		writeSample(w, h.name+"_sum", h.labels, labelValues, "", "", snapshot[i].sum)
+		writeSample(w, h.name+"_count", h.labels, labelValues, "", "", float64(snapshot[i].count))
+	}
+}
+
+// writeSample writes one sample line with an optional extra label (the histogram "le")
+func writeSample(w *bufio.Writer, name string, labels, labelValues []string, extraLabel, extraValue string, value float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraLabel != "" {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, label, escapeLabelValue(labelValues[i]))
+		}
+		if extraLabel != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, extraLabel, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+// formatFloat formats a sample value
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(help string) string        { return helpEscaper.Replace(help) }
+func escapeLabelValue(value string) string { return labelEscaper.Replace(value) }
+
+// sortedKeys returns the keys of a series map in order
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// splitKey splits a map key back into label values
+func splitKey(key string, count int) []string {
+	if count == 0 {
+		return nil
+	}
+	return strings.Split(key, "\xff")
+}
@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"bytes"
+	"flag"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// testRegistry returns a registry with one metric of each kind and awkward label values
+func testRegistry() *Registry {
+	r := NewRegistry()
+
+	requests := r.Counter("test_requests_total", "Requests by path.\nSecond line with a \\ backslash.", "path", "code")
+	requests.Inc("/hosts", "200")
+	requests.Add(2, "/hosts", "200")
+	requests.Inc(`C:\wol "quoted"`+"\nnext", "500")
+
+	temperature := r.Gauge("test_temperature", "Current temperature.", "room")
+	temperature.Set(21.5, "office")
+	temperature.Set(-3, "garage")
+	temperature.Set(0.000125, "freezer")
+
+	duration := r.Histogram("test_duration_seconds", "Duration by method.", []float64{0.1, 1, 2.5}, "method")
+	for _, value := range []float64{0.05, 0.1, 0.5, 2.5, 7} {
+		duration.Observe(value, "arp")
+	}
+	duration.Observe(0.25, "ping")
+
+	plain := r.Histogram("test_size_bytes", "Sizes without labels.", []float64{100})
+	plain.Observe(50)
+	plain.Observe(150)
+
+	r.CounterFunc("test_hits_total", "Hits read at scrape time.", nil, func(emit Emit) {
+		emit(1e6)
+	})
+	r.GaugeFunc("test_limits", "Special values.", []string{"kind"}, func(emit Emit) {
+		emit(math.Inf(1), "upper")
+		emit(math.Inf(-1), "lower")
+		emit(math.NaN(), "unknown")
+	})
+	r.GaugeFunc("test_empty", "A collector without samples.", nil, func(emit Emit) {})
+	return r
+}
+
+func TestRegistryWriteGolden(t *testing.T) {
+	var out bytes.Buffer
+	if err := testRegistry().Write(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join("testdata", "registry.prom")
+	if *update {
+		if err := os.WriteFile(golden, out.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("output differs from %s (run go test -update to rewrite it):\n%s", golden, out.String())
+	}
+}
+
+func TestRegistryPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(r *Registry)
+	}{
+		{"duplicate name", func(r *Registry) {
+			r.Counter("test_total", "")
+			r.Gauge("test_total", "")
+		}},
+		{"too few label values", func(r *Registry) {
+			r.Counter("test_total", "", "a", "b").Inc("x")
+		}},
+		{"too many label values", func(r *Registry) {
+			r.Histogram("test_seconds", "", DefaultBuckets).Observe(1, "x")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			tt.fn(NewRegistry())
+		})
+	}
+}
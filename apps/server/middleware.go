@@ -66,11 +66,49 @@ func (s *Server) AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 		user := s.getCurrentUser(r)
+		if user != nil {
+			setLogUserID(r.Context(), user.ID)
+		}
 		ctx := context.WithValue(r.Context(), "user", user)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// requestIDMiddleware assigns each request an ID (keeping a valid X-Request-ID set by a proxy),
+// echoes it in the X-Request-ID response header and adds it and the client IP to log lines
+// written with the request context. Completed requests are logged at debug level (component http).
+func (s *Server) requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !isValidRequestID(id) {
+			id, _ = generateID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := withLogFields(r.Context(), &logFields{RequestID: id, RemoteIP: s.clientIP(r)})
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		httpLog.DebugContext(ctx, "%s %s %d %s", r.Method, r.URL.Path, recorder.status, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+// isValidRequestID accepts short IDs made of letters, digits, '-', '_' and '.'
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > MaxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
 // GetUserFromContext retrieves user from request context
 func GetUserFromContext(r *http.Request) *User {
 	user, _ := r.Context().Value("user").(*User)
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"sort"
 	"sync"
 	"time"
 
+	"golang.org/x/crypto/acme/autocert"
 	"golang.org/x/crypto/bcrypt"
+
+	"server/oui"
 )
 
 type User struct {
@@ -26,20 +30,40 @@ type Host struct {
 	MAC             string     `json:"mac"`
 	Broadcast       string     `json:"broadcast"`
 	Interface       string     `json:"interface"`
-	StaticIP        string     `json:"static_ip"`        // Static IPv4 address - manually specified IP for ping/WoL (ignores ARP when not using fallback)
+	StaticIP        string     `json:"static_ip"`        // Static IPv4 and/or IPv6 address (comma-separated for dual-stack) - manually specified IP for ping/WoL (ignores ARP when not using fallback)
 	UseAsFallback   bool       `json:"use_as_fallback"`  // If true, use static IP only when ARP resolution fails or host not responding
 	UserID          *string    `json:"user"`
 	Created         time.Time  `json:"created"`
 	Updated         time.Time  `json:"updated"`
+	// MAC address details from the OUI database (responses only, not stored)
+	Vendor              string `json:"vendor,omitempty"`
+	LocallyAdministered bool   `json:"mac_locally_administered,omitempty"`
+	RandomizedMAC       bool   `json:"mac_randomized,omitempty"`
+	// Address tracking (responses only, from host_ip_history and host_alerts)
+	LastIP   string     `json:"last_ip,omitempty"`   // Address the host was last found at
+	LastSeen *time.Time `json:"last_seen,omitempty"` // Time of the last status check that found the host
+	Alerts   int        `json:"alerts,omitempty"`    // Number of open alerts
 }
 
 type Server struct {
-	DB            *sql.DB
-	Config        *Config
-	PingRateLimit *RateLimiter
-	WoLRateLimit  *RateLimiter
-	WoLHistory    *WoLHistory
-	PingCache     *PingCache
+	DB                *sql.DB
+	Config            *Config
+	PingRateLimit     *RateLimiter
+	WoLRateLimit      *RateLimiter
+	ShutdownRateLimit *RateLimiter
+	WoLHistory        *WoLHistory
+	PingCache         *PingCache
+	Agents            *AgentRegistry
+	Relays            *RelayHub // nil in relay mode
+	Secrets           *SecretBox // nil unless a feature that stores secrets is enabled
+	WebProxies        map[string]*webProxy
+	ProxyActivity     *ProxyActivity
+	Vendors           *oui.Database
+	Metrics           *Metrics // nil unless metrics_token is set
+	ACME              *autocert.Manager // nil unless acme_domains is set
+	Runtime           *RuntimeConfig // Settings changed while running (config reload); nil in relay mode
+	WakeChains        *WakeChainRuns // Background wake chains (nil in relay mode)
+	Lifecycle         context.Context // Cancelled when the server starts shutting down (nil in relay mode)
 }
 
 type WoLHistory struct {
@@ -57,7 +81,8 @@ func initDatabase(dbPath string) (*sql.DB, error) {
 	// - _foreign_keys=1: Enable foreign key constraints
 	dbPath = dbPath + "?_journal_mode=WAL&_busy_timeout=5000&_synchronous=NORMAL&_cache_size=1000&_foreign_keys=1"
 
-	db, err := sql.Open("sqlite", dbPath)
+	// The SQLite driver wrapped to count database errors for metrics and trace statements
+	db, err := sql.Open(countingDriverName, dbPath)
 	if err != nil {
 		return nil, err
 	}
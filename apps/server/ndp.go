@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net"
+)
+
+// Neighbor Discovery message types and options (RFC 4861)
+const (
+	ndpTypeNeighborSolicitation  = 135
+	ndpTypeNeighborAdvertisement = 136
+
+	ndpOptionSourceLinkAddress = 1
+	ndpOptionTargetLinkAddress = 2
+)
+
+// marshalNeighborSolicitation builds a Neighbor Solicitation for target that announces our
+// link-layer address, so the target can answer without resolving us first.
+// The checksum is left empty - the kernel fills it in for ICMPv6 sockets.
+func marshalNeighborSolicitation(target net.IP, source net.HardwareAddr) []byte {
+	msg := make([]byte, 24, 24+2+len(source))
+	msg[0] = ndpTypeNeighborSolicitation
+	copy(msg[8:24], target.To16())
+	if len(source) == 6 {
+		msg = append(msg, ndpOptionSourceLinkAddress, 1)
+		msg = append(msg, source...)
+	}
+	return msg
+}
+
+// parseNeighborAdvertisement returns the target address of a Neighbor Advertisement and
+// the link-layer address from its options (nil when the option is missing)
+func parseNeighborAdvertisement(msg []byte) (net.IP, net.HardwareAddr, bool) {
+	if len(msg) < 24 || msg[0] != ndpTypeNeighborAdvertisement || msg[1] != 0 {
+		return nil, nil, false
+	}
+	target := make(net.IP, net.IPv6len)
+	copy(target, msg[8:24])
+
+	var hwAddr net.HardwareAddr
+	for opts := msg[24:]; len(opts) >= 2; {
+		length := int(opts[1]) * 8 // Option length is in units of 8 bytes
+		if length == 0 || length > len(opts) {
+			break
+		}
+		if opts[0] == ndpOptionTargetLinkAddress && length >= 8 {
+			hwAddr = append(net.HardwareAddr(nil), opts[2:8]...)
+		}
+		opts = opts[length:]
+	}
+	return target, hwAddr, true
+}
+
+// solicitedNodeAddress returns the solicited-node multicast group of an address
+// (ff02::1:ffXX:XXXX with the low 24 bits of the address)
+func solicitedNodeAddress(ip net.IP) net.IP {
+	addr := net.ParseIP("ff02::1:ff00:0")
+	copy(addr[13:], ip.To16()[13:])
+	return addr
+}
+
+// onLinkIPv6Interfaces returns the interfaces on which an IPv6 address can be solicited:
+// the selected interfaces, or every active IPv6 interface with a prefix containing the
+// address (all of them for link-local addresses)
+func onLinkIPv6Interfaces(target net.IP, networkInterface string) ([]net.Interface, error) {
+	interfaces, err := ipv6Interfaces(networkInterface)
+	if err != nil || networkInterface != "" || target.IsLinkLocalUnicast() {
+		return interfaces, err
+	}
+
+	var result []net.Interface
+	for _, iface := range interfaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() == nil && ipNet.Contains(target) {
+				result = append(result, iface)
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// ipv6DiscoveryUseful reports whether an all-nodes discovery may find a host: it has a static
+// IPv6 address, or one of the interfaces has a global (or unique local) IPv6 address. Link-local
+// addresses alone do not count - every interface with IPv6 enabled has one, also on networks
+// where only IPv4 is used, whose offline hosts would otherwise pay for a discovery on every check.
+func ipv6DiscoveryUseful(staticIPs []string, networkInterface string) bool {
+	for _, ip := range staticIPs {
+		if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+			return true
+		}
+	}
+
+	interfaces, err := ipv6Interfaces(networkInterface)
+	if err != nil {
+		return false
+	}
+	for _, iface := range interfaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() == nil && ipNet.IP.IsGlobalUnicast() {
+				return true
+			}
+		}
+	}
+	return false
+}
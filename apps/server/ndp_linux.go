@@ -0,0 +1,291 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// NDPPingIP sends an IPv6 Neighbor Solicitation to an address and returns the hardware
+// address from the Neighbor Advertisement - the IPv6 counterpart of ARPPingIP.
+//
+// Like ARPPingIP it returns on the FIRST interface that gets an answer, so the caller
+// must verify the returned MAC address. Without interfaces, every active interface on
+// the address' link is tried. Requires CAP_NET_RAW.
+func NDPPingIP(ip string, ifaceName string) (net.HardwareAddr, error) {
+	target := net.ParseIP(ip)
+	if target == nil || target.To4() != nil {
+		return nil, fmt.Errorf("invalid IPv6 address: %s", ip)
+	}
+
+	interfaces, err := onLinkIPv6Interfaces(target, ifaceName)
+	if err != nil {
+		return nil, err
+	}
+	if len(interfaces) == 0 {
+		return nil, fmt.Errorf("%s is not on a directly connected IPv6 network", ip)
+	}
+
+	var lastErr error
+	for _, iface := range interfaces {
+		netLog.Debug("Sending Neighbor Solicitation for %s via %s", ip, iface.Name)
+		hwAddr, duration, err := solicitNeighbor(target, iface, NDPSolicitTimeout)
+		if err == nil {
+			netLog.Debug("NDP reply from %s: MAC %s via %s (%.3fms)", ip, hwAddr, iface.Name, duration.Seconds()*1000)
+			return hwAddr, nil
+		}
+		if errors.Is(err, unix.EPERM) {
+			netLog.Warning("NDP ping requires CAP_NET_RAW capability. Run with: sudo setcap cap_net_raw+ep /path/to/wolweb")
+			return nil, fmt.Errorf("NDP ping requires elevated permissions (CAP_NET_RAW)")
+		}
+		netLog.Debug("No NDP reply from %s via %s: %v", ip, iface.Name, err)
+		lastErr = err
+	}
+	return nil, fmt.Errorf("NDP ping failed: %w", lastErr)
+}
+
+// solicitNeighbor sends one Neighbor Solicitation on an interface and waits for the advertisement
+func solicitNeighbor(target net.IP, iface net.Interface, timeout time.Duration) (net.HardwareAddr, time.Duration, error) {
+	conn, err := openICMPv6Conn(iface, ndpTypeNeighborAdvertisement)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+
+	msg := marshalNeighborSolicitation(target, iface.HardwareAddr)
+	dst := &net.IPAddr{IP: solicitedNodeAddress(target), Zone: iface.Name}
+
+	start := time.Now()
+	conn.SetDeadline(start.Add(timeout))
+	if _, err := conn.WriteTo(msg, dst); err != nil {
+		return nil, 0, fmt.Errorf("failed to send Neighbor Solicitation: %w", err)
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return nil, 0, err
+		}
+		advertised, hwAddr, ok := parseNeighborAdvertisement(buf[:n])
+		if ok && advertised.Equal(target) && hwAddr != nil {
+			return hwAddr, time.Since(start), nil
+		}
+	}
+}
+
+// NDPDiscoverMAC looks for a MAC address among the IPv6 nodes on the link(s) - the IPv6
+// counterpart of the ARP subnet scan, since IPv6 subnets are too large to scan.
+// An echo request to ff02::1 (all nodes) makes every node answer, which fills the
+// neighbour table; nodes that did not end up in the table are solicited directly.
+// Returns the address of the node (global addresses preferred over link-local).
+func NDPDiscoverMAC(ctx context.Context, mac string, networkInterface string) (string, error) {
+	targetMAC := normalizeMACAddress(mac)
+
+	interfaces, err := ipv6Interfaces(networkInterface)
+	if err != nil {
+		return "", err
+	}
+
+	for _, iface := range interfaces {
+		responders, err := sharedAllNodesPing(ctx, iface)
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if err != nil {
+			netLog.Debug("IPv6 all-nodes ping on %s failed: %v", iface.Name, err)
+			continue
+		}
+		netLog.Debug("IPv6 all-nodes ping on %s: %d node(s) answered", iface.Name, len(responders))
+
+		if ips, err := GetIPsFromMAC(targetMAC, iface.Name); err == nil {
+			for _, ip := range ips {
+				if net.ParseIP(ip).To4() == nil {
+					return ip, nil
+				}
+			}
+		}
+
+		// Solicit the responders the kernel did not learn about
+		known := make(map[string]bool)
+		if neighbors, err := ListNeighbors(NeighborFilter{Interfaces: iface.Name, States: NUDValid, Family: 6}); err == nil {
+			for _, n := range neighbors {
+				known[n.IP.String()] = true
+			}
+		}
+		var (
+			mutex sync.Mutex
+			found string
+			wg    sync.WaitGroup
+		)
+		for _, responder := range responders {
+			if known[responder.String()] {
+				continue
+			}
+			wg.Add(1)
+			go func(ip net.IP) {
+				defer wg.Done()
+				hwAddr, _, err := solicitNeighbor(ip, iface, NDPSolicitTimeout)
+				if err == nil && normalizeMACAddress(hwAddr.String()) == targetMAC {
+					mutex.Lock()
+					found = ip.String()
+					mutex.Unlock()
+				}
+			}(responder)
+		}
+		wg.Wait()
+		if found != "" {
+			return found, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+	}
+
+	return "", fmt.Errorf("MAC %s not found among IPv6 nodes", targetMAC)
+}
+
+// DiscoverIPv6Neighbors pings all IPv6 nodes on an interface and returns the neighbour
+// table entries of the nodes that answered (plus the ones already known)
+func DiscoverIPv6Neighbors(ctx context.Context, iface net.Interface) ([]Neighbor, error) {
+	if _, err := sharedAllNodesPing(ctx, iface); err != nil {
+		return nil, err
+	}
+	return ListNeighbors(NeighborFilter{Interfaces: iface.Name, States: NUDValid, Family: 6})
+}
+
+// allNodesPing is an all-nodes ping of one interface, shared by concurrent discoveries
+// (e.g. a bulk ping of several offline hosts) and reused for NDPDiscoveryReuse
+type allNodesPing struct {
+	done       chan struct{}
+	started    time.Time
+	responders []net.IP
+	err        error
+}
+
+var (
+	allNodesPingsMutex sync.Mutex
+	allNodesPings      = make(map[string]*allNodesPing) // interface -> latest ping
+)
+
+// sharedAllNodesPing returns the responders of a recent all-nodes ping of an interface,
+// pinging again when there is none. A cancelled caller stops waiting; the ping itself
+// completes for the other callers.
+func sharedAllNodesPing(ctx context.Context, iface net.Interface) ([]net.IP, error) {
+	allNodesPingsMutex.Lock()
+	ping, exists := allNodesPings[iface.Name]
+	if !exists || time.Since(ping.started) > NDPDiscoveryReuse {
+		ping = &allNodesPing{done: make(chan struct{}), started: time.Now()}
+		allNodesPings[iface.Name] = ping
+		go func() {
+			ping.responders, ping.err = pingAllNodes(iface, NDPDiscoveryTimeout)
+			close(ping.done)
+		}()
+	}
+	allNodesPingsMutex.Unlock()
+
+	select {
+	case <-ping.done:
+		return ping.responders, ping.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// pingAllNodes sends an echo request to ff02::1 on an interface and returns the
+// addresses that answered within the timeout
+func pingAllNodes(iface net.Interface, timeout time.Duration) ([]net.IP, error) {
+	conn, err := openICMPv6Conn(iface, icmpv6TypeEchoReply)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Raw sockets see every echo reply on the system, so replies are matched by identifier
+	idBytes := make([]byte, 2)
+	rand.Read(idBytes)
+	echoID := binary.BigEndian.Uint16(idBytes)
+	msg := marshalICMPEcho(icmpv6TypeEchoRequest, echoID, 1, []byte("wol-web-discover"))
+	dst := &net.IPAddr{IP: net.ParseIP(IPv6AllNodesAddress), Zone: iface.Name}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.WriteTo(msg, dst); err != nil {
+		return nil, fmt.Errorf("failed to send echo request: %w", err)
+	}
+
+	var responders []net.IP
+	seen := make(map[string]bool)
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return responders, nil
+			}
+			return responders, err
+		}
+		msgType, id, seq, ok := parseICMPEcho(buf[:n])
+		if !ok || msgType != icmpv6TypeEchoReply || id != echoID || seq != 1 {
+			continue
+		}
+		ipAddr, ok := addr.(*net.IPAddr)
+		if !ok || seen[ipAddr.IP.String()] || isLocalIP(ipAddr.IP) {
+			continue
+		}
+		seen[ipAddr.IP.String()] = true
+		responders = append(responders, ipAddr.IP)
+	}
+}
+
+// openICMPv6Conn opens a raw ICMPv6 socket bound to an interface that only receives one
+// message type. Neighbor Discovery requires a hop limit of 255 on every message.
+func openICMPv6Conn(iface net.Interface, receiveType int) (net.PacketConn, error) {
+	fd, err := unix.Socket(unix.AF_INET6, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.IPPROTO_ICMPV6)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ICMPv6 socket: %w", err)
+	}
+
+	var filter unix.ICMPv6Filter
+	for i := range filter.Data {
+		filter.Data[i] = 0xffffffff
+	}
+	filter.Data[receiveType>>5] &^= 1 << (receiveType & 31)
+
+	var errs []string
+	if err := unix.SetsockoptICMPv6Filter(fd, unix.SOL_ICMPV6, unix.ICMPV6_FILTER, &filter); err != nil {
+		errs = append(errs, fmt.Sprintf("filter: %v", err))
+	}
+	if err := unix.SetsockoptInt(fd, unix.IPPROTO_IPV6, unix.IPV6_MULTICAST_HOPS, 255); err != nil {
+		errs = append(errs, fmt.Sprintf("multicast hops: %v", err))
+	}
+	if err := unix.SetsockoptInt(fd, unix.IPPROTO_IPV6, unix.IPV6_UNICAST_HOPS, 255); err != nil {
+		errs = append(errs, fmt.Sprintf("unicast hops: %v", err))
+	}
+	if err := unix.SetsockoptString(fd, unix.SOL_SOCKET, unix.SO_BINDTODEVICE, iface.Name); err != nil {
+		errs = append(errs, fmt.Sprintf("bind to interface %s: %v", iface.Name, err))
+	}
+	if len(errs) > 0 {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to set up ICMPv6 socket: %s", strings.Join(errs, "; "))
+	}
+
+	file := os.NewFile(uintptr(fd), "icmpv6")
+	conn, err := net.FilePacketConn(file)
+	file.Close() // FilePacketConn duplicates the descriptor
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ICMPv6 socket: %w", err)
+	}
+	return conn, nil
+}
@@ -0,0 +1,25 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// NDPPingIP is not supported on Windows
+func NDPPingIP(ip string, ifaceName string) (net.HardwareAddr, error) {
+	return nil, fmt.Errorf("NDP ping is not supported on Windows")
+}
+
+// NDPDiscoverMAC is not supported on Windows
+func NDPDiscoverMAC(ctx context.Context, mac string, networkInterface string) (string, error) {
+	return "", fmt.Errorf("IPv6 neighbour discovery is not supported on Windows")
+}
+
+// DiscoverIPv6Neighbors is not supported on Windows
+func DiscoverIPv6Neighbors(ctx context.Context, iface net.Interface) ([]Neighbor, error) {
+	return nil, fmt.Errorf("IPv6 neighbour discovery is not supported on Windows")
+}
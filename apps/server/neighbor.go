@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Neighbor is an entry of the kernel neighbour table (ARP for IPv4, NDP for IPv6)
+type Neighbor struct {
+	IP        net.IP
+	MAC       net.HardwareAddr
+	Interface string
+	State     uint16 // NUD* state bits
+}
+
+// Neighbour Unreachability Detection states (values as defined by the Linux kernel)
+const (
+	NUDIncomplete = 0x01
+	NUDReachable  = 0x02
+	NUDStale      = 0x04
+	NUDDelay      = 0x08
+	NUDProbe      = 0x10
+	NUDFailed     = 0x20
+	NUDNoARP      = 0x40
+	NUDPermanent  = 0x80
+
+	// NUDValid are the states in which an entry holds a usable hardware address
+	NUDValid = NUDReachable | NUDStale | NUDDelay | NUDProbe | NUDPermanent
+)
+
+// NeighborFilter selects neighbour table entries
+type NeighborFilter struct {
+	Interfaces string // Comma-separated interface names (empty = all interfaces)
+	States     uint16 // NUD state mask (0 = any state)
+	Family     int    // 4 or 6 (0 = both)
+}
+
+// matches reports whether a neighbour passes the interface, state and family filters
+func (f NeighborFilter) matches(n Neighbor) bool {
+	if f.States != 0 && n.State&f.States == 0 {
+		return false
+	}
+	if f.Family == 4 && n.IP.To4() == nil {
+		return false
+	}
+	if f.Family == 6 && n.IP.To4() != nil {
+		return false
+	}
+	if f.Interfaces == "" {
+		return true
+	}
+	for _, iface := range strings.Split(f.Interfaces, ",") {
+		if strings.TrimSpace(iface) == n.Interface {
+			return true
+		}
+	}
+	return false
+}
+
+// neighborStateName returns a readable name of the most significant NUD state bit
+func neighborStateName(state uint16) string {
+	switch {
+	case state&NUDPermanent != 0:
+		return "PERMANENT"
+	case state&NUDNoARP != 0:
+		return "NOARP"
+	case state&NUDReachable != 0:
+		return "REACHABLE"
+	case state&NUDStale != 0:
+		return "STALE"
+	case state&NUDDelay != 0:
+		return "DELAY"
+	case state&NUDProbe != 0:
+		return "PROBE"
+	case state&NUDFailed != 0:
+		return "FAILED"
+	case state&NUDIncomplete != 0:
+		return "INCOMPLETE"
+	}
+	return "NONE"
+}
+
+// GetIPsFromMAC finds the addresses of a MAC address in the neighbour table (passive lookup only).
+// Only entries with a usable hardware address on the given interface(s) are considered.
+// IPv4 addresses come first, then global and link-local IPv6 addresses; within each group
+// REACHABLE entries come before STALE ones.
+func GetIPsFromMAC(mac string, networkInterface string) ([]string, error) {
+	neighbors, err := ListNeighbors(NeighborFilter{Interfaces: networkInterface, States: NUDValid})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read neighbour table: %w", err)
+	}
+
+	targetMAC := normalizeMACAddress(mac)
+	var matches []Neighbor
+	for _, n := range neighbors {
+		if normalizeMACAddress(n.MAC.String()) == targetMAC {
+			matches = append(matches, n)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("IP address not found for MAC %s in ARP table", targetMAC)
+	}
+
+	rank := func(n Neighbor) int {
+		r := 0
+		switch {
+		case n.IP.To4() != nil:
+		case !n.IP.IsLinkLocalUnicast():
+			r = 2
+		default:
+			r = 4
+		}
+		if n.State&NUDReachable == 0 {
+			r++
+		}
+		return r
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return rank(matches[i]) < rank(matches[j]) })
+
+	ips := make([]string, len(matches))
+	for i, n := range matches {
+		netLog.Debug("Neighbour table: MAC %s at %s on %s (%s)", targetMAC, n.IP, n.Interface, neighborStateName(n.State))
+		ips[i] = n.IP.String()
+	}
+	return ips, nil
+}
+
+// GetIPFromMAC finds the preferred routable address of a MAC address in the neighbour table:
+// IPv4 first, then global IPv6 (link-local addresses cannot be used without an interface)
+func GetIPFromMAC(mac string, networkInterface string) (string, error) {
+	ips, err := GetIPsFromMAC(mac, networkInterface)
+	if err != nil {
+		return "", err
+	}
+	for _, ip := range ips {
+		if !net.ParseIP(ip).IsLinkLocalUnicast() {
+			return ip, nil
+		}
+	}
+	return "", fmt.Errorf("only link-local addresses found for MAC %s", normalizeMACAddress(mac))
+}
+
+// pingNeighbor checks that an address answers on the local link and returns its hardware
+// address: ARP for IPv4, Neighbor Solicitation for IPv6
+func pingNeighbor(ctx context.Context, ip string, networkInterface string) (net.HardwareAddr, error) {
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		return NDPPingIP(ip, networkInterface)
+	}
+	return ARPPingIP(ctx, ip, networkInterface)
+}
+
+// GetMACFromARP returns the MAC address of an IP address from the neighbour table
+func GetMACFromARP(ip string, networkInterface string) (string, error) {
+	target := net.ParseIP(ip)
+	if target == nil {
+		return "", fmt.Errorf("invalid IP address: %s", ip)
+	}
+
+	neighbors, err := ListNeighbors(NeighborFilter{Interfaces: networkInterface, States: NUDValid})
+	if err != nil {
+		return "", fmt.Errorf("failed to read neighbour table: %w", err)
+	}
+	for _, n := range neighbors {
+		if n.IP.Equal(target) && len(n.MAC) > 0 {
+			return strings.ToLower(n.MAC.String()), nil
+		}
+	}
+	return "", fmt.Errorf("MAC address not found in ARP table")
+}
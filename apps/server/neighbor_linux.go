@@ -0,0 +1,200 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// netlinkSeq numbers netlink requests so replies can be matched
+var netlinkSeq uint32
+
+// ListNeighbors reads the kernel neighbour table (IPv4 and IPv6) over rtnetlink
+func ListNeighbors(filter NeighborFilter) ([]Neighbor, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list interfaces: %w", err)
+	}
+	names := make(map[int32]string, len(interfaces))
+	for _, iface := range interfaces {
+		names[int32(iface.Index)] = iface.Name
+	}
+
+	messages, err := netlinkRequest(unix.RTM_GETNEIGH, unix.NLM_F_DUMP, marshalNdMsg(unix.AF_UNSPEC, 0), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var neighbors []Neighbor
+	for _, msg := range messages {
+		if msg.Header.Type != unix.RTM_NEWNEIGH || len(msg.Data) < unix.SizeofNdMsg {
+			continue
+		}
+
+		family := msg.Data[0]
+		if family != unix.AF_INET && family != unix.AF_INET6 {
+			continue // Bridge FDB and other families
+		}
+		n := Neighbor{
+			Interface: names[int32(binary.NativeEndian.Uint32(msg.Data[4:8]))],
+			State:     binary.NativeEndian.Uint16(msg.Data[8:10]),
+		}
+
+		for attrType, value := range parseRouteAttrs(msg.Data[unix.SizeofNdMsg:]) {
+			switch attrType {
+			case unix.NDA_DST:
+				n.IP = net.IP(value)
+			case unix.NDA_LLADDR:
+				n.MAC = net.HardwareAddr(value)
+			}
+		}
+		if n.IP == nil || !filter.matches(n) {
+			continue
+		}
+		neighbors = append(neighbors, n)
+	}
+	return neighbors, nil
+}
+
+// flushNeighbor deletes all dynamic neighbour entries for an IP address (like "ip neigh flush to IP").
+// Permanent and NOARP entries are kept. Requires CAP_NET_ADMIN.
+func flushNeighbor(ip net.IP) (int, error) {
+	neighbors, err := ListNeighbors(NeighborFilter{})
+	if err != nil {
+		return 0, err
+	}
+
+	family := byte(unix.AF_INET6)
+	dst := ip.To16()
+	if ip4 := ip.To4(); ip4 != nil {
+		family, dst = unix.AF_INET, ip4
+	}
+
+	flushed := 0
+	for _, n := range neighbors {
+		if !n.IP.Equal(ip) || n.State&(NUDPermanent|NUDNoARP) != 0 {
+			continue
+		}
+		iface, err := net.InterfaceByName(n.Interface)
+		if err != nil {
+			continue
+		}
+
+		attr := marshalRouteAttr(unix.NDA_DST, dst)
+		_, err = netlinkRequest(unix.RTM_DELNEIGH, unix.NLM_F_ACK, marshalNdMsg(family, int32(iface.Index)), attr)
+		if err != nil && !errors.Is(err, unix.ENOENT) {
+			return flushed, fmt.Errorf("failed to delete neighbour %s on %s: %w", ip, n.Interface, err)
+		}
+		flushed++
+	}
+	return flushed, nil
+}
+
+// netlinkRequest sends one rtnetlink request and collects the replies until the dump
+// ends or the kernel acknowledges the request
+func netlinkRequest(msgType uint16, flags uint16, payload []byte, attrs []byte) ([]syscall.NetlinkMessage, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open netlink socket: %w", err)
+	}
+	defer unix.Close(fd)
+
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("failed to bind netlink socket: %w", err)
+	}
+
+	seq := atomic.AddUint32(&netlinkSeq, 1)
+	length := unix.SizeofNlMsghdr + len(payload) + len(attrs)
+	req := make([]byte, unix.SizeofNlMsghdr, length)
+	binary.NativeEndian.PutUint32(req[0:4], uint32(length))
+	binary.NativeEndian.PutUint16(req[4:6], msgType)
+	binary.NativeEndian.PutUint16(req[6:8], unix.NLM_F_REQUEST|flags)
+	binary.NativeEndian.PutUint32(req[8:12], seq)
+	req = append(req, payload...)
+	req = append(req, attrs...)
+
+	if err := unix.Sendto(fd, req, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("failed to send netlink request: %w", err)
+	}
+
+	var result []syscall.NetlinkMessage
+	buf := make([]byte, 32*1024)
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read netlink reply: %w", err)
+		}
+		messages, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return nil, fmt.Errorf("invalid netlink reply: %w", err)
+		}
+
+		for _, msg := range messages {
+			if msg.Header.Seq != seq {
+				continue
+			}
+			switch msg.Header.Type {
+			case unix.NLMSG_DONE:
+				return result, nil
+			case unix.NLMSG_ERROR:
+				if len(msg.Data) < 4 {
+					return nil, fmt.Errorf("truncated netlink error")
+				}
+				if code := int32(binary.NativeEndian.Uint32(msg.Data[0:4])); code != 0 {
+					return nil, unix.Errno(-code)
+				}
+				return result, nil // Acknowledgement
+			}
+			result = append(result, msg)
+			if msg.Header.Flags&unix.NLM_F_MULTI == 0 && flags&unix.NLM_F_ACK == 0 {
+				return result, nil
+			}
+		}
+	}
+}
+
+// marshalNdMsg encodes the fixed neighbour message header (struct ndmsg)
+func marshalNdMsg(family byte, ifindex int32) []byte {
+	b := make([]byte, unix.SizeofNdMsg)
+	b[0] = family
+	binary.NativeEndian.PutUint32(b[4:8], uint32(ifindex))
+	return b
+}
+
+// marshalRouteAttr encodes a single rtnetlink attribute, padded to 4 bytes
+func marshalRouteAttr(attrType uint16, value []byte) []byte {
+	length := unix.SizeofRtAttr + len(value)
+	b := make([]byte, (length+unix.RTA_ALIGNTO-1) & ^(unix.RTA_ALIGNTO-1))
+	binary.NativeEndian.PutUint16(b[0:2], uint16(length))
+	binary.NativeEndian.PutUint16(b[2:4], attrType)
+	copy(b[unix.SizeofRtAttr:], value)
+	return b
+}
+
+// parseRouteAttrs decodes the attributes following a fixed rtnetlink header
+func parseRouteAttrs(b []byte) map[uint16][]byte {
+	attrs := make(map[uint16][]byte)
+	for len(b) >= unix.SizeofRtAttr {
+		length := int(binary.NativeEndian.Uint16(b[0:2]))
+		attrType := binary.NativeEndian.Uint16(b[2:4])
+		if length < unix.SizeofRtAttr || length > len(b) {
+			break
+		}
+		attrs[attrType] = b[unix.SizeofRtAttr:length]
+
+		aligned := (length + unix.RTA_ALIGNTO - 1) & ^(unix.RTA_ALIGNTO - 1)
+		if aligned > len(b) {
+			break
+		}
+		b = b[aligned:]
+	}
+	return attrs
+}
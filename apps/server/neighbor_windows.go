@@ -0,0 +1,62 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+)
+
+// ListNeighbors reads the IPv4 ARP table from "arp -a" (IPv6 neighbours are not listed on Windows).
+// Windows does not expose NUD states here: dynamic entries are reported as REACHABLE,
+// static entries as PERMANENT.
+func ListNeighbors(filter NeighborFilter) ([]Neighbor, error) {
+	output, err := exec.Command("arp", "-a").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run arp command: %w", err)
+	}
+
+	// "Interface: 192.168.1.5 --- 0xb" headers name interfaces by address
+	interfaceNames := make(map[string]string)
+	if interfaces, err := net.Interfaces(); err == nil {
+		for _, iface := range interfaces {
+			addrs, _ := iface.Addrs()
+			for _, addr := range addrs {
+				if ipNet, ok := addr.(*net.IPNet); ok {
+					interfaceNames[ipNet.IP.String()] = iface.Name
+				}
+			}
+		}
+	}
+
+	var neighbors []Neighbor
+	currentInterface := ""
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && strings.HasPrefix(fields[0], "Interface:") {
+			currentInterface = interfaceNames[fields[1]]
+			continue
+		}
+		if len(fields) < 3 {
+			continue
+		}
+
+		ip := net.ParseIP(fields[0])
+		mac, err := net.ParseMAC(fields[1])
+		if ip == nil || err != nil || mac[0]&1 != 0 {
+			continue // Header line, broadcast or multicast entry
+		}
+
+		n := Neighbor{IP: ip, MAC: mac, Interface: currentInterface, State: NUDReachable}
+		if strings.EqualFold(fields[2], "static") {
+			n.State = NUDPermanent
+		}
+		if filter.matches(n) {
+			neighbors = append(neighbors, n)
+		}
+	}
+	return neighbors, nil
+}
@@ -1,19 +1,24 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"net"
 	"os/exec"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // PingHost pings a host and returns true if it's reachable
-// Uses Linux ping command format
 func PingHost(host string, timeout int) bool {
-	cmd := exec.Command("ping", "-c", "1", "-W", fmt.Sprintf("%d", timeout), host)
-	err := cmd.Run()
-	return err == nil
+	_, ok := PingHostRTT(context.Background(), host, time.Duration(timeout)*time.Second, "")
+	return ok
 }
 
 // PingHostWithInterface pings a host using a specific network interface
@@ -21,119 +26,106 @@ func PingHost(host string, timeout int) bool {
 // WARNING: When multiple interfaces are specified with overlapping IP ranges,
 // this function may succeed on the wrong network segment. See arping_helpers_linux.go
 // ARPPingIP function documentation for details about this limitation.
-func PingHostWithInterface(host string, timeout int, networkInterface string) bool {
-	// If no interface specified, use default ping
-	if networkInterface == "" {
-		return PingHost(host, timeout)
-	}
+func PingHostWithInterface(ctx context.Context, host string, timeout int, networkInterface string) bool {
+	_, ok := PingHostRTT(ctx, host, time.Duration(timeout)*time.Second, networkInterface)
+	return ok
+}
 
-	// Parse multiple interfaces (comma-separated) - tries each until success
-	// WARNING: Returns on first successful ping - may be wrong device if IPs overlap
-	interfaces := strings.Split(networkInterface, ",")
-	for _, iface := range interfaces {
-		iface = strings.TrimSpace(iface)
-		if iface == "" {
-			continue
+// PingHostRTT sends an ICMP echo request and returns the round-trip time.
+//
+// Native ICMP sockets are used where available (see icmp_linux.go); otherwise the
+// system ping binary is executed. With multiple interfaces (comma-separated) each is
+// tried until one succeeds, then an unbound ping is tried as a last resort.
+func PingHostRTT(ctx context.Context, host string, timeout time.Duration, networkInterface string) (rtt time.Duration, ok bool) {
+	_, span := startSpan(ctx, "PingHostRTT", attribute.String("network.peer.address", host), attribute.String("network.interface.name", networkInterface))
+	method := "icmp"
+	defer func() {
+		span.SetAttributes(attribute.String("ping.method", method), attribute.Bool("icmp.reply", ok))
+		endSpan(span, nil)
+	}()
+
+	var interfaces []string
+	for _, iface := range strings.Split(networkInterface, ",") {
+		if iface = strings.TrimSpace(iface); iface != "" {
+			interfaces = append(interfaces, iface)
 		}
+	}
+	ip := net.ParseIP(host)
 
-		// Try ping with this specific interface
-		cmd := exec.Command("ping", "-c", "1", "-W", fmt.Sprintf("%d", timeout), "-I", iface, host)
-		if err := cmd.Run(); err == nil {
-			return true // Success with this interface - WARNING: see function documentation
+	// Link-local IPv6 addresses need an interface - try every IPv6 interface instead of default routing
+	if len(interfaces) == 0 && ip != nil && ip.To4() == nil && ip.IsLinkLocalUnicast() {
+		if ipv6Ifaces, err := ipv6Interfaces(""); err == nil {
+			for _, iface := range ipv6Ifaces {
+				interfaces = append(interfaces, iface.Name)
+			}
 		}
 	}
 
-	// If all interface-specific pings failed, fallback to default ping
-	return PingHost(host, timeout)
-}
-
-// GetMACFromARP tries to get MAC address from ARP table for a given IP
-// Uses Linux arp command format
-func GetMACFromARP(ip string) (string, error) {
-	cmd := exec.Command("arp", "-n", ip)
+	// Fall back to default routing if all interface-specific pings fail
+	// WARNING: Returns on first successful ping - may be wrong device if IPs overlap
+	interfaces = append(interfaces, "")
 
-	output, err := cmd.Output()
-	if err != nil {
-		return "", fmt.Errorf("failed to run arp command: %w", err)
+	for _, iface := range interfaces {
+		if ip != nil {
+			rtt, err := pingICMP(ip, timeout, iface)
+			if err == nil {
+				return rtt, true
+			}
+			if !errors.Is(err, errICMPUnavailable) {
+				continue
+			}
+			netLog.Debug("Native ICMP unavailable (%v) - using system ping", err)
+		}
+		method = "command"
+		if rtt, ok := pingWithCommand(host, timeout, iface); ok {
+			return rtt, true
+		}
 	}
-
-	// Parse ARP output to extract MAC address
-	return parseMACFromARPOutput(string(output))
+	return 0, false
 }
 
-// GetIPFromMAC tries to find IP address from MAC in ARP table (passive lookup only)
-// Uses Linux arp command format
-func GetIPFromMAC(mac string) (string, error) {
-	// Normalize the MAC address for comparison
-	targetMAC := normalizeMACAddress(mac)
-
-	cmd := exec.Command("arp", "-n")
+// pingTimeRegex extracts the round-trip time from ping output ("time=0.42 ms")
+var pingTimeRegex = regexp.MustCompile(`time[=<]([0-9.]+) ?ms`)
 
-	output, err := cmd.Output()
-	if err != nil {
-		return "", fmt.Errorf("failed to run arp command: %w", err)
+// pingWithCommand pings a host with the system ping binary
+// Uses Linux ping command format
+func pingWithCommand(host string, timeout time.Duration, iface string) (time.Duration, bool) {
+	seconds := int((timeout + time.Second - 1) / time.Second)
+	args := []string{"-c", "1", "-W", fmt.Sprintf("%d", seconds)}
+	if iface != "" {
+		args = append(args, "-I", iface)
 	}
+	args = append(args, host)
 
-	// Parse full ARP table to find IP for this MAC
-	return parseIPFromARPOutput(string(output), targetMAC)
-}
-
-// parseIPFromARPOutput scans ARP table for an IP with matching MAC
-// Uses Linux ARP format: "192.168.1.100 ether aa:bb:cc:dd:ee:ff C eth0"
-func parseIPFromARPOutput(output, targetMAC string) (string, error) {
-	lines := strings.Split(output, "\n")
-
-	ipRegex := regexp.MustCompile(`(\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3})`)
-	macRegex := regexp.MustCompile(`([0-9a-fA-F]{2}:[0-9a-fA-F]{2}:[0-9a-fA-F]{2}:[0-9a-fA-F]{2}:[0-9a-fA-F]{2}:[0-9a-fA-F]{2})`)
-
-	for _, line := range lines {
-		// Skip empty lines and headers
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
-
-		// Find MAC in this line
-		macMatches := macRegex.FindStringSubmatch(line)
-		if len(macMatches) < 2 {
-			continue
-		}
-
-		// Normalize found MAC
-		foundMAC := normalizeMACAddress(macMatches[1])
-
-		// Check if this is the MAC we're looking for
-		if foundMAC == targetMAC {
-			// Find IP in this line
-			ipMatches := ipRegex.FindStringSubmatch(line)
-			if len(ipMatches) >= 2 {
-				return ipMatches[1], nil
-			}
-		}
+	output, err := exec.Command("ping", args...).Output()
+	if err != nil {
+		return 0, false
 	}
 
-	return "", fmt.Errorf("IP address not found for MAC %s in ARP table", targetMAC)
-}
-
-// parseMACFromARPOutput parses MAC address from Linux ARP output
-// Linux ARP format: "192.168.1.100 ether aa:bb:cc:dd:ee:ff C eth0"
-func parseMACFromARPOutput(output string) (string, error) {
-	lines := strings.Split(output, "\n")
-
-	macRegex := regexp.MustCompile(`([0-9a-fA-F]{2}:[0-9a-fA-F]{2}:[0-9a-fA-F]{2}:[0-9a-fA-F]{2}:[0-9a-fA-F]{2}:[0-9a-fA-F]{2})`)
-
-	for _, line := range lines {
-		if matches := macRegex.FindStringSubmatch(line); len(matches) > 1 {
-			return strings.ToLower(matches[1]), nil
+	// The RTT is unknown (0) if the output format is not recognized
+	var rtt time.Duration
+	if matches := pingTimeRegex.FindSubmatch(output); len(matches) == 2 {
+		if ms, err := strconv.ParseFloat(string(matches[1]), 64); err == nil {
+			rtt = time.Duration(ms * float64(time.Millisecond))
 		}
 	}
-
-	return "", fmt.Errorf("MAC address not found in ARP table")
+	return rtt, true
 }
 
 // SendWakeOnLan sends a WOL packet using the specified network interface(s)
 // When multiple interfaces are specified, broadcasts to ALL of them (not just first successful)
-func SendWakeOnLanWithInterface(mac, targetIP string, port int, networkInterfaces string) error {
-	Debug("SendWakeOnLan called: MAC=%s, Target=%s:%d, Interfaces=%s",
+func SendWakeOnLanWithInterface(ctx context.Context, mac, targetIP string, port int, networkInterfaces string) (err error) {
+	_, span := startSpan(ctx, "SendWakeOnLan",
+		attribute.String("host.mac", mac),
+		attribute.String("network.peer.address", targetIP),
+		attribute.Int("network.peer.port", port),
+		attribute.String("network.interface.name", networkInterfaces))
+	defer func() { endSpan(span, err) }()
+
+	// Lets magic packet listeners recognize the packet when it arrives back
+	sentMagicPackets.record(mac)
+
+	netLog.Debug("SendWakeOnLan called: MAC=%s, Target=%s:%d, Interfaces=%s",
 		mac, targetIP, port, func() string {
 			if networkInterfaces == "" {
 				return "(all)"
@@ -141,9 +133,14 @@ func SendWakeOnLanWithInterface(mac, targetIP string, port int, networkInterface
 			return networkInterfaces
 		}())
 
+	// IPv6 has no broadcast - magic packets go to a multicast group (usually ff02::1) instead
+	if ip := net.ParseIP(targetIP); ip != nil && ip.To4() == nil {
+		return sendWakeOnLanIPv6(mac, ip, port, networkInterfaces)
+	}
+
 	// If no specific interface is specified, use the default behavior
 	if networkInterfaces == "" {
-		Debug("Using default WoL behavior (all interfaces)")
+		netLog.Debug("Using default WoL behavior (all interfaces)")
 		return sendWakeOnLanDefault(mac, targetIP, port)
 	}
 
@@ -152,7 +149,7 @@ func SendWakeOnLanWithInterface(mac, targetIP string, port int, networkInterface
 	var errors []string
 	successCount := 0
 
-	Debug("Attempting to broadcast WoL packet via %d interface(s)", len(interfaces))
+	netLog.Debug("Attempting to broadcast WoL packet via %d interface(s)", len(interfaces))
 
 	for _, networkInterface := range interfaces {
 		networkInterface = strings.TrimSpace(networkInterface)
@@ -160,14 +157,14 @@ func SendWakeOnLanWithInterface(mac, targetIP string, port int, networkInterface
 			continue
 		}
 
-		Debug("Trying interface: %s", networkInterface)
+		netLog.Debug("Trying interface: %s", networkInterface)
 
 		// Get the interface
 		iface, err := net.InterfaceByName(networkInterface)
 		if err != nil {
 			errMsg := fmt.Sprintf("interface %s not found: %v", networkInterface, err)
 			errors = append(errors, errMsg)
-			Error("%s", errMsg)
+			netLog.Error("%s", errMsg)
 			continue
 		}
 
@@ -176,7 +173,7 @@ func SendWakeOnLanWithInterface(mac, targetIP string, port int, networkInterface
 		if err != nil {
 			errMsg := fmt.Sprintf("failed to get addresses for interface %s: %v", networkInterface, err)
 			errors = append(errors, errMsg)
-			Error("%s", errMsg)
+			netLog.Error("%s", errMsg)
 			continue
 		}
 
@@ -193,35 +190,35 @@ func SendWakeOnLanWithInterface(mac, targetIP string, port int, networkInterface
 		if localIP == nil {
 			errMsg := fmt.Sprintf("no IPv4 address found on interface %s", networkInterface)
 			errors = append(errors, errMsg)
-			Error("%s", errMsg)
+			netLog.Error("%s", errMsg)
 			continue
 		}
 
-		Debug("Found local IP %s on interface %s", localIP.String(), networkInterface)
+		netLog.Debug("Found local IP %s on interface %s", localIP.String(), networkInterface)
 
 		// Send from this interface (continue to other interfaces even on success)
 		err = sendWakeOnLanFromIP(mac, targetIP, port, localIP.String())
 		if err == nil {
 			successCount++
-			Debug("Success WoL packet sent via interface %s (IP: %s) to %s:%d for MAC %s",
+			netLog.Debug("Success WoL packet sent via interface %s (IP: %s) to %s:%d for MAC %s",
 				networkInterface, localIP.String(), targetIP, port, mac)
 		} else {
 			errMsg := fmt.Sprintf("failed to send via interface %s: %v", networkInterface, err)
 			errors = append(errors, errMsg)
-			Error("%s", errMsg)
+			netLog.Error("%s", errMsg)
 		}
 	}
 
 	// Return success if at least one interface succeeded
 	if successCount > 0 {
-		Debug("Success WoL packet broadcast to %d/%d interface(s) for MAC %s",
+		netLog.Debug("Success WoL packet broadcast to %d/%d interface(s) for MAC %s",
 			successCount, len(interfaces), mac)
 		return nil
 	}
 
 	// All interfaces failed
 	if len(errors) > 0 {
-		Error("WoL packet failed on all %d interface(s). Errors: %v", len(interfaces), strings.Join(errors, "; "))
+		netLog.Error("WoL packet failed on all %d interface(s). Errors: %v", len(interfaces), strings.Join(errors, "; "))
 		return fmt.Errorf("all interfaces failed: %s", strings.Join(errors, "; "))
 	}
 	return fmt.Errorf("no valid interfaces found")
@@ -229,30 +226,30 @@ func SendWakeOnLanWithInterface(mac, targetIP string, port int, networkInterface
 
 // sendWakeOnLanDefault uses the default WOL implementation
 func sendWakeOnLanDefault(mac, targetIP string, port int) error {
-	Debug("sendWakeOnLanDefault: Trying all available interfaces")
+	netLog.Debug("sendWakeOnLanDefault: Trying all available interfaces")
 
 	// Create magic packet
 	magicPacket, err := createMagicPacket(mac)
 	if err != nil {
-		Error("Failed to create magic packet for MAC %s: %v", mac, err)
+		netLog.Error("Failed to create magic packet for MAC %s: %v", mac, err)
 		return fmt.Errorf("failed to create magic packet: %w", err)
 	}
 
 	// Resolve the broadcast address using udp4 to ensure IPv4
 	addr, err := net.ResolveUDPAddr("udp4", fmt.Sprintf("%s:%d", targetIP, port))
 	if err != nil {
-		Error("Failed to resolve UDP address %s:%d: %v", targetIP, port, err)
+		netLog.Error("Failed to resolve UDP address %s:%d: %v", targetIP, port, err)
 		return fmt.Errorf("failed to resolve UDP address %s:%d: %w", targetIP, port, err)
 	}
 
 	// Get a list of all network interfaces
 	interfaces, err := net.Interfaces()
 	if err != nil {
-		Error("Failed to get network interfaces: %v", err)
+		netLog.Error("Failed to get network interfaces: %v", err)
 		return fmt.Errorf("failed to get network interfaces: %w", err)
 	}
 
-	Debug("Found %d network interfaces", len(interfaces))
+	netLog.Debug("Found %d network interfaces", len(interfaces))
 
 	// Try to send from each active interface
 	var lastErr error
@@ -262,14 +259,14 @@ func sendWakeOnLanDefault(mac, targetIP string, port int) error {
 	for _, iface := range interfaces {
 		// Skip down or loopback interfaces
 		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
-			Debug("Skipping interface %s (down or loopback)", iface.Name)
+			netLog.Debug("Skipping interface %s (down or loopback)", iface.Name)
 			continue
 		}
 
 		// Get addresses for this interface
 		addrs, err := iface.Addrs()
 		if err != nil {
-			Debug("Failed to get addresses for interface %s: %v", iface.Name, err)
+			netLog.Debug("Failed to get addresses for interface %s: %v", iface.Name, err)
 			continue
 		}
 
@@ -288,13 +285,13 @@ func sendWakeOnLanDefault(mac, targetIP string, port int) error {
 				Port: 0,
 			}
 
-			Debug("Attempting to send from interface %s (IP: %s)", iface.Name, ipNet.IP.String())
+			netLog.Debug("Attempting to send from interface %s (IP: %s)", iface.Name, ipNet.IP.String())
 
 			// Create UDP connection bound to this specific interface
 			conn, err := net.DialUDP("udp4", localAddr, addr)
 			if err != nil {
 				lastErr = err
-				Debug("Failed to create UDP connection from %s: %v", ipNet.IP.String(), err)
+				netLog.Debug("Failed to create UDP connection from %s: %v", ipNet.IP.String(), err)
 				continue
 			}
 
@@ -305,29 +302,29 @@ func sendWakeOnLanDefault(mac, targetIP string, port int) error {
 			if err == nil {
 				// Success! Packet sent
 				successCount++
-				Debug("Successfully sent WoL packet from interface %s (IP: %s)", iface.Name, ipNet.IP.String())
+				netLog.Debug("Successfully sent WoL packet from interface %s (IP: %s)", iface.Name, ipNet.IP.String())
 			} else {
 				lastErr = err
-				Debug("Failed to send from interface %s (IP: %s): %v", iface.Name, ipNet.IP.String(), err)
+				netLog.Debug("Failed to send from interface %s (IP: %s): %v", iface.Name, ipNet.IP.String(), err)
 			}
 		}
 	}
 
 	// If at least one send succeeded, return success
 	if successCount > 0 {
-		Debug("Success WoL packet sent from %d interface(s) to %s:%d for MAC %s",
+		netLog.Debug("Success WoL packet sent from %d interface(s) to %s:%d for MAC %s",
 			successCount, targetIP, port, mac)
 		return nil
 	}
 
 	// If we got here, all interfaces failed
 	if lastErr != nil {
-		Error("Failed to send WoL packet from any interface. Tried: %v. Last error: %v",
+		netLog.Error("Failed to send WoL packet from any interface. Tried: %v. Last error: %v",
 			triedInterfaces, lastErr)
 		return fmt.Errorf("failed to send magic packet from any interface (tried %d): %w", len(triedInterfaces), lastErr)
 	}
 
-	Error("No suitable network interface found for sending WoL packet")
+	netLog.Error("No suitable network interface found for sending WoL packet")
 	return fmt.Errorf("no suitable network interface found")
 }
 
@@ -395,6 +392,103 @@ func sendWakeOnLanFromIP(mac, targetIP string, port int, localIP string) error {
 	return nil
 }
 
+// sendWakeOnLanIPv6 sends a WOL packet to an IPv6 target. Multicast groups (e.g. ff02::1,
+// all link-local nodes) and link-local addresses are scoped to a link, so the packet is sent
+// on every selected interface - or every active IPv6 interface when none is selected.
+// Other unicast targets are routed normally.
+func sendWakeOnLanIPv6(mac string, target net.IP, port int, networkInterfaces string) error {
+	magicPacket, err := createMagicPacket(mac)
+	if err != nil {
+		netLog.Error("Failed to create magic packet for MAC %s: %v", mac, err)
+		return fmt.Errorf("failed to create magic packet: %w", err)
+	}
+
+	if !target.IsMulticast() && !target.IsLinkLocalUnicast() {
+		return sendUDP6(magicPacket, &net.UDPAddr{IP: target, Port: port})
+	}
+
+	interfaces, err := ipv6Interfaces(networkInterfaces)
+	if err != nil {
+		return err
+	}
+
+	var errors []string
+	successCount := 0
+	for _, iface := range interfaces {
+		addr := &net.UDPAddr{IP: target, Port: port, Zone: iface.Name}
+		if err := sendUDP6(magicPacket, addr); err != nil {
+			errors = append(errors, fmt.Sprintf("failed to send via interface %s: %v", iface.Name, err))
+			netLog.Debug("Failed to send WoL packet to %s: %v", addr, err)
+			continue
+		}
+		successCount++
+		netLog.Debug("Success WoL packet sent to %s for MAC %s", addr, mac)
+	}
+
+	if successCount > 0 {
+		return nil
+	}
+	if len(errors) > 0 {
+		netLog.Error("WoL packet failed on all %d IPv6 interface(s). Errors: %v", len(interfaces), strings.Join(errors, "; "))
+		return fmt.Errorf("all interfaces failed: %s", strings.Join(errors, "; "))
+	}
+	return fmt.Errorf("no IPv6-capable network interface found")
+}
+
+// sendUDP6 sends a single IPv6 UDP datagram
+func sendUDP6(payload []byte, addr *net.UDPAddr) error {
+	conn, err := net.DialUDP("udp6", nil, addr)
+	if err != nil {
+		return fmt.Errorf("failed to create UDP connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(payload); err != nil {
+		return fmt.Errorf("failed to send magic packet: %w", err)
+	}
+	return nil
+}
+
+// ipv6Interfaces returns the selected interfaces (comma-separated), or all active
+// non-loopback interfaces with an IPv6 address when none are selected
+func ipv6Interfaces(networkInterfaces string) ([]net.Interface, error) {
+	var result []net.Interface
+	if networkInterfaces != "" {
+		for _, name := range strings.Split(networkInterfaces, ",") {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			iface, err := net.InterfaceByName(name)
+			if err != nil {
+				return nil, fmt.Errorf("interface %s not found: %w", name, err)
+			}
+			result = append(result, *iface)
+		}
+		return result, nil
+	}
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network interfaces: %w", err)
+	}
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() == nil {
+				result = append(result, iface)
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
 // createMagicPacket creates a Wake-on-LAN magic packet
 func createMagicPacket(mac string) ([]byte, error) {
 	// Remove colons and hyphens from MAC address
@@ -441,3 +535,34 @@ func createMagicPacket(mac string) ([]byte, error) {
 
 	return packet, nil
 }
+
+// parseMagicPacket extracts the target MAC address from a Wake-on-LAN magic packet
+// (the inverse of createMagicPacket). The synchronization stream may appear anywhere
+// in the payload; a trailing SecureOn password is ignored.
+func parseMagicPacket(packet []byte) (string, error) {
+	sync := []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+
+	for i := 0; i+102 <= len(packet); i++ {
+		if !bytes.Equal(packet[i:i+6], sync) {
+			continue
+		}
+
+		// 16 repetitions of the MAC address must follow
+		mac := packet[i+6 : i+12]
+		if bytes.Equal(mac, sync) {
+			continue // Longer synchronization stream - try the next offset
+		}
+		valid := true
+		for r := 1; r < 16; r++ {
+			if !bytes.Equal(packet[i+6+r*6:i+12+r*6], mac) {
+				valid = false
+				break
+			}
+		}
+		if valid {
+			return net.HardwareAddr(mac).String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("not a magic packet (%d bytes)", len(packet))
+}
@@ -0,0 +1,148 @@
+//go:build ignore
+
+// gen_registry downloads the IEEE MA-L, MA-M and MA-S registries and writes them as the
+// compressed registry.csv.gz embedded by the oui package. Run with "go generate ./oui";
+// pass local copies of the IEEE CSV files as arguments to build from those instead.
+package main
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// IEEE registry files (the site refuses requests without a browser-like User-Agent)
+var registryURLs = []string{
+	"https://standards-oui.ieee.org/oui/oui.csv",
+	"https://standards-oui.ieee.org/oui28/mam.csv",
+	"https://standards-oui.ieee.org/oui36/oui36.csv",
+}
+
+const output = "registry.csv.gz"
+
+func main() {
+	sources := registryURLs
+	if len(os.Args) > 1 {
+		sources = os.Args[1:]
+	}
+
+	entries := make(map[string][2]string) // Assignment -> registry, organization
+	for _, source := range sources {
+		count, err := readRegistry(source, entries)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gen_registry: %s: %v\n", source, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s: %d assignments\n", source, count)
+	}
+
+	if err := writeRegistry(entries); err != nil {
+		fmt.Fprintf(os.Stderr, "gen_registry: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("wrote %d assignments to %s\n", len(entries), output)
+}
+
+// readRegistry adds the assignments of an IEEE CSV file or URL to entries
+func readRegistry(source string, entries map[string][2]string) (int, error) {
+	var body io.ReadCloser
+	if strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://") {
+		req, err := http.NewRequest(http.MethodGet, source, nil)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; wol-web registry generator)")
+		client := &http.Client{Timeout: 2 * time.Minute}
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return 0, fmt.Errorf("server returned %s", resp.Status)
+		}
+		body = resp.Body
+	} else {
+		file, err := os.Open(source)
+		if err != nil {
+			return 0, err
+		}
+		body = file
+	}
+	defer body.Close()
+
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	count := 0
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %w", line, err)
+		}
+		if line == 1 || len(record) < 3 {
+			continue // Header
+		}
+		assignment := strings.ToUpper(strings.TrimSpace(record[1]))
+		organization := strings.Join(strings.Fields(record[2]), " ")
+		if assignment == "" || organization == "" {
+			continue
+		}
+		entries[assignment] = [2]string{strings.TrimSpace(record[0]), organization}
+		count++
+	}
+	if count == 0 {
+		return 0, fmt.Errorf("no assignments found")
+	}
+	return count, nil
+}
+
+// writeRegistry writes the assignments sorted by prefix, without the organization addresses
+func writeRegistry(entries map[string][2]string) error {
+	assignments := make([]string, 0, len(entries))
+	for assignment := range entries {
+		assignments = append(assignments, assignment)
+	}
+	sort.Strings(assignments)
+
+	file, err := os.Create(output + ".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(output + ".tmp")
+
+	compressed, err := gzip.NewWriterLevel(file, gzip.BestCompression)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	writer := csv.NewWriter(compressed)
+	writer.Write([]string{"Registry", "Assignment", "Organization Name"})
+	for _, assignment := range assignments {
+		entry := entries[assignment]
+		writer.Write([]string{entry[0], assignment, entry[1]})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := compressed.Close(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(output+".tmp", output)
+}
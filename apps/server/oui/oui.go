@@ -0,0 +1,242 @@
+// Package oui maps MAC addresses to the organization their prefix is registered to in the
+// IEEE registries (MA-L/OUI, MA-M and MA-S), and flags locally administered addresses.
+//
+// The registries are bundled as registry.csv.gz, generated from the files the IEEE publishes
+// with "go generate" (see gen_registry.go). Newer registry files (oui.csv, mam.csv,
+// oui36.csv or oui.txt) can be loaded on top at runtime.
+package oui
+
+//go:generate go run gen_registry.go
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+//go:embed registry.csv.gz
+var bundled []byte
+
+// Prefix lengths of the IEEE registries in hex digits, longest first
+var prefixLengths = []int{9, 7, 6} // MA-S (36 bits), MA-M (28 bits), MA-L (24 bits)
+
+// Well-known locally administered prefixes of virtual machines and containers.
+// They are not registered with the IEEE, but are not randomized either.
+var localPrefixes = map[string]string{
+	"525400": "QEMU/KVM virtual NIC",
+	"0242":   "Docker container",
+	"0A0027": "VirtualBox host-only adapter",
+}
+
+// oui.txt lines: "00-1B-21   (hex)		Intel Corporate"
+var ouiTextLine = regexp.MustCompile(`^\s*([0-9A-Fa-f]{2})-([0-9A-Fa-f]{2})-([0-9A-Fa-f]{2})\s+\(hex\)\s+(.+?)\s*$`)
+
+// Info describes a MAC address
+type Info struct {
+	Vendor              string // Registered organization ("" = unknown)
+	LocallyAdministered bool   // The U/L bit is set - the address was not assigned by a vendor
+	Randomized          bool   // Locally administered and not a known virtual NIC - most likely a private (randomized) Wi-Fi address
+	Multicast           bool   // The I/G bit is set - not a device address
+}
+
+// Database is a prefix -> organization table, safe for concurrent use
+type Database struct {
+	mutex   sync.RWMutex
+	vendors map[string]string // Upper-case hex prefix (6, 7 or 9 digits) -> organization
+}
+
+// New returns a database with the bundled registries
+func New() *Database {
+	db := &Database{vendors: make(map[string]string)}
+	registry, err := gzip.NewReader(bytes.NewReader(bundled))
+	if err == nil {
+		_, err = db.Load(registry)
+	}
+	if err != nil {
+		panic(fmt.Sprintf("oui: invalid bundled database: %v", err))
+	}
+	return db
+}
+
+// Load adds the entries of an IEEE registry file (CSV or oui.txt format) to the database,
+// replacing existing entries with the same prefix. Returns the number of entries read.
+func (db *Database) Load(r io.Reader) (int, error) {
+	reader := bufio.NewReader(r)
+	head, _ := reader.Peek(9)
+
+	var entries map[string]string
+	var err error
+	if strings.EqualFold(string(head), "Registry,") {
+		entries, err = parseCSV(reader)
+	} else {
+		entries, err = parseText(reader)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(entries) == 0 {
+		return 0, errors.New("no registry entries found (expected IEEE oui.csv, mam.csv, oui36.csv or oui.txt)")
+	}
+
+	db.mutex.Lock()
+	for prefix, vendor := range entries {
+		db.vendors[prefix] = vendor
+	}
+	db.mutex.Unlock()
+	return len(entries), nil
+}
+
+// LoadFile adds the entries of an IEEE registry file to the database
+func (db *Database) LoadFile(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	count, err := db.Load(file)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	return count, nil
+}
+
+// ReloadFile replaces the database with the bundled entries plus the entries of an IEEE
+// registry file. On error the database is left unchanged.
+func (db *Database) ReloadFile(path string) (int, error) {
+	fresh := New()
+	count, err := fresh.LoadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	db.mutex.Lock()
+	db.vendors = fresh.vendors
+	db.mutex.Unlock()
+	return count, nil
+}
+
+// Len returns the number of prefixes in the database
+func (db *Database) Len() int {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	return len(db.vendors)
+}
+
+// Lookup returns the vendor and the address flags of a MAC address in any common
+// notation (aa:bb:cc:dd:ee:ff, aa-bb-..., aabb.ccdd.eeff). Invalid addresses return an empty Info.
+func (db *Database) Lookup(mac string) Info {
+	digits := hexDigits(mac)
+	if len(digits) != 12 {
+		return Info{}
+	}
+
+	var info Info
+	firstOctet := hexValue(digits[0])<<4 | hexValue(digits[1])
+	info.Multicast = firstOctet&0x01 != 0
+	info.LocallyAdministered = firstOctet&0x02 != 0
+
+	if info.LocallyAdministered {
+		for prefix, name := range localPrefixes {
+			if strings.HasPrefix(digits, prefix) {
+				info.Vendor = name
+				return info
+			}
+		}
+		info.Randomized = !info.Multicast
+		return info
+	}
+
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	for _, length := range prefixLengths {
+		if vendor, ok := db.vendors[digits[:length]]; ok {
+			info.Vendor = vendor
+			break
+		}
+	}
+	return info
+}
+
+// parseCSV reads the IEEE CSV format: Registry,Assignment,Organization Name,Organization Address
+func parseCSV(r io.Reader) (map[string]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	entries := make(map[string]string)
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if line == 1 || len(record) < 3 {
+			continue // Header
+		}
+		prefix := strings.ToUpper(strings.TrimSpace(record[1]))
+		vendor := strings.TrimSpace(record[2])
+		if !validPrefix(prefix) || vendor == "" {
+			return nil, fmt.Errorf("line %d: invalid assignment '%s'", line, record[1])
+		}
+		entries[prefix] = vendor
+	}
+}
+
+// parseText reads the "(hex)" lines of the IEEE oui.txt format and ignores everything else
+func parseText(r io.Reader) (map[string]string, error) {
+	entries := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		match := ouiTextLine.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		entries[strings.ToUpper(match[1]+match[2]+match[3])] = match[4]
+	}
+	return entries, scanner.Err()
+}
+
+// validPrefix reports whether a prefix is an upper-case hex MA-L, MA-M or MA-S assignment
+func validPrefix(prefix string) bool {
+	if len(prefix) != 6 && len(prefix) != 7 && len(prefix) != 9 {
+		return false
+	}
+	return len(hexDigits(prefix)) == len(prefix)
+}
+
+// hexDigits returns the upper-case hex digits of a MAC address, dropping separators
+func hexDigits(mac string) string {
+	var b strings.Builder
+	for _, c := range mac {
+		switch {
+		case c >= '0' && c <= '9', c >= 'A' && c <= 'F':
+			b.WriteRune(c)
+		case c >= 'a' && c <= 'f':
+			b.WriteRune(c - 'a' + 'A')
+		case c == ':' || c == '-' || c == '.':
+		default:
+			return ""
+		}
+	}
+	return b.String()
+}
+
+// hexValue returns the value of an upper-case hex digit
+func hexValue(c byte) byte {
+	if c >= 'A' {
+		return c - 'A' + 10
+	}
+	return c - '0'
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -9,6 +10,8 @@ import (
 type PingCacheEntry struct {
 	PingSuccess bool
 	ARPSuccess  bool
+	RTT         time.Duration   // ICMP round-trip time (0 if unknown)
+	Services    []ServiceResult // Service check results (nil if the host has no checks or was offline)
 	Timestamp   time.Time
 	InProgress  bool            // Indicates if a ping is currently in progress
 	WaitChan    chan pingResult // Channel for request coalescing
@@ -19,11 +22,18 @@ type PingCache struct {
 	cache      map[string]*PingCacheEntry // Key: host_id or MAC address
 	cacheMutex sync.RWMutex
 	ttl        time.Duration // How long to keep cached results
+	hits       atomic.Uint64 // Lookups answered from the cache
+	misses     atomic.Uint64 // Lookups that found no valid result
+	coalesced  atomic.Uint64 // Requests that waited for a ping already in progress
+	stop       chan struct{} // Closed by Close to end the cleanup goroutine
+	stopOnce   sync.Once
 }
 
 type pingResult struct {
 	PingSuccess bool
 	ARPSuccess  bool
+	RTT         time.Duration
+	Services    []ServiceResult
 	Error       error
 }
 
@@ -32,6 +42,7 @@ func NewPingCache(ttl time.Duration) *PingCache {
 	pc := &PingCache{
 		cache: make(map[string]*PingCacheEntry),
 		ttl:   ttl,
+		stop:  make(chan struct{}),
 	}
 
 	// Start cleanup goroutine
@@ -40,21 +51,25 @@ func NewPingCache(ttl time.Duration) *PingCache {
 	return pc
 }
 
-// Get retrieves cached ping result if valid, returns nil if cache miss or expired
+// Get retrieves cached ping result if valid, returns nil if cache miss or expired.
+// A ping in progress is a miss - callers coalesce with it through StartPing.
 func (pc *PingCache) Get(key string) *PingCacheEntry {
 	pc.cacheMutex.RLock()
 	defer pc.cacheMutex.RUnlock()
 
 	entry, exists := pc.cache[key]
-	if !exists {
+	if !exists || entry.InProgress {
+		pc.misses.Add(1)
 		return nil
 	}
 
 	// Check if expired
 	if time.Since(entry.Timestamp) > pc.ttl {
+		pc.misses.Add(1)
 		return nil
 	}
 
+	pc.hits.Add(1)
 	return entry
 }
 
@@ -70,6 +85,7 @@ func (pc *PingCache) StartPing(key string) (isFirstRequest bool, waitChan chan p
 	// Check if there's already a ping in progress
 	if exists && entry.InProgress {
 		// Return existing wait channel - this request will coalesce with ongoing ping
+		pc.coalesced.Add(1)
 		return false, entry.WaitChan
 	}
 
@@ -85,7 +101,7 @@ func (pc *PingCache) StartPing(key string) (isFirstRequest bool, waitChan chan p
 }
 
 // Set stores a ping result in the cache
-func (pc *PingCache) Set(key string, pingSuccess, arpSuccess bool) {
+func (pc *PingCache) Set(key string, probe hostProbeResult) {
 	pc.cacheMutex.Lock()
 	defer pc.cacheMutex.Unlock()
 
@@ -94,24 +110,22 @@ func (pc *PingCache) Set(key string, pingSuccess, arpSuccess bool) {
 	// If there was a ping in progress, notify all waiters
 	if exists && entry.InProgress {
 		result := pingResult{
-			PingSuccess: pingSuccess,
-			ARPSuccess:  arpSuccess,
+			PingSuccess: probe.PingSuccess,
+			ARPSuccess:  probe.ARPSuccess,
+			RTT:         probe.RTT,
+			Services:    probe.Services,
 			Error:       nil,
 		}
 
-		// Notify all waiting requests (non-blocking)
-		select {
-		case entry.WaitChan <- result:
-		default:
-			// Channel full or no receivers, continue
-		}
-		close(entry.WaitChan)
+		notifyWaiters(entry.WaitChan, result)
 	}
 
 	// Store the result
 	pc.cache[key] = &PingCacheEntry{
-		PingSuccess: pingSuccess,
-		ARPSuccess:  arpSuccess,
+		PingSuccess: probe.PingSuccess,
+		ARPSuccess:  probe.ARPSuccess,
+		RTT:         probe.RTT,
+		Services:    probe.Services,
 		Timestamp:   time.Now(),
 		InProgress:  false,
 		WaitChan:    nil,
@@ -133,18 +147,27 @@ func (pc *PingCache) SetError(key string, err error) {
 			Error:       err,
 		}
 
-		// Notify all waiting requests (non-blocking)
-		select {
-		case entry.WaitChan <- result:
-		default:
-		}
-		close(entry.WaitChan)
+		notifyWaiters(entry.WaitChan, result)
 	}
 
 	// Remove from cache on error (don't cache failures long-term)
 	delete(pc.cache, key)
 }
 
+// notifyWaiters fills the buffered wait channel with copies of the result and closes it.
+// Each coalesced request receives one copy; a single send would leave the other waiters
+// reading the zero value (offline) from the closed channel.
+func notifyWaiters(waitChan chan pingResult, result pingResult) {
+	for filled := false; !filled; {
+		select {
+		case waitChan <- result:
+		default:
+			filled = true // Buffer full
+		}
+	}
+	close(waitChan)
+}
+
 // Invalidate removes a cache entry (useful after sending WoL packet)
 func (pc *PingCache) Invalidate(key string) {
 	pc.cacheMutex.Lock()
@@ -161,12 +184,27 @@ func (pc *PingCache) InvalidateAll() {
 	pc.cache = make(map[string]*PingCacheEntry)
 }
 
+// SetTTL changes how long results are kept, e.g. after ping_timeout_seconds was reloaded.
+// Cached results expire according to the new TTL.
+func (pc *PingCache) SetTTL(ttl time.Duration) {
+	pc.cacheMutex.Lock()
+	defer pc.cacheMutex.Unlock()
+
+	pc.ttl = ttl
+}
+
 // cleanupExpiredEntries periodically removes expired cache entries
 func (pc *PingCache) cleanupExpiredEntries() {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-ticker.C:
+		case <-pc.stop:
+			return
+		}
+
 		pc.cacheMutex.Lock()
 		now := time.Now()
 
@@ -202,5 +240,13 @@ func (pc *PingCache) GetStats() map[string]interface{} {
 		"in_progress":      inProgressCount,
 		"cached_results":   cachedCount,
 		"ttl_seconds":      pc.ttl.Seconds(),
+		"hits":             pc.hits.Load(),
+		"misses":           pc.misses.Load(),
+		"coalesced":        pc.coalesced.Load(),
 	}
 }
+
+// Close stops the cleanup goroutine
+func (pc *PingCache) Close() {
+	pc.stopOnce.Do(func() { close(pc.stop) })
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sshCommandResult holds the outcome of a remote command execution
+type sshCommandResult struct {
+	Output             string // Combined stdout/stderr (truncated)
+	HostKeyFingerprint string // SHA256 fingerprint of the server host key
+	Disconnected       bool   // Connection dropped before exit status (normal for poweroff)
+}
+
+// limitedBuffer is an io.Writer that keeps at most max bytes and discards the rest
+type limitedBuffer struct {
+	buf bytes.Buffer
+	max int
+}
+
+func (lb *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := lb.max - lb.buf.Len(); remaining > 0 {
+		if len(p) > remaining {
+			lb.buf.Write(p[:remaining])
+		} else {
+			lb.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// generateSSHKey creates a new ed25519 private key in OpenSSH PEM format
+func generateSSHKey() ([]byte, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	block, err := ssh.MarshalPrivateKey(privateKey, "wol-web-extended")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+	return pem.EncodeToMemory(block), nil
+}
+
+// parseSSHPrivateKey parses an unencrypted PEM private key and returns a signer
+func parseSSHPrivateKey(pemData []byte) (ssh.Signer, error) {
+	signer, err := ssh.ParsePrivateKey(pemData)
+	if err != nil {
+		var passphraseErr *ssh.PassphraseMissingError
+		if errors.As(err, &passphraseErr) {
+			return nil, fmt.Errorf("passphrase-protected keys are not supported")
+		}
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	return signer, nil
+}
+
+// authorizedKeyLine returns the authorized_keys entry for a signer's public key
+func authorizedKeyLine(signer ssh.Signer) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey()))) + " wol-web-extended"
+}
+
+// runSSHCommand connects to address:port with key-based authentication and runs a command.
+//
+// Host key verification:
+//   - If expectedFingerprint is set, the server host key must match it (SHA256 format)
+//   - Otherwise the key is accepted and its fingerprint returned so the caller can pin it
+//     (trust on first use)
+//
+// Power-off commands frequently terminate the connection before an exit status is sent;
+// this is reported as success with Disconnected set.
+func runSSHCommand(address string, port int, user string, signer ssh.Signer, expectedFingerprint string, command string, timeout time.Duration) (*sshCommandResult, error) {
+	result := &sshCommandResult{}
+
+	config := &ssh.ClientConfig{
+		User: user,
+		Auth: []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			fingerprint := ssh.FingerprintSHA256(key)
+			result.HostKeyFingerprint = fingerprint
+			if expectedFingerprint != "" && fingerprint != expectedFingerprint {
+				return fmt.Errorf("host key mismatch: expected %s, got %s", expectedFingerprint, fingerprint)
+			}
+			return nil
+		},
+	}
+
+	target := net.JoinHostPort(address, strconv.Itoa(port))
+	Debug("Connecting via SSH to %s@%s", user, target)
+
+	conn, err := net.DialTimeout("tcp", target, timeout)
+	if err != nil {
+		return result, fmt.Errorf("SSH connection to %s failed: %w", target, err)
+	}
+
+	// The deadline bounds the handshake and opening the session, so a server that accepts
+	// the connection but never answers cannot hang the request
+	conn.SetDeadline(time.Now().Add(timeout))
+	clientConn, channels, requests, err := ssh.NewClientConn(conn, target, config)
+	if err != nil {
+		conn.Close()
+		return result, fmt.Errorf("SSH connection to %s failed: %w", target, err)
+	}
+	client := ssh.NewClient(clientConn, channels, requests)
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return result, fmt.Errorf("failed to open SSH session: %w", err)
+	}
+	defer session.Close()
+	conn.SetDeadline(time.Time{})
+
+	output := &limitedBuffer{max: SSHMaxOutputBytes}
+	session.Stdout = output
+	session.Stderr = output
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Run(command)
+	}()
+
+	select {
+	case err = <-done:
+	case <-time.After(timeout):
+		client.Close()
+		result.Output = output.buf.String()
+		return result, fmt.Errorf("command timed out after %s", timeout)
+	}
+
+	result.Output = output.buf.String()
+	if err != nil {
+		var exitMissing *ssh.ExitMissingError
+		if errors.As(err, &exitMissing) || errors.Is(err, io.EOF) {
+			result.Disconnected = true
+			return result, nil
+		}
+		return result, fmt.Errorf("command failed: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// testSSHServer is an in-process SSH server that accepts one client key and answers every
+// exec request with "ran: <command>" and exit status 0
+type testSSHServer struct {
+	listener    net.Listener
+	fingerprint string
+}
+
+func newTestSSHServer(t *testing.T, clientKey ssh.PublicKey) *testSSHServer {
+	t.Helper()
+
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientKey.Marshal()) {
+				return nil, ssh.ErrNoAuth
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestSSHConn(conn, config)
+		}
+	}()
+
+	return &testSSHServer{listener: listener, fingerprint: ssh.FingerprintSHA256(hostSigner.PublicKey())}
+}
+
+func serveTestSSHConn(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer channel.Close()
+			for req := range channelRequests {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+				// Payload is an SSH string holding the command
+				command := string(req.Payload[4:])
+				req.Reply(true, nil)
+				channel.Write([]byte("ran: " + command))
+				status := make([]byte, 4)
+				binary.BigEndian.PutUint32(status, 0)
+				channel.SendRequest("exit-status", false, status)
+				return
+			}
+		}()
+	}
+}
+
+func (ts *testSSHServer) port() int {
+	return ts.listener.Addr().(*net.TCPAddr).Port
+}
+
+func newTestSSHSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	pemData, err := generateSSHKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := parseSSHPrivateKey(pemData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func TestRunSSHCommandTrustOnFirstUse(t *testing.T) {
+	signer := newTestSSHSigner(t)
+	server := newTestSSHServer(t, signer.PublicKey())
+
+	result, err := runSSHCommand("127.0.0.1", server.port(), "root", signer, "", "poweroff", 5*time.Second)
+	if err != nil {
+		t.Fatalf("runSSHCommand: %v", err)
+	}
+	if result.HostKeyFingerprint != server.fingerprint {
+		t.Errorf("fingerprint = %q, want %q", result.HostKeyFingerprint, server.fingerprint)
+	}
+	if result.Output != "ran: poweroff" {
+		t.Errorf("output = %q, want %q", result.Output, "ran: poweroff")
+	}
+
+	// The pinned fingerprint is accepted on the next connection
+	if _, err := runSSHCommand("127.0.0.1", server.port(), "root", signer, result.HostKeyFingerprint, "poweroff", 5*time.Second); err != nil {
+		t.Fatalf("runSSHCommand with pinned fingerprint: %v", err)
+	}
+}
+
+func TestRunSSHCommandFingerprintMismatch(t *testing.T) {
+	signer := newTestSSHSigner(t)
+	server := newTestSSHServer(t, signer.PublicKey())
+
+	pinned := "SHA256:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+	result, err := runSSHCommand("127.0.0.1", server.port(), "root", signer, pinned, "poweroff", 5*time.Second)
+	if err == nil || !strings.Contains(err.Error(), "host key mismatch") {
+		t.Fatalf("err = %v, want host key mismatch", err)
+	}
+	if result.HostKeyFingerprint != server.fingerprint {
+		t.Errorf("fingerprint = %q, want %q", result.HostKeyFingerprint, server.fingerprint)
+	}
+	if result.Output != "" {
+		t.Errorf("command ran despite mismatch: %q", result.Output)
+	}
+}
+
+func TestRunSSHCommandHandshakeTimeout(t *testing.T) {
+	// Accepts connections but never speaks SSH
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	signer := newTestSSHSigner(t)
+	port := listener.Addr().(*net.TCPAddr).Port
+	start := time.Now()
+	_, err = runSSHCommand("127.0.0.1", port, "root", signer, "", "poweroff", 500*time.Millisecond)
+	if err == nil {
+		t.Fatal("expected an error from a server that never answers")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("runSSHCommand took %s, want about 500ms", elapsed)
+	}
+}
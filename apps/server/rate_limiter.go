@@ -37,6 +37,7 @@ func (s *Server) checkDynamicRateLimit(userKey string, limit int, window time.Du
 	// Check if we're within the dynamic limit
 	if len(validRequests) >= limit {
 		reqWindow.times = validRequests
+		s.PingRateLimit.rejected.Add(1)
 		return false
 	}
 
@@ -0,0 +1,546 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Relay job types
+const (
+	RelayJobWake     = "wake"
+	RelayJobProbe    = "probe"
+	RelayJobServices = "services"
+)
+
+// relayNameRegex restricts relay names to simple identifiers
+var relayNameRegex = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// errRelayAlreadyPolling is returned by Poll when another poll under the same name is active
+var errRelayAlreadyPolling = errors.New("relay is already connected")
+
+// RelayConfig is a relay with its own token; the token only authenticates this relay name
+type RelayConfig struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+}
+
+// validateRelayConfig checks a single relays entry
+func validateRelayConfig(c RelayConfig) error {
+	if !relayNameRegex.MatchString(c.Name) {
+		return fmt.Errorf("invalid relay name '%s' (1-64 characters: letters, digits, '.', '_', '-')", c.Name)
+	}
+	if len(c.Token) < MinAgentTokenLength {
+		return fmt.Errorf("token of relay '%s' must be at least %d characters long", c.Name, MinAgentTokenLength)
+	}
+	return nil
+}
+
+// RelayJobHost is the subset of host data a relay needs to wake or probe a host
+type RelayJobHost struct {
+	Name          string `json:"name"`
+	MAC           string `json:"mac"`
+	Broadcast     string `json:"broadcast"`
+	Interface     string `json:"interface"`
+	StaticIP      string `json:"static_ip"`
+	UseAsFallback bool   `json:"use_as_fallback"`
+}
+
+// RelayJob is a wake, probe or service check request delivered to a relay
+type RelayJob struct {
+	ID          string        `json:"id"`
+	Type        string        `json:"type"`
+	Host        RelayJobHost  `json:"host"`
+	FlushARP    bool          `json:"flush_arp,omitempty"`
+	PingTimeout int           `json:"ping_timeout"`
+	IP          string        `json:"ip,omitempty"`       // Address the host was found at (services jobs)
+	Services    []HostService `json:"services,omitempty"` // Checks to run (services jobs)
+}
+
+// RelayJobResult is the outcome of a relay job reported back to the server
+type RelayJobResult struct {
+	ID          string          `json:"id"`
+	Success     bool            `json:"success"`
+	PingSuccess bool            `json:"ping_success"`
+	ARPSuccess  bool            `json:"arp_success"`
+	IP          string          `json:"ip,omitempty"`
+	MAC         string          `json:"mac,omitempty"` // MAC address that answered at IP
+	RTTMicros   int64           `json:"rtt_us,omitempty"`
+	Services    []ServiceResult `json:"services,omitempty"`
+	Error       string          `json:"error,omitempty"`
+}
+
+// RelayPollRequest is sent by a relay on every long-poll: identity plus results of finished jobs
+type RelayPollRequest struct {
+	Name       string           `json:"name"`
+	Version    string           `json:"version"`
+	Interfaces []string         `json:"interfaces"`
+	Results    []RelayJobResult `json:"results"`
+}
+
+// RelayInfo describes a relay known to the server
+type RelayInfo struct {
+	Name       string    `json:"name"`
+	Online     bool      `json:"online"`
+	LastSeen   time.Time `json:"last_seen"`
+	Version    string    `json:"version"`
+	Interfaces []string  `json:"interfaces"`
+}
+
+// relayState is the hub record of one relay
+type relayState struct {
+	version    string
+	interfaces []string
+	lastSeen   time.Time
+	polling    int // Number of active long-poll requests
+	jobs       chan *RelayJob
+	delivered  []*RelayJob // Jobs returned by the last poll, until the relay reports their results
+}
+
+// RelayHub dispatches jobs to relays connected via outbound long-poll requests
+// and routes their results back to the waiting callers
+type RelayHub struct {
+	mutex   sync.Mutex
+	relays  map[string]*relayState
+	waiters map[string]chan RelayJobResult // job ID -> result channel
+}
+
+// NewRelayHub creates an empty relay hub
+func NewRelayHub() *RelayHub {
+	return &RelayHub{
+		relays:  make(map[string]*relayState),
+		waiters: make(map[string]chan RelayJobResult),
+	}
+}
+
+// isOnline reports whether a relay is currently connected or polled recently
+func (st *relayState) isOnline() bool {
+	return st.polling > 0 || time.Since(st.lastSeen) <= RelayOfflineAfter
+}
+
+// Dispatch queues a job for a relay and waits for its result
+func (h *RelayHub) Dispatch(name string, job *RelayJob, timeout time.Duration) (RelayJobResult, error) {
+	h.mutex.Lock()
+	st, exists := h.relays[name]
+	if !exists || !st.isOnline() {
+		h.mutex.Unlock()
+		return RelayJobResult{}, fmt.Errorf("relay '%s' is offline", name)
+	}
+
+	job.ID = newJobID()
+	resultChan := make(chan RelayJobResult, 1)
+	h.waiters[job.ID] = resultChan
+
+	select {
+	case st.jobs <- job:
+	default:
+		delete(h.waiters, job.ID)
+		h.mutex.Unlock()
+		return RelayJobResult{}, fmt.Errorf("relay '%s' job queue is full", name)
+	}
+	h.mutex.Unlock()
+
+	Debug("Dispatched %s job %s for host '%s' to relay '%s'", job.Type, job.ID, job.Host.Name, name)
+
+	select {
+	case result := <-resultChan:
+		return result, nil
+	case <-time.After(timeout):
+		h.mutex.Lock()
+		delete(h.waiters, job.ID)
+		h.mutex.Unlock()
+		return RelayJobResult{}, fmt.Errorf("relay '%s' did not answer within %s", name, timeout)
+	}
+}
+
+// Poll registers a relay report, delivers its results and waits for new jobs. Jobs returned
+// by a poll are delivered again by the next one unless it reports their results.
+func (h *RelayHub) Poll(ctx context.Context, req RelayPollRequest) ([]*RelayJob, error) {
+	h.mutex.Lock()
+	st, exists := h.relays[req.Name]
+	if !exists {
+		if len(h.relays) >= MaxRelays {
+			h.mutex.Unlock()
+			return nil, fmt.Errorf("too many relays (max %d)", MaxRelays)
+		}
+		st = &relayState{jobs: make(chan *RelayJob, RelayJobQueueSize)}
+		h.relays[req.Name] = st
+		Info("Relay '%s' connected (version %s, interfaces: %s)", req.Name, req.Version, strings.Join(req.Interfaces, ","))
+	}
+	// A relay polls one request at a time, so a second poller is another process using the name
+	if st.polling > 0 {
+		h.mutex.Unlock()
+		return nil, errRelayAlreadyPolling
+	}
+	st.version = req.Version
+	st.interfaces = req.Interfaces
+	st.lastSeen = time.Now()
+	st.polling++
+
+	reported := make(map[string]bool, len(req.Results))
+	for _, result := range req.Results {
+		reported[result.ID] = true
+		if waiter, ok := h.waiters[result.ID]; ok {
+			waiter <- result
+			delete(h.waiters, result.ID)
+		} else {
+			Debug("Relay '%s' reported result for unknown or expired job %s", req.Name, result.ID)
+		}
+	}
+
+	// The relay runs the jobs of a poll before polling again with their results, so
+	// delivered jobs without a result never reached it (e.g. the response was lost)
+	var jobs []*RelayJob
+	for _, job := range st.delivered {
+		if _, waiting := h.waiters[job.ID]; waiting && !reported[job.ID] {
+			Debug("Redelivering %s job %s to relay '%s'", job.Type, job.ID, req.Name)
+			jobs = append(jobs, job)
+		}
+	}
+	st.delivered = nil
+	h.mutex.Unlock()
+
+	defer func() {
+		h.mutex.Lock()
+		st.polling--
+		st.lastSeen = time.Now()
+		h.mutex.Unlock()
+	}()
+
+	timer := time.NewTimer(RelayPollTimeout)
+	defer timer.Stop()
+
+	for {
+		// Deliver immediately, picking up anything else that is already queued
+	drain:
+		for len(jobs) < RelayMaxJobsPerPoll {
+			select {
+			case job := <-st.jobs:
+				if h.isWaiting(job.ID) {
+					jobs = append(jobs, job)
+				}
+			default:
+				break drain
+			}
+		}
+		if len(jobs) > 0 {
+			// Kept until the next poll reports their results, as writing the response
+			// may fail without the handler noticing
+			h.mutex.Lock()
+			st.delivered = jobs
+			h.mutex.Unlock()
+			return jobs, nil
+		}
+
+		select {
+		case job := <-st.jobs:
+			if h.isWaiting(job.ID) {
+				jobs = append(jobs, job)
+			}
+		case <-timer.C:
+			return nil, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// isWaiting reports whether a caller is still waiting for a job (skips expired jobs)
+func (h *RelayHub) isWaiting(jobID string) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	_, ok := h.waiters[jobID]
+	return ok
+}
+
+// List returns all relays known to the hub, sorted by name
+func (h *RelayHub) List() []RelayInfo {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	relays := make([]RelayInfo, 0, len(h.relays))
+	for name, st := range h.relays {
+		relays = append(relays, RelayInfo{
+			Name:       name,
+			Online:     st.isOnline(),
+			LastSeen:   st.lastSeen,
+			Version:    st.version,
+			Interfaces: st.interfaces,
+		})
+	}
+	sort.Slice(relays, func(i, j int) bool { return relays[i].Name < relays[j].Name })
+	return relays
+}
+
+// IsOnline reports whether the named relay is connected
+func (h *RelayHub) IsOnline(name string) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	st, exists := h.relays[name]
+	return exists && st.isOnline()
+}
+
+// hostRelay returns the relay a host is assigned to, or "" for hosts on the local network
+func (s *Server) hostRelay(ctx context.Context, hostID string) string {
+	if s.Relays == nil || s.DB == nil {
+		return ""
+	}
+	var relay string
+	err := s.DB.QueryRowContext(ctx, "SELECT relay FROM host_relays WHERE host_id = ?", hostID).Scan(&relay)
+	if err != nil && err != sql.ErrNoRows {
+		Warning("Failed to look up relay for host %s: %v", hostID, err)
+	}
+	return relay
+}
+
+// newRelayJob creates a job for a host
+func (s *Server) newRelayJob(jobType string, host Host) *RelayJob {
+	return &RelayJob{
+		Type: jobType,
+		Host: RelayJobHost{
+			Name:          host.Name,
+			MAC:           host.MAC,
+			Broadcast:     host.Broadcast,
+			Interface:     host.Interface,
+			StaticIP:      host.StaticIP,
+			UseAsFallback: host.UseAsFallback,
+		},
+		PingTimeout: s.live().PingTimeout,
+	}
+}
+
+// wakeViaRelay sends a wake job to the relay a host is assigned to
+func (s *Server) wakeViaRelay(host Host, relay string) error {
+	Debug("Sending WoL for host '%s' (MAC: %s) via relay '%s'", host.Name, host.MAC, relay)
+	result, err := s.Relays.Dispatch(relay, s.newRelayJob(RelayJobWake, host), RelayWakeTimeout)
+	if err != nil {
+		return err
+	}
+	if !result.Success {
+		return fmt.Errorf("relay '%s': %s", relay, result.Error)
+	}
+	return nil
+}
+
+// probeViaRelay checks a host's status through the relay it is assigned to
+func (s *Server) probeViaRelay(host Host, relay string, flushARP bool) hostProbeResult {
+	job := s.newRelayJob(RelayJobProbe, host)
+	job.FlushARP = flushARP
+
+	// The relay may run a full ARP scan, which takes up to the ping timeout
+	timeout := time.Duration(s.live().PingTimeout)*time.Second + RelayWakeTimeout
+	result, err := s.Relays.Dispatch(relay, job, timeout)
+	if err != nil {
+		Debug("Status check of host '%s' via relay failed: %v", host.Name, err)
+		return hostProbeResult{Method: ProbeMethodRelay}
+	}
+	if result.Error != "" {
+		Debug("Relay '%s' reported error for host '%s': %s", relay, host.Name, result.Error)
+	}
+	return hostProbeResult{
+		PingSuccess: result.PingSuccess,
+		ARPSuccess:  result.ARPSuccess,
+		IP:          result.IP,
+		MAC:         result.MAC,
+		RTT:         time.Duration(result.RTTMicros) * time.Microsecond,
+		Method:      ProbeMethodRelay,
+	}
+}
+
+// checkServicesViaRelay runs the service checks of an online host through its relay. If
+// the relay does not answer, every service is reported down with the error.
+func (s *Server) checkServicesViaRelay(ctx context.Context, host Host, ip string, services []HostService) []ServiceResult {
+	relay := s.hostRelay(ctx, host.ID)
+	job := s.newRelayJob(RelayJobServices, host)
+	job.IP = ip
+	job.Services = services
+
+	// Checks run in parallel, so the longest check timeout bounds the job
+	longest := DefaultServiceTimeoutSeconds
+	for _, service := range services {
+		longest = max(longest, service.TimeoutSeconds)
+	}
+	result, err := s.Relays.Dispatch(relay, job, time.Duration(longest)*time.Second+RelayWakeTimeout)
+	if err == nil && result.Error != "" {
+		err = fmt.Errorf("relay '%s': %s", relay, result.Error)
+	}
+	if err == nil && len(result.Services) != len(services) {
+		err = fmt.Errorf("relay '%s' returned %d of %d service results (relay version too old?)", relay, len(result.Services), len(services))
+	}
+	if err != nil {
+		Debug("Service checks of host '%s' via relay failed: %v", host.Name, err)
+		results := make([]ServiceResult, len(services))
+		for i, service := range services {
+			results[i] = ServiceResult{Name: service.Name, Error: err.Error()}
+		}
+		return results
+	}
+	return result.Services
+}
+
+// relayEndpointEnabled reports whether a shared or per-relay token is configured
+func (s *Server) relayEndpointEnabled() bool {
+	return s.Config.RelayToken != "" || len(s.Config.Relays) > 0
+}
+
+// checkRelayToken verifies the relay token from the Authorization header. It returns the
+// relay name a per-relay token is bound to, or "" for the shared relay_token.
+func (s *Server) checkRelayToken(r *http.Request) (string, bool) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		return "", false
+	}
+	for _, relay := range s.Config.Relays {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(relay.Token)) == 1 {
+			return relay.Name, true
+		}
+	}
+	if s.Config.RelayToken == "" {
+		return "", false
+	}
+	return "", subtle.ConstantTimeCompare([]byte(token), []byte(s.Config.RelayToken)) == 1
+}
+
+// relayNameReserved reports whether a relay name has its own token in relays
+func (s *Server) relayNameReserved(name string) bool {
+	for _, relay := range s.Config.Relays {
+		if relay.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// handleRelayPoll is the long-poll endpoint used by relays: it accepts job results
+// and returns new jobs as soon as they are queued (or an empty list after the poll timeout)
+func (s *Server) handleRelayPoll(w http.ResponseWriter, r *http.Request) {
+	if !s.relayEndpointEnabled() {
+		http.NotFound(w, r)
+		return
+	}
+
+	boundName, ok := s.checkRelayToken(r)
+	if !ok {
+		Warning("Relay poll with invalid token from %s", s.clientIP(r))
+		sendJSONErrorWithCode(w, "Invalid relay token", ErrCodeUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	var req RelayPollRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 256*1024)).Decode(&req); err != nil {
+		sendJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !relayNameRegex.MatchString(req.Name) {
+		sendJSONErrorWithCode(w, "Invalid relay name (1-64 characters: letters, digits, '.', '_', '-')", ErrCodeInvalidName, http.StatusBadRequest)
+		return
+	}
+	// Per-relay tokens only authenticate their own name, and names with their own token
+	// cannot be used with the shared token
+	if (boundName != "" && req.Name != boundName) || (boundName == "" && s.relayNameReserved(req.Name)) {
+		Warning("Relay poll from %s as '%s' with a token not valid for that name", s.clientIP(r), req.Name)
+		sendJSONErrorWithCode(w, fmt.Sprintf("Token is not valid for relay '%s'", req.Name), ErrCodeForbidden, http.StatusForbidden)
+		return
+	}
+	req.Version = truncateString(req.Version, 32)
+	if len(req.Interfaces) > MaxAgentMACs {
+		req.Interfaces = req.Interfaces[:MaxAgentMACs]
+	}
+
+	// End the long poll when the server shuts down so it does not hold up the drain
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	if s.Lifecycle != nil {
+		defer context.AfterFunc(s.Lifecycle, cancel)()
+	}
+
+	jobs, err := s.Relays.Poll(ctx, req)
+	if err != nil {
+		if errors.Is(err, errRelayAlreadyPolling) {
+			Warning("Relay poll from %s refused: relay '%s' is already connected", s.clientIP(r), req.Name)
+			sendJSONErrorWithCode(w, fmt.Sprintf("Relay '%s' is already connected", req.Name), ErrCodeAlreadyExists, http.StatusConflict)
+			return
+		}
+		if s.isStopping() {
+			sendJSONError(w, "Server is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		if r.Context().Err() != nil {
+			return // Relay disconnected
+		}
+		sendJSONError(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if jobs == nil {
+		jobs = []*RelayJob{}
+	}
+	sendJSON(w, map[string]interface{}{"jobs": jobs}, http.StatusOK)
+}
+
+// handleRelays lists relays known to the server
+func (s *Server) handleRelays(w http.ResponseWriter, r *http.Request) {
+	sendJSON(w, s.Relays.List(), http.StatusOK)
+}
+
+// handleHostRelay handles GET and PUT of the relay a host is assigned to
+func (s *Server) handleHostRelay(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r)
+	hostID := mux.Vars(r)["id"]
+
+	host, ok := s.loadAccessibleHost(w, user, hostID)
+	if !ok {
+		return
+	}
+
+	if r.Method == "PUT" {
+		if s.live().ReadOnlyMode || (s.Config.UseAuth && user != nil && user.ReadOnly) {
+			sendJSONError(w, "Read-only access: modification not allowed", http.StatusForbidden)
+			return
+		}
+
+		var req struct {
+			Relay string `json:"relay"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendJSONError(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		req.Relay = strings.TrimSpace(req.Relay)
+
+		var err error
+		if req.Relay == "" {
+			_, err = s.DB.Exec("DELETE FROM host_relays WHERE host_id = ?", host.ID)
+		} else {
+			if !relayNameRegex.MatchString(req.Relay) {
+				sendJSONErrorWithCode(w, "Invalid relay name (1-64 characters: letters, digits, '.', '_', '-')", ErrCodeInvalidName, http.StatusBadRequest)
+				return
+			}
+			_, err = s.DB.Exec("INSERT INTO host_relays (host_id, relay) VALUES (?, ?) ON CONFLICT(host_id) DO UPDATE SET relay = excluded.relay", host.ID, req.Relay)
+		}
+		if err != nil {
+			Error("Failed to update relay for host '%s': %v", host.Name, err)
+			sendJSONError(w, "Failed to update relay", http.StatusInternalServerError)
+			return
+		}
+
+		s.PingCache.Invalidate(host.ID)
+		Debug("Host '%s' assigned to relay '%s'", host.Name, req.Relay)
+	}
+
+	relay := s.hostRelay(r.Context(), host.ID)
+	sendJSON(w, map[string]interface{}{
+		"relay":  relay,
+		"online": relay != "" && s.Relays.IsOnline(relay),
+	}, http.StatusOK)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"server/oui"
+)
+
+// relayOptions configures relay mode (--relay)
+type relayOptions struct {
+	ServerURL string // Base URL of the central server including url_prefix
+	Token     string // Relay token (relay_token or the relay's token in relays on the server)
+	Name      string // Relay name hosts are assigned to
+	Interface string // Default network interface(s) for hosts without an interface
+}
+
+// runRelay connects to the central server with outbound long-poll requests and
+// executes wake/probe jobs for hosts assigned to this relay on the local network.
+// It only returns on configuration errors.
+func runRelay(opts relayOptions) error {
+	if opts.ServerURL == "" {
+		return fmt.Errorf("server URL is required")
+	}
+	if !strings.HasPrefix(opts.ServerURL, "http://") && !strings.HasPrefix(opts.ServerURL, "https://") {
+		return fmt.Errorf("server URL must start with http:// or https://")
+	}
+	if opts.Token == "" {
+		return fmt.Errorf("relay token is required (set RELAY_TOKEN or use -relay-token-file)")
+	}
+	if !relayNameRegex.MatchString(opts.Name) {
+		return fmt.Errorf("invalid relay name '%s' (1-64 characters: letters, digits, '.', '_', '-')", opts.Name)
+	}
+	if opts.Interface != "" {
+		if err := validateNetworkInterface(opts.Interface); err != nil {
+			return err
+		}
+	}
+
+	endpoint := strings.TrimSuffix(opts.ServerURL, "/") + "/api/relay/poll"
+	client := &http.Client{Timeout: RelayPollTimeout + AgentRequestTimeout}
+
+	// Local server instance used to run the same wake/probe code paths as the central server
+	local := &Server{
+		Config: &Config{
+			DefaultNetworkInterface: opts.Interface,
+			EnablePerHostInterfaces: true,
+			PingTimeout:             5,
+		},
+		WoLHistory: NewWoLHistory(MaxWoLHistoryEntries),
+		PingCache:  NewPingCache(time.Second),
+		Vendors:    oui.New(),
+	}
+
+	Info("Starting relay '%s' v%s - connecting to %s", opts.Name, Version, endpoint)
+
+	var results []RelayJobResult
+	for {
+		jobs, err := pollRelayJobs(client, endpoint, opts, results)
+		if err != nil {
+			Warning("Relay poll failed: %v - retrying in %s", err, RelayRetryInterval)
+			time.Sleep(RelayRetryInterval)
+			continue // Keep unsent results for the next attempt
+		}
+		results = nil
+
+		if len(jobs) == 0 {
+			continue
+		}
+
+		// Run jobs in parallel - probes may take up to the ping timeout each
+		var wg sync.WaitGroup
+		var mutex sync.Mutex
+		for _, job := range jobs {
+			wg.Add(1)
+			go func(job *RelayJob) {
+				defer wg.Done()
+				result := local.runRelayJob(job, opts.Interface)
+				mutex.Lock()
+				results = append(results, result)
+				mutex.Unlock()
+			}(job)
+		}
+		wg.Wait()
+	}
+}
+
+// pollRelayJobs sends finished results and waits for new jobs
+func pollRelayJobs(client *http.Client, endpoint string, opts relayOptions, results []RelayJobResult) ([]*RelayJob, error) {
+	body, err := json.Marshal(RelayPollRequest{
+		Name:       opts.Name,
+		Version:    Version,
+		Interfaces: localInterfaceNames(),
+		Results:    results,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+opts.Token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned %s", resp.Status)
+	}
+
+	var reply struct {
+		Jobs []*RelayJob `json:"jobs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return nil, fmt.Errorf("invalid server response: %w", err)
+	}
+	return reply.Jobs, nil
+}
+
+// runRelayJob executes a single wake or probe job on the local network
+func (s *Server) runRelayJob(job *RelayJob, defaultInterface string) RelayJobResult {
+	result := RelayJobResult{ID: job.ID}
+
+	host := Host{
+		Name:          job.Host.Name,
+		MAC:           job.Host.MAC,
+		Broadcast:     job.Host.Broadcast,
+		Interface:     job.Host.Interface,
+		StaticIP:      job.Host.StaticIP,
+		UseAsFallback: job.Host.UseAsFallback,
+	}
+	if host.Interface == "" {
+		host.Interface = defaultInterface
+	}
+
+	// Jobs come from the server, but validate like any other input before touching the network
+	if err := sanitizeMACAddress(host.MAC); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if host.StaticIP != "" {
+		if err := sanitizeStaticIP(host.StaticIP); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	}
+	if job.PingTimeout >= 1 && job.PingTimeout <= 60 && job.PingTimeout != s.Config.PingTimeout {
+		// Use the server's ping timeout without modifying the shared configuration
+		config := *s.Config
+		config.PingTimeout = job.PingTimeout
+		jobServer := *s
+		jobServer.Config = &config
+		s = &jobServer
+	}
+
+	switch job.Type {
+	case RelayJobWake:
+		Info("Relay job %s: waking host '%s' (MAC: %s)", job.ID, host.Name, host.MAC)
+		if err := s.wakeHost(context.Background(), host); err != nil {
+			result.Error = err.Error()
+			Warning("Relay job %s: wake of host '%s' failed: %v", job.ID, host.Name, err)
+			return result
+		}
+		result.Success = true
+	case RelayJobProbe:
+		probe := s.probeHost(context.Background(), host, job.FlushARP)
+		result.Success = true
+		result.PingSuccess = probe.PingSuccess
+		result.ARPSuccess = probe.ARPSuccess
+		result.IP = probe.IP
+		result.MAC = probe.MAC
+		result.RTTMicros = probe.RTT.Microseconds()
+		Debug("Relay job %s: host '%s' ping: %v, arp: %v", job.ID, host.Name, probe.PingSuccess, probe.ARPSuccess)
+	case RelayJobServices:
+		if net.ParseIP(job.IP) == nil {
+			result.Error = "invalid IP address: " + job.IP
+			return result
+		}
+		if len(job.Services) > MaxHostServices {
+			result.Error = fmt.Sprintf("too many service checks (max %d)", MaxHostServices)
+			return result
+		}
+		for i := range job.Services {
+			if err := validateHostService(&job.Services[i]); err != nil {
+				result.Error = err.Error()
+				return result
+			}
+		}
+		result.Services = s.runServiceChecks(host, job.IP, job.Services)
+		result.Success = true
+		Debug("Relay job %s: checked %d services of host '%s' at %s", job.ID, len(result.Services), host.Name, job.IP)
+	default:
+		result.Error = "unknown job type: " + job.Type
+	}
+	return result
+}
+
+// localInterfaceNames returns the names of up, non-loopback interfaces
+func localInterfaceNames() []string {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp != 0 && iface.Flags&net.FlagLoopback == 0 {
+			names = append(names, iface.Name)
+		}
+	}
+	return names
+}
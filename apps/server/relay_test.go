@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRelayPollRedeliversUnreportedJobs(t *testing.T) {
+	hub := NewRelayHub()
+	poll := func(ctx context.Context, results ...RelayJobResult) ([]*RelayJob, error) {
+		return hub.Poll(ctx, RelayPollRequest{Name: "branch", Results: results})
+	}
+
+	// Register the relay
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := poll(cancelled); err != context.Canceled {
+		t.Fatalf("first poll: err = %v, want context.Canceled", err)
+	}
+
+	done := make(chan RelayJobResult, 1)
+	go func() {
+		result, err := hub.Dispatch("branch", &RelayJob{Type: "wake"}, 5*time.Second)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- result
+	}()
+
+	jobs, err := poll(context.Background())
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("poll: %d jobs, err = %v; want the dispatched job", len(jobs), err)
+	}
+	jobID := jobs[0].ID
+
+	// The response was lost: the next poll reports nothing and gets the job again
+	jobs, err = poll(context.Background())
+	if err != nil || len(jobs) != 1 || jobs[0].ID != jobID {
+		t.Fatalf("poll without results: %v, err = %v; want job %s again", jobs, err, jobID)
+	}
+
+	// Reporting the result completes the dispatch and ends the redelivery
+	if jobs, err := poll(cancelled, RelayJobResult{ID: jobID, Success: true}); err != context.Canceled || len(jobs) != 0 {
+		t.Fatalf("poll with result: %v, err = %v; want no jobs", jobs, err)
+	}
+	select {
+	case result := <-done:
+		if !result.Success {
+			t.Errorf("result = %+v, want success", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("dispatch did not receive the result")
+	}
+	if jobs, err := poll(cancelled); err != context.Canceled || len(jobs) != 0 {
+		t.Fatalf("poll after result: %v, err = %v; want no jobs", jobs, err)
+	}
+}
@@ -40,8 +40,32 @@ func (s *Server) setupRoutes(router *mux.Router) {
 		apiPrefix = strings.TrimSuffix(apiPrefix, "/")
 	}
 
+	// OpenTelemetry request spans
+	if s.Config.TracingEndpoint != "" {
+		router.Use(s.tracingMiddleware(apiPrefix))
+	}
+
+	// Request IDs, request log fields and the request log
+	router.Use(s.requestIDMiddleware)
+
+	// Client certificates for API access (mTLS)
+	if s.Config.TLSClientCAFile != "" {
+		router.Use(s.clientCertMiddleware(apiPrefix))
+	}
+
+	// Prometheus metrics (authenticated with the metrics token)
+	if s.Metrics != nil {
+		router.Use(s.metricsMiddleware(apiPrefix))
+		router.HandleFunc(apiPrefix+"/metrics", s.handleMetrics).Methods("GET")
+	}
+
 	api := router.PathPrefix(apiPrefix + "/api").Subrouter()
 
+	// Proxied applications must not use the API with the visitor's session
+	if len(s.WebProxies) > 0 {
+		api.Use(s.webProxyRefererMiddleware(apiPrefix))
+	}
+
 	// Health check endpoint (no authentication required)
 	api.HandleFunc("/health", s.handleHealth).Methods("GET")
 
@@ -52,6 +76,15 @@ func (s *Server) setupRoutes(router *mux.Router) {
 	api.HandleFunc("/auth/setup", s.handleInitialSetup).Methods("POST")
 	api.HandleFunc("/auth/has-superuser", s.handleHasSuperuser).Methods("GET")
 
+	// Companion agent endpoint (authenticated with the shared agent token)
+	api.HandleFunc("/agent/heartbeat", s.handleAgentHeartbeat).Methods("POST")
+
+	// Relay long-poll endpoint (authenticated with the shared relay token)
+	api.HandleFunc("/relay/poll", s.handleRelayPoll).Methods("POST")
+
+	// Proxy activity for idle-shutdown policies (user session or agent token)
+	api.HandleFunc("/proxies/activity", s.handleProxyActivity).Methods("GET")
+
 	// Protected endpoints - apply auth middleware
 	protected := api.PathPrefix("").Subrouter()
 	protected.Use(s.AuthMiddleware)
@@ -63,18 +96,48 @@ func (s *Server) setupRoutes(router *mux.Router) {
 	// Host management endpoints
 	protected.HandleFunc("/hosts", s.handleHosts).Methods("GET", "POST")
 	protected.HandleFunc("/hosts/{id}", s.handleHost).Methods("GET", "PUT", "DELETE")
+	protected.HandleFunc("/hosts/{id}/dependencies", s.handleHostDependencies).Methods("GET", "PUT")
+	protected.HandleFunc("/hosts/{id}/services", s.handleHostServices).Methods("GET", "PUT")
+	protected.HandleFunc("/hosts/{id}/power", s.handleHostPower).Methods("GET", "PUT", "DELETE")
+	protected.HandleFunc("/hosts/{id}/shutdown", s.handleShutdown).Methods("POST")
+	protected.HandleFunc("/hosts/{id}/agent/command", s.handleAgentCommand).Methods("POST")
+	protected.HandleFunc("/hosts/{id}/relay", s.handleHostRelay).Methods("GET", "PUT")
+	protected.HandleFunc("/hosts/{id}/ip-history", s.handleHostIPHistory).Methods("GET")
+	protected.HandleFunc("/hosts/{id}/alerts", s.handleHostAlerts).Methods("GET")
+	protected.HandleFunc("/hosts/{id}/alerts/{alertId}", s.handleHostAlert).Methods("DELETE")
+	protected.HandleFunc("/hosts/{id}/alerts/{alertId}/apply", s.handleApplyHostAlert).Methods("POST")
+	protected.HandleFunc("/alerts", s.handleAlerts).Methods("GET")
+	protected.HandleFunc("/relays", s.handleRelays).Methods("GET")
+	protected.HandleFunc("/proxies/web/{name}/status", s.handleWebProxyStatus).Methods("GET")
 
 	// Ping endpoints
 	protected.HandleFunc("/ping", s.handlePing).Methods("POST")
 	protected.HandleFunc("/ping/bulk", s.handleBulkPing).Methods("POST")
 
+	// Network discovery (superuser only when auth is enabled)
+	protected.HandleFunc("/discovery/scan", s.handleDiscoveryScan).Methods("POST")
+
+	// DHCP lease/reservation import (superuser only when auth is enabled)
+	protected.HandleFunc("/import/dhcp", s.handleDHCPImport).Methods("POST")
+
 	// Wake-on-LAN endpoint
 	protected.HandleFunc("/wake", s.handleWake).Methods("POST")
+	protected.HandleFunc("/wake/chains/{id}", s.handleWakeChain).Methods("GET")
+
+	// Audit log endpoint (superuser only when auth is enabled)
+	protected.HandleFunc("/audit", s.handleAuditLog).Methods("GET")
+
+	// Runtime settings stored in the database (superuser only; changes require auth)
+	protected.HandleFunc("/settings", s.handleGetSettings).Methods("GET")
+	protected.HandleFunc("/settings", s.handleUpdateSettings).Methods("PUT")
 
 	// User management endpoints (superuser only)
 	protected.HandleFunc("/users", s.handleUsers).Methods("GET", "POST")
 	protected.HandleFunc("/users/{id}", s.handleUserDetail).Methods("GET", "PUT", "DELETE")
 
+	// Waking web proxies (before the SPA catch-all)
+	s.setupWebProxyRoutes(router)
+
 	// Setup static file serving with SPA routing support
 	if apiPrefix != "" {
 		// With prefix: serve static files at the prefix root and catch-all
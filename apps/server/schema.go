@@ -45,6 +45,109 @@ func initSchema(db *sql.DB) error {
 			created DATETIME DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 		)`,
+
+		// Host dependencies table - wake chain ordering (host_id requires depends_on_id to be woken first)
+		`CREATE TABLE IF NOT EXISTS host_dependencies (
+			host_id TEXT NOT NULL,
+			depends_on_id TEXT NOT NULL,
+			position INTEGER NOT NULL DEFAULT 0,
+			delay_seconds INTEGER NOT NULL DEFAULT 0,
+			wait_online BOOLEAN DEFAULT FALSE,
+			timeout_seconds INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (host_id, depends_on_id),
+			FOREIGN KEY (host_id) REFERENCES hosts(id) ON DELETE CASCADE,
+			FOREIGN KEY (depends_on_id) REFERENCES hosts(id) ON DELETE CASCADE
+		)`,
+
+		// Host power actions table - SSH shutdown/sleep configuration (private key encrypted at rest)
+		`CREATE TABLE IF NOT EXISTS host_power_actions (
+			host_id TEXT PRIMARY KEY,
+			ssh_address TEXT,
+			ssh_port INTEGER NOT NULL DEFAULT 22,
+			ssh_user TEXT NOT NULL,
+			command TEXT NOT NULL,
+			private_key BLOB NOT NULL,
+			host_key_fingerprint TEXT,
+			created DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (host_id) REFERENCES hosts(id) ON DELETE CASCADE
+		)`,
+
+		// Host relays table - hosts on remote networks woken and checked by a relay node
+		`CREATE TABLE IF NOT EXISTS host_relays (
+			host_id TEXT PRIMARY KEY,
+			relay TEXT NOT NULL,
+			FOREIGN KEY (host_id) REFERENCES hosts(id) ON DELETE CASCADE
+		)`,
+
+		// Host services table - TCP/HTTP(S) checks run when a status check finds the host online
+		`CREATE TABLE IF NOT EXISTS host_services (
+			host_id TEXT NOT NULL,
+			position INTEGER NOT NULL DEFAULT 0,
+			name TEXT NOT NULL,
+			type TEXT NOT NULL,
+			port INTEGER NOT NULL,
+			path TEXT NOT NULL DEFAULT '',
+			expect_status INTEGER NOT NULL DEFAULT 0,
+			expect_body TEXT NOT NULL DEFAULT '',
+			tls_skip_verify BOOLEAN DEFAULT FALSE,
+			timeout_seconds INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (host_id, position),
+			FOREIGN KEY (host_id) REFERENCES hosts(id) ON DELETE CASCADE
+		)`,
+
+		// Host IP history table - addresses a host was found at; a new row is added when the
+		// address (of the same family) changes, otherwise last_seen and sightings are updated
+		`CREATE TABLE IF NOT EXISTS host_ip_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			host_id TEXT NOT NULL,
+			ip TEXT NOT NULL,
+			family INTEGER NOT NULL,
+			first_seen DATETIME DEFAULT CURRENT_TIMESTAMP,
+			last_seen DATETIME DEFAULT CURRENT_TIMESTAMP,
+			sightings INTEGER NOT NULL DEFAULT 1,
+			FOREIGN KEY (host_id) REFERENCES hosts(id) ON DELETE CASCADE
+		)`,
+
+		// Host alerts table - MAC mismatches, IP changes and static IP suggestions found by status checks
+		`CREATE TABLE IF NOT EXISTS host_alerts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			host_id TEXT NOT NULL,
+			type TEXT NOT NULL,
+			ip TEXT NOT NULL DEFAULT '',
+			previous_ip TEXT NOT NULL DEFAULT '',
+			expected_mac TEXT NOT NULL DEFAULT '',
+			detected_mac TEXT NOT NULL DEFAULT '',
+			message TEXT NOT NULL,
+			count INTEGER NOT NULL DEFAULT 1,
+			created DATETIME DEFAULT CURRENT_TIMESTAMP,
+			last_seen DATETIME DEFAULT CURRENT_TIMESTAMP,
+			dismissed BOOLEAN DEFAULT FALSE,
+			FOREIGN KEY (host_id) REFERENCES hosts(id) ON DELETE CASCADE
+		)`,
+
+		// Audit log table - security-relevant actions (remote shutdown, etc.)
+		`CREATE TABLE IF NOT EXISTS audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			time DATETIME DEFAULT CURRENT_TIMESTAMP,
+			user_id TEXT,
+			actor TEXT NOT NULL,
+			action TEXT NOT NULL,
+			host_id TEXT,
+			host_name TEXT,
+			success BOOLEAN DEFAULT FALSE,
+			detail TEXT,
+			remote_ip TEXT
+		)`,
+
+		// Settings table - configuration changed through /api/settings, one JSON value per
+		// config key (see settingsFields); takes precedence over config.json
+		`CREATE TABLE IF NOT EXISTS settings (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL,
+			updated DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_by TEXT
+		)`,
 	}
 
 	for _, query := range tables {
@@ -58,6 +161,11 @@ func initSchema(db *sql.DB) error {
 		`CREATE INDEX IF NOT EXISTS idx_hosts_user_id ON hosts(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_sessions_expires ON sessions(expires)`,
+		`CREATE INDEX IF NOT EXISTS idx_host_dependencies_depends_on ON host_dependencies(depends_on_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_host_ip_history_host_id ON host_ip_history(host_id, family)`,
+		`CREATE INDEX IF NOT EXISTS idx_host_alerts_host_id ON host_alerts(host_id, dismissed)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_time ON audit_log(time)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_host_id ON audit_log(host_id)`,
 	}
 
 	for _, query := range indexes {
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sdNotify reports state changes to systemd for Type=notify services ("READY=1",
+// "STOPPING=1", "WATCHDOG=1", "STATUS=..."). Without NOTIFY_SOCKET it does nothing.
+func sdNotify(states ...string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:] // Abstract socket
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		Debug("sd_notify: %v", err)
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(strings.Join(states, "\n"))); err != nil {
+		Debug("sd_notify: %v", err)
+	}
+}
+
+// watchdogInterval returns how often to ping the systemd watchdog (half of WatchdogSec),
+// or 0 when the watchdog is not enabled for this process
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// watchdog pings the systemd watchdog while the database answers, so systemd restarts
+// a server that hangs
+func (s *Server) watchdog() {
+	interval := watchdogInterval()
+	if interval == 0 {
+		return
+	}
+	Info("Systemd watchdog enabled (ping every %s)", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.stopping():
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		var one int
+		err := s.DB.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+		cancel()
+		if err != nil {
+			Warning("Watchdog: database not responding: %v", err)
+			continue
+		}
+		sdNotify("WATCHDOG=1")
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// SecretBox encrypts secrets (e.g. SSH private keys) before they are stored in the database.
+//
+// Secrets are sealed with AES-256-GCM using a 32-byte master key kept in a separate file
+// (secret_key_file, default: "wol.key" next to the database). A copy of the database alone
+// is therefore not enough to recover stored secrets.
+//
+// Each secret is bound to the row it belongs to through the GCM additional data (e.g. the
+// host ID for an SSH key), so a sealed value copied to another row does not decrypt.
+//
+// Sealed format: 12-byte random nonce followed by the GCM ciphertext and tag.
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// secretKeySize is the size of the AES-256 master key in bytes
+const secretKeySize = 32
+
+// defaultSecretKeyPath returns the default master key location next to the database file
+func defaultSecretKeyPath(dbPath string) string {
+	return filepath.Join(filepath.Dir(dbPath), "wol.key")
+}
+
+// LoadOrCreateSecretBox loads the master key from keyPath, generating a new random
+// key with owner-only permissions if the file does not exist yet
+func LoadOrCreateSecretBox(keyPath string) (*SecretBox, error) {
+	key, err := os.ReadFile(keyPath)
+	if os.IsNotExist(err) {
+		key = make([]byte, secretKeySize)
+		if _, err := io.ReadFull(rand.Reader, key); err != nil {
+			return nil, fmt.Errorf("failed to generate secret key: %w", err)
+		}
+		// O_EXCL prevents overwriting a key created concurrently by another process
+		file, err := os.OpenFile(keyPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create secret key file: %w", err)
+		}
+		if _, err := file.Write(key); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to write secret key file: %w", err)
+		}
+		if err := file.Close(); err != nil {
+			return nil, fmt.Errorf("failed to write secret key file: %w", err)
+		}
+		Info("Generated new secret key: %s", keyPath)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read secret key file: %w", err)
+	}
+
+	if len(key) != secretKeySize {
+		return nil, fmt.Errorf("secret key file %s must contain exactly %d bytes, got %d", keyPath, secretKeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize GCM: %w", err)
+	}
+
+	return &SecretBox{aead: aead}, nil
+}
+
+// Seal encrypts plaintext bound to associatedData and returns nonce||ciphertext
+func (sb *SecretBox) Seal(plaintext, associatedData []byte) ([]byte, error) {
+	nonce := make([]byte, sb.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return sb.aead.Seal(nonce, nonce, plaintext, associatedData), nil
+}
+
+// Open decrypts data produced by Seal with the same associatedData
+func (sb *SecretBox) Open(sealed, associatedData []byte) ([]byte, error) {
+	nonceSize := sb.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, fmt.Errorf("sealed secret is too short")
+	}
+	plaintext, err := sb.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], associatedData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret (wrong secret key or row?): %w", err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func newTestSecretBox(t *testing.T) *SecretBox {
+	t.Helper()
+	secrets, err := LoadOrCreateSecretBox(filepath.Join(t.TempDir(), "wol.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return secrets
+}
+
+func TestSecretBoxBindsAssociatedData(t *testing.T) {
+	secrets := newTestSecretBox(t)
+	sealed, err := secrets.Seal([]byte("private key"), powerKeyAAD("host-a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		aad   []byte
+		opens bool
+	}{
+		{"same host", powerKeyAAD("host-a"), true},
+		{"other host", powerKeyAAD("host-b"), false},
+		{"no associated data", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext, err := secrets.Open(sealed, tt.aad)
+			if opens := err == nil; opens != tt.opens {
+				t.Fatalf("Open err = %v, want opens %v", err, tt.opens)
+			}
+			if tt.opens && string(plaintext) != "private key" {
+				t.Errorf("plaintext = %q", plaintext)
+			}
+		})
+	}
+}
+
+func TestResealLegacyPowerKeys(t *testing.T) {
+	s := newTestDependencyServer(t, "a", "b")
+	s.Secrets = newTestSecretBox(t)
+
+	legacy, err := s.Secrets.Seal([]byte("key of a"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := s.Secrets.Seal([]byte("key of b"), powerKeyAAD("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for hostID, sealed := range map[string][]byte{"a": legacy, "b": current} {
+		if _, err := s.DB.Exec("INSERT INTO host_power_actions (host_id, ssh_user, command, private_key) VALUES (?, 'root', 'poweroff', ?)", hostID, sealed); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.resealLegacyPowerKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	for hostID, want := range map[string]string{"a": "key of a", "b": "key of b"} {
+		_, sealed, err := s.loadPowerActionWithKey(hostID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plaintext, err := s.Secrets.Open(sealed, powerKeyAAD(hostID))
+		if err != nil || string(plaintext) != want {
+			t.Errorf("host %s: Open = %q, %v; want %q", hostID, plaintext, err, want)
+		}
+		if hostID == "b" && !bytes.Equal(sealed, current) {
+			t.Errorf("host b: key already bound to its host was rewritten")
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// HostService is a TCP or HTTP(S) check of a service running on a host
+type HostService struct {
+	Name           string `json:"name"`                      // Display name, e.g. "SSH" or "Web UI"
+	Type           string `json:"type"`                      // "tcp", "http" or "https"
+	Port           int    `json:"port"`                      // TCP port on the host
+	Path           string `json:"path,omitempty"`            // HTTP(S) request path (default "/")
+	ExpectStatus   int    `json:"expect_status,omitempty"`   // Expected HTTP status (0 = any 2xx/3xx)
+	ExpectBody     string `json:"expect_body,omitempty"`     // Text the HTTP response body must contain
+	TLSSkipVerify  bool   `json:"tls_skip_verify,omitempty"` // Accept self-signed certificates (https)
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"` // Check timeout (default DefaultServiceTimeoutSeconds)
+}
+
+// ServiceResult is the outcome of one service check
+type ServiceResult struct {
+	Name      string  `json:"name"`
+	Up        bool    `json:"up"`
+	LatencyMS float64 `json:"latency_ms,omitempty"` // Connect time (tcp) or time to the response headers (http)
+	Status    int     `json:"status,omitempty"`     // HTTP status code
+	Error     string  `json:"error,omitempty"`
+}
+
+// Service check types
+const (
+	ServiceTypeTCP   = "tcp"
+	ServiceTypeHTTP  = "http"
+	ServiceTypeHTTPS = "https"
+)
+
+// validateHostService checks and normalizes a service check definition
+func validateHostService(service *HostService) error {
+	service.Name = strings.TrimSpace(service.Name)
+	service.Type = strings.ToLower(strings.TrimSpace(service.Type))
+	service.Path = strings.TrimSpace(service.Path)
+
+	if service.Name == "" {
+		return fmt.Errorf("service name is required")
+	}
+	if len(service.Name) > MaxServiceNameLength {
+		return fmt.Errorf("service name '%s' is too long (max %d characters)", service.Name, MaxServiceNameLength)
+	}
+	if service.Port < 1 || service.Port > 65535 {
+		return fmt.Errorf("service '%s': port must be between 1-65535", service.Name)
+	}
+	if service.TimeoutSeconds < 0 || service.TimeoutSeconds > MaxServiceTimeoutSeconds {
+		return fmt.Errorf("service '%s': timeout_seconds must be between 0-%d", service.Name, MaxServiceTimeoutSeconds)
+	}
+
+	switch service.Type {
+	case ServiceTypeTCP:
+		if service.Path != "" || service.ExpectStatus != 0 || service.ExpectBody != "" || service.TLSSkipVerify {
+			return fmt.Errorf("service '%s': path, expect_status, expect_body and tls_skip_verify only apply to http and https checks", service.Name)
+		}
+	case ServiceTypeHTTP, ServiceTypeHTTPS:
+		if service.Path == "" {
+			service.Path = "/"
+		}
+		if !strings.HasPrefix(service.Path, "/") || strings.ContainsAny(service.Path, " \t\r\n") {
+			return fmt.Errorf("service '%s': path must start with '/' and contain no whitespace", service.Name)
+		}
+		if service.ExpectStatus != 0 && (service.ExpectStatus < 100 || service.ExpectStatus > 599) {
+			return fmt.Errorf("service '%s': expect_status must be between 100-599", service.Name)
+		}
+		if len(service.ExpectBody) > MaxServiceExpectBodyLength {
+			return fmt.Errorf("service '%s': expect_body is too long (max %d characters)", service.Name, MaxServiceExpectBodyLength)
+		}
+		if service.Type == ServiceTypeHTTP && service.TLSSkipVerify {
+			return fmt.Errorf("service '%s': tls_skip_verify only applies to https checks", service.Name)
+		}
+	default:
+		return fmt.Errorf("service '%s': type must be tcp, http or https", service.Name)
+	}
+	return nil
+}
+
+// loadHostServices returns the service checks of a host in declaration order
+func (s *Server) loadHostServices(ctx context.Context, hostID string) ([]HostService, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT name, type, port, path, expect_status, expect_body, tls_skip_verify, timeout_seconds
+		FROM host_services WHERE host_id = ? ORDER BY position`, hostID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var services []HostService
+	for rows.Next() {
+		var service HostService
+		if err := rows.Scan(&service.Name, &service.Type, &service.Port, &service.Path, &service.ExpectStatus,
+			&service.ExpectBody, &service.TLSSkipVerify, &service.TimeoutSeconds); err != nil {
+			return nil, err
+		}
+		services = append(services, service)
+	}
+	return services, rows.Err()
+}
+
+// checkHostServices runs the service checks of a host that a status check found online,
+// all at once, against the address it was found at. Offline hosts are not checked.
+func (s *Server) checkHostServices(ctx context.Context, host Host, result *hostProbeResult) {
+	if result.IP == "" || !(result.PingSuccess || result.ARPSuccess) {
+		return
+	}
+	ctx, span := startSpan(ctx, "checkHostServices", attribute.String("host.id", host.ID), attribute.String("host.ip", result.IP))
+	defer span.End()
+
+	services, err := s.loadHostServices(ctx, host.ID)
+	if err != nil {
+		Error("Failed to load service checks of host '%s': %v", host.Name, err)
+		return
+	}
+	if len(services) == 0 {
+		return
+	}
+
+	// Hosts on a relay's network can only be reached from there
+	if result.Method == ProbeMethodRelay {
+		result.Services = s.checkServicesViaRelay(ctx, host, result.IP, services)
+		return
+	}
+	result.Services = s.runServiceChecks(host, result.IP, services)
+}
+
+// runServiceChecks runs service checks against ip in parallel
+func (s *Server) runServiceChecks(host Host, ipAddress string, services []HostService) []ServiceResult {
+	zone := ""
+	if ip := net.ParseIP(ipAddress); ip != nil && ip.IsLinkLocalUnicast() {
+		// Link-local addresses need the interface; use the host's interface when there is only one
+		if iface := s.determineNetworkInterface(host); !strings.Contains(iface, ",") {
+			zone = iface
+		}
+	}
+
+	results := make([]ServiceResult, len(services))
+	var wg sync.WaitGroup
+	for i, service := range services {
+		wg.Add(1)
+		go func(i int, service HostService) {
+			defer wg.Done()
+			results[i] = checkService(ipAddress, zone, service)
+		}(i, service)
+	}
+	wg.Wait()
+
+	for _, r := range results {
+		if !r.Up {
+			Debug("Host '%s' service '%s' is down: %s", host.Name, r.Name, r.Error)
+		}
+	}
+	return results
+}
+
+// checkService runs one TCP or HTTP(S) check against an address
+func checkService(ip, zone string, service HostService) ServiceResult {
+	timeout := time.Duration(service.TimeoutSeconds) * time.Second
+	if timeout == 0 {
+		timeout = DefaultServiceTimeoutSeconds * time.Second
+	}
+	host := ip
+	if zone != "" {
+		host = ip + "%" + zone
+	}
+	address := net.JoinHostPort(host, strconv.Itoa(service.Port))
+	result := ServiceResult{Name: service.Name}
+
+	if service.Type == ServiceTypeTCP {
+		start := time.Now()
+		conn, err := net.DialTimeout("tcp", address, timeout)
+		if err != nil {
+			result.Error = serviceError(err)
+			return result
+		}
+		conn.Close()
+		result.Up = true
+		result.LatencyMS = roundMS(time.Since(start))
+		return result
+	}
+
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:             nil, // Hosts are on the local network - never use a proxy from the environment
+			DisableKeepAlives: true,
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: service.TLSSkipVerify},
+		},
+		// Redirects are an answer too (e.g. to a login page) - do not follow them
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, "GET", service.Type+"://"+strings.ReplaceAll(address, "%", "%25")+service.Path, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	request.Header.Set("User-Agent", "wol-web/"+Version)
+
+	start := time.Now()
+	response, err := client.Do(request)
+	if err != nil {
+		result.Error = serviceError(err)
+		return result
+	}
+	defer response.Body.Close()
+	result.LatencyMS = roundMS(time.Since(start))
+	result.Status = response.StatusCode
+
+	switch {
+	case service.ExpectStatus != 0 && response.StatusCode != service.ExpectStatus:
+		result.Error = fmt.Sprintf("status %d, expected %d", response.StatusCode, service.ExpectStatus)
+	case service.ExpectStatus == 0 && response.StatusCode >= 400:
+		result.Error = fmt.Sprintf("status %d", response.StatusCode)
+	case service.ExpectBody != "":
+		body, err := io.ReadAll(io.LimitReader(response.Body, MaxServiceResponseBytes))
+		if err != nil {
+			result.Error = serviceError(err)
+		} else if !strings.Contains(string(body), service.ExpectBody) {
+			result.Error = "response does not contain the expected text"
+		} else {
+			result.Up = true
+		}
+	default:
+		result.Up = true
+	}
+	return result
+}
+
+// serviceError shortens network errors for display ("connection refused", "timeout")
+func serviceError(err error) string {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return "timeout"
+	}
+	message := err.Error()
+	if i := strings.LastIndex(message, ": "); i >= 0 {
+		message = message[i+2:]
+	}
+	return message
+}
+
+// roundMS converts a duration to milliseconds with two decimals
+func roundMS(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Millisecond)*100) / 100
+}
+
+// addServices adds service check results to a ping response. services_ready is true
+// when every service is up, so "online but not ready yet" can be told apart.
+func addServices(response map[string]interface{}, services []ServiceResult) map[string]interface{} {
+	if len(services) == 0 {
+		return response
+	}
+	ready := true
+	for _, service := range services {
+		ready = ready && service.Up
+	}
+	response["services"] = services
+	response["services_ready"] = ready
+	return response
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// settingsFields are the configuration keys that can be changed through /api/settings, with
+// the environment variable overriding each. Precedence: environment > database > config file.
+var settingsFields = []struct {
+	Name string
+	Env  string
+}{
+	{"ping_timeout_seconds", "PING_TIMEOUT_SECONDS"},
+	{"ping_rate_limit_per_minute", "PING_RATE_LIMIT_PER_MINUTE"},
+	{"wake_rate_limit_per_minute", "WAKE_RATE_LIMIT_PER_MINUTE"},
+	{"shutdown_rate_limit_per_minute", "SHUTDOWN_RATE_LIMIT_PER_MINUTE"},
+	{"auth_expire_hours", "AUTH_EXPIRE_HOURS"},
+	{"default_network_interface", "DEFAULT_NETWORK_INTERFACE"},
+	{"readonly_mode", "READONLY_MODE"},
+}
+
+// Setting sources reported by GET /api/settings
+const (
+	SettingSourceEnv      = "env"
+	SettingSourceDatabase = "database"
+	SettingSourceConfig   = "config" // config.json or the default
+)
+
+// Setting is the current value of a setting and where it comes from
+type Setting struct {
+	Value     json.RawMessage `json:"value"`
+	Source    string          `json:"source"`
+	Env       string          `json:"env"`
+	Locked    bool            `json:"locked"` // Set by the environment variable, cannot be changed here
+	Updated   *time.Time      `json:"updated,omitempty"`
+	UpdatedBy string          `json:"updated_by,omitempty"`
+}
+
+// SettingsResponse is returned by GET and PUT /api/settings
+type SettingsResponse struct {
+	Settings map[string]Setting `json:"settings"`
+}
+
+// storedSetting is a row of the settings table
+type storedSetting struct {
+	Value     json.RawMessage
+	Updated   time.Time
+	UpdatedBy string
+}
+
+// settingEnv returns the environment variable of a settings key, or false for other keys
+func settingEnv(name string) (string, bool) {
+	for _, field := range settingsFields {
+		if field.Name == name {
+			return field.Env, true
+		}
+	}
+	return "", false
+}
+
+// storedSettings returns the settings saved in the database
+func (s *Server) storedSettings() (map[string]storedSetting, error) {
+	rows, err := s.DB.Query("SELECT key, value, updated, updated_by FROM settings")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stored := make(map[string]storedSetting)
+	for rows.Next() {
+		var key, value string
+		var updated sql.NullTime
+		var updatedBy sql.NullString
+		if err := rows.Scan(&key, &value, &updated, &updatedBy); err != nil {
+			return nil, err
+		}
+		stored[key] = storedSetting{Value: json.RawMessage(value), Updated: updated.Time, UpdatedBy: updatedBy.String}
+	}
+	return stored, rows.Err()
+}
+
+// withStoredSettings returns base with the database settings applied and validated, and the
+// keys taken from the database. changes (nil values remove a setting) are applied on top of
+// the stored settings, so a change can be checked before it is saved. Settings whose
+// environment variable is set keep the environment value.
+func (s *Server) withStoredSettings(base *Config, changes map[string]json.RawMessage) (*Config, []string, error) {
+	stored, err := s.storedSettings()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read settings: %w", err)
+	}
+
+	merged := *base
+	var names []string
+	for _, field := range settingsFields {
+		row, ok := stored[field.Name]
+		value := row.Value
+		if change, changed := changes[field.Name]; changed {
+			value, ok = change, change != nil
+		}
+		if !ok || os.Getenv(field.Env) != "" {
+			continue
+		}
+
+		// Decode one key at a time so a wrong type names the setting
+		object := append(append([]byte(`{"`+field.Name+`":`), value...), '}')
+		if err := json.Unmarshal(object, &merged); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				return nil, nil, fmt.Errorf("invalid %s: expected %s, got %s", field.Name, typeErr.Type, typeErr.Value)
+			}
+			return nil, nil, fmt.Errorf("invalid %s: %v", field.Name, err)
+		}
+		names = append(names, field.Name)
+	}
+
+	if err := validateConfig(&merged); err != nil {
+		return nil, nil, err
+	}
+	return &merged, names, nil
+}
+
+// loadSettings applies the settings stored in the database at startup
+func (s *Server) loadSettings() {
+	s.Runtime.mutex.Lock()
+	defer s.Runtime.mutex.Unlock()
+
+	s.applyBaseConfig(s.Runtime.base)
+	if len(s.Runtime.database) > 0 {
+		Info("Using settings stored in the database: %s", strings.Join(s.Runtime.database, ", "))
+	}
+}
+
+// settingsResponse describes the current value and source of each setting
+func (s *Server) settingsResponse() (SettingsResponse, error) {
+	stored, err := s.storedSettings()
+	if err != nil {
+		return SettingsResponse{}, err
+	}
+	current, err := json.Marshal(s.live())
+	if err != nil {
+		return SettingsResponse{}, err
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(current, &values); err != nil {
+		return SettingsResponse{}, err
+	}
+
+	response := SettingsResponse{Settings: make(map[string]Setting, len(settingsFields))}
+	for _, field := range settingsFields {
+		setting := Setting{Value: values[field.Name], Source: SettingSourceConfig, Env: field.Env}
+		if os.Getenv(field.Env) != "" {
+			setting.Source = SettingSourceEnv
+			setting.Locked = true
+		} else if slices.Contains(s.Runtime.database, field.Name) {
+			setting.Source = SettingSourceDatabase
+			row := stored[field.Name]
+			if !row.Updated.IsZero() {
+				setting.Updated = &row.Updated
+			}
+			setting.UpdatedBy = row.UpdatedBy
+		}
+		response.Settings[field.Name] = setting
+	}
+	return response, nil
+}
+
+// handleGetSettings returns the settings that can be changed at runtime, with their current
+// values and sources (superuser only when auth is enabled)
+func (s *Server) handleGetSettings(w http.ResponseWriter, r *http.Request) {
+	if s.Config.UseAuth {
+		if _, ok := s.checkSuperuser(w, r); !ok {
+			return
+		}
+	}
+
+	s.Runtime.mutex.Lock()
+	response, err := s.settingsResponse()
+	s.Runtime.mutex.Unlock()
+	if err != nil {
+		Error("Failed to read settings: %v", err)
+		sendJSONErrorWithCode(w, "Failed to read settings", ErrCodeDatabaseError, http.StatusInternalServerError)
+		return
+	}
+	sendJSON(w, response, http.StatusOK)
+}
+
+// handleUpdateSettings stores settings in the database and applies them immediately
+// (superuser only, requires auth). The body maps keys to values; null removes the stored
+// value so the config file applies again.
+//
+// Example: {"ping_timeout_seconds": 3, "readonly_mode": null}
+func (s *Server) handleUpdateSettings(w http.ResponseWriter, r *http.Request) {
+	// Without auth anyone could lift readonly_mode, so settings are only changed by superusers
+	if !s.Config.UseAuth {
+		sendJSONErrorWithCode(w, "Changing settings requires authentication (use_auth)", ErrCodeForbidden, http.StatusForbidden)
+		return
+	}
+	user, ok := s.checkSuperuser(w, r)
+	if !ok {
+		return
+	}
+
+	var changes map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+		sendJSONErrorWithCode(w, "Invalid request body", ErrCodeInvalidInput, http.StatusBadRequest)
+		return
+	}
+	if len(changes) == 0 {
+		sendJSONErrorWithCode(w, "No settings given", ErrCodeMissingField, http.StatusBadRequest)
+		return
+	}
+
+	var details []string
+	for name, value := range changes {
+		env, known := settingEnv(name)
+		if !known {
+			sendJSONErrorWithCode(w, fmt.Sprintf("Unknown setting '%s'", name), ErrCodeInvalidInput, http.StatusBadRequest)
+			return
+		}
+		if os.Getenv(env) != "" {
+			sendJSONErrorWithCode(w, fmt.Sprintf("%s is set by the %s environment variable", name, env), ErrCodeSettingLocked, http.StatusConflict)
+			return
+		}
+		if bytes.Equal(bytes.TrimSpace(value), []byte("null")) {
+			changes[name] = nil
+			details = append(details, name+" reset")
+			continue
+		}
+		if name == "default_network_interface" {
+			var interfaces string
+			if json.Unmarshal(value, &interfaces) == nil {
+				if err := validateNetworkInterface(interfaces); err != nil {
+					handleValidationError(w, err, http.StatusBadRequest)
+					return
+				}
+			}
+		}
+		details = append(details, name+"="+string(value))
+	}
+	slices.Sort(details)
+
+	s.Runtime.mutex.Lock()
+	defer s.Runtime.mutex.Unlock()
+
+	effective, names, err := s.withStoredSettings(s.Runtime.base, changes)
+	if err != nil {
+		sendJSONErrorWithCode(w, err.Error(), ErrCodeInvalidInput, http.StatusBadRequest)
+		return
+	}
+	if err := s.saveSettings(changes, user.Name); err != nil {
+		Error("Failed to save settings: %v", err)
+		sendJSONErrorWithCode(w, "Failed to save settings", ErrCodeDatabaseError, http.StatusInternalServerError)
+		return
+	}
+	s.Runtime.database = names
+	s.applyLiveConfig(effective)
+
+	entry := s.newAuditEntry(r, AuditActionSettingsChange, Host{})
+	entry.Success = true
+	entry.Detail = strings.Join(details, ", ")
+	s.recordAudit(entry)
+
+	response, err := s.settingsResponse()
+	if err != nil {
+		Error("Failed to read settings: %v", err)
+		sendJSONErrorWithCode(w, "Failed to read settings", ErrCodeDatabaseError, http.StatusInternalServerError)
+		return
+	}
+	sendJSON(w, response, http.StatusOK)
+}
+
+// saveSettings stores (or with a nil value removes) settings in one transaction
+func (s *Server) saveSettings(changes map[string]json.RawMessage, updatedBy string) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for name, value := range changes {
+		if value == nil {
+			_, err = tx.Exec("DELETE FROM settings WHERE key = ?", name)
+		} else {
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, value); err != nil {
+				return err
+			}
+			_, err = tx.Exec(`INSERT INTO settings (key, value, updated, updated_by) VALUES (?, ?, ?, ?)
+				ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated = excluded.updated, updated_by = excluded.updated_by`,
+				name, compact.String(), time.Now(), updatedBy)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
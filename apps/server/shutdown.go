@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// stopping returns a channel that is closed when the server starts shutting down
+// (never closed without a lifecycle, e.g. in relay mode)
+func (s *Server) stopping() <-chan struct{} {
+	if s.Lifecycle == nil {
+		return nil
+	}
+	return s.Lifecycle.Done()
+}
+
+// isStopping reports whether the server is shutting down
+func (s *Server) isStopping() bool {
+	return s.Lifecycle != nil && s.Lifecycle.Err() != nil
+}
+
+// closeOnStop closes a listener or socket when the server starts shutting down
+func (s *Server) closeOnStop(c io.Closer) {
+	if s.Lifecycle == nil {
+		return
+	}
+	go func() {
+		<-s.Lifecycle.Done()
+		c.Close()
+	}()
+}
+
+// serve runs the HTTP server on all listeners until SIGINT or SIGTERM and then shuts down gracefully:
+// background loops and listeners stop, in-flight requests (including streaming bulk
+// pings) get up to shutdown_timeout_seconds to complete and the database WAL is
+// checkpointed. The caller closes the database after serve returns.
+func (s *Server) serve(httpServer *http.Server, listeners []net.Listener, stop context.CancelFunc) {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	serveErr := make(chan error, len(listeners))
+	addresses := make([]string, len(listeners))
+	for i, listener := range listeners {
+		addresses[i] = listener.Addr().String()
+		go func() {
+			serveErr <- httpServer.Serve(listener)
+		}()
+	}
+
+	sdNotify("READY=1", "STATUS=Listening on "+strings.Join(addresses, ", "))
+	go s.watchdog()
+
+	select {
+	case err := <-serveErr:
+		Fatal("Failed to start server: %v", err)
+	case sig := <-signals:
+		Info("Received %s - shutting down (waiting up to %ds for requests to complete)", sig, s.Config.ShutdownTimeout)
+	}
+	sdNotify("STOPPING=1", "STATUS=Shutting down")
+	stop()
+
+	// A second signal skips the drain
+	go func() {
+		if sig, ok := <-signals; ok {
+			Warning("Received %s again - closing open connections", sig)
+			httpServer.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.Config.ShutdownTimeout)*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		Warning("Requests still running after %ds - closing open connections: %v", s.Config.ShutdownTimeout, err)
+		httpServer.Close()
+	}
+
+	s.PingCache.Close()
+	s.Agents.Close()
+	s.checkpointDatabase()
+	Info("Shutdown complete")
+}
+
+// checkpointDatabase moves the WAL into the database file and truncates it, so a
+// stopped server leaves a self-contained wol.db
+func (s *Server) checkpointDatabase() {
+	if _, err := s.DB.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		Warning("Failed to checkpoint database WAL: %v", err)
+		return
+	}
+	Debug("Database WAL checkpointed")
+}
+
+// cleanupRateLimiters periodically removes stale rate limiter entries
+func (s *Server) cleanupRateLimiters() {
+	ticker := time.NewTicker(RateLimiterCleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.PingRateLimit.CleanupOldEntries()
+			s.WoLRateLimit.CleanupOldEntries()
+			s.ShutdownRateLimit.CleanupOldEntries()
+		case <-s.stopping():
+			return
+		}
+	}
+}
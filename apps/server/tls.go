@@ -0,0 +1,392 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/acme"
+)
+
+// tlsEnabled reports whether the server serves HTTPS itself
+func (c *Config) tlsEnabled() bool {
+	return c.TLSCertFile != "" || c.TLSSelfSigned || c.ACMEDomains != ""
+}
+
+// certReloader serves the configured certificate and picks up renewed files without a restart
+type certReloader struct {
+	certFile string
+	keyFile  string
+	mutex    sync.RWMutex
+	cert     *tls.Certificate
+	modTime  time.Time // Newest modification time of the files when they were loaded
+}
+
+// newCertReloader loads a certificate and key pair
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	c := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// load (re)reads the certificate and key files
+func (c *certReloader) load() error {
+	modTime := c.filesModTime()
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate %s: %w", c.certFile, err)
+	}
+
+	c.mutex.Lock()
+	c.cert = &cert
+	c.modTime = modTime
+	c.mutex.Unlock()
+	return nil
+}
+
+// filesModTime returns the newest modification time of the certificate and key files
+func (c *certReloader) filesModTime() time.Time {
+	var newest time.Time
+	for _, path := range []string{c.certFile, c.keyFile} {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return newest
+}
+
+// GetCertificate returns the current certificate for a TLS handshake
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.cert, nil
+}
+
+// expires returns when the current certificate expires
+func (c *certReloader) expires() time.Time {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if c.cert.Leaf == nil {
+		return time.Time{}
+	}
+	return c.cert.Leaf.NotAfter
+}
+
+// watchCertificate reloads the certificate when its files change (e.g. renewed by certbot).
+// A broken pair (key and certificate not replaced together yet) keeps the previous one.
+func (s *Server) watchCertificate(c *certReloader) {
+	ticker := time.NewTicker(TLSReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.stopping():
+			return
+		}
+
+		// Replace a generated certificate that expires or misses a new address; the
+		// new files are picked up below
+		if s.Config.TLSSelfSigned {
+			if _, err := ensureSelfSignedCertificate(c.certFile, c.keyFile); err != nil {
+				Warning("Failed to regenerate self-signed TLS certificate: %v", err)
+			}
+		}
+
+		c.mutex.RLock()
+		loaded := c.modTime
+		c.mutex.RUnlock()
+		if !c.filesModTime().After(loaded) {
+			continue
+		}
+
+		if err := c.load(); err != nil {
+			Warning("Failed to reload TLS certificate (keeping the previous one): %v", err)
+			continue
+		}
+		Info("Reloaded TLS certificate %s (expires %s)", c.certFile, c.expires().Format("2006-01-02"))
+	}
+}
+
+// newTLSConfig builds the HTTPS configuration. With tls_self_signed and no certificate
+// files configured, a certificate is generated next to the database on first run.
+// With acme_domains, certificates come from the ACME manager instead.
+func (s *Server) newTLSConfig(dbPath string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	if err := s.setClientCAs(tlsConfig); err != nil {
+		return nil, err
+	}
+
+	if s.Config.ACMEDomains != "" {
+		manager, err := s.newACMEManager(dbPath)
+		if err != nil {
+			return nil, err
+		}
+		s.ACME = manager
+		tlsConfig.GetCertificate = s.ACME.GetCertificate
+		tlsConfig.NextProtos = append(tlsConfig.NextProtos, acme.ALPNProto) // TLS-ALPN-01 challenges
+		return tlsConfig, nil
+	}
+
+	if s.Config.TLSCertFile == "" {
+		dir := filepath.Dir(dbPath)
+		s.Config.TLSCertFile = filepath.Join(dir, SelfSignedCertFile)
+		s.Config.TLSKeyFile = filepath.Join(dir, SelfSignedKeyFile)
+	}
+	if s.Config.TLSSelfSigned {
+		created, err := ensureSelfSignedCertificate(s.Config.TLSCertFile, s.Config.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create self-signed certificate: %w", err)
+		}
+		if created {
+			Info("Generated self-signed TLS certificate %s", s.Config.TLSCertFile)
+		}
+	}
+
+	certs, err := newCertReloader(s.Config.TLSCertFile, s.Config.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	go s.watchCertificate(certs)
+
+	tlsConfig.GetCertificate = certs.GetCertificate
+	return tlsConfig, nil
+}
+
+// setClientCAs loads tls_client_ca_file. Client certificates are verified when presented;
+// clientCertMiddleware requires them for the API.
+func (s *Server) setClientCAs(tlsConfig *tls.Config) error {
+	if s.Config.TLSClientCAFile == "" {
+		return nil
+	}
+	caPEM, err := os.ReadFile(s.Config.TLSClientCAFile)
+	if err != nil {
+		return fmt.Errorf("failed to read tls_client_ca_file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return fmt.Errorf("no certificates found in tls_client_ca_file %s", s.Config.TLSClientCAFile)
+	}
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	return nil
+}
+
+// ensureSelfSignedCertificate generates an ECDSA certificate for this machine's name,
+// localhost and its addresses unless both files exist. A certificate generated earlier is
+// replaced when it is about to expire or no longer covers the name or an address (e.g. a
+// new DHCP lease); other certificates are never touched. Reports whether one was created.
+func ensureSelfSignedCertificate(certFile, keyFile string) (bool, error) {
+	hostname, dnsNames, ips := selfSignedNames()
+
+	_, certErr := os.Stat(certFile)
+	_, keyErr := os.Stat(keyFile)
+	if certErr == nil && keyErr == nil {
+		cert, err := readCertificate(certFile)
+		if err != nil {
+			return false, nil // Reported when the pair is loaded
+		}
+		reason := selfSignedRenewalReason(cert, dnsNames, ips, time.Now())
+		if reason == "" {
+			return false, nil
+		}
+		Info("Regenerating self-signed TLS certificate %s: %s", certFile, reason)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return false, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return false, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: hostname, Organization: []string{SelfSignedCertOrganization}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(SelfSignedCertValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              dnsNames,
+		IPAddresses:           ips,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return false, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// selfSignedNames returns the hostname and the names and addresses a self-signed
+// certificate is issued for: localhost, the hostname and the non-link-local addresses
+func selfSignedNames() (string, []string, []net.IP) {
+	hostname, _ := os.Hostname()
+	dnsNames := []string{"localhost"}
+	if hostname != "" && hostname != "localhost" {
+		dnsNames = append(dnsNames, hostname)
+	}
+	ips := []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && !ipNet.IP.IsLinkLocalUnicast() {
+				ips = append(ips, ipNet.IP)
+			}
+		}
+	}
+	return hostname, dnsNames, ips
+}
+
+// readCertificate parses the first certificate of a PEM file
+func readCertificate(certFile string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var block *pem.Block
+		if block, data = pem.Decode(data); block == nil {
+			return nil, fmt.Errorf("no certificate found in %s", certFile)
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
+
+// selfSignedRenewalReason returns why a certificate generated by ensureSelfSignedCertificate
+// should be replaced, or "" when it is still good or was not generated by this server
+func selfSignedRenewalReason(cert *x509.Certificate, dnsNames []string, ips []net.IP, now time.Time) string {
+	generated := false
+	for _, organization := range cert.Subject.Organization {
+		generated = generated || organization == SelfSignedCertOrganization
+	}
+	if !generated || cert.Issuer.String() != cert.Subject.String() {
+		return ""
+	}
+
+	if now.Add(SelfSignedCertRenewBefore).After(cert.NotAfter) {
+		return fmt.Sprintf("expires %s", cert.NotAfter.Format("2006-01-02"))
+	}
+	for _, name := range dnsNames {
+		if cert.VerifyHostname(name) != nil {
+			return fmt.Sprintf("does not cover %s", name)
+		}
+	}
+	for _, ip := range ips {
+		if cert.VerifyHostname(ip.String()) != nil {
+			return fmt.Sprintf("does not cover %s", ip)
+		}
+	}
+	return ""
+}
+
+// clientCertExemptRoutes are API endpoints reachable without a client certificate: the
+// health check and endpoints authenticated with their own shared tokens
+var clientCertExemptRoutes = map[string]bool{
+	"/api/health":           true,
+	"/api/agent/heartbeat":  true,
+	"/api/relay/poll":       true,
+	"/api/proxies/activity": true,
+}
+
+// clientCertMiddleware requires a client certificate signed by tls_client_ca_file for API
+// requests (mTLS). The web UI itself loads without one.
+func (s *Server) clientCertMiddleware(prefix string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := ""
+			if current := mux.CurrentRoute(r); current != nil {
+				route, _ = current.GetPathTemplate()
+				route = strings.TrimPrefix(route, prefix)
+			}
+
+			if strings.HasPrefix(route, "/api/") && !clientCertExemptRoutes[route] &&
+				(r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+				Warning("API request without a valid client certificate from %s: %s %s", s.clientIP(r), r.Method, r.URL.Path)
+				sendJSONErrorWithCode(w, "Client certificate required", ErrCodeClientCertRequired, http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// httpsRedirectHandler permanently redirects plain HTTP requests to the HTTPS listener
+// (the first TCP listen_address)
+func (s *Server) httpsRedirectHandler() http.Handler {
+	var httpsPort string
+	for _, address := range listenAddresses(s.Config.ListenAddress) {
+		if _, ok := unixSocketPath(address); !ok {
+			_, httpsPort, _ = net.SplitHostPort(address)
+			break
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]" // IPv6 literal
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host += ":" + httpsPort
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// startHTTPSRedirect listens on tls_redirect_address and redirects to HTTPS. With ACME it
+// also answers HTTP-01 challenges, which the CA sends to port 80.
+func (s *Server) startHTTPSRedirect() error {
+	listener, err := net.Listen("tcp", s.Config.TLSRedirectAddress)
+	if err != nil {
+		return err
+	}
+	s.closeOnStop(listener)
+
+	handler := s.httpsRedirectHandler()
+	if s.ACME != nil {
+		handler = s.ACME.HTTPHandler(handler)
+	}
+	go func() {
+		server := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+		if err := server.Serve(listener); err != nil && !s.isStopping() {
+			Warning("HTTP redirect listener on %s stopped: %v", s.Config.TLSRedirectAddress, err)
+		}
+	}()
+	return nil
+}
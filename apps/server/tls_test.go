@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEnsureSelfSignedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, SelfSignedCertFile), filepath.Join(dir, SelfSignedKeyFile)
+
+	created, err := ensureSelfSignedCertificate(certFile, keyFile)
+	if err != nil || !created {
+		t.Fatalf("first call: created = %v, err = %v; want a new certificate", created, err)
+	}
+	if _, err := newCertReloader(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	created, err = ensureSelfSignedCertificate(certFile, keyFile)
+	if err != nil || created {
+		t.Fatalf("second call: created = %v, err = %v; want the existing certificate kept", created, err)
+	}
+
+	cert, err := readCertificate(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, dnsNames, ips := selfSignedNames()
+	foreign := *cert
+	foreign.Subject.Organization = []string{"Example Corp"}
+
+	tests := []struct {
+		name     string
+		cert     bool // Generated certificate (foreign otherwise)
+		dnsNames []string
+		ips      []net.IP
+		now      time.Time
+		renew    bool
+	}{
+		{"current", true, dnsNames, ips, time.Now(), false},
+		{"new address", true, dnsNames, append(ips, net.ParseIP("203.0.113.7")), time.Now(), true},
+		{"new hostname", true, append(dnsNames, "renamed-host"), ips, time.Now(), true},
+		{"about to expire", true, dnsNames, ips, cert.NotAfter.Add(-SelfSignedCertRenewBefore / 2), true},
+		{"expired", true, dnsNames, ips, cert.NotAfter.Add(time.Hour), true},
+		{"foreign expired", false, dnsNames, ips, cert.NotAfter.Add(time.Hour), false},
+		{"foreign new address", false, dnsNames, append(ips, net.ParseIP("203.0.113.7")), time.Now(), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cert
+			if !tt.cert {
+				c = &foreign
+			}
+			reason := selfSignedRenewalReason(c, tt.dnsNames, tt.ips, tt.now)
+			if (reason != "") != tt.renew {
+				t.Errorf("reason = %q, want renewal %v", reason, tt.renew)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -83,50 +84,78 @@ func sanitizeIPAddress(ip string) error {
 	return nil
 }
 
-// sanitizeStaticIPv4 validates static IPv4 addresses for hosts
-// This is used for the static_ip field which allows manual IP specification
-func sanitizeStaticIPv4(ip string) error {
-	ip = strings.TrimSpace(ip)
+// sanitizeStaticIP validates static addresses for hosts
+// This is used for the static_ip field which allows manual IP specification.
+// Dual-stack hosts may list one IPv4 and one IPv6 address, separated by a comma.
+func sanitizeStaticIP(value string) error {
+	value = strings.TrimSpace(value)
 
 	// Empty is valid - means no static IP configured
-	if ip == "" {
+	if value == "" {
 		return nil
 	}
 
-	// Parse and validate IP address
-	parsedIP := net.ParseIP(ip)
-	if parsedIP == nil {
-		return &ValidationError{Code: ErrCodeInvalidIP, Message: fmt.Sprintf("invalid IPv4 address format: %s", ip)}
+	ips := strings.Split(value, ",")
+	if len(ips) > 2 {
+		return &ValidationError{Code: ErrCodeInvalidIP, Message: "at most one IPv4 and one IPv6 address are allowed"}
 	}
 
-	// Ensure it's IPv4 (not IPv6)
-	if parsedIP.To4() == nil {
-		return &ValidationError{Code: ErrCodeInvalidIP, Message: fmt.Sprintf("only IPv4 addresses are supported: %s", ip)}
-	}
+	families := make(map[bool]bool) // IPv4 -> seen
+	for _, ip := range ips {
+		ip = strings.TrimSpace(ip)
 
-	// Prevent access to localhost/loopback addresses
-	if parsedIP.IsLoopback() {
-		return &ValidationError{Code: ErrCodeInvalidIP, Message: "loopback addresses are not allowed"}
-	}
+		// Parse and validate IP address
+		parsedIP := net.ParseIP(ip)
+		if parsedIP == nil {
+			return &ValidationError{Code: ErrCodeInvalidIP, Message: fmt.Sprintf("invalid IP address format: %s", ip)}
+		}
 
-	// Prevent multicast addresses
-	if parsedIP.IsMulticast() {
-		return &ValidationError{Code: ErrCodeInvalidIP, Message: "multicast addresses are not allowed"}
-	}
+		isIPv4 := parsedIP.To4() != nil
+		if families[isIPv4] {
+			return &ValidationError{Code: ErrCodeInvalidIP, Message: "at most one IPv4 and one IPv6 address are allowed"}
+		}
+		families[isIPv4] = true
 
-	// Prevent broadcast address
-	if ip == "255.255.255.255" {
-		return &ValidationError{Code: ErrCodeInvalidIP, Message: "broadcast address is not allowed"}
-	}
+		// Prevent access to localhost/loopback addresses
+		if parsedIP.IsLoopback() {
+			return &ValidationError{Code: ErrCodeInvalidIP, Message: "loopback addresses are not allowed"}
+		}
 
-	// Prevent all-zero address
-	if ip == "0.0.0.0" {
-		return &ValidationError{Code: ErrCodeInvalidIP, Message: "all-zero address is not allowed"}
+		// Prevent multicast addresses
+		if parsedIP.IsMulticast() {
+			return &ValidationError{Code: ErrCodeInvalidIP, Message: "multicast addresses are not allowed"}
+		}
+
+		// Prevent broadcast address
+		if parsedIP.Equal(net.IPv4bcast) {
+			return &ValidationError{Code: ErrCodeInvalidIP, Message: "broadcast address is not allowed"}
+		}
+
+		// Prevent all-zero address
+		if parsedIP.IsUnspecified() {
+			return &ValidationError{Code: ErrCodeInvalidIP, Message: "all-zero address is not allowed"}
+		}
 	}
 
 	return nil
 }
 
+// splitStaticIPs returns the addresses of a static_ip field (IPv4 first)
+func splitStaticIPs(value string) []string {
+	var ipv4, ipv6 []string
+	for _, ip := range strings.Split(value, ",") {
+		ip = strings.TrimSpace(ip)
+		if parsed := net.ParseIP(ip); parsed == nil {
+			continue
+		} else if parsed.To4() != nil {
+			ipv4 = append(ipv4, ip)
+		} else {
+			ipv6 = append(ipv6, ip)
+		}
+	}
+	return append(ipv4, ipv6...)
+}
+
 // sanitizeMACAddress validates and sanitizes MAC addresses
 func sanitizeMACAddress(mac string) error {
 	mac = strings.TrimSpace(mac)
@@ -155,6 +184,11 @@ func sanitizeMACAddress(mac string) error {
 		return &ValidationError{Code: ErrCodeInvalidMAC, Message: "all-zero MAC address is not allowed"}
 	}
 
+	// Prevent multicast MAC addresses (least significant bit of the first octet) - no NIC has one
+	if first, _ := strconv.ParseUint(cleanMAC[:2], 16, 8); first&1 == 1 {
+		return &ValidationError{Code: ErrCodeInvalidMAC, Message: "multicast MAC address is not allowed"}
+	}
+
 	return nil
 }
 
@@ -165,20 +199,25 @@ func sanitizeBroadcastAddress(broadcast string) error {
 		return &ValidationError{Code: ErrCodeMissingField, Message: "broadcast address cannot be empty"}
 	}
 
-	// Validate format: IP:PORT
-	parts := strings.Split(broadcast, ":")
-	if len(parts) != 2 {
-		return &ValidationError{Code: ErrCodeInvalidBroadcast, Message: "broadcast address must be in format IP:PORT"}
+	// Validate format: IP:PORT or [IPv6]:PORT
+	host, port, err := net.SplitHostPort(broadcast)
+	if err != nil {
+		return &ValidationError{Code: ErrCodeInvalidBroadcast, Message: "broadcast address must be in format IP:PORT or [IPv6]:PORT"}
 	}
 
-	// Validate IP part
-	if err := sanitizeIPAddress(parts[0]); err != nil {
+	// Validate IP part - IPv6 has no broadcast, so multicast groups (e.g. ff02::1) are allowed there
+	parsedIP := net.ParseIP(host)
+	if parsedIP != nil && parsedIP.To4() == nil {
+		if parsedIP.IsLoopback() || parsedIP.IsUnspecified() {
+			return &ValidationError{Code: ErrCodeInvalidBroadcast, Message: fmt.Sprintf("invalid broadcast IP: %s", host)}
+		}
+	} else if err := sanitizeIPAddress(host); err != nil {
 		return &ValidationError{Code: ErrCodeInvalidBroadcast, Message: fmt.Sprintf("invalid broadcast IP: %v", err)}
 	}
 
 	// Validate port part (simple check)
 	portRegex := regexp.MustCompile(`^[0-9]{1,5}$`)
-	if !portRegex.MatchString(parts[1]) {
+	if !portRegex.MatchString(port) {
 		return &ValidationError{Code: ErrCodeInvalidBroadcast, Message: "invalid port format in broadcast address"}
 	}
 
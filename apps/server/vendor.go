@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// loadVendorDatabase loads the configured OUI registry file on top of the bundled vendor list
+func (s *Server) loadVendorDatabase() error {
+	if s.Config.OUIDatabase == "" {
+		Info("OUI database: %d bundled IEEE prefixes", s.Vendors.Len())
+		return nil
+	}
+	count, err := s.Vendors.ReloadFile(s.Config.OUIDatabase)
+	if err != nil {
+		return err
+	}
+	Info("OUI database: loaded %d prefixes from %s", count, s.Config.OUIDatabase)
+	return nil
+}
+
+// watchVendorDatabase reloads the OUI registry file whenever its modification time changes,
+// so the registry can be updated without restarting the server
+func (s *Server) watchVendorDatabase() {
+	path := s.Config.OUIDatabase
+	var lastModified time.Time
+	if info, err := os.Stat(path); err == nil {
+		lastModified = info.ModTime()
+	}
+
+	ticker := time.NewTicker(OUIReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.stopping():
+			return
+		}
+
+		info, err := os.Stat(path)
+		if err != nil || info.ModTime().Equal(lastModified) {
+			continue
+		}
+		lastModified = info.ModTime()
+
+		count, err := s.Vendors.ReloadFile(path)
+		if err != nil {
+			Warning("Failed to reload OUI database (keeping the previous one): %v", err)
+			continue
+		}
+		Info("OUI database: reloaded %d prefixes from %s", count, path)
+	}
+}
+
+// annotateHostVendor fills in the vendor details of a host's MAC address.
+// Hosts whose MAC address is hidden (read-only access) are left alone.
+func (s *Server) annotateHostVendor(host *Host) {
+	if host.MAC == "" {
+		return
+	}
+	info := s.Vendors.Lookup(host.MAC)
+	host.Vendor = info.Vendor
+	host.LocallyAdministered = info.LocallyAdministered
+	host.RandomizedMAC = info.Randomized
+}
+
+// describeMAC returns a MAC address with its vendor for log messages,
+// e.g. "b8:27:eb:12:34:56 (Raspberry Pi Foundation)"
+func (s *Server) describeMAC(mac string) string {
+	info := s.Vendors.Lookup(mac)
+	switch {
+	case info.Vendor != "":
+		return fmt.Sprintf("%s (%s)", mac, info.Vendor)
+	case info.Randomized:
+		return fmt.Sprintf("%s (randomized)", mac)
+	case info.LocallyAdministered:
+		return fmt.Sprintf("%s (locally administered)", mac)
+	}
+	return mac
+}
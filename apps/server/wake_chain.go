@@ -0,0 +1,527 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// HostDependency declares that a host requires another host to be woken first.
+//
+// After the dependency has been woken, the dependent host is only woken once either:
+//   - DelaySeconds have elapsed (fixed delay), or
+//   - the dependency answers a status check (WaitOnline), up to TimeoutSeconds
+type HostDependency struct {
+	DependsOnID    string `json:"depends_on"`
+	DependsOnName  string `json:"depends_on_name,omitempty"`
+	DelaySeconds   int    `json:"delay_seconds"`
+	WaitOnline     bool   `json:"wait_online"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+}
+
+// wakeChainNode is a host in a wake chain together with its direct dependencies
+type wakeChainNode struct {
+	Host         Host
+	Dependencies []HostDependency
+}
+
+// WakeStepResult reports the outcome of a single host in a wake chain
+type WakeStepResult struct {
+	HostID        string  `json:"host_id"`
+	HostName      string  `json:"host_name"`
+	Status        string  `json:"status"` // "pending", "waiting", "sent", "already_online", "failed", "dependency_timeout", "skipped"
+	WaitedSeconds float64 `json:"waited_seconds,omitempty"`
+	Error         string  `json:"error,omitempty"`
+}
+
+// Wake step statuses
+const (
+	WakeStepPending           = "pending" // Not reached yet (background chains)
+	WakeStepWaiting           = "waiting" // Waiting for dependencies to boot
+	WakeStepSent              = "sent"
+	WakeStepAlreadyOnline     = "already_online"
+	WakeStepFailed            = "failed"
+	WakeStepDependencyTimeout = "dependency_timeout"
+	WakeStepSkipped           = "skipped"
+)
+
+// hostScopeQuery returns the WHERE clause fragment and arguments that restrict hosts
+// to those visible to the given user (own hosts in auth mode, NULL user_id otherwise)
+func (s *Server) hostScopeQuery(user *User) (string, []interface{}) {
+	if s.Config.UseAuth && user != nil {
+		return "user_id = ?", []interface{}{user.ID}
+	}
+	return "user_id IS NULL", []interface{}{}
+}
+
+// hostInScope reports whether a loaded host is visible to the user, like hostScopeQuery
+func (s *Server) hostInScope(host Host, user *User) bool {
+	if s.Config.UseAuth && user != nil {
+		return host.UserID != nil && *host.UserID == user.ID
+	}
+	return host.UserID == nil
+}
+
+// hostScopeForHost returns the host scope clause matching the owner of an already loaded host
+func hostScopeForHost(host Host) (string, []interface{}) {
+	if host.UserID != nil {
+		return "user_id = ?", []interface{}{*host.UserID}
+	}
+	return "user_id IS NULL", []interface{}{}
+}
+
+// loadHostDependencies returns the direct dependencies of a host in declaration order
+func (s *Server) loadHostDependencies(hostID string) ([]HostDependency, error) {
+	rows, err := s.DB.Query(`SELECT d.depends_on_id, h.name, d.delay_seconds, d.wait_online, d.timeout_seconds
+		FROM host_dependencies d JOIN hosts h ON h.id = d.depends_on_id
+		WHERE d.host_id = ? ORDER BY d.position`, hostID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deps []HostDependency
+	for rows.Next() {
+		var dep HostDependency
+		if err := rows.Scan(&dep.DependsOnID, &dep.DependsOnName, &dep.DelaySeconds, &dep.WaitOnline, &dep.TimeoutSeconds); err != nil {
+			return nil, err
+		}
+		deps = append(deps, dep)
+	}
+	return deps, rows.Err()
+}
+
+// loadDependencyGraph returns all dependency edges between hosts in the given scope, read
+// within the transaction that is about to change them
+func loadDependencyGraph(tx *sql.Tx, scope string, args []interface{}) (map[string][]string, error) {
+	rows, err := tx.Query(`SELECT d.host_id, d.depends_on_id FROM host_dependencies d
+		JOIN hosts h ON h.id = d.host_id
+		WHERE h.`+scope+` ORDER BY d.host_id, d.position`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	graph := make(map[string][]string)
+	for rows.Next() {
+		var hostID, dependsOnID string
+		if err := rows.Scan(&hostID, &dependsOnID); err != nil {
+			return nil, err
+		}
+		graph[hostID] = append(graph[hostID], dependsOnID)
+	}
+	return graph, rows.Err()
+}
+
+// findDependencyCycle returns the host IDs forming a cycle reachable from start, or nil if
+// the graph is acyclic from that point. The returned path starts and ends with the same host.
+func findDependencyCycle(graph map[string][]string, start string) []string {
+	const (
+		unvisited = iota
+		inStack
+		done
+	)
+	state := make(map[string]int)
+	var stack []string
+	var cycle []string
+
+	var visit func(id string) bool
+	visit = func(id string) bool {
+		state[id] = inStack
+		stack = append(stack, id)
+		for _, dep := range graph[id] {
+			switch state[dep] {
+			case inStack:
+				// Extract the cycle from the current DFS stack
+				for i, stackID := range stack {
+					if stackID == dep {
+						cycle = append(append([]string{}, stack[i:]...), dep)
+						break
+					}
+				}
+				return true
+			case unvisited:
+				if visit(dep) {
+					return true
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[id] = done
+		return false
+	}
+
+	if visit(start) {
+		return cycle
+	}
+	return nil
+}
+
+// buildWakeChain returns the hosts to wake for a target host in dependency order
+// (dependencies first, target last). A host without dependencies yields a single-node chain.
+func (s *Server) buildWakeChain(target Host) ([]wakeChainNode, error) {
+	scope, scopeArgs := hostScopeForHost(target)
+
+	var chain []wakeChainNode
+	visited := make(map[string]bool)
+	inProgress := make(map[string]bool)
+
+	var visit func(host Host) error
+	visit = func(host Host) error {
+		if visited[host.ID] {
+			return nil
+		}
+		if inProgress[host.ID] {
+			// Cycles are rejected when dependencies are saved, but never loop forever
+			return fmt.Errorf("dependency cycle detected at host '%s'", host.Name)
+		}
+		inProgress[host.ID] = true
+
+		deps, err := s.loadHostDependencies(host.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, dep := range deps {
+			var depHost Host
+			args := append([]interface{}{dep.DependsOnID}, scopeArgs...)
+			err := s.DB.QueryRow("SELECT id, name, mac, broadcast, interface, static_ip, use_as_fallback, user_id FROM hosts WHERE id = ? AND "+scope, args...).
+				Scan(&depHost.ID, &depHost.Name, &depHost.MAC, &depHost.Broadcast, &depHost.Interface, &depHost.StaticIP, &depHost.UseAsFallback, &depHost.UserID)
+			if err == sql.ErrNoRows {
+				// Dependency deleted or no longer owned by the same user - ignore it
+				Warning("Host '%s' depends on unavailable host %s - ignoring dependency", host.Name, dep.DependsOnID)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := visit(depHost); err != nil {
+				return err
+			}
+		}
+
+		inProgress[host.ID] = false
+		visited[host.ID] = true
+		chain = append(chain, wakeChainNode{Host: host, Dependencies: deps})
+
+		if len(chain) > MaxWakeChainLength {
+			return fmt.Errorf("wake chain exceeds maximum length of %d hosts", MaxWakeChainLength)
+		}
+		return nil
+	}
+
+	if err := visit(target); err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
+
+// walkWakeChain wakes each host of a chain in order and reports per-step results.
+//
+// Hosts that are already online are skipped. Before waking a host, each dependency that
+// was woken during this walk is given time to boot - either a fixed delay or, for
+// "wait until online" dependencies, until it answers a status check. If a dependency
+// fails to wake or does not come online in time, the remaining steps are skipped.
+//
+// onStep (optional) is called with the index of each step when it starts waiting and when
+// it is finished. Returns the step results and whether the whole chain completed successfully.
+func (s *Server) walkWakeChain(ctx context.Context, chain []wakeChainNode, onStep func(int, WakeStepResult)) ([]WakeStepResult, bool) {
+	steps := make([]WakeStepResult, 0, len(chain))
+	report := func(step WakeStepResult) {
+		if onStep != nil {
+			onStep(len(steps), step)
+		}
+	}
+	wokenAt := make(map[string]time.Time)
+	hostsByID := make(map[string]Host)
+	for _, node := range chain {
+		hostsByID[node.Host.ID] = node.Host
+	}
+
+	aborted := false
+	for _, node := range chain {
+		step := WakeStepResult{HostID: node.Host.ID, HostName: node.Host.Name}
+
+		if aborted {
+			step.Status = WakeStepSkipped
+			report(step)
+			steps = append(steps, step)
+			continue
+		}
+
+		// Give woken dependencies time to boot before waking this host
+		waitStart := time.Now()
+		for _, dep := range node.Dependencies {
+			if _, ok := wokenAt[dep.DependsOnID]; ok {
+				report(WakeStepResult{HostID: node.Host.ID, HostName: node.Host.Name, Status: WakeStepWaiting})
+				break
+			}
+		}
+		for _, dep := range node.Dependencies {
+			woken, ok := wokenAt[dep.DependsOnID]
+			if !ok {
+				continue // Dependency was already online (or unavailable)
+			}
+			depHost := hostsByID[dep.DependsOnID]
+
+			if dep.WaitOnline {
+				if err := s.waitForHostOnline(ctx, depHost, dep.TimeoutSeconds); err != nil {
+					aborted = true
+					if ctx.Err() != nil {
+						step.Status = WakeStepSkipped
+						step.Error = "cancelled"
+						break
+					}
+					Warning("Wake chain - host '%s' did not come online before '%s': %v", depHost.Name, node.Host.Name, err)
+					step.Status = WakeStepDependencyTimeout
+					step.Error = fmt.Sprintf("dependency '%s' did not come online: %v", depHost.Name, err)
+					break
+				}
+				continue
+			}
+
+			if remaining := time.Duration(dep.DelaySeconds)*time.Second - time.Since(woken); remaining > 0 {
+				Debug("Wake chain - waiting %.1fs after '%s' before waking '%s'", remaining.Seconds(), depHost.Name, node.Host.Name)
+				select {
+				case <-time.After(remaining):
+				case <-ctx.Done():
+					step.Status = WakeStepSkipped
+					step.Error = "cancelled"
+					aborted = true
+				}
+				if aborted {
+					break
+				}
+			}
+		}
+		if waited := time.Since(waitStart); waited >= time.Second {
+			step.WaitedSeconds = waited.Round(100 * time.Millisecond).Seconds()
+		}
+		if aborted {
+			report(step)
+			steps = append(steps, step)
+			continue
+		}
+
+		// Skip hosts that are already online
+		if status := s.getHostStatus(ctx, node.Host); status.PingSuccess || status.ARPSuccess {
+			Debug("Wake chain - host '%s' is already online, skipping", node.Host.Name)
+			step.Status = WakeStepAlreadyOnline
+			report(step)
+			steps = append(steps, step)
+			continue
+		}
+
+		if err := s.wakeHost(ctx, node.Host); err != nil {
+			Warning("Wake chain - failed to wake host '%s': %v", node.Host.Name, err)
+			step.Status = WakeStepFailed
+			step.Error = err.Error()
+			aborted = true
+			report(step)
+			steps = append(steps, step)
+			continue
+		}
+
+		Debug("Wake chain - magic packet sent to host '%s'", node.Host.Name)
+		wokenAt[node.Host.ID] = time.Now()
+		step.Status = WakeStepSent
+		report(step)
+		steps = append(steps, step)
+	}
+
+	return steps, !aborted
+}
+
+// waitForHostOnline polls a host's status until it is online, the timeout expires
+// or the context is cancelled
+func (s *Server) waitForHostOnline(ctx context.Context, host Host, timeoutSeconds int) error {
+	if timeoutSeconds <= 0 {
+		timeoutSeconds = DefaultWakeChainTimeoutSeconds
+	}
+	deadline := time.Now().Add(time.Duration(timeoutSeconds) * time.Second)
+
+	for {
+		result := s.probeHost(ctx, host, false)
+		s.PingCache.Set(host.ID, result)
+		s.trackHostAddress(ctx, host, result)
+		if result.PingSuccess || result.ARPSuccess {
+			Debug("Host '%s' is online", host.Name)
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("timed out after %ds", timeoutSeconds)
+		}
+
+		// Check one last time at the deadline rather than giving up early
+		wait := WakeChainPollInterval
+		if remaining < wait {
+			wait = remaining
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// describeCycle formats a dependency cycle using host names where available
+func (s *Server) describeCycle(cycle []string) string {
+	names := make([]string, len(cycle))
+	for i, id := range cycle {
+		var name string
+		if err := s.DB.QueryRow("SELECT name FROM hosts WHERE id = ?", id).Scan(&name); err != nil {
+			name = id
+		}
+		names[i] = name
+	}
+	return strings.Join(names, " -> ")
+}
+
+// WakeChainRun is a wake chain walked in the background. POST /api/wake returns its ID and
+// GET /api/wake/chains/{id} reports the progress.
+type WakeChainRun struct {
+	ID       string           `json:"id"`
+	HostID   string           `json:"host_id"`
+	HostName string           `json:"host_name"`
+	Done     bool             `json:"done"`
+	Success  bool             `json:"success"`
+	Steps    []WakeStepResult `json:"steps"`
+	Started  time.Time        `json:"started"`
+	Finished *time.Time       `json:"finished,omitempty"`
+
+	host Host // Target host; the run is visible to users who can see it
+}
+
+// WakeChainRuns keeps running and recently finished background wake chains
+type WakeChainRuns struct {
+	mutex sync.Mutex
+	runs  map[string]*WakeChainRun
+}
+
+// NewWakeChainRuns creates an empty registry
+func NewWakeChainRuns() *WakeChainRuns {
+	return &WakeChainRuns{runs: make(map[string]*WakeChainRun)}
+}
+
+// Get returns a copy of a run
+func (r *WakeChainRuns) Get(id string) (WakeChainRun, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	run, exists := r.runs[id]
+	if !exists {
+		return WakeChainRun{}, false
+	}
+	snapshot := *run
+	snapshot.Steps = append([]WakeStepResult(nil), run.Steps...)
+	return snapshot, true
+}
+
+// add registers a new run and forgets runs finished longer than WakeChainRetention ago
+func (r *WakeChainRuns) add(run *WakeChainRun) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for id, old := range r.runs {
+		if old.Finished != nil && time.Since(*old.Finished) > WakeChainRetention {
+			delete(r.runs, id)
+		}
+	}
+	r.runs[run.ID] = run
+}
+
+// update changes a run under the registry lock
+func (r *WakeChainRuns) update(run *WakeChainRun, change func(*WakeChainRun)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	change(run)
+}
+
+// startWakeChain walks a chain in the background, detached from the request: a chain may
+// wait minutes for dependencies, longer than proxies and browsers keep a request open. The
+// walk ends when the server shuts down or the chain's deadline passes.
+func (s *Server) startWakeChain(ctx context.Context, chain []wakeChainNode) (WakeChainRun, error) {
+	id, err := generateID()
+	if err != nil {
+		return WakeChainRun{}, err
+	}
+	target := chain[len(chain)-1].Host
+	run := &WakeChainRun{
+		ID:       id,
+		HostID:   target.ID,
+		HostName: target.Name,
+		Steps:    make([]WakeStepResult, len(chain)),
+		Started:  time.Now(),
+		host:     target,
+	}
+	for i, node := range chain {
+		run.Steps[i] = WakeStepResult{HostID: node.Host.ID, HostName: node.Host.Name, Status: WakeStepPending}
+	}
+	s.WakeChains.add(run)
+
+	// Keep the request's log fields and trace, but not its cancellation
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), wakeChainDeadline(chain))
+	go func() {
+		defer cancel()
+		if s.Lifecycle != nil {
+			defer context.AfterFunc(s.Lifecycle, cancel)()
+		}
+
+		steps, ok := s.walkWakeChain(ctx, chain, func(i int, step WakeStepResult) {
+			s.WakeChains.update(run, func(run *WakeChainRun) { run.Steps[i] = step })
+		})
+		s.WakeChains.update(run, func(run *WakeChainRun) {
+			finished := time.Now()
+			copy(run.Steps, steps)
+			run.Done = true
+			run.Success = ok
+			run.Finished = &finished
+		})
+		if ok {
+			InfoContext(ctx, "Wake chain for host '%s' completed", target.Name)
+		} else {
+			WarningContext(ctx, "Wake chain for host '%s' failed", target.Name)
+		}
+	}()
+
+	snapshot, _ := s.WakeChains.Get(id)
+	return snapshot, nil
+}
+
+// wakeChainDeadline is the longest a chain may take: every dependency wait at its limit
+func wakeChainDeadline(chain []wakeChainNode) time.Duration {
+	total := WakeChainDeadlineMargin
+	for _, node := range chain {
+		for _, dep := range node.Dependencies {
+			switch {
+			case !dep.WaitOnline:
+				total += time.Duration(dep.DelaySeconds) * time.Second
+			case dep.TimeoutSeconds > 0:
+				total += time.Duration(dep.TimeoutSeconds) * time.Second
+			default:
+				total += DefaultWakeChainTimeoutSeconds * time.Second
+			}
+		}
+	}
+	return total
+}
+
+// handleWakeChain returns the progress of a background wake chain
+func (s *Server) handleWakeChain(w http.ResponseWriter, r *http.Request) {
+	run, exists := s.WakeChains.Get(mux.Vars(r)["id"])
+	if !exists || !s.hostInScope(run.host, GetUserFromContext(r)) {
+		sendJSONErrorWithCode(w, "Wake chain not found", ErrCodeNotFound, http.StatusNotFound)
+		return
+	}
+	sendJSON(w, run, http.StatusOK)
+}
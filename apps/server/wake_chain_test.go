@@ -0,0 +1,161 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindDependencyCycle(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph map[string][]string
+		start string
+		want  []string
+	}{
+		{"no dependencies", map[string][]string{}, "a", nil},
+		{"chain", map[string][]string{"a": {"b"}, "b": {"c"}}, "a", nil},
+		{"diamond", map[string][]string{"a": {"b", "c"}, "b": {"d"}, "c": {"d"}}, "a", nil},
+		{"self", map[string][]string{"a": {"a"}}, "a", []string{"a", "a"}},
+		{"two hosts", map[string][]string{"a": {"b"}, "b": {"a"}}, "a", []string{"a", "b", "a"}},
+		{"through start", map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}}, "a", []string{"a", "b", "c", "a"}},
+		{"reachable from start", map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"b"}}, "a", []string{"b", "c", "b"}},
+		{"not reachable from start", map[string][]string{"a": {"b"}, "c": {"d"}, "d": {"c"}}, "a", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findDependencyCycle(tt.graph, tt.start); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findDependencyCycle = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// newTestDependencyServer returns a server with an empty database and the given hosts
+// (without owner, as with auth disabled)
+func newTestDependencyServer(t *testing.T, hostIDs ...string) *Server {
+	t.Helper()
+	db, err := initDatabase(filepath.Join(t.TempDir(), "wol.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	for i, id := range hostIDs {
+		_, err := db.Exec("INSERT INTO hosts (id, name, mac, broadcast, interface, static_ip) VALUES (?, ?, ?, ?, '', '')",
+			id, "host-"+id, "aa:bb:cc:dd:ee:0"+string(rune('0'+i)), "192.168.1.255")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &Server{DB: db, Config: &Config{}}
+}
+
+// addTestDependencies inserts dependency edges in declaration order, bypassing validation
+func addTestDependencies(t *testing.T, db *sql.DB, hostID string, dependsOn ...string) {
+	t.Helper()
+	for i, dep := range dependsOn {
+		if _, err := db.Exec("INSERT INTO host_dependencies (host_id, depends_on_id, position) VALUES (?, ?, ?)", hostID, dep, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// wakeChainOrder returns the host IDs of a chain in wake order
+func wakeChainOrder(chain []wakeChainNode) []string {
+	ids := make([]string, len(chain))
+	for i, node := range chain {
+		ids[i] = node.Host.ID
+	}
+	return ids
+}
+
+func TestBuildWakeChain(t *testing.T) {
+	s := newTestDependencyServer(t, "nas", "switch", "router", "vm", "other")
+	// vm needs nas and switch; both need the router
+	addTestDependencies(t, s.DB, "vm", "nas", "switch")
+	addTestDependencies(t, s.DB, "nas", "router")
+	addTestDependencies(t, s.DB, "switch", "router")
+	// Dependencies owned by another user are ignored
+	if _, err := s.DB.Exec("INSERT INTO users (id, name, password) VALUES ('u1', 'alice', 'x')"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DB.Exec("UPDATE hosts SET user_id = 'u1' WHERE id = 'other'"); err != nil {
+		t.Fatal(err)
+	}
+	addTestDependencies(t, s.DB, "router", "other")
+
+	target, err := s.loadHostByID("vm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err := s.buildWakeChain(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := wakeChainOrder(chain), []string{"router", "nas", "switch", "vm"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wake order = %v, want %v", got, want)
+	}
+
+	// A host without dependencies is a single-node chain
+	single, err := s.loadHostByID("switch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DB.Exec("DELETE FROM host_dependencies WHERE host_id = 'switch'"); err != nil {
+		t.Fatal(err)
+	}
+	chain, err = s.buildWakeChain(single)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := wakeChainOrder(chain), []string{"switch"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wake order = %v, want %v", got, want)
+	}
+}
+
+func TestBuildWakeChainCycle(t *testing.T) {
+	s := newTestDependencyServer(t, "a", "b")
+	addTestDependencies(t, s.DB, "a", "b")
+	addTestDependencies(t, s.DB, "b", "a")
+
+	target, err := s.loadHostByID("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.buildWakeChain(target); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("err = %v, want a cycle error", err)
+	}
+}
+
+func TestUpdateHostDependenciesRejectsCycle(t *testing.T) {
+	s := newTestDependencyServer(t, "a", "b")
+
+	put := func(hostID, dependsOn string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/hosts/"+hostID+"/dependencies",
+			strings.NewReader(`[{"depends_on": "`+dependsOn+`"}]`))
+		rec := httptest.NewRecorder()
+		s.updateHostDependencies(rec, req, nil, hostID, "host-"+hostID)
+		return rec
+	}
+
+	if rec := put("a", "b"); rec.Code != http.StatusOK {
+		t.Fatalf("a -> b: status = %d: %s", rec.Code, rec.Body)
+	}
+	rec := put("b", "a")
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), ErrCodeDependencyCycle) {
+		t.Fatalf("b -> a: status = %d: %s", rec.Code, rec.Body)
+	}
+
+	var edges int
+	if err := s.DB.QueryRow("SELECT COUNT(*) FROM host_dependencies").Scan(&edges); err != nil {
+		t.Fatal(err)
+	}
+	if edges != 1 {
+		t.Errorf("%d dependencies stored, want 1", edges)
+	}
+}
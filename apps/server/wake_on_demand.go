@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// onDemandWaker wakes a host on behalf of a proxy. Concurrent callers share a single
+// wake attempt, so a burst of connections produces one wake and one wait.
+type onDemandWaker struct {
+	server        *Server
+	label         string // Log prefix, e.g. "Wake proxy on :2222"
+	hostID        string
+	targetAddress string                    // Optional fixed host address
+	timeout       time.Duration             // Maximum time for waking and readiness checks
+	ready         func(address string) bool // Optional service readiness check after the host is online
+
+	mutex   sync.Mutex
+	pending *wakeAttempt
+}
+
+// wakeAttempt is a wake-and-wait operation shared by all callers arriving while it runs
+type wakeAttempt struct {
+	done    chan struct{}
+	address string
+	err     error
+}
+
+// start begins a wake attempt unless one is already running and returns it without waiting
+func (w *onDemandWaker) start() *wakeAttempt {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.pending != nil {
+		return w.pending
+	}
+
+	attempt := &wakeAttempt{done: make(chan struct{})}
+	w.pending = attempt
+	go func() {
+		attempt.address, attempt.err = w.wakeAndWait()
+		w.mutex.Lock()
+		w.pending = nil
+		w.mutex.Unlock()
+		close(attempt.done)
+	}()
+	return attempt
+}
+
+// inProgress reports whether a wake attempt is currently running
+func (w *onDemandWaker) inProgress() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.pending != nil
+}
+
+// ensureOnline returns the address of the host, waking it and waiting for it first if needed
+func (w *onDemandWaker) ensureOnline() (string, error) {
+	attempt := w.start()
+	<-attempt.done
+	return attempt.address, attempt.err
+}
+
+// wakeAndWait checks the host status and, if offline, wakes it (including its
+// dependency chain) and waits until it answers status checks and, if configured,
+// the service readiness check
+func (w *onDemandWaker) wakeAndWait() (string, error) {
+	s := w.server
+	deadline := time.Now().Add(w.timeout)
+
+	// Reload the host so edits (MAC, static IP, ...) apply without restart
+	host, err := s.loadHostByID(w.hostID)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("host %s not found", w.hostID)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load host: %w", err)
+	}
+
+	status := s.getHostStatus(context.Background(), host)
+	if !status.PingSuccess && !status.ARPSuccess {
+		Info("%s: host '%s' is offline - waking", w.label, host.Name)
+
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		defer cancel()
+
+		chain, err := s.buildWakeChain(host)
+		if err != nil {
+			return "", fmt.Errorf("failed to load wake dependencies: %w", err)
+		}
+		if len(chain) > 1 {
+			if _, ok := s.walkWakeChain(ctx, chain, nil); !ok {
+				return "", fmt.Errorf("wake chain for host '%s' failed", host.Name)
+			}
+		} else if err := s.wakeHost(ctx, host); err != nil {
+			return "", fmt.Errorf("failed to wake host '%s': %w", host.Name, err)
+		}
+
+		if err := s.waitForHostOnline(ctx, host, int(time.Until(deadline)/time.Second)); err != nil {
+			return "", fmt.Errorf("host '%s' did not come online: %w", host.Name, err)
+		}
+		Info("%s: host '%s' is online", w.label, host.Name)
+	}
+
+	address := w.targetAddress
+	if address == "" {
+		address = s.resolveHostAddress(context.Background(), host)
+	}
+	if address == "" {
+		return "", fmt.Errorf("address of host '%s' could not be resolved", host.Name)
+	}
+
+	// The host may answer ARP before its service is up
+	if w.ready != nil {
+		for !w.ready(address) {
+			if time.Now().Add(WakeProxyDialRetryInterval).After(deadline) {
+				return "", fmt.Errorf("service on host '%s' did not respond within %s", host.Name, w.timeout)
+			}
+			time.Sleep(WakeProxyDialRetryInterval)
+		}
+	}
+
+	return address, nil
+}
+
+// loadHostByID fetches a host regardless of owner (for server-side configuration such as proxies)
+func (s *Server) loadHostByID(hostID string) (Host, error) {
+	var host Host
+	err := s.DB.QueryRow("SELECT id, name, mac, broadcast, interface, static_ip, use_as_fallback, user_id FROM hosts WHERE id = ?", hostID).
+		Scan(&host.ID, &host.Name, &host.MAC, &host.Broadcast, &host.Interface, &host.StaticIP, &host.UseAsFallback, &host.UserID)
+	return host, err
+}
+
+// ProxyActivity tracks connections and requests through wake proxies per host,
+// so external shutdown policies can tell how long a host has been idle
+type ProxyActivity struct {
+	mutex   sync.Mutex
+	started time.Time
+	hosts   map[string]*proxyHostActivity
+}
+
+// proxyHostActivity is the activity record of one host
+type proxyHostActivity struct {
+	active       int
+	total        int64
+	lastActivity time.Time
+}
+
+// ProxyHostActivity is the activity report of one proxied host
+type ProxyHostActivity struct {
+	HostID            string     `json:"host_id"`
+	HostName          string     `json:"host_name"`
+	ActiveConnections int        `json:"active_connections"`
+	TotalConnections  int64      `json:"total_connections"`
+	LastActivity      *time.Time `json:"last_activity"` // null if never used since server start
+	IdleSeconds       int64      `json:"idle_seconds"`  // 0 while connections are active
+}
+
+// NewProxyActivity creates an empty activity tracker
+func NewProxyActivity() *ProxyActivity {
+	return &ProxyActivity{
+		started: time.Now(),
+		hosts:   make(map[string]*proxyHostActivity),
+	}
+}
+
+// Begin records the start of a connection or request to a host
+func (pa *ProxyActivity) Begin(hostID string) {
+	pa.mutex.Lock()
+	defer pa.mutex.Unlock()
+
+	activity, exists := pa.hosts[hostID]
+	if !exists {
+		activity = &proxyHostActivity{}
+		pa.hosts[hostID] = activity
+	}
+	activity.active++
+	activity.total++
+	activity.lastActivity = time.Now()
+}
+
+// End records the end of a connection or request to a host
+func (pa *ProxyActivity) End(hostID string) {
+	pa.mutex.Lock()
+	defer pa.mutex.Unlock()
+
+	if activity, exists := pa.hosts[hostID]; exists {
+		activity.active--
+		activity.lastActivity = time.Now()
+	}
+}
+
+// Report returns the activity of the given hosts, sorted by host name
+func (pa *ProxyActivity) Report(hosts []Host) []ProxyHostActivity {
+	pa.mutex.Lock()
+	defer pa.mutex.Unlock()
+
+	now := time.Now()
+	report := make([]ProxyHostActivity, 0, len(hosts))
+	for _, host := range hosts {
+		entry := ProxyHostActivity{
+			HostID:      host.ID,
+			HostName:    host.Name,
+			IdleSeconds: int64(now.Sub(pa.started) / time.Second),
+		}
+		if activity, exists := pa.hosts[host.ID]; exists {
+			last := activity.lastActivity
+			entry.ActiveConnections = activity.active
+			entry.TotalConnections = activity.total
+			entry.LastActivity = &last
+			entry.IdleSeconds = int64(now.Sub(last) / time.Second)
+			if activity.active > 0 {
+				entry.IdleSeconds = 0
+			}
+		}
+		report = append(report, entry)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].HostName < report[j].HostName })
+	return report
+}
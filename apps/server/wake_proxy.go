@@ -0,0 +1,185 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WakeProxyConfig describes a wake-on-demand TCP proxy: connections accepted on Listen
+// wake the host (if needed) and are then forwarded to TargetPort on the host.
+type WakeProxyConfig struct {
+	Listen             string `json:"listen"`               // Local address to accept connections on (e.g. ":2222")
+	HostID             string `json:"host_id"`              // Host to wake
+	TargetPort         int    `json:"target_port"`          // Port on the host to forward connections to
+	TargetAddress      string `json:"target_address"`       // Optional fixed host address (default: resolved host IP)
+	WaitTimeoutSeconds int    `json:"wait_timeout_seconds"` // Maximum wait for the host and service to come up (default 120)
+	MaxConnections     int    `json:"max_connections"`      // Maximum concurrent connections, waiting or forwarded (default 32)
+}
+
+// validateWakeProxyConfig checks a single proxy entry
+func validateWakeProxyConfig(p WakeProxyConfig) error {
+	_, port, err := net.SplitHostPort(p.Listen)
+	if err != nil {
+		return fmt.Errorf("invalid listen address '%s': %v", p.Listen, err)
+	}
+	if portNum, err := strconv.Atoi(port); err != nil || portNum < 1 || portNum > 65535 {
+		return fmt.Errorf("invalid port in listen address '%s': must be 1-65535", p.Listen)
+	}
+	if p.HostID == "" {
+		return fmt.Errorf("host_id is required for proxy on '%s'", p.Listen)
+	}
+	if p.TargetPort < 1 || p.TargetPort > 65535 {
+		return fmt.Errorf("target_port for proxy on '%s' must be 1-65535", p.Listen)
+	}
+	if p.WaitTimeoutSeconds < 0 || p.WaitTimeoutSeconds > MaxWakeProxyWaitSeconds {
+		return fmt.Errorf("wait_timeout_seconds for proxy on '%s' must be 0-%d", p.Listen, MaxWakeProxyWaitSeconds)
+	}
+	if p.MaxConnections < 0 || p.MaxConnections > MaxWakeProxyConnections {
+		return fmt.Errorf("max_connections for proxy on '%s' must be 0-%d", p.Listen, MaxWakeProxyConnections)
+	}
+	return nil
+}
+
+// wakeProxy is a running proxy listener
+type wakeProxy struct {
+	server   *Server
+	config   WakeProxyConfig
+	timeout  time.Duration
+	slots    chan struct{} // Connection slots (concurrency limit)
+	listener net.Listener
+	waker    *onDemandWaker // Wake attempt shared by concurrent connections
+}
+
+// startWakeProxies starts a listener for every configured wake proxy
+func (s *Server) startWakeProxies() error {
+	for _, cfg := range s.Config.WakeProxies {
+		if cfg.WaitTimeoutSeconds == 0 {
+			cfg.WaitTimeoutSeconds = DefaultWakeProxyWaitSeconds
+		}
+		if cfg.MaxConnections == 0 {
+			cfg.MaxConnections = DefaultWakeProxyMaxConnections
+		}
+
+		host, err := s.loadHostByID(cfg.HostID)
+		if err == sql.ErrNoRows {
+			Warning("Wake proxy on %s: host %s not found - connections will be rejected until it exists", cfg.Listen, cfg.HostID)
+		} else if err != nil {
+			return fmt.Errorf("wake proxy on %s: %w", cfg.Listen, err)
+		}
+
+		listener, err := net.Listen("tcp", cfg.Listen)
+		if err != nil {
+			return fmt.Errorf("wake proxy on %s: %w", cfg.Listen, err)
+		}
+
+		proxy := &wakeProxy{
+			server:   s,
+			config:   cfg,
+			timeout:  time.Duration(cfg.WaitTimeoutSeconds) * time.Second,
+			slots:    make(chan struct{}, cfg.MaxConnections),
+			listener: listener,
+			waker: &onDemandWaker{
+				server:        s,
+				label:         "Wake proxy on " + cfg.Listen,
+				hostID:        cfg.HostID,
+				targetAddress: cfg.TargetAddress,
+				timeout:       time.Duration(cfg.WaitTimeoutSeconds) * time.Second,
+			},
+		}
+		s.closeOnStop(listener)
+		go proxy.serve()
+
+		Info("Wake proxy listening on %s -> host '%s' port %d (wait timeout: %ds, max connections: %d)",
+			cfg.Listen, host.Name, cfg.TargetPort, cfg.WaitTimeoutSeconds, cfg.MaxConnections)
+	}
+	return nil
+}
+
+// serve accepts connections until the listener is closed
+func (p *wakeProxy) serve() {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			if !p.server.isStopping() {
+				Warning("Wake proxy on %s stopped: %v", p.config.Listen, err)
+			}
+			return
+		}
+
+		select {
+		case p.slots <- struct{}{}:
+			go func() {
+				defer func() { <-p.slots }()
+				p.handleConnection(conn)
+			}()
+		default:
+			Warning("Wake proxy on %s: connection limit (%d) reached - rejecting %s", p.config.Listen, p.config.MaxConnections, conn.RemoteAddr())
+			conn.Close()
+		}
+	}
+}
+
+// handleConnection wakes the host if needed and splices the client connection to it
+func (p *wakeProxy) handleConnection(client net.Conn) {
+	defer client.Close()
+	deadline := time.Now().Add(p.timeout)
+
+	Debug("Wake proxy on %s: connection from %s", p.config.Listen, client.RemoteAddr())
+
+	// Waiting connections count as activity too - someone wants the host up
+	p.server.ProxyActivity.Begin(p.config.HostID)
+	defer p.server.ProxyActivity.End(p.config.HostID)
+
+	address, err := p.waker.ensureOnline()
+	if err != nil {
+		Warning("Wake proxy on %s: %v - closing connection from %s", p.config.Listen, err, client.RemoteAddr())
+		return
+	}
+
+	// The host may answer ARP before the service is listening - retry until the deadline
+	target := net.JoinHostPort(address, strconv.Itoa(p.config.TargetPort))
+	var backend net.Conn
+	for {
+		backend, err = net.DialTimeout("tcp", target, WakeProxyDialTimeout)
+		if err == nil {
+			break
+		}
+		if time.Now().Add(WakeProxyDialRetryInterval).After(deadline) {
+			Warning("Wake proxy on %s: failed to connect to %s: %v", p.config.Listen, target, err)
+			return
+		}
+		Debug("Wake proxy on %s: %s not reachable yet (%v) - retrying", p.config.Listen, target, err)
+		time.Sleep(WakeProxyDialRetryInterval)
+	}
+	defer backend.Close()
+
+	Debug("Wake proxy on %s: forwarding %s -> %s", p.config.Listen, client.RemoteAddr(), target)
+	spliceConnections(client, backend)
+}
+
+// spliceConnections copies data in both directions until both sides are done
+func spliceConnections(a, b net.Conn) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	copyHalf := func(dst, src net.Conn) {
+		defer wg.Done()
+		io.Copy(dst, src)
+		// Propagate EOF so the other direction can finish
+		if tcp, ok := dst.(*net.TCPConn); ok {
+			tcp.CloseWrite()
+		} else {
+			dst.Close()
+		}
+	}
+	go copyHalf(a, b)
+	go copyHalf(b, a)
+	wg.Wait()
+}
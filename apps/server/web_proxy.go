@@ -0,0 +1,479 @@
+package main
+
+import (
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// WebProxyConfig describes a waking HTTP reverse proxy mounted at {url_prefix}/proxy/{name}/.
+// Requests wake the host (if needed) and are forwarded to TargetPort on the host; browsers
+// get a "waking up" page that reloads once the upstream responds.
+type WebProxyConfig struct {
+	Name               string `json:"name"`                 // Path segment under /proxy/
+	HostID             string `json:"host_id"`              // Host to wake
+	TargetPort         int    `json:"target_port"`          // Port of the web service on the host
+	TargetScheme       string `json:"target_scheme"`        // "http" (default) or "https"
+	TargetAddress      string `json:"target_address"`       // Optional fixed host address (default: resolved host IP)
+	TLSSkipVerify      bool   `json:"tls_skip_verify"`      // Accept self-signed upstream certificates (https only)
+	WaitTimeoutSeconds int    `json:"wait_timeout_seconds"` // Maximum wait for the host and service to come up (default 120)
+}
+
+// webProxyNameRegex restricts proxy names to URL-safe path segments
+var webProxyNameRegex = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// validateWebProxyConfig checks a single web proxy entry
+func validateWebProxyConfig(p WebProxyConfig) error {
+	if !webProxyNameRegex.MatchString(p.Name) {
+		return fmt.Errorf("invalid name '%s' (1-64 characters: letters, digits, '.', '_', '-')", p.Name)
+	}
+	if p.HostID == "" {
+		return fmt.Errorf("host_id is required for proxy '%s'", p.Name)
+	}
+	if p.TargetPort < 1 || p.TargetPort > 65535 {
+		return fmt.Errorf("target_port for proxy '%s' must be 1-65535", p.Name)
+	}
+	if p.TargetScheme != "" && p.TargetScheme != "http" && p.TargetScheme != "https" {
+		return fmt.Errorf("target_scheme for proxy '%s' must be 'http' or 'https'", p.Name)
+	}
+	if p.WaitTimeoutSeconds < 0 || p.WaitTimeoutSeconds > MaxWakeProxyWaitSeconds {
+		return fmt.Errorf("wait_timeout_seconds for proxy '%s' must be 0-%d", p.Name, MaxWakeProxyWaitSeconds)
+	}
+	return nil
+}
+
+// webProxy is a configured HTTP reverse proxy
+type webProxy struct {
+	server    *Server
+	config    WebProxyConfig
+	prefix    string // Public mount path including url_prefix, without trailing slash
+	transport *http.Transport
+	waker     *onDemandWaker
+
+	mutex   sync.Mutex
+	address string // Upstream host address while the upstream is known to respond
+	lastErr string // Error of the last failed wake attempt
+}
+
+// startWebProxies prepares all configured web proxies (routes are added by setupRoutes)
+func (s *Server) startWebProxies() error {
+	s.WebProxies = make(map[string]*webProxy)
+	for _, cfg := range s.Config.WebProxies {
+		if cfg.TargetScheme == "" {
+			cfg.TargetScheme = "http"
+		}
+		if cfg.WaitTimeoutSeconds == 0 {
+			cfg.WaitTimeoutSeconds = DefaultWakeProxyWaitSeconds
+		}
+
+		host, err := s.loadHostByID(cfg.HostID)
+		if err == sql.ErrNoRows {
+			Warning("Web proxy '%s': host %s not found - requests will fail until it exists", cfg.Name, cfg.HostID)
+		} else if err != nil {
+			return fmt.Errorf("web proxy '%s': %w", cfg.Name, err)
+		}
+
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if cfg.TLSSkipVerify {
+			transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		}
+
+		proxy := &webProxy{
+			server:    s,
+			config:    cfg,
+			prefix:    s.buildURL("/proxy/" + cfg.Name),
+			transport: transport,
+		}
+		proxy.waker = &onDemandWaker{
+			server:        s,
+			label:         "Web proxy '" + cfg.Name + "'",
+			hostID:        cfg.HostID,
+			targetAddress: cfg.TargetAddress,
+			timeout:       time.Duration(cfg.WaitTimeoutSeconds) * time.Second,
+			ready:         proxy.upstreamResponds,
+		}
+		s.WebProxies[cfg.Name] = proxy
+
+		Info("Web proxy %s/ -> host '%s' %s port %d (wait timeout: %ds)",
+			proxy.prefix, host.Name, cfg.TargetScheme, cfg.TargetPort, cfg.WaitTimeoutSeconds)
+	}
+	return nil
+}
+
+// upstreamURL returns the base URL of the upstream service at the given address
+func (p *webProxy) upstreamURL(address string) *url.URL {
+	return &url.URL{
+		Scheme: p.config.TargetScheme,
+		Host:   net.JoinHostPort(address, strconv.Itoa(p.config.TargetPort)),
+	}
+}
+
+// upstreamResponds reports whether the upstream answers HTTP requests (any status counts)
+func (p *webProxy) upstreamResponds(address string) bool {
+	client := &http.Client{
+		Transport: p.transport,
+		Timeout:   WebProxyProbeTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Get(p.upstreamURL(address).String() + "/")
+	if err != nil {
+		Debug("Web proxy '%s': upstream not responding yet: %v", p.config.Name, err)
+		return false
+	}
+	resp.Body.Close()
+	return true
+}
+
+// readyAddress returns the upstream address if the upstream is known to respond
+func (p *webProxy) readyAddress() (string, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.address, p.address != ""
+}
+
+// startWake begins (or joins) a wake attempt and records its outcome
+func (p *webProxy) startWake() *wakeAttempt {
+	running := p.waker.inProgress()
+	attempt := p.waker.start()
+	if !running {
+		go func() {
+			<-attempt.done
+			p.mutex.Lock()
+			defer p.mutex.Unlock()
+			if attempt.err != nil {
+				p.lastErr = attempt.err.Error()
+				Warning("Web proxy '%s': %v", p.config.Name, attempt.err)
+				return
+			}
+			p.address = attempt.address
+			p.lastErr = ""
+		}()
+	}
+	return attempt
+}
+
+// markDown forgets the upstream address after a failed request
+func (p *webProxy) markDown() {
+	p.mutex.Lock()
+	p.address = ""
+	p.mutex.Unlock()
+}
+
+// ServeHTTP forwards a request to the upstream, waking the host first if needed.
+// The request path must already be stripped of the proxy prefix.
+func (p *webProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s := p.server
+
+	user := s.getCurrentUser(r)
+	if s.Config.UseAuth && user == nil {
+		if r.Method == http.MethodGet && acceptsHTML(r) {
+			http.Redirect(w, r, s.buildURL("/auth"), http.StatusSeeOther)
+			return
+		}
+		sendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	// Only the owner of the host may wake it and use its services
+	host, ok := s.loadAccessibleHost(w, user, p.config.HostID)
+	if !ok {
+		return
+	}
+
+	s.ProxyActivity.Begin(p.config.HostID)
+	defer s.ProxyActivity.End(p.config.HostID)
+
+	address, ready := p.readyAddress()
+	if !ready {
+		// Read-only users may use a running service but not wake the host
+		if (s.live().ReadOnlyMode || (s.Config.UseAuth && user != nil && user.ReadOnly)) && !p.waker.inProgress() {
+			if status := s.getHostStatus(r.Context(), host); !status.PingSuccess && !status.ARPSuccess {
+				sendJSONErrorWithCode(w, "Host is offline and read-only access cannot wake it", ErrCodeReadOnlyMode, http.StatusForbidden)
+				return
+			}
+		}
+
+		// Give running hosts a moment to answer so they don't flash the waking page
+		attempt := p.startWake()
+		select {
+		case <-attempt.done:
+		case <-time.After(WebProxyInterstitialDelay):
+		}
+		if !isClosed(attempt.done) || attempt.err != nil {
+			p.serveInterstitial(w, r)
+			return
+		}
+		address = attempt.address
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(p.upstreamURL(address))
+			pr.SetXForwarded()
+			pr.Out.Header.Set("X-Forwarded-Prefix", p.prefix)
+			removeCookie(pr.Out, "session_id")
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			// Sandbox proxied pages into an opaque origin. Browsers enforce every policy
+			// header, so the upstream's own policy cannot lift it.
+			resp.Header.Add("Content-Security-Policy", WebProxyContentSecurityPolicy)
+			// Keep the Referer on requests from proxied pages, so webProxyRefererMiddleware
+			// can refuse their API calls
+			resp.Header.Set("Referrer-Policy", "same-origin")
+			p.filterSetCookies(resp.Header)
+			return nil
+		},
+		Transport: p.transport,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			Warning("Web proxy '%s': upstream request failed: %v", p.config.Name, err)
+			p.markDown()
+			if r.Method == http.MethodGet && acceptsHTML(r) {
+				p.startWake()
+				p.serveInterstitial(w, r)
+				return
+			}
+			sendJSONError(w, "Upstream unavailable", http.StatusBadGateway)
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// serveInterstitial answers with the "waking up" page (browsers) or a 503 (other clients)
+func (p *webProxy) serveInterstitial(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Retry-After", strconv.Itoa(int(WebProxyStatusPollInterval/time.Second)))
+
+	if !acceptsHTML(r) {
+		sendJSONError(w, "Host is waking up - retry later", http.StatusServiceUnavailable)
+		return
+	}
+
+	hostName := p.config.HostID
+	if host, err := p.server.loadHostByID(p.config.HostID); err == nil {
+		hostName = host.Name
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	webProxyInterstitial.Execute(w, map[string]interface{}{
+		"Name":       p.config.Name,
+		"HostName":   hostName,
+		"StatusURL":  p.server.buildURL("/api/proxies/web/" + p.config.Name + "/status"),
+		"IntervalMS": int(WebProxyStatusPollInterval / time.Millisecond),
+	})
+}
+
+// webProxyInterstitial is shown while the upstream host is waking up
+var webProxyInterstitial = template.Must(template.New("waking").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Waking up {{.HostName}}</title>
+<style>
+body { font-family: system-ui, sans-serif; display: flex; align-items: center; justify-content: center; min-height: 100vh; margin: 0; background: #f5f5f5; color: #222; }
+main { text-align: center; padding: 2rem; }
+.spinner { width: 2.5rem; height: 2.5rem; margin: 0 auto 1.5rem; border: 4px solid #ccc; border-top-color: #3b82f6; border-radius: 50%; animation: spin 1s linear infinite; }
+@keyframes spin { to { transform: rotate(360deg); } }
+#error { color: #b91c1c; }
+</style>
+</head>
+<body>
+<main>
+<div class="spinner"></div>
+<h1>Waking up {{.HostName}}</h1>
+<p id="status">This page reloads automatically once the service responds.</p>
+<p id="error"></p>
+<noscript><p>Reload this page in a few seconds.</p></noscript>
+</main>
+<script>
+(function () {
+  var statusURL = {{.StatusURL}};
+  function poll() {
+    fetch(statusURL, { credentials: "same-origin", cache: "no-store" })
+      .then(function (r) { return r.json(); })
+      .then(function (s) {
+        if (s.ready) { location.reload(); return; }
+        document.getElementById("error").textContent = s.waking ? "" : (s.error || "");
+        setTimeout(poll, {{.IntervalMS}});
+      })
+      .catch(function () { setTimeout(poll, {{.IntervalMS}}); });
+  }
+  setTimeout(poll, {{.IntervalMS}});
+})();
+</script>
+</body>
+</html>
+`))
+
+// handleWebProxyStatus reports whether a web proxy's upstream is ready (polled by the waking page)
+func (s *Server) handleWebProxyStatus(w http.ResponseWriter, r *http.Request) {
+	proxy, exists := s.WebProxies[mux.Vars(r)["name"]]
+	if !exists {
+		sendJSONErrorWithCode(w, "Proxy not found", ErrCodeNotFound, http.StatusNotFound)
+		return
+	}
+	if _, ok := s.loadAccessibleHost(w, GetUserFromContext(r), proxy.config.HostID); !ok {
+		return
+	}
+
+	_, ready := proxy.readyAddress()
+	waking := proxy.waker.inProgress()
+
+	proxy.mutex.Lock()
+	lastErr := proxy.lastErr
+	proxy.mutex.Unlock()
+
+	response := map[string]interface{}{
+		"name":    proxy.config.Name,
+		"host_id": proxy.config.HostID,
+		"ready":   ready,
+		"waking":  waking,
+	}
+	if !ready && !waking && lastErr != "" {
+		response["error"] = lastErr
+	}
+	sendJSON(w, response, http.StatusOK)
+}
+
+// handleProxyActivity reports per-host usage of wake and web proxies for idle-shutdown
+// policies. Accessible with the shared agent token (all hosts), a per-agent token (the hosts
+// with its MACs) or a user session (own hosts).
+func (s *Server) handleProxyActivity(w http.ResponseWriter, r *http.Request) {
+	bound, agent := s.checkAgentToken(r)
+	if !agent && !s.checkAuth(w, r) {
+		return
+	}
+	user := s.getCurrentUser(r)
+	inScope := func(host Host) bool {
+		if !agent {
+			return s.hostInScope(host, user)
+		}
+		if bound == nil {
+			return true
+		}
+		mac, ok := normalizeAgentMAC(host.MAC)
+		return ok && s.agentOwningMAC(mac) == bound
+	}
+
+	seen := make(map[string]bool)
+	var hosts []Host
+	addHost := func(hostID string) {
+		if seen[hostID] {
+			return
+		}
+		seen[hostID] = true
+		if host, err := s.loadHostByID(hostID); err == nil && inScope(host) {
+			hosts = append(hosts, host)
+		}
+	}
+	for _, cfg := range s.Config.WakeProxies {
+		addHost(cfg.HostID)
+	}
+	for _, cfg := range s.Config.WebProxies {
+		addHost(cfg.HostID)
+	}
+
+	sendJSON(w, s.ProxyActivity.Report(hosts), http.StatusOK)
+}
+
+// setupWebProxyRoutes mounts the configured web proxies (must run before the SPA catch-all)
+func (s *Server) setupWebProxyRoutes(router *mux.Router) {
+	for _, proxy := range s.WebProxies {
+		router.Handle(proxy.prefix, http.RedirectHandler(proxy.prefix+"/", http.StatusMovedPermanently))
+		router.PathPrefix(proxy.prefix + "/").Handler(http.StripPrefix(proxy.prefix, proxy))
+	}
+}
+
+// webProxyRefererMiddleware refuses API requests made by pages served through a web proxy.
+// Proxied pages are sandboxed into an opaque origin (WebProxyContentSecurityPolicy); this check
+// is a second line of defense for browsers that ignore the sandbox, and relies on the Referer
+// header (see CONFIG.md).
+func (s *Server) webProxyRefererMiddleware(prefix string) mux.MiddlewareFunc {
+	proxyPath := s.buildURL("/proxy/")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := ""
+			if current := mux.CurrentRoute(r); current != nil {
+				route, _ = current.GetPathTemplate()
+				route = strings.TrimPrefix(route, prefix)
+			}
+			// The waking page polls its status endpoint
+			if route != "/api/proxies/web/{name}/status" {
+				if referer, err := url.Parse(r.Referer()); err == nil && referer.Host == r.Host && strings.HasPrefix(referer.Path, proxyPath) {
+					Warning("Refused API request from a proxied page (%s) from %s: %s %s", referer.Path, s.clientIP(r), r.Method, r.URL.Path)
+					sendJSONErrorWithCode(w, "API requests from proxied pages are not allowed", ErrCodeForbidden, http.StatusForbidden)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// acceptsHTML reports whether the client is a browser expecting a page
+func acceptsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// isClosed reports whether a channel has been closed
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// filterSetCookies drops upstream cookies that could reach the rest of wol-web: the session
+// cookie and any cookie whose Path is outside the proxy's mount path
+func (p *webProxy) filterSetCookies(header http.Header) {
+	values := header.Values("Set-Cookie")
+	if len(values) == 0 {
+		return
+	}
+	header.Del("Set-Cookie")
+	for _, value := range values {
+		cookie, err := http.ParseSetCookie(value)
+		if err != nil {
+			Debug("Web proxy '%s': dropped invalid Set-Cookie: %v", p.config.Name, err)
+			continue
+		}
+		if cookie.Name == "session_id" || !p.coversCookiePath(cookie.Path) {
+			Warning("Web proxy '%s': dropped upstream cookie '%s' (path %q)", p.config.Name, cookie.Name, cookie.Path)
+			continue
+		}
+		header.Add("Set-Cookie", value)
+	}
+}
+
+// coversCookiePath reports whether a cookie Path stays inside the proxy's mount path. An empty
+// Path defaults to the directory of the request, which is always inside it.
+func (p *webProxy) coversCookiePath(path string) bool {
+	return path == "" || path == p.prefix || strings.HasPrefix(path, p.prefix+"/")
+}
+
+// removeCookie drops a cookie from an outgoing request, keeping all others
+func removeCookie(r *http.Request, name string) {
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	for _, cookie := range cookies {
+		if cookie.Name != name {
+			r.AddCookie(cookie)
+		}
+	}
+}
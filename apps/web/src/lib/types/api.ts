@@ -42,11 +42,261 @@ export interface Host {
 	user: string | null;
 	created: string;
 	updated: string;
+	vendor?: string; // Organization of the MAC address prefix (OUI database)
+	mac_locally_administered?: boolean;
+	mac_randomized?: boolean; // Likely a private (randomized) address
+	last_ip?: string; // Address the host was last found at
+	last_seen?: string;
+	alerts?: number; // Number of open alerts
+}
+
+export interface HostIPRecord {
+	ip: string;
+	first_seen: string;
+	last_seen: string;
+	sightings: number;
+}
+
+export type HostAlertType = 'mac_mismatch' | 'ip_changed' | 'static_ip_suggestion';
+
+export interface HostAlert {
+	id: number;
+	host_id: string;
+	host_name?: string; // Only in GET /api/alerts
+	type: HostAlertType;
+	ip?: string;
+	previous_ip?: string;
+	expected_mac?: string;
+	detected_mac?: string;
+	detected_vendor?: string;
+	message: string;
+	count: number;
+	created: string;
+	last_seen: string;
+	dismissed: boolean;
+}
+
+export interface HostDependency {
+	depends_on: string;
+	depends_on_name?: string;
+	delay_seconds: number;
+	wait_online: boolean;
+	timeout_seconds?: number;
+}
+
+export interface WakeStepResult {
+	host_id: string;
+	host_name: string;
+	status: 'pending' | 'waiting' | 'sent' | 'already_online' | 'failed' | 'dependency_timeout' | 'skipped';
+	waited_seconds?: number;
+	error?: string;
+}
+
+export interface WakeChainRun {
+	id: string;
+	host_id: string;
+	host_name: string;
+	done: boolean;
+	success: boolean;
+	steps: WakeStepResult[];
+	started: string;
+	finished?: string;
+}
+
+export interface PowerAction {
+	ssh_address: string;
+	ssh_port: number;
+	ssh_user: string;
+	command: string;
+	private_key?: string; // write-only
+	has_private_key: boolean;
+	public_key?: string;
+	host_key_fingerprint: string;
+	updated: string;
+}
+
+export interface ShutdownResult {
+	success: boolean;
+	message: string;
+	output?: string;
+	disconnected?: boolean;
+	error?: string;
+}
+
+export interface AuditEntry {
+	id: number;
+	time: string;
+	user_id: string | null;
+	actor: string;
+	action: string;
+	host_id?: string;
+	host_name?: string;
+	success: boolean;
+	detail?: string;
+	remote_ip?: string;
+}
+
+export type SettingName =
+	| 'ping_timeout_seconds'
+	| 'ping_rate_limit_per_minute'
+	| 'wake_rate_limit_per_minute'
+	| 'shutdown_rate_limit_per_minute'
+	| 'auth_expire_hours'
+	| 'default_network_interface'
+	| 'readonly_mode';
+
+export interface Setting {
+	value: number | string | boolean;
+	source: 'env' | 'database' | 'config'; // config = config.json or the default
+	env: string;
+	locked: boolean; // Set by the environment variable, cannot be changed
+	updated?: string;
+	updated_by?: string;
+}
+
+// GET /api/settings and the response of PUT /api/settings
+export interface SettingsResponse {
+	settings: Record<SettingName, Setting>;
+}
+
+// PUT /api/settings body; null removes the stored value
+export type SettingsUpdate = Partial<Record<SettingName, number | string | boolean | null>>;
+
+export interface RelayInfo {
+	name: string;
+	online: boolean;
+	last_seen: string;
+	version: string;
+	interfaces: string[];
+}
+
+export interface HostRelay {
+	relay: string; // empty = local network
+	online: boolean;
+}
+
+export interface AgentState {
+	online: boolean;
+	last_seen: string;
+	hostname: string;
+	os: string;
+	version: string;
+	uptime_seconds: number;
+	load: [number, number, number];
+	pending_command?: AgentCommand;
+}
+
+export interface AgentCommand {
+	id: string;
+	action: 'sleep' | 'shutdown' | 'reboot';
+	queued: string;
+}
+
+export interface WebProxyStatus {
+	name: string;
+	host_id: string;
+	ready: boolean;
+	waking: boolean;
+	error?: string;
+}
+
+export interface ProxyHostActivity {
+	host_id: string;
+	host_name: string;
+	active_connections: number;
+	total_connections: number;
+	last_activity: string | null;
+	idle_seconds: number;
+}
+
+export interface DiscoveryRequest {
+	interfaces?: string;
+	cidrs?: string[];
+	timeout_seconds?: number;
+	ipv6?: boolean;
+	resolve_names?: boolean;
+}
+
+export interface DiscoveredDevice {
+	ip: string;
+	mac: string;
+	interface: string;
+	broadcast?: string; // Suggested broadcast address for a new host
+	name?: string; // Suggested host name
+	dns_name?: string;
+	mdns_name?: string;
+	netbios_name?: string;
+	vendor?: string;
+	mac_locally_administered?: boolean;
+	mac_randomized?: boolean;
+	known: boolean;
+	host_ids?: string[];
+}
+
+export interface DiscoveryResult {
+	devices: DiscoveredDevice[];
+	scanned: string[];
+	skipped?: string[];
+	timed_out: boolean;
+	duration_ms: number;
+}
+
+export type DHCPImportFormat = 'auto' | 'dnsmasq' | 'isc' | 'kea-leases' | 'kea-config';
+
+export interface DHCPImportRequest {
+	content: string; // Lease or reservation file content
+	format?: DHCPImportFormat;
+	update_only?: boolean; // Only update hosts that already exist
+	dry_run?: boolean;
+}
+
+export interface DHCPImportHost {
+	action: 'created' | 'updated' | 'unchanged' | 'skipped';
+	host_id?: string;
+	name: string;
+	mac: string;
+	static_ip?: string;
+	broadcast?: string; // Suggested broadcast (created hosts)
+	reason?: string; // Why the device was skipped
+}
+
+export interface DHCPImportResult {
+	format: Exclude<DHCPImportFormat, 'auto'>;
+	created: number;
+	updated: number;
+	unchanged: number;
+	skipped: number;
+	dry_run?: boolean;
+	hosts: DHCPImportHost[];
+}
+
+export interface HostService {
+	name: string;
+	type: 'tcp' | 'http' | 'https';
+	port: number;
+	path?: string; // HTTP(S) request path (default "/")
+	expect_status?: number; // 0/absent = any status below 400
+	expect_body?: string;
+	tls_skip_verify?: boolean;
+	timeout_seconds?: number;
+}
+
+export interface ServiceResult {
+	name: string;
+	up: boolean;
+	latency_ms?: number;
+	status?: number; // HTTP status code
+	error?: string;
 }
 
 export interface PingResult {
 	ping_success: boolean;
 	arp_success: boolean;
+	rtt_ms?: number; // ICMP round-trip time, absent if the host did not answer ICMP
+	services?: ServiceResult[]; // Absent if the host has no service checks or is offline
+	services_ready?: boolean; // All services up
+	agent_online?: boolean;
+	agent?: AgentState;
 	rate_limited?: boolean;
 	server_unreachable?: boolean;
 }
@@ -56,6 +306,11 @@ export interface BulkPingResult {
 	host_name: string;
 	ping_success: boolean;
 	arp_success: boolean;
+	rtt_ms?: number;
+	services?: ServiceResult[];
+	services_ready?: boolean;
+	agent_online?: boolean;
+	agent?: AgentState;
 	server_unreachable?: boolean;
 }
 
@@ -63,4 +318,6 @@ export interface APIError {
 	error: string;
 	message?: string;
 	status?: string;
+	request_id?: string; // Echoes the X-Request-ID response header
+	trace_id?: string; // Set when server-side tracing is enabled
 }
@@ -11,23 +11,24 @@ export interface ValidationResult {
 }
 
 /**
- * Validates a broadcast address in IP:PORT format
- * @param broadcast - The broadcast address to validate (e.g., "255.255.255.255:9")
+ * Validates a broadcast address in IP:PORT or [IPv6]:PORT format
+ * @param broadcast - The broadcast address to validate (e.g., "255.255.255.255:9" or "[ff02::1]:9")
  * @returns Validation result with error message if invalid
  */
 export function validateBroadcastAddress(broadcast: string): ValidationResult {
-	const parts = broadcast.split(':');
+	const ipv6Match = broadcast.match(/^\[([^\]]+)\]:(\d+)$/);
+	const parts = ipv6Match ? [ipv6Match[1], ipv6Match[2]] : broadcast.split(':');
 	if (parts.length !== 2) {
 		return {
 			valid: false,
-			error: 'Invalid format. Use IP:PORT format like 255.255.255.255:9'
+			error: 'Invalid format. Use IP:PORT format like 255.255.255.255:9 or [ff02::1]:9'
 		};
 	}
 
 	const [ip, portStr] = parts;
 	const port = parseInt(portStr);
 
-	if (!IP_REGEX.test(ip)) {
+	if (ipv6Match ? !validateIPv6(ip) : !IP_REGEX.test(ip)) {
 		return { valid: false, error: 'Invalid IP address format' };
 	}
 
@@ -69,6 +70,35 @@ export function validateIPv4(ip: string, allowEmpty: boolean = true): boolean {
 	return true;
 }
 
+/**
+ * Validates an IPv6 address (without zone)
+ * @param ip - The IP address to validate
+ * @returns True if valid IPv6 address
+ */
+export function validateIPv6(ip: string): boolean {
+	if (!ip.includes(':') || ip.includes('%')) return false;
+	try {
+		// The URL parser normalizes valid IPv6 literals and rejects invalid ones
+		return new URL(`http://[${ip}]/`).hostname.length > 2;
+	} catch {
+		return false;
+	}
+}
+
+/**
+ * Validates a host static IP: one IPv4 and/or one IPv6 address, comma-separated
+ * @param value - The static IP field to validate
+ * @returns True if valid or empty
+ */
+export function validateStaticIP(value: string): boolean {
+	if (!value) return true;
+
+	const ips = value.split(',').map((ip) => ip.trim());
+	const ipv4 = ips.filter((ip) => validateIPv4(ip, false));
+	const ipv6 = ips.filter((ip) => validateIPv6(ip));
+	return ipv4.length + ipv6.length === ips.length && ipv4.length <= 1 && ipv6.length <= 1;
+}
+
 /**
  * Validates a host name to ensure it only contains allowed characters.
  * Returns error codes that match localization keys.